@@ -0,0 +1,112 @@
+// Package calendario escribe eventos en formato iCalendar (RFC 5545) para
+// que los alumnos y profesores se suscriban desde su aplicacion de
+// calendario. No depende de la base de datos; los handlers arman los
+// eventos.
+package calendario
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// largoLinea es el maximo de octetos por linea antes de plegarla
+const largoLinea = 75
+
+const formatoFecha = "20060102T150405Z"
+
+// Evento es un VEVENT. UID debe ser estable para que el cliente reemplace
+// el evento cuando cambia en lugar de duplicarlo. Sin Fin el evento es un
+// instante, como el vencimiento de un trabajo.
+type Evento struct {
+	UID         string
+	Resumen     string
+	Descripcion string
+	Inicio      time.Time
+	Fin         time.Time
+	Modificado  time.Time
+}
+
+// Escribir genera el calendario 'nombre' con los eventos. 'generado' es la
+// marca DTSTAMP de todos los eventos.
+func Escribir(w io.Writer, nombre string, eventos []Evento, generado time.Time) error {
+	bw := bufio.NewWriter(w)
+	c := &escritor{w: bw}
+
+	c.linea("BEGIN:VCALENDAR")
+	c.linea("VERSION:2.0")
+	c.linea("PRODID:-//vaula//calendario//ES")
+	c.linea("CALSCALE:GREGORIAN")
+	c.linea("METHOD:PUBLISH")
+	c.linea("X-WR-CALNAME:" + escapar(nombre))
+	for _, e := range eventos {
+		c.linea("BEGIN:VEVENT")
+		c.linea("UID:" + e.UID)
+		c.linea("DTSTAMP:" + fecha(generado))
+		c.linea("DTSTART:" + fecha(e.Inicio))
+		if !e.Fin.IsZero() {
+			c.linea("DTEND:" + fecha(e.Fin))
+		}
+		if !e.Modificado.IsZero() {
+			c.linea("LAST-MODIFIED:" + fecha(e.Modificado))
+		}
+		c.linea("SUMMARY:" + escapar(e.Resumen))
+		if e.Descripcion != "" {
+			c.linea("DESCRIPTION:" + escapar(e.Descripcion))
+		}
+		c.linea("END:VEVENT")
+	}
+	c.linea("END:VCALENDAR")
+
+	if c.err != nil {
+		return c.err
+	}
+	return bw.Flush()
+}
+
+type escritor struct {
+	w   *bufio.Writer
+	err error
+}
+
+// linea escribe una linea terminada en CRLF, plegada en lineas de hasta
+// largoLinea octetos sin partir caracteres UTF-8
+func (c *escritor) linea(s string) {
+	if c.err != nil {
+		return
+	}
+	limite := largoLinea
+	for len(s) > limite {
+		corte := limite
+		for corte > 0 && !utf8.RuneStart(s[corte]) {
+			corte--
+		}
+		if _, c.err = c.w.WriteString(s[:corte] + "\r\n "); c.err != nil {
+			return
+		}
+		s = s[corte:]
+		// el espacio inicial de la continuacion cuenta en el largo
+		limite = largoLinea - 1
+	}
+	_, c.err = c.w.WriteString(s + "\r\n")
+}
+
+func fecha(t time.Time) string {
+	return t.UTC().Format(formatoFecha)
+}
+
+var escapes = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// escapar aplica el escape de los valores TEXT
+func escapar(s string) string {
+	return escapes.Replace(s)
+}
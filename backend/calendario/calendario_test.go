@@ -0,0 +1,73 @@
+package calendario
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEscribir(t *testing.T) {
+	lima := time.FixedZone("Lima", -5*60*60)
+	eventos := []Evento{
+		{
+			UID:        "examen-1@vaula",
+			Resumen:    "Parcial; Algoritmos, grupo A",
+			Inicio:     time.Date(2022, 6, 20, 8, 0, 0, 0, lima),
+			Fin:        time.Date(2022, 6, 20, 10, 0, 0, 0, lima),
+			Modificado: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			UID:         "trabajo-2@vaula",
+			Resumen:     "Informe",
+			Descripcion: "Linea 1\nLinea 2",
+			Inicio:      time.Date(2022, 6, 22, 23, 59, 0, 0, time.UTC),
+		},
+	}
+	var b bytes.Buffer
+	generado := time.Date(2022, 6, 2, 0, 0, 0, 0, time.UTC)
+	if err := Escribir(&b, "Vaula", eventos, generado); err != nil {
+		t.Fatalf("Error inesperado %s", err)
+	}
+	ics := b.String()
+
+	for _, esperado := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"UID:examen-1@vaula\r\nDTSTAMP:20220602T000000Z\r\n",
+		"DTSTART:20220620T130000Z\r\nDTEND:20220620T150000Z\r\n",
+		"LAST-MODIFIED:20220601T120000Z\r\n",
+		`SUMMARY:Parcial\; Algoritmos\, grupo A` + "\r\n",
+		"DTSTART:20220622T235900Z\r\nSUMMARY:Informe\r\n",
+		`DESCRIPTION:Linea 1\nLinea 2` + "\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, esperado) {
+			t.Errorf("Se esperaba %q en el calendario. Se obtuvo %q", esperado, ics)
+		}
+	}
+	if strings.Count(ics, "BEGIN:VEVENT") != 2 || strings.Count(ics, "DTEND") != 1 {
+		t.Errorf("Se esperaban 2 eventos y solo el examen con fin. Se obtuvo %q", ics)
+	}
+}
+
+func TestPlegarLineas(t *testing.T) {
+	resumen := strings.Repeat("á", 100)
+	var b bytes.Buffer
+	Escribir(&b, "Vaula", []Evento{{UID: "x@vaula", Resumen: resumen, Inicio: time.Now()}}, time.Now())
+
+	lineas := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	desplegado := ""
+	for _, l := range lineas {
+		if len(l) > largoLinea {
+			t.Errorf("Linea de %d octetos: %q", len(l), l)
+		}
+		if strings.HasPrefix(l, " ") {
+			desplegado += l[1:]
+		} else {
+			desplegado += "\n" + l
+		}
+	}
+	if !strings.Contains(desplegado, "\nSUMMARY:"+resumen+"\n") {
+		t.Errorf("El resumen no se recupera al desplegar las lineas: %q", desplegado)
+	}
+}
@@ -0,0 +1,267 @@
+// Package consulta arma la parte variable de los SELECT de listados a
+// partir de la query string: filtros por campo, orden y paginacion. Cada
+// listado declara en una Definicion que campos acepta, asi las columnas
+// que llegan al SQL siempre salen de esa lista y nunca de la request.
+package consulta
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PorPaginaDefecto = 50
+	PorPaginaMax     = 200
+)
+
+// parametros reservados, el resto de la query string se interpreta como
+// filtros
+const (
+	ParamPagina    = "pagina"
+	ParamPorPagina = "porPagina"
+	ParamOrden     = "orden"
+)
+
+// tipos de campo
+const (
+	Entero   = "entero"
+	Decimal  = "decimal"
+	Booleano = "booleano"
+	Texto    = "texto"
+	Fecha    = "fecha" // RFC 3339
+)
+
+// Campo es un campo del listado. Por defecto no se puede filtrar ni
+// ordenar por el.
+type Campo struct {
+	Columna string
+	Tipo    string
+	Filtro  bool
+	Parcial bool // el filtro de texto busca el valor dentro del campo
+	Orden   bool
+}
+
+// Definicion son los campos que acepta un listado, por su nombre en la
+// API. Desempate es la columna unica con la que se completa el orden para
+// que las paginas no se solapen; por defecto "id".
+type Definicion struct {
+	Campos    map[string]Campo
+	Desempate string
+}
+
+// ErrParametro indica un parametro de la query string que no se puede usar
+type ErrParametro struct {
+	Parametro string
+	Motivo    string
+}
+
+func (e *ErrParametro) Error() string {
+	return fmt.Sprintf("Parametro '%s' invalido: %s", e.Parametro, e.Motivo)
+}
+
+type condicion struct {
+	columna string
+	parcial bool
+	valor   interface{}
+}
+
+type criterio struct {
+	columna string
+	desc    bool
+}
+
+// Consulta es un listado ya validado. El valor cero pide la primera pagina
+// con PorPaginaDefecto elementos, sin filtros y ordenada por id.
+type Consulta struct {
+	Pagina    int
+	PorPagina int
+
+	condiciones []condicion
+	orden       []criterio
+	desempate   string
+}
+
+// Parse valida la query string contra la definicion. Los parametros que
+// no son campos filtrables se ignoran, porque algunas rutas aceptan otros
+// parametros propios.
+func (d Definicion) Parse(q url.Values) (Consulta, error) {
+	c := Consulta{Pagina: 1, PorPagina: PorPaginaDefecto, desempate: d.Desempate}
+
+	if v := q.Get(ParamPagina); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return c, &ErrParametro{ParamPagina, "debe ser un entero mayor a 0"}
+		}
+		c.Pagina = n
+	}
+	if v := q.Get(ParamPorPagina); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return c, &ErrParametro{ParamPorPagina, "debe ser un entero mayor a 0"}
+		}
+		if n > PorPaginaMax {
+			n = PorPaginaMax
+		}
+		c.PorPagina = n
+	}
+
+	if v := q.Get(ParamOrden); v != "" {
+		for _, nombre := range strings.Split(v, ",") {
+			desc := strings.HasPrefix(nombre, "-")
+			nombre = strings.TrimPrefix(nombre, "-")
+			campo, ok := d.Campos[nombre]
+			if !ok || !campo.Orden {
+				return c, &ErrParametro{ParamOrden, fmt.Sprintf("no se puede ordenar por '%s'", nombre)}
+			}
+			c.orden = append(c.orden, criterio{columna: campo.Columna, desc: desc})
+		}
+	}
+
+	// orden fijo para que la consulta sea la misma con los mismos parametros
+	nombres := make([]string, 0, len(q))
+	for nombre := range q {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	for _, nombre := range nombres {
+		campo, ok := d.Campos[nombre]
+		if !ok || !campo.Filtro {
+			continue
+		}
+		valor, err := convertir(campo.Tipo, q.Get(nombre))
+		if err != nil {
+			return c, &ErrParametro{nombre, err.Error()}
+		}
+		c.condiciones = append(c.condiciones, condicion{
+			columna: campo.Columna,
+			parcial: campo.Parcial && campo.Tipo == Texto,
+			valor:   valor,
+		})
+	}
+	return c, nil
+}
+
+func convertir(tipo, v string) (interface{}, error) {
+	switch tipo {
+	case Entero:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("se esperaba un entero")
+		}
+		return n, nil
+	case Decimal:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("se esperaba un numero")
+		}
+		return f, nil
+	case Booleano:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("se esperaba true o false")
+		}
+		return b, nil
+	case Fecha:
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("se esperaba una fecha RFC 3339")
+		}
+		return t, nil
+	default:
+		return v, nil
+	}
+}
+
+// Filtro devuelve el WHERE, vacio si no hay filtros, y sus argumentos. Los
+// argumentos se numeran desde $1.
+func (c Consulta) Filtro() (string, []interface{}) {
+	if len(c.condiciones) == 0 {
+		return "", nil
+	}
+	partes := make([]string, 0, len(c.condiciones))
+	args := make([]interface{}, 0, len(c.condiciones))
+	for i, cond := range c.condiciones {
+		if cond.parcial {
+			partes = append(partes, fmt.Sprintf("%s ILIKE '%%' || $%d || '%%'", cond.columna, i+1))
+			args = append(args, escaparLike(cond.valor.(string)))
+			continue
+		}
+		partes = append(partes, fmt.Sprintf("%s = $%d", cond.columna, i+1))
+		args = append(args, cond.valor)
+	}
+	return " WHERE " + strings.Join(partes, " AND "), args
+}
+
+// escaparLike evita que % y _ del usuario actuen como comodines
+func escaparLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// OrdenYPagina devuelve ORDER BY, LIMIT y OFFSET
+func (c Consulta) OrdenYPagina() string {
+	desempate := c.desempate
+	if desempate == "" {
+		desempate = "id"
+	}
+	porPagina := c.PorPagina
+	if porPagina < 1 {
+		porPagina = PorPaginaDefecto
+	}
+	pagina := c.Pagina
+	if pagina < 1 {
+		pagina = 1
+	}
+
+	partes := []string{}
+	for _, o := range c.orden {
+		if o.desc {
+			partes = append(partes, o.columna+" DESC")
+		} else {
+			partes = append(partes, o.columna)
+		}
+	}
+	partes = append(partes, desempate)
+	return fmt.Sprintf(" ORDER BY %s LIMIT %d OFFSET %d",
+		strings.Join(partes, ", "), porPagina, (pagina-1)*porPagina)
+}
+
+// Paginas calcula cuantas paginas hay con 'total' elementos
+func (c Consulta) Paginas(total int) int {
+	porPagina := c.PorPagina
+	if porPagina < 1 {
+		porPagina = PorPaginaDefecto
+	}
+	if total == 0 {
+		return 1
+	}
+	return (total + porPagina - 1) / porPagina
+}
+
+// Enlaces arma la cabecera Link (RFC 8288) con las paginas first, prev,
+// next y last, conservando el resto de la query string de 'u'
+func (c Consulta) Enlaces(u *url.URL, total int) string {
+	ultima := c.Paginas(total)
+	enlace := func(pagina int, rel string) string {
+		q := u.Query()
+		q.Set(ParamPagina, strconv.Itoa(pagina))
+		if c.PorPagina > 0 {
+			q.Set(ParamPorPagina, strconv.Itoa(c.PorPagina))
+		}
+		destino := url.URL{Path: u.Path, RawQuery: q.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, destino.String(), rel)
+	}
+
+	enlaces := []string{enlace(1, "first")}
+	if c.Pagina > 1 {
+		enlaces = append(enlaces, enlace(c.Pagina-1, "prev"))
+	}
+	if c.Pagina < ultima {
+		enlaces = append(enlaces, enlace(c.Pagina+1, "next"))
+	}
+	enlaces = append(enlaces, enlace(ultima, "last"))
+	return strings.Join(enlaces, ", ")
+}
@@ -0,0 +1,106 @@
+package consulta
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var definicionPrueba = Definicion{
+	Campos: map[string]Campo{
+		"nombre":      {Columna: "nombre", Tipo: Texto, Filtro: true, Parcial: true, Orden: true},
+		"cursoId":     {Columna: "cursoId", Tipo: Entero, Filtro: true},
+		"activo":      {Columna: "activo", Tipo: Booleano, Filtro: true},
+		"fechaInicio": {Columna: "fechaInicio", Tipo: Fecha, Orden: true},
+	},
+}
+
+func parse(t *testing.T, query string) Consulta {
+	q, _ := url.ParseQuery(query)
+	c, err := definicionPrueba.Parse(q)
+	if err != nil {
+		t.Fatalf("Error inesperado con %q: %s", query, err)
+	}
+	return c
+}
+
+func TestFiltroYOrden(t *testing.T) {
+	c := parse(t, "cursoId=3&activo=true&orden=-fechaInicio,nombre&format=gift")
+
+	where, args := c.Filtro()
+	if where != " WHERE activo = $1 AND cursoId = $2" {
+		t.Errorf("WHERE inesperado %q", where)
+	}
+	if !reflect.DeepEqual(args, []interface{}{true, 3}) {
+		t.Errorf("Argumentos inesperados %v", args)
+	}
+	if orden := c.OrdenYPagina(); orden != " ORDER BY fechaInicio DESC, nombre, id LIMIT 50 OFFSET 0" {
+		t.Errorf("ORDER BY inesperado %q", orden)
+	}
+}
+
+func TestFiltroParcial(t *testing.T) {
+	c := parse(t, "nombre=100%25_final")
+
+	where, args := c.Filtro()
+	if where != " WHERE nombre ILIKE '%' || $1 || '%'" {
+		t.Errorf("WHERE inesperado %q", where)
+	}
+	if args[0] != `100\%\_final` {
+		t.Errorf("Se esperaban los comodines escapados. Se obtuvo %v", args[0])
+	}
+}
+
+func TestPaginacion(t *testing.T) {
+	c := parse(t, "pagina=3&porPagina=1000")
+	if c.PorPagina != PorPaginaMax {
+		t.Errorf("Se esperaba el maximo %d por pagina. Se obtuvo %d", PorPaginaMax, c.PorPagina)
+	}
+	if orden := c.OrdenYPagina(); !strings.HasSuffix(orden, "LIMIT 200 OFFSET 400") {
+		t.Errorf("LIMIT inesperado %q", orden)
+	}
+
+	var cero Consulta
+	if orden := cero.OrdenYPagina(); orden != " ORDER BY id LIMIT 50 OFFSET 0" {
+		t.Errorf("La consulta vacia deberia pedir la primera pagina. Se obtuvo %q", orden)
+	}
+}
+
+func TestParametrosInvalidos(t *testing.T) {
+	for _, query := range []string{
+		"pagina=0",
+		"porPagina=x",
+		"orden=password",
+		"orden=cursoId", // filtrable pero no ordenable
+		"cursoId=uno",
+		"activo=quizas",
+	} {
+		q, _ := url.ParseQuery(query)
+		_, err := definicionPrueba.Parse(q)
+		if _, ok := err.(*ErrParametro); !ok {
+			t.Errorf("Se esperaba ErrParametro con %q. Se obtuvo %v", query, err)
+		}
+	}
+}
+
+func TestEnlaces(t *testing.T) {
+	c := parse(t, "pagina=2&porPagina=10")
+	u, _ := url.Parse("/examenes?cursoId=1&pagina=2&porPagina=10")
+
+	enlaces := c.Enlaces(u, 35)
+	for _, esperado := range []string{
+		`</examenes?cursoId=1&pagina=1&porPagina=10>; rel="first"`,
+		`</examenes?cursoId=1&pagina=1&porPagina=10>; rel="prev"`,
+		`</examenes?cursoId=1&pagina=3&porPagina=10>; rel="next"`,
+		`</examenes?cursoId=1&pagina=4&porPagina=10>; rel="last"`,
+	} {
+		if !strings.Contains(enlaces, esperado) {
+			t.Errorf("Se esperaba %s en %s", esperado, enlaces)
+		}
+	}
+
+	if enlaces := c.Enlaces(u, 0); strings.Contains(enlaces, `rel="next"`) {
+		t.Errorf("Sin elementos no hay pagina siguiente. Se obtuvo %s", enlaces)
+	}
+}
@@ -0,0 +1,90 @@
+// Package correo envia los correos de las notificaciones detras de una
+// interfaz, para poder cambiar entre un servidor SMTP real y un buzon en
+// memoria (pruebas) o el log (desarrollo) sin tocar los handlers.
+package correo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Mensaje es un correo de texto plano para un solo destinatario
+type Mensaje struct {
+	Para   string
+	Asunto string
+	Cuerpo string
+}
+
+type Mailer interface {
+	// Enviar entrega el mensaje al servidor de correo. Un error indica que
+	// el envio se puede reintentar mas tarde.
+	Enviar(ctx context.Context, m Mensaje) error
+}
+
+// FromEnv construye el Mailer configurado por las variables de entorno.
+// MAIL_DRIVER puede ser 'log' (por defecto) o 'smtp'.
+func FromEnv() (Mailer, error) {
+	switch strings.ToLower(os.Getenv("MAIL_DRIVER")) {
+	case "", "log":
+		return Registro{}, nil
+	case "smtp":
+		return NewSMTP(SMTPConfig{
+			Host:      os.Getenv("SMTP_HOST"),
+			Port:      os.Getenv("SMTP_PORT"),
+			Usuario:   os.Getenv("SMTP_USER"),
+			Clave:     os.Getenv("SMTP_PASSWORD"),
+			Remitente: os.Getenv("MAIL_FROM"),
+		})
+	default:
+		return nil, errors.New("correo: MAIL_DRIVER desconocido")
+	}
+}
+
+// Registro solo escribe en el log los correos que recibe
+type Registro struct{}
+
+func (Registro) Enviar(ctx context.Context, m Mensaje) error {
+	log.Printf("CORREO para %s: %s", m.Para, m.Asunto)
+	return nil
+}
+
+// Memoria guarda los correos enviados en lugar de enviarlos. Fallar hace
+// que los siguientes envios devuelvan error, para probar los reintentos.
+type Memoria struct {
+	mu       sync.Mutex
+	enviados []Mensaje
+	Fallar   int
+}
+
+// ErrSimulado es el error que devuelve Memoria mientras Fallar > 0
+var ErrSimulado = errors.New("correo: fallo simulado")
+
+func (b *Memoria) Enviar(ctx context.Context, m Mensaje) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Fallar > 0 {
+		b.Fallar--
+		return ErrSimulado
+	}
+	b.enviados = append(b.enviados, m)
+	return nil
+}
+
+// Enviados devuelve una copia de los correos recibidos hasta ahora
+func (b *Memoria) Enviados() []Mensaje {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Mensaje{}, b.enviados...)
+}
+
+// Vaciar descarta los correos recibidos
+func (b *Memoria) Vaciar() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.enviados = nil
+	b.Fallar = 0
+}
@@ -0,0 +1,59 @@
+package correo
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArmarMensaje(t *testing.T) {
+	m := Mensaje{Para: "alumno@vaula.pe", Asunto: "Calificación publicada", Cuerpo: "Linea 1\nLinea 2"}
+	fecha := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	crudo := string(armarMensaje("vaula@vaula.pe", m, fecha))
+
+	for _, esperado := range []string{
+		"From: vaula@vaula.pe\r\n",
+		"To: alumno@vaula.pe\r\n",
+		"Subject: =?utf-8?q?Calificaci=C3=B3n_publicada?=\r\n",
+		"Date: Mon, 01 Mar 2021 10:00:00 +0000\r\n",
+		"\r\n\r\nLinea 1\r\nLinea 2",
+	} {
+		if !strings.Contains(crudo, esperado) {
+			t.Errorf("Se esperaba %q en el mensaje. Se obtuvo %q", esperado, crudo)
+		}
+	}
+}
+
+func TestNewSMTPSinHost(t *testing.T) {
+	if _, err := NewSMTP(SMTPConfig{Remitente: "vaula@vaula.pe"}); err == nil {
+		t.Errorf("Se esperaba un error sin SMTP_HOST")
+	}
+	s, err := NewSMTP(SMTPConfig{Host: "smtp.vaula.pe", Remitente: "vaula@vaula.pe"})
+	if err != nil {
+		t.Fatalf("Error inesperado %s", err)
+	}
+	if s.cfg.Port != "587" {
+		t.Errorf("Se esperaba el puerto 587 por defecto. Se obtuvo %s", s.cfg.Port)
+	}
+}
+
+func TestMemoriaFallar(t *testing.T) {
+	b := &Memoria{Fallar: 1}
+	m := Mensaje{Para: "alumno@vaula.pe", Asunto: "Hola"}
+
+	if err := b.Enviar(context.Background(), m); err != ErrSimulado {
+		t.Errorf("Se esperaba ErrSimulado. Se obtuvo %v", err)
+	}
+	if err := b.Enviar(context.Background(), m); err != nil {
+		t.Errorf("Error inesperado %s", err)
+	}
+	if enviados := b.Enviados(); len(enviados) != 1 || enviados[0].Para != m.Para {
+		t.Errorf("Se esperaba un correo enviado. Se obtuvo %v", enviados)
+	}
+
+	b.Vaciar()
+	if len(b.Enviados()) != 0 {
+		t.Errorf("Se esperaba el buzon vacio")
+	}
+}
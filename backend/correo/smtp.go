@@ -0,0 +1,75 @@
+package correo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host      string
+	Port      string // 587 por defecto
+	Usuario   string // sin usuario no se autentica
+	Clave     string
+	Remitente string
+}
+
+// SMTP envia los correos por un servidor SMTP con STARTTLS si el servidor
+// lo ofrece
+type SMTP struct {
+	cfg SMTPConfig
+}
+
+func NewSMTP(cfg SMTPConfig) (*SMTP, error) {
+	if cfg.Host == "" || cfg.Remitente == "" {
+		return nil, errors.New("correo: faltan SMTP_HOST o MAIL_FROM")
+	}
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	return &SMTP{cfg: cfg}, nil
+}
+
+func (s *SMTP) Enviar(ctx context.Context, m Mensaje) error {
+	var auth smtp.Auth
+	if s.cfg.Usuario != "" {
+		auth = smtp.PlainAuth("", s.cfg.Usuario, s.cfg.Clave, s.cfg.Host)
+	}
+	addr := net.JoinHostPort(s.cfg.Host, s.cfg.Port)
+
+	// smtp.SendMail no recibe contexto, se respeta al menos su cancelacion
+	hecho := make(chan error, 1)
+	go func() {
+		hecho <- smtp.SendMail(addr, auth, s.cfg.Remitente, []string{m.Para},
+			armarMensaje(s.cfg.Remitente, m, time.Now()))
+	}()
+	select {
+	case err := <-hecho:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// armarMensaje escribe las cabeceras y el cuerpo en formato RFC 5322. El
+// asunto se codifica para admitir tildes.
+func armarMensaje(remitente string, m Mensaje, fecha time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", remitente)
+	fmt.Fprintf(&b, "To: %s\r\n", m.Para)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Asunto))
+	fmt.Fprintf(&b, "Date: %s\r\n", fecha.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	cuerpo := strings.ReplaceAll(m.Cuerpo, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(cuerpo, "\n", "\r\n"))
+	return b.Bytes()
+}
@@ -0,0 +1,67 @@
+package exportar
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type hojaCSV struct {
+	buf    *bufio.Writer
+	w      *csv.Writer
+	locale Locale
+	inicio bool
+}
+
+// NewCSV escribe CSV en UTF-8 con BOM, para que Excel reconozca las tildes
+func NewCSV(w io.Writer, locale Locale) Hoja {
+	buf := bufio.NewWriter(w)
+	cw := csv.NewWriter(buf)
+	cw.Comma = locale.Separador
+	return &hojaCSV{buf: buf, w: cw, locale: locale}
+}
+
+func (h *hojaCSV) Fila(celdas ...Celda) error {
+	if !h.inicio {
+		h.inicio = true
+		if _, err := h.buf.WriteString("\ufeff"); err != nil {
+			return err
+		}
+	}
+	campos := make([]string, len(celdas))
+	for i, c := range celdas {
+		if c.Numero != nil {
+			campos[i] = h.numero(*c.Numero)
+		} else {
+			campos[i] = texto(c.Texto)
+		}
+	}
+	return h.w.Write(campos)
+}
+
+// texto antepone un apostrofo a los textos que una hoja de calculo
+// interpretaria como formula, p. ej. un nombre "=HYPERLINK(...)"
+func texto(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func (h *hojaCSV) numero(f float64) string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if h.locale.Decimal != '.' {
+		s = strings.Replace(s, ".", string(h.locale.Decimal), 1)
+	}
+	return s
+}
+
+func (h *hojaCSV) Cerrar() error {
+	h.w.Flush()
+	if err := h.w.Error(); err != nil {
+		return err
+	}
+	return h.buf.Flush()
+}
@@ -0,0 +1,110 @@
+// Package exportar escribe tablas como hojas de calculo (CSV o XLSX) fila
+// por fila, sin armar el archivo completo en memoria, para poder enviarlas
+// directamente en la respuesta HTTP.
+package exportar
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrFormato se devuelve cuando el formato pedido no esta soportado
+var ErrFormato = errors.New("exportar: formato no soportado")
+
+const (
+	FormatoCSV  = "csv"
+	FormatoXLSX = "xlsx"
+)
+
+// Celda es un texto o un numero. Una celda sin texto ni numero queda vacia.
+type Celda struct {
+	Texto  string
+	Numero *float64
+}
+
+func Texto(s string) Celda {
+	return Celda{Texto: s}
+}
+
+func Numero(f float64) Celda {
+	return Celda{Numero: &f}
+}
+
+func Vacia() Celda {
+	return Celda{}
+}
+
+type Hoja interface {
+	Fila(celdas ...Celda) error
+	// Cerrar termina el archivo. Hasta entonces parte de la salida puede
+	// seguir en buffers.
+	Cerrar() error
+}
+
+// Nueva crea la hoja del formato pedido sobre 'w'
+func Nueva(formato string, w io.Writer, locale Locale) (Hoja, error) {
+	switch formato {
+	case FormatoCSV:
+		return NewCSV(w, locale), nil
+	case FormatoXLSX:
+		return NewXLSX(w, "Hoja1")
+	}
+	return nil, ErrFormato
+}
+
+func ContentType(formato string) string {
+	switch formato {
+	case FormatoCSV:
+		return "text/csv; charset=utf-8"
+	case FormatoXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+// Locale indica como escribir los numeros en formatos de texto. XLSX
+// guarda los numeros sin formato y es la hoja de calculo la que los
+// muestra segun la configuracion del usuario.
+type Locale struct {
+	Decimal   rune
+	Separador rune // separador de campos del CSV
+}
+
+var (
+	LocalePunto = Locale{Decimal: '.', Separador: ','}
+	// con coma decimal las hojas de calculo esperan ';' entre campos
+	LocaleComa = Locale{Decimal: ',', Separador: ';'}
+)
+
+// idiomas que usan coma decimal, salvo las regiones de regionesPunto
+var idiomasComa = map[string]bool{
+	"es": true, "pt": true, "fr": true, "de": true, "it": true,
+	"nl": true, "ru": true, "pl": true, "tr": true, "sv": true,
+	"da": true, "fi": true, "nb": true, "cs": true, "ca": true,
+}
+
+// paises de habla hispana que usan punto decimal
+var regionesPunto = map[string]bool{
+	"pe": true, "mx": true, "us": true, "gt": true, "do": true,
+	"hn": true, "ni": true, "pa": true, "sv": true, "pr": true,
+	"ch": true,
+}
+
+// ParseLocale interpreta una etiqueta como 'es-PE' o un encabezado
+// Accept-Language; solo se usa el primer idioma. Por defecto se usa punto
+// decimal.
+func ParseLocale(s string) Locale {
+	tag := strings.TrimSpace(strings.SplitN(s, ",", 2)[0])
+	tag = strings.TrimSpace(strings.SplitN(tag, ";", 2)[0])
+	partes := strings.FieldsFunc(strings.ToLower(tag), func(r rune) bool {
+		return r == '-' || r == '_'
+	})
+	if len(partes) == 0 || !idiomasComa[partes[0]] {
+		return LocalePunto
+	}
+	if len(partes) > 1 && regionesPunto[partes[len(partes)-1]] {
+		return LocalePunto
+	}
+	return LocaleComa
+}
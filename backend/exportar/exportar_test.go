@@ -0,0 +1,99 @@
+package exportar
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestParseLocale(t *testing.T) {
+	casos := map[string]Locale{
+		"":                      LocalePunto,
+		"en-US":                 LocalePunto,
+		"es-PE":                 LocalePunto,
+		"es-ES,es;q=0.9":        LocaleComa,
+		"es":                    LocaleComa,
+		"pt_BR":                 LocaleComa,
+		"de-CH":                 LocalePunto,
+		"fr-FR;q=0.8, en;q=0.5": LocaleComa,
+	}
+	for s, esperado := range casos {
+		if l := ParseLocale(s); l != esperado {
+			t.Errorf("ParseLocale(%q): se esperaba %v. Se obtuvo %v", s, esperado, l)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewCSV(&buf, LocaleComa)
+	h.Fila(Texto("codigo"), Texto("nombres"), Texto("final"))
+	h.Fila(Texto("20201234"), Texto("Ana; Maria"), Numero(13.75))
+	h.Fila(Texto("20205678"), Texto("Jose"), Vacia())
+	if err := h.Cerrar(); err != nil {
+		t.Fatalf("No se cerro la hoja %s", err)
+	}
+
+	esperado := "\ufeffcodigo;nombres;final\n20201234;\"Ana; Maria\";13,75\n20205678;Jose;\n"
+	if buf.String() != esperado {
+		t.Errorf("Se esperaba %q. Se obtuvo %q", esperado, buf.String())
+	}
+}
+
+func TestCSVFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewCSV(&buf, LocalePunto)
+	h.Fila(Texto("=1+1"), Texto("+51 999"), Texto("-2"), Texto("@SUM(A1)"), Texto("a=b"), Numero(-2.5))
+	if err := h.Cerrar(); err != nil {
+		t.Fatalf("No se cerro la hoja %s", err)
+	}
+
+	// los numeros negativos no se alteran, solo las celdas de texto
+	esperado := "\ufeff'=1+1,'+51 999,'-2,'@SUM(A1),a=b,-2.5\n"
+	if buf.String() != esperado {
+		t.Errorf("Se esperaba %q. Se obtuvo %q", esperado, buf.String())
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := NewXLSX(&buf, "Notas")
+	if err != nil {
+		t.Fatalf("No se creo la hoja %s", err)
+	}
+	h.Fila(Texto("apellidos"), Texto("final"))
+	h.Fila(Texto("Perez & Diaz"), Numero(15.5))
+	if err := h.Cerrar(); err != nil {
+		t.Fatalf("No se cerro la hoja %s", err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("El XLSX no es un zip valido %s", err)
+	}
+	var sheet string
+	for _, f := range z.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			b, _ := ioutil.ReadAll(r)
+			sheet = string(b)
+		}
+	}
+	if !strings.Contains(sheet, `<c r="A2" t="inlineStr"><is><t xml:space="preserve">Perez &amp; Diaz</t></is></c>`) {
+		t.Errorf("Se esperaba el texto escapado en A2. Se obtuvo %s", sheet)
+	}
+	if !strings.Contains(sheet, `<c r="B2"><v>15.5</v></c>`) {
+		t.Errorf("Se esperaba el numero en B2. Se obtuvo %s", sheet)
+	}
+}
+
+func TestColumnaXLSX(t *testing.T) {
+	casos := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"}
+	for i, esperado := range casos {
+		if c := columnaXLSX(i); c != esperado {
+			t.Errorf("columnaXLSX(%d): se esperaba %s. Se obtuvo %s", i, esperado, c)
+		}
+	}
+}
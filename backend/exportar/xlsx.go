@@ -0,0 +1,118 @@
+package exportar
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Partes fijas del paquete SpreadsheetML con una sola hoja. Los textos se
+// guardan como inlineStr para no tener que juntar una tabla de strings
+// compartidos antes de escribir las filas.
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+	xlsxSheetInicio = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetFin = `</sheetData></worksheet>`
+)
+
+type hojaXLSX struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	fila  int
+}
+
+// NewXLSX escribe un libro con una hoja. Las partes fijas se escriben al
+// inicio y la hoja queda abierta como ultima entrada del zip, asi las filas
+// se comprimen y envian a medida que llegan.
+func NewXLSX(w io.Writer, nombreHoja string) (Hoja, error) {
+	z := zip.NewWriter(w)
+	var nombre strings.Builder
+	xml.EscapeText(&nombre, []byte(nombreHoja))
+
+	partes := []struct{ ruta, contenido string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, nombre.String())},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, p := range partes {
+		f, err := z.Create(p.ruta)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, p.contenido); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	sheet := bufio.NewWriter(f)
+	if _, err := sheet.WriteString(xlsxSheetInicio); err != nil {
+		return nil, err
+	}
+	return &hojaXLSX{zip: z, sheet: sheet}, nil
+}
+
+func (h *hojaXLSX) Fila(celdas ...Celda) error {
+	h.fila++
+	fmt.Fprintf(h.sheet, `<row r="%d">`, h.fila)
+	for i, c := range celdas {
+		ref := columnaXLSX(i) + strconv.Itoa(h.fila)
+		switch {
+		case c.Numero != nil:
+			fmt.Fprintf(h.sheet, `<c r="%s"><v>%s</v></c>`, ref,
+				strconv.FormatFloat(*c.Numero, 'f', -1, 64))
+		case c.Texto != "":
+			fmt.Fprintf(h.sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			xml.EscapeText(h.sheet, []byte(c.Texto))
+			h.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := h.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (h *hojaXLSX) Cerrar() error {
+	if _, err := h.sheet.WriteString(xlsxSheetFin); err != nil {
+		return err
+	}
+	if err := h.sheet.Flush(); err != nil {
+		return err
+	}
+	return h.zip.Close()
+}
+
+// columnaXLSX convierte un indice desde 0 en la letra de columna: A, ..., Z, AA
+func columnaXLSX(i int) string {
+	s := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		s = string(rune('A'+(i-1)%26)) + s
+	}
+	return s
+}
@@ -0,0 +1,106 @@
+// Package formatos lee y escribe preguntas en los formatos de intercambio
+// de otras plataformas (Moodle XML, GIFT). Trabaja con sus propios tipos,
+// sin base de datos; los handlers los convierten a models.Pregunta.
+package formatos
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Tipos de pregunta soportados, con los nombres de Moodle
+const (
+	OpcionMultiple = "multichoice"
+	VerdaderoFalso = "truefalse"
+	RespuestaCorta = "shortanswer"
+	Numerica       = "numerical"
+	Ensayo         = "essay"
+)
+
+const (
+	Verdadero = "Verdadero"
+	Falso     = "Falso"
+)
+
+type Pregunta struct {
+	Nombre       string
+	Tipo         string
+	Enunciado    string
+	Puntaje      float64
+	Alternativas []Alternativa
+}
+
+// Alternativa es una respuesta posible. Se considera correcta si su
+// fraccion (el porcentaje del puntaje que otorga) es mayor que 0.
+type Alternativa struct {
+	Texto      string
+	Fraccion   float64
+	Tolerancia float64 // solo en las numericas
+}
+
+func (a Alternativa) Correcta() bool {
+	return a.Fraccion > 0
+}
+
+// Omitida es una pregunta del archivo que no se pudo importar. Indice
+// cuenta desde 1 en el orden del archivo.
+type Omitida struct {
+	Indice int    `json:"indice"`
+	Nombre string `json:"nombre"`
+	Motivo string `json:"motivo"`
+}
+
+// validar comprueba que la pregunta tenga lo necesario para su tipo.
+// Devuelve el motivo por el que se omite o "".
+func validar(p Pregunta) string {
+	if strings.TrimSpace(p.Enunciado) == "" {
+		return "la pregunta no tiene enunciado"
+	}
+	correctas := 0
+	for _, a := range p.Alternativas {
+		if a.Correcta() {
+			correctas++
+		}
+	}
+
+	switch p.Tipo {
+	case OpcionMultiple:
+		if len(p.Alternativas) < 2 {
+			return "una pregunta de opcion multiple necesita al menos 2 alternativas"
+		}
+		if correctas == 0 {
+			return "ninguna alternativa es correcta"
+		}
+	case VerdaderoFalso:
+		if len(p.Alternativas) != 2 || correctas != 1 {
+			return "una pregunta verdadero/falso necesita exactamente una respuesta correcta"
+		}
+	case RespuestaCorta, Numerica:
+		if correctas == 0 {
+			return "la pregunta no tiene respuestas correctas"
+		}
+	case Ensayo:
+	default:
+		return fmt.Sprintf("tipo de pregunta '%s' no soportado", p.Tipo)
+	}
+	return ""
+}
+
+var (
+	reSaltos   = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>`)
+	reEtiqueta = regexp.MustCompile(`<[^>]*>`)
+	reLineas   = regexp.MustCompile(`[ \t]*\n\s*`)
+)
+
+// textoPlano convierte el HTML de un enunciado en texto: quita las
+// etiquetas, deja un salto de linea por parrafo y decodifica las entidades
+func textoPlano(s string) string {
+	s = reSaltos.ReplaceAllString(s, "\n")
+	s = reEtiqueta.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = strings.Replace(s, "\u00a0", " ", -1)
+	s = reLineas.ReplaceAllString(s, "\n")
+	return strings.TrimSpace(s)
+}
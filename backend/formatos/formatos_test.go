@@ -0,0 +1,171 @@
+package formatos
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const moodleXML = `<?xml version="1.0" encoding="UTF-8"?>
+<quiz>
+  <question type="category">
+    <category><text>$course$/Historia</text></category>
+  </question>
+  <question type="multichoice">
+    <name><text>Capital</text></name>
+    <questiontext format="html"><text><![CDATA[<p>Capital del <b>Peru</b>&nbsp;?</p>]]></text></questiontext>
+    <defaultgrade>2.0000000</defaultgrade>
+    <answer fraction="100"><text>Lima</text></answer>
+    <answer fraction="0"><text>Cusco</text></answer>
+  </question>
+  <question type="truefalse">
+    <name><text>Agua</text></name>
+    <questiontext format="plain_text"><text>El agua hierve a 100 C</text></questiontext>
+    <answer fraction="100"><text>true</text></answer>
+    <answer fraction="0"><text>false</text></answer>
+  </question>
+  <question type="numerical">
+    <name><text>Pi</text></name>
+    <questiontext format="plain_text"><text>Valor de pi</text></questiontext>
+    <answer fraction="100"><text>3.14</text><tolerance>0.01</tolerance></answer>
+  </question>
+  <question type="matching">
+    <name><text>Parejas</text></name>
+    <questiontext format="plain_text"><text>Une</text></questiontext>
+  </question>
+  <question type="multichoice">
+    <name><text>Sin correcta</text></name>
+    <questiontext format="plain_text"><text>?</text></questiontext>
+    <answer fraction="0"><text>a</text></answer>
+    <answer fraction="0"><text>b</text></answer>
+  </question>
+</quiz>`
+
+func TestParseMoodleXML(t *testing.T) {
+	preguntas, omitidas, err := ParseMoodleXML(strings.NewReader(moodleXML))
+	if err != nil {
+		t.Fatalf("Error inesperado %s", err)
+	}
+	if len(preguntas) != 3 || len(omitidas) != 2 {
+		t.Fatalf("Se esperaban 3 preguntas y 2 omitidas. Se obtuvo %d y %d (%v)",
+			len(preguntas), len(omitidas), omitidas)
+	}
+
+	p := preguntas[0]
+	if p.Enunciado != "Capital del Peru ?" || p.Puntaje != 2 || !p.Alternativas[0].Correcta() {
+		t.Errorf("Se esperaba la pregunta de opcion multiple en texto plano. Se obtuvo %+v", p)
+	}
+	if preguntas[1].Alternativas[0].Texto != Verdadero {
+		t.Errorf("Se esperaba la alternativa 'Verdadero'. Se obtuvo %+v", preguntas[1].Alternativas)
+	}
+	if preguntas[2].Alternativas[0].Tolerancia != 0.01 {
+		t.Errorf("Se esperaba tolerancia 0.01. Se obtuvo %+v", preguntas[2].Alternativas)
+	}
+	if omitidas[0].Indice != 4 || omitidas[0].Nombre != "Parejas" {
+		t.Errorf("Se esperaba omitir la pregunta 4 'Parejas'. Se obtuvo %+v", omitidas[0])
+	}
+}
+
+const gift = `// preguntas de prueba
+$CATEGORY: $course$/Historia
+
+::Capital:: Capital del Peru? {=Lima ~Cusco#No ~%-50%Arequipa}
+
+::Agua:: El agua hierve a 100 C {T}
+
+::Pi:: Valor de pi {#3.14:0.01}
+
+::Rango:: Un numero entre 1 y 5 {#1..5}
+
+::Autor:: Quien escribio "Los rios profundos"? {=Arguedas =Jose Maria Arguedas}
+
+::Ensayo:: Describe la conquista {}
+
+Gandhi nacio el {~1 de enero =2 de octubre} de 1869
+
+::Escape:: 2 + 2 \= 4? {=si\~ ~no}
+
+::Parejas:: Une {=a -> 1 =b -> 2}
+
+Solo una descripcion
+`
+
+func TestParseGIFT(t *testing.T) {
+	preguntas, omitidas, err := ParseGIFT(strings.NewReader(gift))
+	if err != nil {
+		t.Fatalf("Error inesperado %s", err)
+	}
+	if len(preguntas) != 8 || len(omitidas) != 2 {
+		t.Fatalf("Se esperaban 8 preguntas y 2 omitidas. Se obtuvo %d y %d (%v)",
+			len(preguntas), len(omitidas), omitidas)
+	}
+
+	tipos := []string{OpcionMultiple, VerdaderoFalso, Numerica, Numerica,
+		RespuestaCorta, Ensayo, OpcionMultiple, OpcionMultiple}
+	for i, tipo := range tipos {
+		if preguntas[i].Tipo != tipo {
+			t.Errorf("Pregunta %d: se esperaba tipo %s. Se obtuvo %s", i+1, tipo, preguntas[i].Tipo)
+		}
+	}
+
+	mc := preguntas[0]
+	if len(mc.Alternativas) != 3 || mc.Alternativas[1].Texto != "Cusco" {
+		t.Errorf("Se esperaban 3 alternativas sin retroalimentacion. Se obtuvo %+v", mc.Alternativas)
+	}
+	if mc.Alternativas[2].Fraccion != -50 || mc.Alternativas[2].Texto != "Arequipa" {
+		t.Errorf("Se esperaba el peso -50 en Arequipa. Se obtuvo %+v", mc.Alternativas[2])
+	}
+	rango := preguntas[3].Alternativas[0]
+	if rango.Texto != "3" || rango.Tolerancia != 2 {
+		t.Errorf("Se esperaba 3 con tolerancia 2. Se obtuvo %+v", rango)
+	}
+	if preguntas[6].Enunciado != "Gandhi nacio el _____ de 1869" {
+		t.Errorf("Se esperaba el enunciado con el espacio. Se obtuvo %q", preguntas[6].Enunciado)
+	}
+	escape := preguntas[7]
+	if escape.Enunciado != "2 + 2 = 4?" || escape.Alternativas[0].Texto != "si~" {
+		t.Errorf("Se esperaban los escapes resueltos. Se obtuvo %+v", escape)
+	}
+	if omitidas[0].Nombre != "Parejas" || omitidas[1].Nombre != "Solo una descripcion" {
+		t.Errorf("Se esperaba omitir 'Parejas' y la descripcion. Se obtuvo %+v", omitidas)
+	}
+}
+
+func TestQTIIdaYVuelta(t *testing.T) {
+	original := Examen{
+		Nombre:   "Parcial <1>",
+		Duracion: 45,
+		Preguntas: []Pregunta{
+			{Nombre: "Capital", Tipo: OpcionMultiple, Enunciado: "Capital del Peru?\nElija una", Puntaje: 2,
+				Alternativas: []Alternativa{{Texto: "Lima", Fraccion: 100}, {Texto: "Cusco & Puno"}}},
+			{Nombre: "Agua", Tipo: VerdaderoFalso, Enunciado: "El agua hierve a 100 C", Puntaje: 1,
+				Alternativas: []Alternativa{{Texto: Verdadero, Fraccion: 100}, {Texto: Falso}}},
+			{Nombre: "Autor", Tipo: RespuestaCorta, Enunciado: "Autor de Los rios profundos", Puntaje: 1,
+				Alternativas: []Alternativa{{Texto: "Arguedas", Fraccion: 100}, {Texto: "Jose Maria Arguedas", Fraccion: 100}}},
+			{Nombre: "Pi", Tipo: Numerica, Enunciado: "Valor de pi", Puntaje: 1,
+				Alternativas: []Alternativa{{Texto: "3.14", Fraccion: 100, Tolerancia: 0.01}}},
+			{Nombre: "Ensayo", Tipo: Ensayo, Enunciado: "Describe la conquista", Puntaje: 5},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := EscribirQTI(&buf, original); err != nil {
+		t.Fatalf("No se escribio el paquete %s", err)
+	}
+	examen, omitidas, err := ParseQTI(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("No se leyo el paquete %s", err)
+	}
+	if len(omitidas) != 0 {
+		t.Errorf("No se esperaban omitidas. Se obtuvo %v", omitidas)
+	}
+	if examen.Nombre != original.Nombre || examen.Duracion != 45 {
+		t.Errorf("Se esperaba el examen '%s' de 45 minutos. Se obtuvo '%s' de %d",
+			original.Nombre, examen.Nombre, examen.Duracion)
+	}
+	if !reflect.DeepEqual(examen.Preguntas, original.Preguntas) {
+		t.Errorf("Las preguntas no sobrevivieron la ida y vuelta.\nSe esperaba %+v\nSe obtuvo %+v",
+			original.Preguntas, examen.Preguntas)
+	}
+}
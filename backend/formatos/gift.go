@@ -0,0 +1,285 @@
+package formatos
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var reFormatoGIFT = regexp.MustCompile(`^\[(html|moodle|plain|markdown)\]`)
+
+// ParseGIFT lee preguntas en formato GIFT, separadas por lineas en blanco.
+// Se ignoran los comentarios (//) y las lineas $CATEGORY. Las preguntas de
+// emparejamiento y las descripciones sin bloque de respuestas se omiten.
+func ParseGIFT(r io.Reader) ([]Pregunta, []Omitida, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	texto := strings.TrimPrefix(string(b), "\ufeff")
+	texto = strings.Replace(texto, "\r\n", "\n", -1)
+
+	preguntas := []Pregunta{}
+	omitidas := []Omitida{}
+	for i, bloque := range bloquesGIFT(texto) {
+		p, motivo := parsePreguntaGIFT(bloque)
+		if motivo == "" {
+			motivo = validar(p)
+		}
+		if motivo != "" {
+			nombre := p.Nombre
+			if nombre == "" {
+				nombre = resumir(p.Enunciado)
+			}
+			omitidas = append(omitidas, Omitida{Indice: i + 1, Nombre: nombre, Motivo: motivo})
+			continue
+		}
+		preguntas = append(preguntas, p)
+	}
+	return preguntas, omitidas, nil
+}
+
+func bloquesGIFT(texto string) []string {
+	bloques := []string{}
+	actual := []string{}
+	cerrar := func() {
+		if len(actual) > 0 {
+			bloques = append(bloques, strings.Join(actual, "\n"))
+			actual = []string{}
+		}
+	}
+	for _, linea := range strings.Split(texto, "\n") {
+		limpia := strings.TrimSpace(linea)
+		switch {
+		case limpia == "":
+			cerrar()
+		case strings.HasPrefix(limpia, "//"), strings.HasPrefix(limpia, "$CATEGORY:"):
+		default:
+			actual = append(actual, linea)
+		}
+	}
+	cerrar()
+	return bloques
+}
+
+func parsePreguntaGIFT(bloque string) (Pregunta, string) {
+	p := Pregunta{Puntaje: 1}
+	s := strings.TrimSpace(bloque)
+
+	if strings.HasPrefix(s, "::") {
+		fin := indexSinEscape(s, "::", 2)
+		if fin < 0 {
+			return p, "falta cerrar el titulo"
+		}
+		p.Nombre = strings.TrimSpace(desescapar(s[2:fin]))
+		s = strings.TrimSpace(s[fin+2:])
+	}
+
+	inicio := indexSinEscape(s, "{", 0)
+	if inicio < 0 {
+		p.Enunciado = desescapar(s)
+		return p, "la pregunta no tiene bloque de respuestas"
+	}
+	fin := indexSinEscape(s, "}", inicio)
+	if fin < 0 {
+		return p, "falta cerrar el bloque de respuestas"
+	}
+
+	enunciado := strings.TrimSpace(s[:inicio])
+	if despues := strings.TrimSpace(s[fin+1:]); despues != "" {
+		// pregunta de completar: la respuesta va en medio del texto
+		enunciado += " _____ " + despues
+	}
+	formato := reFormatoGIFT.FindStringSubmatch(enunciado)
+	enunciado = desescapar(reFormatoGIFT.ReplaceAllString(enunciado, ""))
+	if len(formato) > 1 && formato[1] == "html" {
+		enunciado = textoPlano(enunciado)
+	}
+	p.Enunciado = strings.TrimSpace(enunciado)
+
+	respuestas := strings.TrimSpace(s[inicio+1 : fin])
+	switch {
+	case respuestas == "":
+		p.Tipo = Ensayo
+	case strings.HasPrefix(respuestas, "#"):
+		p.Tipo = Numerica
+		return p, parseNumericaGIFT(&p, respuestas[1:])
+	case esVerdaderoFalso(respuestas):
+		p.Tipo = VerdaderoFalso
+		correcta := strings.ToUpper(strings.TrimSpace(quitarRetroalimentacion(respuestas)))
+		verdadero := correcta == "T" || correcta == "TRUE"
+		p.Alternativas = []Alternativa{
+			{Texto: Verdadero, Fraccion: fraccion(verdadero)},
+			{Texto: Falso, Fraccion: fraccion(!verdadero)},
+		}
+	case indexSinEscape(respuestas, "->", 0) >= 0:
+		return p, "las preguntas de emparejamiento no estan soportadas"
+	default:
+		p.Tipo = RespuestaCorta
+		partes := partirRespuestas(respuestas)
+		if len(partes) == 0 {
+			return p, "formato de respuestas invalido"
+		}
+		for _, r := range partes {
+			if r.marca == '~' {
+				p.Tipo = OpcionMultiple
+			}
+			a, err := alternativaGIFT(r)
+			if err != "" {
+				return p, err
+			}
+			p.Alternativas = append(p.Alternativas, a)
+		}
+	}
+	return p, ""
+}
+
+func parseNumericaGIFT(p *Pregunta, cuerpo string) string {
+	partes := partirRespuestas(cuerpo)
+	if len(partes) == 0 {
+		partes = []respuestaGIFT{{marca: '=', texto: cuerpo}}
+	}
+	for _, r := range partes {
+		a, err := alternativaGIFT(r)
+		if err != "" {
+			return err
+		}
+		texto := strings.TrimSpace(a.Texto)
+		var valor float64
+		var errNum error
+		if i := strings.Index(texto, ".."); i >= 0 {
+			var min, max float64
+			min, errNum = strconv.ParseFloat(strings.TrimSpace(texto[:i]), 64)
+			if errNum == nil {
+				max, errNum = strconv.ParseFloat(strings.TrimSpace(texto[i+2:]), 64)
+			}
+			valor, a.Tolerancia = (min+max)/2, (max-min)/2
+		} else if i := strings.Index(texto, ":"); i >= 0 {
+			valor, errNum = strconv.ParseFloat(strings.TrimSpace(texto[:i]), 64)
+			if errNum == nil {
+				a.Tolerancia, errNum = strconv.ParseFloat(strings.TrimSpace(texto[i+1:]), 64)
+			}
+		} else {
+			valor, errNum = strconv.ParseFloat(texto, 64)
+		}
+		if errNum != nil {
+			return fmt.Sprintf("respuesta numerica invalida '%s'", texto)
+		}
+		a.Texto = strconv.FormatFloat(valor, 'f', -1, 64)
+		p.Alternativas = append(p.Alternativas, a)
+	}
+	return ""
+}
+
+type respuestaGIFT struct {
+	marca byte // '=' correcta, '~' incorrecta
+	texto string
+}
+
+// partirRespuestas separa el bloque en respuestas que empiezan con '=' o
+// '~'. El texto conserva los escapes para poder cortar despues en '#'.
+func partirRespuestas(s string) []respuestaGIFT {
+	respuestas := []respuestaGIFT{}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if len(respuestas) > 0 && i+1 < len(s) {
+				respuestas[len(respuestas)-1].texto += s[i : i+2]
+			}
+			i++
+		case '=', '~':
+			respuestas = append(respuestas, respuestaGIFT{marca: s[i]})
+		default:
+			if len(respuestas) > 0 {
+				respuestas[len(respuestas)-1].texto += s[i : i+1]
+			}
+		}
+	}
+	return respuestas
+}
+
+// alternativaGIFT interpreta el peso (%50%) y quita la retroalimentacion
+func alternativaGIFT(r respuestaGIFT) (Alternativa, string) {
+	a := Alternativa{Fraccion: fraccion(r.marca == '=')}
+	texto := strings.TrimSpace(quitarRetroalimentacion(r.texto))
+	if strings.HasPrefix(texto, "%") {
+		fin := strings.Index(texto[1:], "%")
+		if fin < 0 {
+			return a, "peso de respuesta invalido"
+		}
+		peso, err := strconv.ParseFloat(texto[1:fin+1], 64)
+		if err != nil {
+			return a, "peso de respuesta invalido"
+		}
+		a.Fraccion = peso
+		texto = texto[fin+2:]
+	}
+	a.Texto = strings.TrimSpace(desescapar(texto))
+	return a, ""
+}
+
+func esVerdaderoFalso(s string) bool {
+	switch strings.ToUpper(strings.TrimSpace(quitarRetroalimentacion(s))) {
+	case "T", "TRUE", "F", "FALSE":
+		return true
+	}
+	return false
+}
+
+func quitarRetroalimentacion(s string) string {
+	if i := indexSinEscape(s, "#", 0); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func fraccion(correcta bool) float64 {
+	if correcta {
+		return 100
+	}
+	return 0
+}
+
+// indexSinEscape busca 'sub' desde 'desde' saltando los caracteres
+// escapados con '\'
+func indexSinEscape(s, sub string, desde int) int {
+	for i := desde; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+func desescapar(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// resumir acorta un enunciado para mostrarlo en el reporte
+func resumir(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > 40 {
+		return string(r[:40]) + "..."
+	}
+	return s
+}
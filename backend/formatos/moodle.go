@@ -0,0 +1,132 @@
+package formatos
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type moodleQuiz struct {
+	Preguntas []moodlePregunta `xml:"question"`
+}
+
+type moodleTexto struct {
+	Formato string `xml:"format,attr"`
+	Texto   string `xml:"text"`
+}
+
+func (t moodleTexto) plano() string {
+	if t.Formato == "html" || t.Formato == "moodle_auto_format" {
+		return textoPlano(t.Texto)
+	}
+	return strings.TrimSpace(t.Texto)
+}
+
+type moodlePregunta struct {
+	Tipo         string            `xml:"type,attr"`
+	Nombre       moodleTexto       `xml:"name"`
+	Enunciado    moodleTexto       `xml:"questiontext"`
+	Puntaje      string            `xml:"defaultgrade"`
+	Alternativas []moodleRespuesta `xml:"answer"`
+}
+
+type moodleRespuesta struct {
+	Fraccion   string `xml:"fraction,attr"`
+	Formato    string `xml:"format,attr"`
+	Texto      string `xml:"text"`
+	Tolerancia string `xml:"tolerance"`
+}
+
+// ParseMoodleXML lee un archivo exportado con el formato "Moodle XML". Las
+// categorias no cuentan como preguntas; los tipos sin equivalente (matching,
+// cloze, calculated, ...) se devuelven como omitidas.
+func ParseMoodleXML(r io.Reader) ([]Pregunta, []Omitida, error) {
+	var quiz moodleQuiz
+	if err := xml.NewDecoder(r).Decode(&quiz); err != nil {
+		return nil, nil, fmt.Errorf("XML de Moodle invalido: %s", err)
+	}
+
+	preguntas := []Pregunta{}
+	omitidas := []Omitida{}
+	indice := 0
+	for _, mp := range quiz.Preguntas {
+		if mp.Tipo == "category" {
+			continue
+		}
+		indice++
+		p, err := mp.convertir()
+		if err == "" {
+			err = validar(p)
+		}
+		if err != "" {
+			omitidas = append(omitidas, Omitida{Indice: indice, Nombre: p.Nombre, Motivo: err})
+			continue
+		}
+		preguntas = append(preguntas, p)
+	}
+	return preguntas, omitidas, nil
+}
+
+// convertir devuelve la pregunta y "" o el motivo por el que se omite
+func (mp moodlePregunta) convertir() (Pregunta, string) {
+	p := Pregunta{
+		Nombre:    strings.TrimSpace(mp.Nombre.Texto),
+		Tipo:      mp.Tipo,
+		Enunciado: mp.Enunciado.plano(),
+		Puntaje:   1,
+	}
+	if mp.Puntaje != "" {
+		puntaje, err := strconv.ParseFloat(strings.TrimSpace(mp.Puntaje), 64)
+		if err != nil {
+			return p, "puntaje invalido"
+		}
+		p.Puntaje = puntaje
+	}
+
+	switch mp.Tipo {
+	case OpcionMultiple, RespuestaCorta, Numerica, VerdaderoFalso:
+	case Ensayo:
+		return p, ""
+	default:
+		return p, fmt.Sprintf("tipo de pregunta '%s' no soportado", mp.Tipo)
+	}
+
+	for _, mr := range mp.Alternativas {
+		fraccion, err := strconv.ParseFloat(strings.TrimSpace(mr.Fraccion), 64)
+		if err != nil {
+			return p, "fraccion de respuesta invalida"
+		}
+		a := Alternativa{Fraccion: fraccion, Texto: strings.TrimSpace(mr.Texto)}
+		if mr.Formato == "html" {
+			a.Texto = textoPlano(mr.Texto)
+		}
+
+		switch mp.Tipo {
+		case VerdaderoFalso:
+			switch strings.ToLower(a.Texto) {
+			case "true":
+				a.Texto = Verdadero
+			case "false":
+				a.Texto = Falso
+			}
+		case Numerica:
+			// '*' acepta cualquier respuesta, no tiene equivalente
+			if a.Texto == "*" {
+				continue
+			}
+			if _, err := strconv.ParseFloat(a.Texto, 64); err != nil {
+				return p, fmt.Sprintf("respuesta numerica invalida '%s'", a.Texto)
+			}
+			if mr.Tolerancia != "" {
+				a.Tolerancia, err = strconv.ParseFloat(strings.TrimSpace(mr.Tolerancia), 64)
+				if err != nil {
+					return p, "tolerancia invalida"
+				}
+			}
+		}
+		p.Alternativas = append(p.Alternativas, a)
+	}
+	return p, ""
+}
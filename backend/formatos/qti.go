@@ -0,0 +1,463 @@
+package formatos
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Examen es lo que se guarda en un paquete QTI: los datos del examen que
+// tienen equivalente en QTI 2.1 y sus preguntas
+type Examen struct {
+	Nombre    string
+	Duracion  int // minutos, 0 sin limite
+	Preguntas []Pregunta
+}
+
+const (
+	qtiNamespace = "http://www.imsglobal.org/xsd/imsqti_v2p1"
+	qtiSchema    = "http://www.imsglobal.org/xsd/imsqti_v2p1 http://www.imsglobal.org/xsd/qti/qtiv2p1/imsqti_v2p1.xsd"
+	qtiTipoTest  = "imsqti_test_xmlv2p1"
+	qtiTipoItem  = "imsqti_item_xmlv2p1"
+
+	qtiMatchCorrect = "http://www.imsglobal.org/question/qti_v2p1/rptemplates/match_correct"
+	qtiMapResponse  = "http://www.imsglobal.org/question/qti_v2p1/rptemplates/map_response"
+)
+
+// EscribirQTI escribe el examen como paquete IMS QTI 2.1: un zip con
+// imsmanifest.xml, un assessmentTest y un assessmentItem por pregunta. El
+// tipo de cada pregunta va en el atributo 'label' del item para que la
+// importacion lo recupere sin adivinarlo.
+func EscribirQTI(w io.Writer, examen Examen) error {
+	z := zip.NewWriter(w)
+
+	items := make([]string, len(examen.Preguntas))
+	for i, p := range examen.Preguntas {
+		items[i] = fmt.Sprintf("item-%d", i+1)
+		f, err := z.Create("items/" + items[i] + ".xml")
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, itemQTI(items[i], p)); err != nil {
+			return err
+		}
+	}
+
+	f, err := z.Create("test.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, testQTI(examen, items)); err != nil {
+		return err
+	}
+
+	f, err = z.Create("imsmanifest.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, manifestQTI(items)); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+func esc(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func numero(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// parrafos convierte el enunciado en parrafos <p>, uno por linea
+func parrafos(s string) string {
+	var b strings.Builder
+	for _, linea := range strings.Split(s, "\n") {
+		if linea = strings.TrimSpace(linea); linea != "" {
+			b.WriteString("<p>" + esc(linea) + "</p>\n")
+		}
+	}
+	return b.String()
+}
+
+func itemQTI(id string, p Pregunta) string {
+	var decl, cuerpo, proceso strings.Builder
+	titulo := p.Nombre
+	if titulo == "" {
+		titulo = resumir(p.Enunciado)
+	}
+
+	correctas := []string{}
+	switch p.Tipo {
+	case OpcionMultiple, VerdaderoFalso:
+		cardinalidad := "single"
+		for i, a := range p.Alternativas {
+			if a.Correcta() {
+				correctas = append(correctas, fmt.Sprintf("A%d", i+1))
+			}
+		}
+		if len(correctas) > 1 {
+			cardinalidad = "multiple"
+		}
+		fmt.Fprintf(&decl, `<responseDeclaration identifier="RESPONSE" cardinality="%s" baseType="identifier">`+
+			"\n<correctResponse>\n", cardinalidad)
+		for _, c := range correctas {
+			fmt.Fprintf(&decl, "<value>%s</value>\n", c)
+		}
+		decl.WriteString("</correctResponse>\n</responseDeclaration>\n")
+
+		maxChoices := 1
+		if cardinalidad == "multiple" {
+			maxChoices = 0
+		}
+		fmt.Fprintf(&cuerpo, `<choiceInteraction responseIdentifier="RESPONSE" shuffle="false" maxChoices="%d">`+"\n", maxChoices)
+		for i, a := range p.Alternativas {
+			fmt.Fprintf(&cuerpo, `<simpleChoice identifier="A%d">%s</simpleChoice>`+"\n", i+1, esc(a.Texto))
+		}
+		cuerpo.WriteString("</choiceInteraction>\n")
+		fmt.Fprintf(&proceso, `<responseProcessing template="%s"/>`+"\n", qtiMatchCorrect)
+
+	case RespuestaCorta:
+		for _, a := range p.Alternativas {
+			if a.Correcta() {
+				correctas = append(correctas, a.Texto)
+			}
+		}
+		decl.WriteString(`<responseDeclaration identifier="RESPONSE" cardinality="single" baseType="string">` + "\n")
+		if len(correctas) > 0 {
+			fmt.Fprintf(&decl, "<correctResponse>\n<value>%s</value>\n</correctResponse>\n", esc(correctas[0]))
+		}
+		decl.WriteString(`<mapping defaultValue="0">` + "\n")
+		for _, c := range correctas {
+			fmt.Fprintf(&decl, `<mapEntry mapKey="%s" mappedValue="%s" caseSensitive="false"/>`+"\n",
+				esc(c), numero(p.Puntaje))
+		}
+		decl.WriteString("</mapping>\n</responseDeclaration>\n")
+		cuerpo.WriteString(`<p><textEntryInteraction responseIdentifier="RESPONSE" expectedLength="20"/></p>` + "\n")
+		fmt.Fprintf(&proceso, `<responseProcessing template="%s"/>`+"\n", qtiMapResponse)
+
+	case Numerica:
+		decl.WriteString(`<responseDeclaration identifier="RESPONSE" cardinality="single" baseType="float">` + "\n")
+		condiciones := []Alternativa{}
+		for _, a := range p.Alternativas {
+			if a.Correcta() {
+				condiciones = append(condiciones, a)
+			}
+		}
+		if len(condiciones) > 0 {
+			fmt.Fprintf(&decl, "<correctResponse>\n<value>%s</value>\n</correctResponse>\n", esc(condiciones[0].Texto))
+		}
+		decl.WriteString("</responseDeclaration>\n")
+		cuerpo.WriteString(`<p><textEntryInteraction responseIdentifier="RESPONSE" expectedLength="10"/></p>` + "\n")
+
+		proceso.WriteString("<responseProcessing>\n<responseCondition>\n")
+		for i, a := range condiciones {
+			etiqueta := "responseElseIf"
+			if i == 0 {
+				etiqueta = "responseIf"
+			}
+			tol := numero(a.Tolerancia)
+			fmt.Fprintf(&proceso, "<%s>\n"+
+				`<equal toleranceMode="absolute" tolerance="%s %s">`+
+				`<variable identifier="RESPONSE"/><baseValue baseType="float">%s</baseValue></equal>`+"\n"+
+				`<setOutcomeValue identifier="SCORE"><baseValue baseType="float">%s</baseValue></setOutcomeValue>`+"\n"+
+				"</%s>\n", etiqueta, tol, tol, esc(a.Texto), numero(p.Puntaje*a.Fraccion/100), etiqueta)
+		}
+		proceso.WriteString("</responseCondition>\n</responseProcessing>\n")
+
+	case Ensayo:
+		decl.WriteString(`<responseDeclaration identifier="RESPONSE" cardinality="single" baseType="string"/>` + "\n")
+		cuerpo.WriteString(`<extendedTextInteraction responseIdentifier="RESPONSE"/>` + "\n")
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<assessmentItem xmlns="%s" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="%s" identifier="%s" title="%s" label="%s" adaptive="false" timeDependent="false">
+%s<outcomeDeclaration identifier="SCORE" cardinality="single" baseType="float"/>
+<outcomeDeclaration identifier="MAXSCORE" cardinality="single" baseType="float">
+<defaultValue><value>%s</value></defaultValue>
+</outcomeDeclaration>
+<itemBody>
+%s%s</itemBody>
+%s</assessmentItem>
+`, qtiNamespace, qtiSchema, id, esc(titulo), p.Tipo, decl.String(), numero(p.Puntaje),
+		parrafos(p.Enunciado), cuerpo.String(), proceso.String())
+}
+
+func testQTI(examen Examen, items []string) string {
+	limite := ""
+	if examen.Duracion > 0 {
+		limite = fmt.Sprintf(`<timeLimits maxTime="%d"/>`+"\n", examen.Duracion*60)
+	}
+	var refs strings.Builder
+	for _, id := range items {
+		fmt.Fprintf(&refs, `<assessmentItemRef identifier="%s" href="items/%s.xml"/>`+"\n", id, id)
+	}
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<assessmentTest xmlns="%s" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="%s" identifier="examen" title="%s" toolName="vaula">
+%s<testPart identifier="parte-1" navigationMode="nonlinear" submissionMode="simultaneous">
+<assessmentSection identifier="seccion-1" title="%s" visible="true">
+%s</assessmentSection>
+</testPart>
+</assessmentTest>
+`, qtiNamespace, qtiSchema, esc(examen.Nombre), limite, esc(examen.Nombre), refs.String())
+}
+
+func manifestQTI(items []string) string {
+	var deps, recursos strings.Builder
+	for _, id := range items {
+		fmt.Fprintf(&deps, `<dependency identifierref="%s"/>`+"\n", id)
+		fmt.Fprintf(&recursos, `<resource identifier="%s" type="%s" href="items/%s.xml">`+
+			"\n"+`<file href="items/%s.xml"/>`+"\n</resource>\n", id, qtiTipoItem, id, id)
+	}
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<manifest xmlns="http://www.imsglobal.org/xsd/imscp_v1p1" identifier="vaula-examen">
+<metadata>
+<schema>QTIv2.1 Package</schema>
+<schemaversion>1.0.0</schemaversion>
+</metadata>
+<organizations/>
+<resources>
+<resource identifier="test" type="%s" href="test.xml">
+<file href="test.xml"/>
+%s</resource>
+%s</resources>
+</manifest>
+`, qtiTipoTest, deps.String(), recursos.String())
+}
+
+// estructuras para leer un paquete QTI, exportado por vaula u otra
+// plataforma
+
+type qtiManifest struct {
+	Recursos []struct {
+		Tipo string `xml:"type,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"resources>resource"`
+}
+
+type qtiTest struct {
+	Titulo string `xml:"title,attr"`
+	Limite struct {
+		MaxTime string `xml:"maxTime,attr"`
+	} `xml:"timeLimits"`
+	Refs []struct {
+		Href string `xml:"href,attr"`
+	} `xml:"testPart>assessmentSection>assessmentItemRef"`
+}
+
+type qtiCondicion struct {
+	Igual struct {
+		Tolerancia string `xml:"tolerance,attr"`
+		Valor      string `xml:"baseValue"`
+	} `xml:"equal"`
+}
+
+type qtiItem struct {
+	Titulo      string `xml:"title,attr"`
+	Etiqueta    string `xml:"label,attr"`
+	Declaracion struct {
+		BaseType  string   `xml:"baseType,attr"`
+		Correctas []string `xml:"correctResponse>value"`
+		Mapeo     []struct {
+			Clave string `xml:"mapKey,attr"`
+			Valor string `xml:"mappedValue,attr"`
+		} `xml:"mapping>mapEntry"`
+	} `xml:"responseDeclaration"`
+	Salidas []struct {
+		Identificador string `xml:"identifier,attr"`
+		Valor         string `xml:"defaultValue>value"`
+	} `xml:"outcomeDeclaration"`
+	Cuerpo struct {
+		XML      string `xml:",innerxml"`
+		Opciones []struct {
+			ID    string `xml:"identifier,attr"`
+			Texto string `xml:",innerxml"`
+		} `xml:"choiceInteraction>simpleChoice"`
+		TextoCorto []struct{} `xml:"p>textEntryInteraction"`
+		Ensayo     []struct{} `xml:"extendedTextInteraction"`
+	} `xml:"itemBody"`
+	Condiciones []qtiCondicion `xml:"responseProcessing>responseCondition>responseIf"`
+	SiNo        []qtiCondicion `xml:"responseProcessing>responseCondition>responseElseIf"`
+}
+
+var reInteraccion = regexp.MustCompile(
+	`(?s)<(choiceInteraction|extendedTextInteraction)\b.*?</(choiceInteraction|extendedTextInteraction)>` +
+		`|<(textEntryInteraction|extendedTextInteraction)\b[^>]*/>`)
+
+// ParseQTI lee un paquete QTI 2.1. Las preguntas siguen el orden del
+// assessmentTest o, si no lo hay, el del manifiesto. Las que no se pueden
+// convertir se devuelven como omitidas.
+func ParseQTI(r io.ReaderAt, size int64) (Examen, []Omitida, error) {
+	examen := Examen{Preguntas: []Pregunta{}}
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return examen, nil, fmt.Errorf("paquete QTI invalido: %s", err)
+	}
+	archivos := map[string]*zip.File{}
+	for _, f := range z.File {
+		archivos[path.Clean(f.Name)] = f
+	}
+
+	var manifest qtiManifest
+	if err := leerXML(archivos, "imsmanifest.xml", &manifest); err != nil {
+		return examen, nil, err
+	}
+
+	items := []string{}
+	for _, rec := range manifest.Recursos {
+		if !strings.HasPrefix(rec.Tipo, "imsqti_test_xmlv2p") {
+			continue
+		}
+		var test qtiTest
+		if err := leerXML(archivos, rec.Href, &test); err != nil {
+			return examen, nil, err
+		}
+		examen.Nombre = test.Titulo
+		if segundos, err := strconv.Atoi(test.Limite.MaxTime); err == nil {
+			examen.Duracion = (segundos + 59) / 60
+		}
+		for _, ref := range test.Refs {
+			items = append(items, path.Join(path.Dir(rec.Href), ref.Href))
+		}
+		break
+	}
+	if len(items) == 0 {
+		for _, rec := range manifest.Recursos {
+			if strings.HasPrefix(rec.Tipo, "imsqti_item_xmlv2p") {
+				items = append(items, rec.Href)
+			}
+		}
+	}
+
+	omitidas := []Omitida{}
+	for i, ruta := range items {
+		var item qtiItem
+		if err := leerXML(archivos, ruta, &item); err != nil {
+			omitidas = append(omitidas, Omitida{Indice: i + 1, Nombre: ruta, Motivo: err.Error()})
+			continue
+		}
+		p, motivo := item.convertir()
+		if motivo == "" {
+			motivo = validar(p)
+		}
+		if motivo != "" {
+			omitidas = append(omitidas, Omitida{Indice: i + 1, Nombre: p.Nombre, Motivo: motivo})
+			continue
+		}
+		examen.Preguntas = append(examen.Preguntas, p)
+	}
+	return examen, omitidas, nil
+}
+
+func leerXML(archivos map[string]*zip.File, ruta string, v interface{}) error {
+	f, ok := archivos[path.Clean(ruta)]
+	if !ok {
+		return fmt.Errorf("el paquete no contiene %s", ruta)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%s invalido: %s", ruta, err)
+	}
+	return nil
+}
+
+// convertir devuelve la pregunta y "" o el motivo por el que se omite. El
+// tipo se toma del 'label' que pone vaula, o se deduce de la interaccion.
+func (item qtiItem) convertir() (Pregunta, string) {
+	p := Pregunta{
+		Nombre:    item.Titulo,
+		Enunciado: textoPlano(reInteraccion.ReplaceAllString(item.Cuerpo.XML, "")),
+		Puntaje:   1,
+	}
+	for _, s := range item.Salidas {
+		if s.Identificador == "MAXSCORE" && s.Valor != "" {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(s.Valor), 64); err == nil {
+				p.Puntaje = v
+			}
+		}
+	}
+
+	switch item.Etiqueta {
+	case OpcionMultiple, VerdaderoFalso, RespuestaCorta, Numerica, Ensayo:
+		p.Tipo = item.Etiqueta
+	default:
+		switch {
+		case len(item.Cuerpo.Opciones) > 0:
+			p.Tipo = OpcionMultiple
+		case len(item.Cuerpo.TextoCorto) > 0 && item.Declaracion.BaseType == "float":
+			p.Tipo = Numerica
+		case len(item.Cuerpo.TextoCorto) > 0:
+			p.Tipo = RespuestaCorta
+		case len(item.Cuerpo.Ensayo) > 0:
+			p.Tipo = Ensayo
+		default:
+			return p, "tipo de interaccion no soportado"
+		}
+	}
+
+	correctas := map[string]bool{}
+	for _, c := range item.Declaracion.Correctas {
+		correctas[strings.TrimSpace(c)] = true
+	}
+
+	switch p.Tipo {
+	case OpcionMultiple, VerdaderoFalso:
+		for _, o := range item.Cuerpo.Opciones {
+			p.Alternativas = append(p.Alternativas, Alternativa{
+				Texto:    textoPlano(o.Texto),
+				Fraccion: fraccion(correctas[o.ID]),
+			})
+		}
+	case RespuestaCorta:
+		vistas := map[string]bool{}
+		for _, m := range item.Declaracion.Mapeo {
+			if v, _ := strconv.ParseFloat(m.Valor, 64); v > 0 && !vistas[m.Clave] {
+				vistas[m.Clave] = true
+				p.Alternativas = append(p.Alternativas, Alternativa{Texto: m.Clave, Fraccion: 100})
+			}
+		}
+		for _, c := range item.Declaracion.Correctas {
+			if c = strings.TrimSpace(c); !vistas[c] {
+				vistas[c] = true
+				p.Alternativas = append(p.Alternativas, Alternativa{Texto: c, Fraccion: 100})
+			}
+		}
+	case Numerica:
+		for _, c := range append(item.Condiciones, item.SiNo...) {
+			a := Alternativa{Texto: strings.TrimSpace(c.Igual.Valor), Fraccion: 100}
+			if campos := strings.Fields(c.Igual.Tolerancia); len(campos) > 0 {
+				a.Tolerancia, _ = strconv.ParseFloat(campos[0], 64)
+			}
+			if a.Texto != "" {
+				p.Alternativas = append(p.Alternativas, a)
+			}
+		}
+		if len(p.Alternativas) == 0 {
+			for _, c := range item.Declaracion.Correctas {
+				p.Alternativas = append(p.Alternativas, Alternativa{Texto: strings.TrimSpace(c), Fraccion: 100})
+			}
+		}
+		for _, a := range p.Alternativas {
+			if _, err := strconv.ParseFloat(a.Texto, 64); err != nil {
+				return p, fmt.Sprintf("respuesta numerica invalida '%s'", a.Texto)
+			}
+		}
+	}
+	return p, ""
+}
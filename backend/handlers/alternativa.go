@@ -42,7 +42,12 @@ func (a *App) getAlternativaByIdHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (a *App) getAlternativasHandler(w http.ResponseWriter, r *http.Request) {
-	alternativas, err := models.GetAlternativas(a.DB)
+	c, ok := consultaListado(w, r, models.ListadoAlternativas)
+	if !ok {
+		return
+	}
+
+	alternativas, total, err := models.GetAlternativas(a.DB, c)
 	if err != nil {
 		log.Printf("GET %s code: %d ERROR: %s -- no se obtuvieron alternativas", r.RequestURI,
 			http.StatusInternalServerError, err.Error())
@@ -51,7 +56,7 @@ func (a *App) getAlternativasHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
-	respondWithJSON(w, http.StatusOK, alternativas)
+	respondWithPagina(w, r, c, total, alternativas)
 	return
 
 }
@@ -21,6 +21,11 @@ func (a *App) getAlumnoByIdHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expansion, ok := expansionRespuesta(w, r, models.ExpandirUsuario)
+	if !ok {
+		return
+	}
+
 	alum := models.Alumno{ID: id}
 	err = alum.GetAlumno(a.DB)
 	if err != nil {
@@ -36,13 +41,29 @@ func (a *App) getAlumnoByIdHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	alumnos := []models.Alumno{alum}
+	if err := models.ExpandirAlumnos(a.DB, alumnos, expansion); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.ExpandirAlumnos", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
-	respondWithJSON(w, http.StatusOK, alum)
+	respondWithJSON(w, http.StatusOK, alumnos[0])
 	return
 }
 
 func (a *App) getAlumnosHandler(w http.ResponseWriter, r *http.Request) {
-	alumnos, err := models.GetAlumnos(a.DB)
+	c, ok := consultaListado(w, r, models.ListadoAlumnos)
+	if !ok {
+		return
+	}
+	expansion, ok := expansionRespuesta(w, r, models.ExpandirUsuario)
+	if !ok {
+		return
+	}
+
+	alumnos, total, err := models.GetAlumnos(a.DB, c)
 	if err != nil {
 		log.Printf("GET %s code: %d ERROR: %s -- models.GetAlumnos", r.RequestURI,
 			http.StatusInternalServerError, err.Error())
@@ -50,8 +71,15 @@ func (a *App) getAlumnosHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := models.ExpandirAlumnos(a.DB, alumnos, expansion); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.ExpandirAlumnos", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
-	respondWithJSON(w, http.StatusOK, alumnos)
+	respondWithPagina(w, r, c, total, alumnos)
 	return
 
 }
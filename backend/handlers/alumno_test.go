@@ -114,6 +114,31 @@ func TestGetAlumno(t *testing.T) {
 	checkResponseCode(t, http.StatusOK, response.Code)
 }
 
+func TestGetAlumnoExpandido(t *testing.T) {
+	utils.ClearTableUsuario(a.DB)
+	utils.AddAlumnos(1, a.DB)
+	ensureAuthorizedUserExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", "/alumnos/1?expand=usuario", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	usuario, _ := m["usuario"].(map[string]interface{})
+	if usuario == nil || usuario["id"] != m["usuarioId"] {
+		t.Errorf("Se esperaba el usuario %v expandido. Se obtuvo %v", m["usuarioId"], m["usuario"])
+	}
+	if _, ok := usuario["password"]; ok {
+		t.Errorf("El usuario expandido no deberia tener password. Se obtuvo %v", usuario)
+	}
+}
+
 func TestUpdateAlumno(t *testing.T) {
 	utils.ClearTableUsuario(a.DB)
 	utils.AddAlumnos(1, a.DB)
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// notas en la escala vigesimal
+const (
+	calificacionMinima = 0
+	calificacionMaxima = 20
+)
+
+func (a *App) getAlumnoTrabajoByIdHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de alumno trabajo invalido")
+		return
+	}
+
+	at := models.AlumnoTrabajo{ID: id}
+	if err := at.GetAlumnoTrabajo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Alumno trabajo no encontrado")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- at.GetAlumnoTrabajo", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	esProfesor, ok := a.accesoAlumnoTrabajo(w, r, at)
+	if !ok {
+		return
+	}
+
+	// abrir la entrega devuelta es como el alumno se entera de la devolucion
+	if !esProfesor && at.FechaDevolucion != nil && !at.DevolucionVista {
+		if err := at.MarcarDevolucionVista(a.DB); err != nil {
+			log.Printf("GET %s code: %d ERROR: %s -- at.MarcarDevolucionVista", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	if !esProfesor {
+		at.OcultarSinDevolver()
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, at)
+	return
+}
+
+// accesoAlumnoTrabajo verifica que el usuario autenticado pueda ver la
+// entrega: un profesor del curso o el alumno que la hizo
+func (a *App) accesoAlumnoTrabajo(w http.ResponseWriter, r *http.Request, at models.AlumnoTrabajo) (esProfesor bool, ok bool) {
+	return a.accesoEntregaAlumno(w, r, at.TrabajoId, at.AlumnoId)
+}
+
+// devolverAlumnoTrabajoHandler publica la nota y los comentarios de la
+// entrega para el alumno
+func (a *App) devolverAlumnoTrabajoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de alumno trabajo invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	at := models.AlumnoTrabajo{ID: id}
+	if err := at.GetAlumnoTrabajo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("POST %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Alumno trabajo no encontrado")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- at.GetAlumnoTrabajo", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	if err := at.Devolver(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- at.Devolver", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.notificarCalificacion(at)
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, at)
+	return
+}
+
+// getDevolucionesHandler lista al alumno autenticado los trabajos devueltos
+// que todavia no abrio
+func (a *App) getDevolucionesHandler(w http.ResponseWriter, r *http.Request) {
+	alumno, ok := a.alumnoAutenticado(w, r)
+	if !ok {
+		return
+	}
+
+	devoluciones, err := models.GetDevolucionesPendientes(a.DB, alumno.ID)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetDevolucionesPendientes", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, devoluciones)
+	return
+}
+
+// calificarAlumnoTrabajoHandler registra la nota de una entrega aplicando
+// la politica de tardanza del trabajo sobre la fecha de la ultima entrega.
+// El alumno no ve la nota hasta que se le devuelve la entrega.
+func (a *App) calificarAlumnoTrabajoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de alumno trabajo invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var payload struct {
+		Calificacion float32 `json:"calificacion"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&payload); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if payload.Calificacion < calificacionMinima || payload.Calificacion > calificacionMaxima {
+		log.Printf("PUT %s code: %d ERROR: calificacion %v fuera de rango", r.RequestURI,
+			http.StatusBadRequest, payload.Calificacion)
+		respondWithError(w, http.StatusBadRequest, "La calificacion debe estar entre 0 y 20")
+		return
+	}
+
+	at := models.AlumnoTrabajo{ID: id}
+	if err := at.GetAlumnoTrabajo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("PUT %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Alumno trabajo no encontrado")
+		default:
+			log.Printf("PUT %s code: %d ERROR: %s -- at.GetAlumnoTrabajo", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	tardanza, err := a.tardanzaAlumnoTrabajo(at)
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- tardanzaAlumnoTrabajo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := at.Calificar(a.DB, payload.Calificacion, tardanza); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- at.Calificar", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, at)
+	return
+}
+
+// tardanzaAlumnoTrabajo calcula el retraso de la ultima entrega segun la
+// politica del trabajo y la prorroga del alumno
+func (a *App) tardanzaAlumnoTrabajo(at models.AlumnoTrabajo) (models.Tardanza, error) {
+	trabajo := models.Trabajo{ID: at.TrabajoId}
+	if err := trabajo.GetTrabajo(a.DB); err != nil {
+		return models.Tardanza{}, err
+	}
+	prorroga := models.Prorroga{AlumnoId: at.AlumnoId, TrabajoId: at.TrabajoId}
+	if err := prorroga.GetProrrogaTrabajo(a.DB); err != nil {
+		return models.Tardanza{}, err
+	}
+	return trabajo.CalcularTardanza(at.FechaFinal, trabajo.FechaFinalPara(prorroga)), nil
+}
+
+// getCalificacionesTrabajoHandler es el reporte de notas de un trabajo
+func (a *App) getCalificacionesTrabajoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de trabajo invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	calificaciones, err := models.GetCalificacionesTrabajo(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetCalificacionesTrabajo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, calificaciones)
+	return
+}
+
+// notificarCalificacion avisa al alumno que su trabajo fue devuelto con
+// nota
+func (a *App) notificarCalificacion(at models.AlumnoTrabajo) {
+	alumno := models.Alumno{ID: at.AlumnoId}
+	if err := alumno.GetAlumno(a.DB); err != nil {
+		log.Printf("NOTIFICACION %s ERROR: %s -- alumno.GetAlumno",
+			models.NotificacionCalificacion, err.Error())
+		return
+	}
+	trabajo := models.Trabajo{ID: at.TrabajoId}
+	if err := trabajo.GetTrabajo(a.DB); err != nil {
+		log.Printf("NOTIFICACION %s ERROR: %s -- trabajo.GetTrabajo",
+			models.NotificacionCalificacion, err.Error())
+		return
+	}
+	a.notificar([]int{alumno.UsuarioId}, models.Notificacion{
+		Tipo:         models.NotificacionCalificacion,
+		Titulo:       "Calificacion publicada",
+		Cuerpo:       fmt.Sprintf("Tu trabajo \"%s\" fue calificado.", trabajo.Descripcion),
+		ReferenciaId: trabajo.ID,
+	})
+	a.publicarEvento(models.CanalUsuario(alumno.UsuarioId), models.EventoCalificacion, map[string]interface{}{
+		"trabajoId":    trabajo.ID,
+		"cursoId":      trabajo.CursoId,
+		"calificacion": at.Calificacion,
+	})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestCalificarAlumnoTrabajoConDescuento(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddEntregas(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	// el trabajo de prueba cierra el 2022-06-22, la entrega llega 2 dias despues
+	a.DB.Exec(context.Background(),
+		`UPDATE trabajos SET politicaTardanza='descuento',
+		descuentoDiario=10, descuentoMaximo=30 WHERE id=1`)
+	a.DB.Exec(context.Background(),
+		`UPDATE alumnoTrabajo SET fechaFinal=$1 WHERE id=1`,
+		time.Date(2022, time.June, 24, 17, 0, 0, 0, time.UTC))
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"calificacion": 15}`)
+	req, _ := http.NewRequest("PUT", "/alumnoTrabajos/1/calificacion", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m["tardio"] != true {
+		t.Errorf("Expected tardio to be 'true'. Got '%v'", m["tardio"])
+	}
+
+	if m["penalizacion"] != 20.0 {
+		t.Errorf("Expected penalizacion to be '20'. Got '%v'", m["penalizacion"])
+	}
+
+	if m["calificacion"] != 12.0 {
+		t.Errorf("Expected calificacion to be '12'. Got '%v'", m["calificacion"])
+	}
+
+	req, _ = http.NewRequest("GET", "/trabajos/1/calificaciones", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var reporte []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &reporte)
+	if len(reporte) != 1 || reporte[0]["tardio"] != true {
+		t.Errorf("Se esperaba el flag tardio en el reporte. Se obtuvo %v", reporte)
+	}
+}
+
+func TestCalificarAlumnoTrabajoFueraDeRango(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddEntregas(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"calificacion": 21}`)
+	req, _ := http.NewRequest("PUT", "/alumnoTrabajos/1/calificacion", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestAlumnoTrabajoOcultoHastaDevolucion(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	utils.AddTrabajos(1, a.DB)
+	docente := miembroCursoPrueba("docente", models.RolProfesor, 1)
+
+	entrega := models.Entrega{TrabajoId: 1, AlumnoId: alumno.ID, NombreArchivo: "a.txt",
+		MimeType: "text/plain", Tamano: 1, Clave: "test/a.txt", FechaEntrega: time.Now()}
+	entrega.CreateEntrega(a.DB)
+	at := models.AlumnoTrabajo{ID: entrega.AlumnoTrabajoId}
+	at.GetAlumnoTrabajo(a.DB)
+	at.Calificar(a.DB, 15, models.Tardanza{})
+	comentario := models.Comentario{AlumnoTrabajoId: at.ID, UsuarioId: docente, Texto: "buen trabajo"}
+	comentario.CreateComentario(a.DB)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	consultar := func() (map[string]interface{}, []interface{}) {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/alumnoTrabajos/%d", at.ID), nil)
+		req.Header.Set("Authorization", token_str)
+		response := executeRequest(req, a)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		var m map[string]interface{}
+		json.Unmarshal(response.Body.Bytes(), &m)
+
+		req, _ = http.NewRequest("GET", fmt.Sprintf("/alumnoTrabajos/%d/comentarios", at.ID), nil)
+		req.Header.Set("Authorization", token_str)
+		response = executeRequest(req, a)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		var comentarios []interface{}
+		json.Unmarshal(response.Body.Bytes(), &comentarios)
+		return m, comentarios
+	}
+
+	m, comentarios := consultar()
+	if m["calificacion"] != 0.0 || m["calificacionBruta"] != 0.0 {
+		t.Errorf("La nota no deberia verse antes de la devolucion. Se obtuvo %v", m)
+	}
+	if len(comentarios) != 0 {
+		t.Errorf("Los comentarios del profesor no deberian verse antes de la devolucion. Se obtuvo %v",
+			comentarios)
+	}
+
+	at.Devolver(a.DB)
+
+	m, comentarios = consultar()
+	if m["calificacion"] != 15.0 {
+		t.Errorf("Se esperaba la nota 15 despues de la devolucion. Se obtuvo %v", m["calificacion"])
+	}
+	if len(comentarios) != 1 {
+		t.Errorf("Se esperaba el comentario del profesor. Se obtuvo %v", comentarios)
+	}
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// getAnunciosCursoHandler lista los anuncios del curso a sus miembros
+func (a *App) getAnunciosCursoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+	if _, ok := a.rolEnCurso(w, r, id); !ok {
+		return
+	}
+
+	anuncios, err := models.GetAnunciosCurso(a.DB, id, getUserId(r))
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetAnunciosCurso", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, anuncios)
+	return
+}
+
+func (a *App) createAnuncioHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+	if !a.profesorDelCurso(w, r, id) {
+		return
+	}
+
+	var anuncio models.Anuncio
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&anuncio); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if anuncio.Titulo == "" || anuncio.Cuerpo == "" {
+		log.Printf("POST %s code: %d ERROR: anuncio incompleto", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "El anuncio debe tener titulo y cuerpo")
+		return
+	}
+
+	anuncio.CursoId = id
+	anuncio.AutorId = getUserId(r)
+	if err := anuncio.CreateAnuncio(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- anuncio.CreateAnuncio", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.notificarCurso(anuncio.CursoId, models.Notificacion{
+		Tipo:         models.NotificacionAnuncio,
+		Titulo:       anuncio.Titulo,
+		Cuerpo:       anuncio.Cuerpo,
+		ReferenciaId: anuncio.ID,
+	})
+	a.publicarEvento(models.CanalCurso(anuncio.CursoId), models.EventoAnuncio, map[string]interface{}{
+		"id":      anuncio.ID,
+		"cursoId": anuncio.CursoId,
+		"titulo":  anuncio.Titulo,
+	})
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, anuncio)
+	return
+}
+
+// getAnuncioByIdHandler devuelve el anuncio y lo marca como leido
+func (a *App) getAnuncioByIdHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de anuncio invalido")
+		return
+	}
+
+	anuncio, ok := a.cargarAnuncio(w, r, id)
+	if !ok {
+		return
+	}
+	if _, ok := a.rolEnCurso(w, r, anuncio.CursoId); !ok {
+		return
+	}
+
+	if err := models.MarcarLeido(a.DB, getUserId(r), models.TipoAnuncio, anuncio.ID); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.MarcarLeido", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	anuncio.Leido = true
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, anuncio)
+	return
+}
+
+// updateAnuncioHandler edita o fija el anuncio; los cambios de titulo o
+// cuerpo quedan en el historial
+func (a *App) updateAnuncioHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de anuncio invalido")
+		return
+	}
+
+	actual, ok := a.cargarAnuncio(w, r, id)
+	if !ok {
+		return
+	}
+	if !a.profesorDelCurso(w, r, actual.CursoId) {
+		return
+	}
+
+	var anuncio models.Anuncio
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&anuncio); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if anuncio.Titulo == "" || anuncio.Cuerpo == "" {
+		log.Printf("PUT %s code: %d ERROR: anuncio incompleto", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "El anuncio debe tener titulo y cuerpo")
+		return
+	}
+
+	anuncio.ID = id
+	if err := anuncio.UpdateAnuncio(a.DB, getUserId(r)); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- anuncio.UpdateAnuncio", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, anuncio)
+	return
+}
+
+func (a *App) deleteAnuncioHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de anuncio invalido")
+		return
+	}
+
+	anuncio, ok := a.cargarAnuncio(w, r, id)
+	if !ok {
+		return
+	}
+	if !a.profesorDelCurso(w, r, anuncio.CursoId) {
+		return
+	}
+
+	if err := anuncio.DeleteAnuncio(a.DB); err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- anuncio.DeleteAnuncio", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("DELETE %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": anuncio.ID})
+	return
+}
+
+func (a *App) getHistorialAnuncioHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de anuncio invalido")
+		return
+	}
+
+	anuncio, ok := a.cargarAnuncio(w, r, id)
+	if !ok {
+		return
+	}
+	if _, ok := a.rolEnCurso(w, r, anuncio.CursoId); !ok {
+		return
+	}
+
+	ediciones, err := models.GetEdiciones(a.DB, models.TipoAnuncio, anuncio.ID)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetEdiciones", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, ediciones)
+	return
+}
+
+func (a *App) cargarAnuncio(w http.ResponseWriter, r *http.Request, id int) (models.Anuncio, bool) {
+	anuncio := models.Anuncio{ID: id}
+	if err := anuncio.GetAnuncio(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("%s %s code: %d ERROR: %s -- no rows", r.Method, r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Anuncio no encontrado")
+		default:
+			log.Printf("%s %s code: %d ERROR: %s -- anuncio.GetAnuncio", r.Method, r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return anuncio, false
+	}
+	return anuncio, true
+}
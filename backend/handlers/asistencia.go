@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// marcarAsistencia es el cuerpo de PUT /sesiones/{id}/asistencias. 'todos'
+// marca con ese estado a cada alumno matriculado y 'asistencias' corrige a
+// los alumnos que lo necesiten.
+type marcarAsistencia struct {
+	Todos       string              `json:"todos"`
+	Asistencias []models.Asistencia `json:"asistencias"`
+}
+
+func (a *App) getSesionesCursoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+
+	sesiones, err := models.GetSesionesCurso(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetSesionesCurso", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, sesiones)
+	return
+}
+
+func (a *App) createSesionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var sesion models.Sesion
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&sesion); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if sesion.Fecha.IsZero() {
+		log.Printf("POST %s code: %d ERROR: sesion sin fecha", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "La sesion debe tener fecha")
+		return
+	}
+
+	curso := models.Curso{ID: id}
+	if err := curso.GetCurso(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("POST %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Curso no encontrado")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- curso.GetCurso", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	sesion.CursoId = curso.ID
+	if err := sesion.CreateSesion(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- sesion.CreateSesion", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, sesion)
+	return
+}
+
+func (a *App) getSesionByIdHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de sesion invalido")
+		return
+	}
+
+	sesion, ok := a.cargarSesion(w, r, id)
+	if !ok {
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, sesion)
+	return
+}
+
+func (a *App) updateSesionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de sesion invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var sesion models.Sesion
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&sesion); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if sesion.Fecha.IsZero() {
+		log.Printf("PUT %s code: %d ERROR: sesion sin fecha", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "La sesion debe tener fecha")
+		return
+	}
+
+	sesion.ID = id
+	if err := sesion.UpdateSesion(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("PUT %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Sesion no encontrada")
+		default:
+			log.Printf("PUT %s code: %d ERROR: %s -- sesion.UpdateSesion", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, sesion)
+	return
+}
+
+func (a *App) deleteSesionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de sesion invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	sesion := models.Sesion{ID: id}
+	if err := sesion.DeleteSesion(a.DB); err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- sesion.DeleteSesion", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("DELETE %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": sesion.ID})
+	return
+}
+
+func (a *App) getAsistenciasSesionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de sesion invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	asistencias, err := models.GetAsistenciasSesion(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetAsistenciasSesion", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, asistencias)
+	return
+}
+
+// marcarAsistenciasHandler registra la asistencia de varios alumnos de una
+// vez. Solo se aceptan alumnos matriculados en el curso de la sesion.
+func (a *App) marcarAsistenciasHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de sesion invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var marcar marcarAsistencia
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&marcar); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	sesion, ok := a.cargarSesion(w, r, id)
+	if !ok {
+		return
+	}
+	alumnos, err := models.GetAlumnosCurso(a.DB, sesion.CursoId)
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- models.GetAlumnosCurso", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	matriculados := map[int]bool{}
+	for _, al := range alumnos {
+		matriculados[al.ID] = true
+	}
+
+	if marcar.Todos != "" && !models.EstadosAsistencia[marcar.Todos] {
+		log.Printf("PUT %s code: %d ERROR: estado %s", r.RequestURI,
+			http.StatusBadRequest, marcar.Todos)
+		respondWithError(w, http.StatusBadRequest, "Estado de asistencia invalido")
+		return
+	}
+
+	// la asistencia de cada alumno queda en su posicion de la lista
+	indice := map[int]int{}
+	asistencias := []models.Asistencia{}
+	if marcar.Todos != "" {
+		for _, al := range alumnos {
+			indice[al.ID] = len(asistencias)
+			asistencias = append(asistencias, models.Asistencia{AlumnoId: al.ID, Estado: marcar.Todos})
+		}
+	}
+	for _, as := range marcar.Asistencias {
+		if !models.EstadosAsistencia[as.Estado] {
+			log.Printf("PUT %s code: %d ERROR: estado %s", r.RequestURI,
+				http.StatusBadRequest, as.Estado)
+			respondWithError(w, http.StatusBadRequest, "Estado de asistencia invalido")
+			return
+		}
+		if !matriculados[as.AlumnoId] {
+			log.Printf("PUT %s code: %d ERROR: alumno %d no matriculado", r.RequestURI,
+				http.StatusBadRequest, as.AlumnoId)
+			respondWithError(w, http.StatusBadRequest,
+				fmt.Sprintf("El alumno %d no esta matriculado en el curso", as.AlumnoId))
+			return
+		}
+		if i, ok := indice[as.AlumnoId]; ok {
+			asistencias[i] = as
+		} else {
+			indice[as.AlumnoId] = len(asistencias)
+			asistencias = append(asistencias, as)
+		}
+	}
+
+	usuarioId := getUserId(r)
+	for i := range asistencias {
+		asistencias[i].ID = 0
+		asistencias[i].SesionId = sesion.ID
+		asistencias[i].UsuarioId = usuarioId
+	}
+	if err := models.GuardarAsistencias(a.DB, asistencias); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- models.GuardarAsistencias", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, asistencias)
+	return
+}
+
+// getAsistenciaCursoHandler devuelve el porcentaje de asistencia de cada
+// matricula. El profesor ve a todos los alumnos y el alumno solo a si mismo.
+func (a *App) getAsistenciaCursoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+
+	alumnoId := 0
+	profesor := models.Profesor{UsuarioId: getUserId(r)}
+	if profesor.GetProfesorByUsuario(a.DB) != nil {
+		alumno, ok := a.alumnoAutenticado(w, r)
+		if !ok {
+			return
+		}
+		alumnoId = alumno.ID
+	}
+
+	resumenes, err := models.GetResumenAsistenciaCurso(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetResumenAsistenciaCurso", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if alumnoId != 0 {
+		propios := []models.ResumenAsistencia{}
+		for _, ra := range resumenes {
+			if ra.Alumno.ID == alumnoId {
+				propios = append(propios, ra)
+			}
+		}
+		resumenes = propios
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, resumenes)
+	return
+}
+
+func (a *App) cargarSesion(w http.ResponseWriter, r *http.Request, id int) (models.Sesion, bool) {
+	sesion := models.Sesion{ID: id}
+	if err := sesion.GetSesion(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("%s %s code: %d ERROR: %s -- no rows", r.Method, r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Sesion no encontrada")
+		default:
+			log.Printf("%s %s code: %d ERROR: %s -- sesion.GetSesion", r.Method, r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return sesion, false
+	}
+	return sesion, true
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestCreateSesion(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"fecha": "2022-06-20T08:00:00-05:00", "tema": "introduccion"}`)
+	req, _ := http.NewRequest("POST", "/cursos/1/sesiones", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["cursoId"] != 1.0 || m["tema"] != "introduccion" {
+		t.Errorf("Expected sesion of curso 1 with tema 'introduccion'. Got '%v'", m)
+	}
+
+	// sin fecha no hay sesion
+	jsonStr = []byte(`{"tema": "repaso"}`)
+	req, _ = http.NewRequest("POST", "/cursos/1/sesiones", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestMarcarAsistencias(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddAlumnoCursos(3, a.DB)
+	utils.AddSesiones(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	// todos presentes salvo el alumno 2
+	jsonStr := []byte(`
+	{
+		"todos": "presente",
+		"asistencias": [{"alumnoId": 2, "estado": "tarde", "observacion": "15 minutos"}]
+	}`)
+	req, _ := http.NewRequest("PUT", "/sesiones/1/asistencias", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if len(m) != 3 {
+		t.Fatalf("Se esperaban 3 asistencias. Se obtuvo %v", m)
+	}
+	for _, as := range m {
+		esperado := "presente"
+		if as["alumnoId"] == 2.0 {
+			esperado = "tarde"
+		}
+		if as["estado"] != esperado {
+			t.Errorf("Expected alumno %v to be '%s'. Got '%v'", as["alumnoId"], esperado, as["estado"])
+		}
+	}
+
+	req, _ = http.NewRequest("GET", "/cursos/1/asistencia", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if len(m) != 3 || m[0]["porcentaje"] != 100.0 {
+		t.Errorf("Se esperaba 100%% de asistencia para los 3 alumnos. Se obtuvo %v", m)
+	}
+}
+
+func TestMarcarAsistenciasInvalidas(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddAlumnoCursos(1, a.DB)
+	utils.AddSesiones(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	casos := []string{
+		`{"todos": "dormido"}`,
+		`{"asistencias": [{"alumnoId": 1, "estado": "faltó"}]}`,
+		// el alumno 2 no esta matriculado
+		`{"asistencias": [{"alumnoId": 2, "estado": "presente"}]}`,
+	}
+	for _, caso := range casos {
+		req, _ := http.NewRequest("PUT", "/sesiones/1/asistencias", bytes.NewBufferString(caso))
+		req.Header.Set("Authorization", token_str)
+		req.Header.Set("Content-Type", "application/json")
+		response := executeRequest(req, a)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestCategoriaAsistencia(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"nombre": "Asistencia", "tipo": "asistencia", "peso": 10}`)
+	req, _ := http.NewRequest("POST", "/cursos/1/categorias", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	req, _ = http.NewRequest("POST", "/cursos/1/categorias", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusConflict, response.Code)
+}
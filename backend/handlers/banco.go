@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+func (a *App) getBancosHandler(w http.ResponseWriter, r *http.Request) {
+	bancos, err := models.GetBancos(a.DB)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetBancos", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, bancos)
+	return
+}
+
+func (a *App) createBancoHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var banco models.BancoPreguntas
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&banco); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if banco.Nombre == "" {
+		log.Printf("POST %s code: %d ERROR: banco sin nombre", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "El banco debe tener nombre")
+		return
+	}
+
+	if err := banco.CreateBanco(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- banco.CreateBanco", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, banco)
+	return
+}
+
+// getPreguntasBancoHandler devuelve las preguntas del banco con sus
+// alternativas
+func (a *App) getPreguntasBancoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de banco invalido")
+		return
+	}
+
+	banco := models.BancoPreguntas{ID: id}
+	if err := banco.GetBanco(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Banco de preguntas no encontrado")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- banco.GetBanco", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	preguntas, err := models.GetPreguntasBanco(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetPreguntasBanco", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, preguntas)
+	return
+}
+
+func (a *App) deleteBancoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de banco invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	banco := models.BancoPreguntas{ID: id}
+	if err := banco.DeleteBanco(a.DB); err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- banco.DeleteBanco", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("DELETE %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": banco.ID})
+	return
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestCreateBanco(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"nombre": "historia"}`)
+	req, _ := http.NewRequest("POST", "/bancos", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m["nombre"] != "historia" {
+		t.Errorf("Expected banco nombre to be 'historia'. Got '%v'", m["nombre"])
+	}
+}
+
+func TestImportarPreguntasGIFT(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddBancosPreguntas(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	gift := []byte("::Capital:: Capital del Peru? {=Lima ~Cusco}\n\n" +
+		"::Agua:: El agua hierve a 100 C {T}\n\n" +
+		"::Parejas:: Une {=a -> 1 =b -> 2}\n")
+	req, _ := http.NewRequest("POST", "/preguntas/import?format=gift&bancoId=1", bytes.NewBuffer(gift))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "text/plain")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m["importadas"] != 2.0 {
+		t.Errorf("Expected 2 importadas. Got '%v'", m["importadas"])
+	}
+	omitidas, _ := m["omitidas"].([]interface{})
+	if len(omitidas) != 1 {
+		t.Errorf("Expected 1 omitida. Got '%v'", m["omitidas"])
+	}
+
+	req, _ = http.NewRequest("GET", "/bancos/1/preguntas", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var preguntas []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &preguntas)
+	if len(preguntas) != 2 || preguntas[1]["tipo"] != "truefalse" {
+		t.Errorf("Expected 2 preguntas, the second truefalse. Got '%v'", preguntas)
+	}
+}
+
+func TestImportarPreguntasSinDestino(t *testing.T) {
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("POST", "/preguntas/import?format=gift",
+		bytes.NewBufferString("Pregunta {T}"))
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/blackadress/vaula/models"
+)
+
+// buscarHandler busca ?q= en cursos, preguntas, trabajos, alumnos y
+// profesores. Cada grupo solo trae lo que el usuario autenticado puede
+// ver; ?limite= acota los resultados por grupo.
+func (a *App) buscarHandler(w http.ResponseWriter, r *http.Request) {
+	texto := strings.TrimSpace(r.URL.Query().Get("q"))
+	if texto == "" {
+		log.Printf("GET %s code: %d ERROR: q vacio", r.RequestURI, http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "Falta el texto a buscar")
+		return
+	}
+
+	limite := models.LimiteBusquedaDefecto
+	if v := r.URL.Query().Get("limite"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Printf("GET %s code: %d ERROR: limite %q -- strconv", r.RequestURI,
+				http.StatusBadRequest, v)
+			respondWithError(w, http.StatusBadRequest, "Limite invalido")
+			return
+		}
+		if n > models.LimiteBusquedaMax {
+			n = models.LimiteBusquedaMax
+		}
+		limite = n
+	}
+
+	resultados, err := models.Buscar(a.DB, getUserId(r), texto, limite)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.Buscar", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, resultados)
+	return
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestBuscarSinTexto(t *testing.T) {
+	ensureAuthorizedUserExists()
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", "/search?q=%20", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestBuscarProfesorDelCurso(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	matricularAlumnoPrueba(alumno, 1)
+	miembroCursoPrueba("docente", models.RolProfesor, 1)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", "/search?q=docente&limite=5", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var resultados models.ResultadosBusqueda
+	json.Unmarshal(response.Body.Bytes(), &resultados)
+	if len(resultados.Profesores) != 1 {
+		t.Errorf("Se esperaba encontrar al profesor del curso. Se obtuvo %v", resultados.Profesores)
+	}
+	if resultados.Preguntas == nil || len(resultados.Alumnos) != 0 {
+		t.Errorf("Un alumno no deberia ver preguntas ni alumnos. Se obtuvo %v", resultados)
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/blackadress/vaula/calendario"
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// getCalendarioHandler devuelve la URL de suscripcion al calendario del
+// usuario autenticado
+func (a *App) getCalendarioHandler(w http.ResponseWriter, r *http.Request) {
+	token := models.TokenCalendario{UsuarioId: getUserId(r)}
+	if err := token.GetTokenCalendario(a.DB); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- token.GetTokenCalendario", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]string{"url": urlCalendario(token)})
+	return
+}
+
+// regenerarTokenCalendarioHandler invalida la URL anterior, por ejemplo si
+// el usuario la compartio por error
+func (a *App) regenerarTokenCalendarioHandler(w http.ResponseWriter, r *http.Request) {
+	token := models.TokenCalendario{UsuarioId: getUserId(r)}
+	if err := token.RegenerarTokenCalendario(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- token.RegenerarTokenCalendario", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]string{"url": urlCalendario(token)})
+	return
+}
+
+// getCalendarioIcsHandler sirve el calendario en formato iCalendar. El
+// token de la URL reemplaza a la cabecera Authorization, que las
+// aplicaciones de calendario no envian.
+func (a *App) getCalendarioIcsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	token := models.TokenCalendario{Token: vars["token"]}
+	if err := token.GetUsuarioPorToken(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: token de calendario desconocido", r.URL.Path,
+				http.StatusNotFound)
+			respondWithError(w, http.StatusNotFound, "Calendario no encontrado")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- token.GetUsuarioPorToken", r.URL.Path,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	eventos, err := models.GetEventosCalendario(a.DB, token.UsuarioId)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetEventosCalendario", r.URL.Path,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	vevents := make([]calendario.Evento, 0, len(eventos))
+	for _, e := range eventos {
+		vevents = append(vevents, eventoCalendario(e))
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="vaula.ics"`)
+	w.WriteHeader(http.StatusOK)
+	if err := calendario.Escribir(w, "Vaula", vevents, time.Now()); err != nil {
+		// la cabecera ya salio, solo queda registrar el error
+		log.Printf("GET %s code: %d ERROR: %s -- calendario.Escribir", r.URL.Path,
+			http.StatusOK, err.Error())
+		return
+	}
+	// la ruta no incluye el token en el log
+	log.Printf("GET /calendar/*.ics code: %d", http.StatusOK)
+}
+
+func urlCalendario(token models.TokenCalendario) string {
+	return fmt.Sprintf("/calendar/%s.ics", token.Token)
+}
+
+// eventoCalendario arma el VEVENT; el UID solo depende del tipo y el id
+// para que los cambios de fecha reemplacen el evento
+func eventoCalendario(e models.EventoCalendario) calendario.Evento {
+	ev := calendario.Evento{
+		UID:        fmt.Sprintf("%s-%d@vaula", e.Tipo, e.ID),
+		Inicio:     e.Inicio,
+		Modificado: e.UpdatedAt,
+	}
+	switch e.Tipo {
+	case "examen":
+		ev.Resumen = fmt.Sprintf("Examen: %s (%s)", e.Nombre, e.Curso)
+		if e.Fin != nil {
+			ev.Fin = *e.Fin
+		}
+	default:
+		ev.Resumen = fmt.Sprintf("Entrega: %s (%s)", e.Nombre, e.Curso)
+		ev.Descripcion = "Fecha limite de entrega del trabajo " + e.Nombre
+	}
+	return ev
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestCalendarioIcs(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	matricularAlumnoPrueba(alumno, 1)
+
+	ahora := time.Now()
+	trabajo := models.Trabajo{Descripcion: "Informe", CursoId: 1, Activo: true,
+		FechaInicio: ahora, FechaFinal: ahora.Add(48 * time.Hour)}
+	trabajo.CreateTrabajo(a.DB)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", "/calendario", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]string
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if !strings.HasPrefix(m["url"], "/calendar/") {
+		t.Fatalf("Se esperaba la URL del calendario. Se obtuvo %v", m)
+	}
+
+	// sin cabecera Authorization
+	req, _ = http.NewRequest("GET", m["url"], nil)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	if ct := response.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Errorf("Se esperaba text/calendar. Se obtuvo %s", ct)
+	}
+	uid := fmt.Sprintf("UID:trabajo-%d@vaula", trabajo.ID)
+	if body := response.Body.String(); !strings.Contains(body, uid) {
+		t.Errorf("Se esperaba %s en el calendario. Se obtuvo %s", uid, body)
+	}
+}
+
+func TestRegenerarTokenCalendario(t *testing.T) {
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", "/calendario", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	var anterior map[string]string
+	json.Unmarshal(response.Body.Bytes(), &anterior)
+
+	req, _ = http.NewRequest("POST", "/calendario/token", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var nuevo map[string]string
+	json.Unmarshal(response.Body.Bytes(), &nuevo)
+	if nuevo["url"] == anterior["url"] {
+		t.Fatalf("Se esperaba una URL nueva. Se obtuvo %v", nuevo)
+	}
+
+	req, _ = http.NewRequest("GET", anterior["url"], nil)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	req, _ = http.NewRequest("GET", nuevo["url"], nil)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+}
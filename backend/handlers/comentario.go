@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// getComentariosHandler devuelve la retroalimentacion de una entrega
+// organizada en hilos
+func (a *App) getComentariosHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de alumno trabajo invalido")
+		return
+	}
+
+	at := models.AlumnoTrabajo{ID: id}
+	if err := at.GetAlumnoTrabajo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Alumno trabajo no encontrado")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- at.GetAlumnoTrabajo", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	esProfesor, ok := a.accesoAlumnoTrabajo(w, r, at)
+	if !ok {
+		return
+	}
+
+	comentarios, err := models.GetComentarios(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetComentarios", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// hasta la devolucion el alumno solo ve sus propios comentarios
+	if !esProfesor && at.FechaDevolucion == nil {
+		propios := []models.Comentario{}
+		for _, c := range comentarios {
+			if c.UsuarioId == getUserId(r) {
+				propios = append(propios, c)
+			}
+		}
+		comentarios = propios
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, models.ArmarHilos(comentarios))
+	return
+}
+
+// createComentarioHandler recibe JSON, o multipart si el comentario lleva
+// un archivo adjunto en el campo 'archivo'. El profesor puede abrir hilos,
+// generales o sobre una pregunta; el alumno solo responde.
+func (a *App) createComentarioHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de alumno trabajo invalido")
+		return
+	}
+
+	at := models.AlumnoTrabajo{ID: id}
+	if err := at.GetAlumnoTrabajo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("POST %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Alumno trabajo no encontrado")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- at.GetAlumnoTrabajo", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	esProfesor, ok := a.accesoAlumnoTrabajo(w, r, at)
+	if !ok {
+		return
+	}
+
+	var comentario models.Comentario
+	var archivo *archivoSubido
+	if tipo, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); tipo == "multipart/form-data" {
+		archivo, ok = recibirArchivo(w, r, false)
+		if !ok {
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		if archivo != nil {
+			defer archivo.Close()
+		}
+		comentario.Texto = r.FormValue("texto")
+		comentario.PreguntaTrabajoId, _ = strconv.Atoi(r.FormValue("preguntaTrabajoId"))
+		comentario.RespuestaA, _ = strconv.Atoi(r.FormValue("respuestaA"))
+	} else {
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&comentario); err != nil {
+			log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+				http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusBadRequest, "Invalid payload")
+			return
+		}
+		defer r.Body.Close()
+	}
+
+	if comentario.Texto == "" && archivo == nil {
+		log.Printf("POST %s code: %d ERROR: comentario vacio", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "El comentario debe tener texto o un archivo")
+		return
+	}
+	if !esProfesor && comentario.RespuestaA == 0 {
+		log.Printf("POST %s code: %d ERROR: alumno sin respuestaA", r.RequestURI,
+			http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "El alumno solo puede responder comentarios")
+		return
+	}
+	if msg := a.validarHiloComentario(&comentario, at); msg != "" {
+		log.Printf("POST %s code: %d ERROR: %s -- validarHiloComentario", r.RequestURI,
+			http.StatusBadRequest, msg)
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	comentario.AlumnoTrabajoId = at.ID
+	comentario.UsuarioId = getUserId(r)
+	if archivo != nil {
+		comentario.NombreArchivo = archivo.Nombre
+		comentario.MimeType = archivo.MimeType
+		comentario.Tamano = archivo.Tamano
+		comentario.Clave = fmt.Sprintf("trabajos/%d/alumnos/%d/comentarios/%d-%s",
+			at.TrabajoId, at.AlumnoId, time.Now().UnixNano(), archivo.Nombre)
+
+		err := a.Storage.Put(r.Context(), comentario.Clave, archivo,
+			comentario.Tamano, comentario.MimeType)
+		if err != nil {
+			log.Printf("POST %s code: %d ERROR: %s -- Storage.Put", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, "No se pudo guardar el archivo")
+			return
+		}
+	}
+
+	if err := comentario.CreateComentario(a.DB); err != nil {
+		if comentario.TieneAdjunto() {
+			a.Storage.Delete(r.Context(), comentario.Clave)
+		}
+		log.Printf("POST %s code: %d ERROR: %s -- comentario.CreateComentario", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	comentario.Respuestas = []models.Comentario{}
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, comentario)
+	return
+}
+
+// validarHiloComentario comprueba que la respuesta o la pregunta del
+// comentario correspondan a la entrega. Las respuestas toman la pregunta
+// del comentario original. Devuelve "" si es valido.
+func (a *App) validarHiloComentario(c *models.Comentario, at models.AlumnoTrabajo) string {
+	if c.RespuestaA != 0 {
+		original := models.Comentario{ID: c.RespuestaA}
+		if err := original.GetComentario(a.DB); err != nil || original.AlumnoTrabajoId != at.ID {
+			return "El comentario al que responde no pertenece a esta entrega"
+		}
+		c.PreguntaTrabajoId = original.PreguntaTrabajoId
+		return ""
+	}
+	if c.PreguntaTrabajoId != 0 {
+		pregunta := models.PreguntaTrabajo{ID: c.PreguntaTrabajoId}
+		if err := pregunta.GetPreguntaTrabajo(a.DB); err != nil || pregunta.TrabajoId != at.TrabajoId {
+			return "La pregunta no pertenece al trabajo"
+		}
+	}
+	return ""
+}
+
+// getEnlaceComentarioHandler devuelve un enlace firmado para descargar el
+// adjunto de un comentario
+func (a *App) getEnlaceComentarioHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de comentario invalido")
+		return
+	}
+
+	comentario := models.Comentario{ID: id}
+	if err := comentario.GetComentario(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Comentario no encontrado")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- comentario.GetComentario", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if !comentario.TieneAdjunto() {
+		log.Printf("GET %s code: %d ERROR: comentario sin adjunto", r.RequestURI,
+			http.StatusNotFound)
+		respondWithError(w, http.StatusNotFound, "El comentario no tiene archivo")
+		return
+	}
+
+	at := models.AlumnoTrabajo{ID: comentario.AlumnoTrabajoId}
+	if err := at.GetAlumnoTrabajo(a.DB); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- at.GetAlumnoTrabajo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if _, ok := a.accesoAlumnoTrabajo(w, r, at); !ok {
+		return
+	}
+
+	url, expira := enlaceFirmado(fmt.Sprintf("/comentarios/%d/archivo", comentario.ID))
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"url":    url,
+		"expira": expira,
+	})
+	return
+}
+
+func (a *App) descargarComentarioHandler(w http.ResponseWriter, r *http.Request) {
+	if !validarEnlace(r) {
+		log.Printf("GET %s code: %d ERROR: enlace invalido o vencido", r.URL.Path,
+			http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "Enlace invalido o vencido")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	comentario := models.Comentario{ID: id}
+	if err := comentario.GetComentario(a.DB); err != nil || !comentario.TieneAdjunto() {
+		log.Printf("GET %s code: %d ERROR: comentario sin archivo", r.URL.Path,
+			http.StatusNotFound)
+		respondWithError(w, http.StatusNotFound, "Archivo no encontrado")
+		return
+	}
+
+	archivo, err := a.Storage.Get(r.Context(), comentario.Clave)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- Storage.Get", r.URL.Path,
+			http.StatusNotFound, err.Error())
+		respondWithError(w, http.StatusNotFound, "Archivo no encontrado")
+		return
+	}
+	defer archivo.Close()
+
+	err = servirArchivo(w, archivo, comentario.NombreArchivo, comentario.MimeType, comentario.Tamano)
+	if err != nil {
+		log.Printf("GET %s ERROR: %s -- servirArchivo", r.URL.Path, err.Error())
+		return
+	}
+	log.Printf("GET %s code: %d", r.URL.Path, http.StatusOK)
+}
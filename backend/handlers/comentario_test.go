@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestComentariosProfesor(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddEntregas(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	// un profesor que no es del curso no puede comentar la entrega
+	jsonStr := []byte(`{"texto": "buena introduccion"}`)
+	req, _ := http.NewRequest("POST", "/alumnoTrabajos/1/comentarios", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	asignarProfesorPrueba(1)
+	req, _ = http.NewRequest("POST", "/alumnoTrabajos/1/comentarios", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	// respuesta con un archivo de retroalimentacion adjunto
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("texto", "ver anotaciones")
+	writer.WriteField("respuestaA", "1")
+	part, _ := writer.CreateFormFile("archivo", "anotaciones.txt")
+	part.Write([]byte("anotaciones del profesor"))
+	writer.Close()
+
+	req, _ = http.NewRequest("POST", "/alumnoTrabajos/1/comentarios", body)
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["nombreArchivo"] != "anotaciones.txt" {
+		t.Errorf("Expected nombreArchivo to be 'anotaciones.txt'. Got '%v'", m["nombreArchivo"])
+	}
+
+	req, _ = http.NewRequest("GET", "/alumnoTrabajos/1/comentarios", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var hilos []models.Comentario
+	json.Unmarshal(response.Body.Bytes(), &hilos)
+	if len(hilos) != 1 || len(hilos[0].Respuestas) != 1 {
+		t.Errorf("Expected 1 hilo with 1 respuesta. Got '%v'", hilos)
+	}
+
+	req, _ = http.NewRequest("GET", "/comentarios/2/enlace", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var enlace map[string]string
+	json.Unmarshal(response.Body.Bytes(), &enlace)
+	req, _ = http.NewRequest("GET", enlace["url"], nil)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	if response.Body.String() != "anotaciones del profesor" {
+		t.Errorf("Expected the adjunto content. Got '%s'", response.Body.String())
+	}
+}
+
+func TestComentariosAlumnoDevolucion(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	utils.AddTrabajos(1, a.DB)
+
+	entrega := models.Entrega{TrabajoId: 1, AlumnoId: alumno.ID, NombreArchivo: "a.txt",
+		MimeType: "text/plain", Tamano: 1, Clave: "test/a.txt", FechaEntrega: time.Now()}
+	entrega.CreateEntrega(a.DB)
+	comentario := models.Comentario{AlumnoTrabajoId: entrega.AlumnoTrabajoId, UsuarioId: 1, Texto: "falta bibliografia"}
+	comentario.CreateComentario(a.DB)
+	at := models.AlumnoTrabajo{ID: entrega.AlumnoTrabajoId}
+	at.Devolver(a.DB)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+	url := fmt.Sprintf("/alumnoTrabajos/%d/comentarios", at.ID)
+
+	// el alumno no abre hilos, solo responde
+	jsonStr := []byte(`{"texto": "hola"}`)
+	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	jsonStr = []byte(fmt.Sprintf(`{"texto": "agregada", "respuestaA": %d}`, comentario.ID))
+	req, _ = http.NewRequest("POST", url, bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	req, _ = http.NewRequest("GET", "/devoluciones", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var devoluciones []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &devoluciones)
+	if len(devoluciones) != 1 {
+		t.Errorf("Expected 1 devolucion pendiente. Got '%v'", devoluciones)
+	}
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/alumnoTrabajos/%d", at.ID), nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("GET", "/devoluciones", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	json.Unmarshal(response.Body.Bytes(), &devoluciones)
+	if len(devoluciones) != 0 {
+		t.Errorf("Expected the devolucion to be vista. Got '%v'", devoluciones)
+	}
+}
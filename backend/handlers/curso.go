@@ -42,7 +42,12 @@ func (a *App) getCursoByIdHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *App) getCursosHandler(w http.ResponseWriter, r *http.Request) {
-	cursos, err := models.GetCursos(a.DB)
+	c, ok := consultaListado(w, r, models.ListadoCursos)
+	if !ok {
+		return
+	}
+
+	cursos, total, err := models.GetCursos(a.DB, c)
 	if err != nil {
 		log.Printf("GET %s code: %d ERROR: %s -- models.GetCursos", r.RequestURI,
 			http.StatusInternalServerError, err.Error())
@@ -51,7 +56,7 @@ func (a *App) getCursosHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
-	respondWithJSON(w, http.StatusOK, cursos)
+	respondWithPagina(w, r, c, total, cursos)
 	return
 
 }
@@ -141,3 +146,63 @@ func (a *App) deleteCursoHandler(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": curso.ID})
 	return
 }
+
+// asignarProfesorHandler asigna un profesor al curso para que administre
+// sus anuncios y foros. Solo un profesor del curso puede asignar a otros;
+// un curso sin profesores lo puede tomar el primer profesor para si mismo.
+func (a *App) asignarProfesorHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+	profesor, ok := a.profesorAutenticado(w, r)
+	if !ok {
+		return
+	}
+
+	var asignacion models.ProfesorCurso
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&asignacion); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if asignacion.ProfesorId == 0 {
+		log.Printf("POST %s code: %d ERROR: asignacion sin profesorId", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "La asignacion debe indicar el profesorId")
+		return
+	}
+
+	tieneProfesores, err := models.CursoTieneProfesores(a.DB, id)
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- models.CursoTieneProfesores", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if tieneProfesores || asignacion.ProfesorId != profesor.ID {
+		if !a.profesorDelCurso(w, r, id) {
+			return
+		}
+	}
+
+	asignacion.CursoId = id
+	if err := asignacion.CreateProfesorCurso(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- asignacion.CreateProfesorCurso", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, asignacion)
+	return
+}
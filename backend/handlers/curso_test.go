@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/blackadress/vaula/models"
 	"github.com/blackadress/vaula/utils"
 )
 
@@ -120,6 +121,58 @@ func TestGetCurso(t *testing.T) {
 	checkResponseCode(t, http.StatusOK, response.Code)
 }
 
+func TestPatchCurso(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	var jsonStr = []byte(`{"activo": false}`)
+	req, _ := http.NewRequest("PATCH", "/cursos/1", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("GET", "/cursos/1", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m["activo"] != false {
+		t.Errorf("Se esperaba el curso inactivo. Se obtuvo %v", m["activo"])
+	}
+	if m["nombre"] != "curso_test_0" || m["siglas"] != "TS-00" {
+		t.Errorf("Los campos fuera del parche no deberian cambiar. Se obtuvo %v", m)
+	}
+
+	for _, parche := range []string{
+		`{"nombre": null}`,  // el curso resultante no es valido
+		`{"profesor": "x"}`, // campo desconocido
+		`{"activo": "no"}`,  // tipo incorrecto
+		`["activo"]`,        // no es un objeto
+	} {
+		req, _ = http.NewRequest("PATCH", "/cursos/1", bytes.NewBufferString(parche))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.Header.Set("Authorization", token_str)
+		response = executeRequest(req, a)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+
+	req, _ = http.NewRequest("PATCH", "/cursos/1", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusUnsupportedMediaType, response.Code)
+}
+
 func TestUpdateCurso(t *testing.T) {
 	utils.ClearTableCurso(a.DB)
 	utils.ClearTableUsuario(a.DB)
@@ -221,3 +274,30 @@ func TestDeleteCurso(t *testing.T) {
 	response = executeRequest(req, a)
 	checkResponseCode(t, http.StatusOK, response.Code)
 }
+
+func TestAsignarProfesorAjeno(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(2, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+	miembroCursoPrueba("docente", models.RolProfesor, 1)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+	profesor := models.Profesor{UsuarioId: token.UserId}
+	profesor.GetProfesorByUsuario(a.DB)
+
+	// el curso 1 ya tiene profesor y el de prueba no es parte de el
+	jsonStr := []byte(fmt.Sprintf(`{"profesorId": %d}`, profesor.ID))
+	req, _ := http.NewRequest("POST", "/cursos/1/profesores", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	// el curso 2 no tiene profesores, el primero puede tomarlo
+	req, _ = http.NewRequest("POST", "/cursos/2/profesores", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// duracion de los enlaces de descarga firmados
+const duracionEnlace = 15 * time.Minute
+
+// enlaceFirmado genera una URL de descarga para 'path' que vence en
+// duracionEnlace y no necesita la cabecera Authorization
+func enlaceFirmado(path string) (string, time.Time) {
+	expira := time.Now().Add(duracionEnlace)
+	exp := strconv.FormatInt(expira.Unix(), 10)
+	return fmt.Sprintf("%s?expira=%s&firma=%s", path, exp, firmarEnlace(path, exp)), expira
+}
+
+// validarEnlace comprueba la firma y la vigencia de un enlace generado
+// por enlaceFirmado
+func validarEnlace(r *http.Request) bool {
+	exp := r.URL.Query().Get("expira")
+	firma := r.URL.Query().Get("firma")
+	expira, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > expira {
+		return false
+	}
+	esperada := firmarEnlace(r.URL.Path, exp)
+	return hmac.Equal([]byte(firma), []byte(esperada))
+}
+
+func firmarEnlace(path, exp string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("SECRET_KEY")))
+	mac.Write([]byte(path + "\n" + exp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// servirArchivo copia el archivo al cliente como adjunto
+func servirArchivo(w http.ResponseWriter, body io.Reader, nombre, mimeType string, tamano int64) error {
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Length", strconv.FormatInt(tamano, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", nombre))
+	w.WriteHeader(http.StatusOK)
+	_, err := io.Copy(w, body)
+	return err
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// tipos de archivo que se aceptan como entrega, detectados por contenido
+// y no por la extension o la cabecera que envia el cliente
+var tiposEntregaPermitidos = map[string]bool{
+	"application/pdf":    true,
+	"application/zip":    true, // tambien docx, odt, xlsx
+	"application/x-gzip": true,
+	"text/plain":         true,
+	"image/png":          true,
+	"image/jpeg":         true,
+}
+
+// maxEntregaBytes lee el limite de MAX_UPLOAD_MB, por defecto 20MB
+func maxEntregaBytes() int64 {
+	mb, err := strconv.Atoi(os.Getenv("MAX_UPLOAD_MB"))
+	if err != nil || mb <= 0 {
+		mb = 20
+	}
+	return int64(mb) << 20
+}
+
+var caracteresNoSeguros = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func nombreSeguro(nombre string) string {
+	nombre = caracteresNoSeguros.ReplaceAllString(filepath.Base(nombre), "_")
+	if nombre == "" || nombre == "." || nombre == ".." {
+		return "archivo"
+	}
+	return nombre
+}
+
+func (a *App) createEntregaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de trabajo invalido")
+		return
+	}
+
+	alumno, ok := a.alumnoAutenticado(w, r)
+	if !ok {
+		return
+	}
+
+	trabajo, limite, ok := a.trabajoAbierto(w, r, id, alumno.ID)
+	if !ok {
+		return
+	}
+	now := time.Now()
+
+	archivo, ok := recibirArchivo(w, r, true)
+	if !ok {
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	defer archivo.Close()
+
+	entrega := models.Entrega{
+		TrabajoId:     trabajo.ID,
+		AlumnoId:      alumno.ID,
+		NombreArchivo: archivo.Nombre,
+		MimeType:      archivo.MimeType,
+		Tamano:        archivo.Tamano,
+		Clave: fmt.Sprintf("trabajos/%d/alumnos/%d/%d-%s",
+			trabajo.ID, alumno.ID, now.UnixNano(), archivo.Nombre),
+		FechaEntrega: now,
+		Tardio:       trabajo.CalcularTardanza(now, limite).Tardio,
+	}
+
+	err = a.Storage.Put(r.Context(), entrega.Clave, archivo, entrega.Tamano, entrega.MimeType)
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- Storage.Put", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, "No se pudo guardar el archivo")
+		return
+	}
+
+	if err := entrega.CreateEntrega(a.DB); err != nil {
+		// no dejar archivos huerfanos en el storage
+		a.Storage.Delete(r.Context(), entrega.Clave)
+		log.Printf("POST %s code: %d ERROR: %s -- entrega.CreateEntrega", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, entrega)
+	return
+}
+
+// trabajoAbierto carga el trabajo y verifica que reciba entregas del
+// alumno, tomando en cuenta su prorroga. Devuelve tambien la fecha limite
+// del alumno para marcar las entregas tardias.
+func (a *App) trabajoAbierto(w http.ResponseWriter, r *http.Request, trabajoId, alumnoId int) (models.Trabajo, time.Time, bool) {
+	trabajo := models.Trabajo{ID: trabajoId}
+	if err := trabajo.GetTrabajo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("%s %s code: %d ERROR: %s -- no rows", r.Method, r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Trabajo no encontrado")
+		default:
+			log.Printf("%s %s code: %d ERROR: %s -- trabajo.GetTrabajo", r.Method, r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return trabajo, time.Time{}, false
+	}
+
+	prorroga := models.Prorroga{AlumnoId: alumnoId, TrabajoId: trabajo.ID}
+	if err := prorroga.GetProrrogaTrabajo(a.DB); err != nil {
+		log.Printf("%s %s code: %d ERROR: %s -- prorroga.GetProrrogaTrabajo", r.Method, r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return trabajo, time.Time{}, false
+	}
+
+	now := time.Now()
+	limite := trabajo.FechaFinalPara(prorroga)
+	if !trabajo.Activo || now.Before(trabajo.FechaInicio) || !trabajo.AceptaEntrega(now, limite) {
+		log.Printf("%s %s code: %d ERROR: trabajo fuera de plazo", r.Method, r.RequestURI,
+			http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "El trabajo no recibe entregas")
+		return trabajo, limite, false
+	}
+	return trabajo, limite, true
+}
+
+// archivoSubido es el archivo recibido en el campo 'archivo' de un
+// formulario multipart, ya validado
+type archivoSubido struct {
+	multipart.File
+	Nombre   string
+	MimeType string
+	Tamano   int64
+}
+
+// recibirArchivo parsea el formulario multipart y valida tamano y tipo del
+// campo 'archivo'. Si no es requerido y no se envio devuelve (nil, true).
+// Quien llama cierra el archivo y llama a r.MultipartForm.RemoveAll.
+func recibirArchivo(w http.ResponseWriter, r *http.Request, requerido bool) (*archivoSubido, bool) {
+	maxBytes := maxEntregaBytes()
+	// margen para las cabeceras del multipart
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1<<20)
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		log.Printf("%s %s code: %d ERROR: %s -- ParseMultipartForm", r.Method, r.RequestURI,
+			http.StatusRequestEntityTooLarge, err.Error())
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Archivo demasiado grande o payload invalido")
+		return nil, false
+	}
+
+	archivo, header, err := r.FormFile("archivo")
+	if err == http.ErrMissingFile && !requerido {
+		return nil, true
+	}
+	if err != nil {
+		r.MultipartForm.RemoveAll()
+		log.Printf("%s %s code: %d ERROR: %s -- FormFile", r.Method, r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Falta el campo 'archivo'")
+		return nil, false
+	}
+
+	// en los errores siguientes se libera lo que el llamador no recibira
+	falla := func() (*archivoSubido, bool) {
+		archivo.Close()
+		r.MultipartForm.RemoveAll()
+		return nil, false
+	}
+
+	if header.Size > maxBytes {
+		log.Printf("%s %s code: %d ERROR: archivo de %d bytes", r.Method, r.RequestURI,
+			http.StatusRequestEntityTooLarge, header.Size)
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Archivo demasiado grande")
+		return falla()
+	}
+
+	mimeType, err := detectarTipo(archivo)
+	if err != nil {
+		log.Printf("%s %s code: %d ERROR: %s -- detectarTipo", r.Method, r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return falla()
+	}
+	if base, _, _ := mime.ParseMediaType(mimeType); !tiposEntregaPermitidos[base] {
+		log.Printf("%s %s code: %d ERROR: tipo %s no permitido", r.Method, r.RequestURI,
+			http.StatusUnsupportedMediaType, mimeType)
+		respondWithError(w, http.StatusUnsupportedMediaType, "Tipo de archivo no permitido")
+		return falla()
+	}
+
+	return &archivoSubido{
+		File:     archivo,
+		Nombre:   nombreSeguro(header.Filename),
+		MimeType: mimeType,
+		Tamano:   header.Size,
+	}, true
+}
+
+// detectarTipo lee el inicio del archivo para deducir su tipo y lo
+// devuelve a la posicion inicial
+func detectarTipo(archivo io.ReadSeeker) (string, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(archivo, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := archivo.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// getEntregasTrabajoHandler lista todas las entregas para un profesor, y
+// solo el historial propio para un alumno
+func (a *App) getEntregasTrabajoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de trabajo invalido")
+		return
+	}
+
+	var entregas []models.Entrega
+	profesor := models.Profesor{UsuarioId: getUserId(r)}
+	if profesor.GetProfesorByUsuario(a.DB) == nil {
+		entregas, err = models.GetEntregasTrabajo(a.DB, id)
+	} else {
+		alumno, ok := a.alumnoAutenticado(w, r)
+		if !ok {
+			return
+		}
+		entregas, err = models.GetEntregasAlumno(a.DB, id, alumno.ID)
+	}
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetEntregas", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, entregas)
+	return
+}
+
+// entregaVisible carga la entrega del path y verifica que la pueda ver el
+// usuario autenticado: un profesor del curso o el alumno que la subio
+func (a *App) entregaVisible(w http.ResponseWriter, r *http.Request) (models.Entrega, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de entrega invalido")
+		return models.Entrega{}, false
+	}
+
+	entrega := models.Entrega{ID: id}
+	if err := entrega.GetEntrega(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Entrega no encontrada")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- entrega.GetEntrega", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return entrega, false
+	}
+
+	_, ok := a.accesoEntregaAlumno(w, r, entrega.TrabajoId, entrega.AlumnoId)
+	return entrega, ok
+}
+
+func (a *App) getEntregaByIdHandler(w http.ResponseWriter, r *http.Request) {
+	entrega, ok := a.entregaVisible(w, r)
+	if !ok {
+		return
+	}
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, entrega)
+	return
+}
+
+// getEnlaceEntregaHandler devuelve un enlace firmado y temporal para
+// descargar el archivo de la entrega
+func (a *App) getEnlaceEntregaHandler(w http.ResponseWriter, r *http.Request) {
+	entrega, ok := a.entregaVisible(w, r)
+	if !ok {
+		return
+	}
+
+	url, expira := enlaceFirmado(fmt.Sprintf("/entregas/%d/archivo", entrega.ID))
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"url":    url,
+		"expira": expira,
+	})
+	return
+}
+
+func (a *App) descargarEntregaHandler(w http.ResponseWriter, r *http.Request) {
+	if !validarEnlace(r) {
+		log.Printf("GET %s code: %d ERROR: enlace invalido o vencido", r.URL.Path,
+			http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "Enlace invalido o vencido")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	entrega := models.Entrega{ID: id}
+	if err := entrega.GetEntrega(a.DB); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- entrega.GetEntrega", r.URL.Path,
+			http.StatusNotFound, err.Error())
+		respondWithError(w, http.StatusNotFound, "Entrega no encontrada")
+		return
+	}
+
+	archivo, err := a.Storage.Get(r.Context(), entrega.Clave)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- Storage.Get", r.URL.Path,
+			http.StatusNotFound, err.Error())
+		respondWithError(w, http.StatusNotFound, "Archivo no encontrado")
+		return
+	}
+	defer archivo.Close()
+
+	err = servirArchivo(w, archivo, entrega.NombreArchivo, entrega.MimeType, entrega.Tamano)
+	if err != nil {
+		log.Printf("GET %s ERROR: %s -- servirArchivo", r.URL.Path, err.Error())
+		return
+	}
+	log.Printf("GET %s code: %d", r.URL.Path, http.StatusOK)
+}
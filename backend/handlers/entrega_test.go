@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/utils"
+)
+
+// addTrabajoAbierto crea un trabajo que recibe entregas ahora mismo
+func addTrabajoAbierto() models.Trabajo {
+	utils.AddCursos(1, a.DB)
+	trabajo := models.Trabajo{
+		Descripcion: "trabajo_abierto",
+		FechaInicio: time.Now().Add(-time.Hour),
+		FechaFinal:  time.Now().Add(time.Hour),
+		CursoId:     1,
+		Activo:      true,
+	}
+	if err := trabajo.CreateTrabajo(a.DB); err != nil {
+		panic(err)
+	}
+	return trabajo
+}
+
+func multipartArchivo(nombre string, contenido []byte) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("archivo", nombre)
+	part.Write(contenido)
+	writer.Close()
+	return body, writer.FormDataContentType()
+}
+
+func TestCreateEntrega(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedAlumnoExists()
+	trabajo := addTrabajoAbierto()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	body, contentType := multipartArchivo("../mi informe.txt", []byte("contenido del informe"))
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/trabajos/%d/entregas", trabajo.ID), body)
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", contentType)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m["nombreArchivo"] != "mi_informe.txt" {
+		t.Errorf("Expected nombreArchivo to be 'mi_informe.txt'. Got '%v'", m["nombreArchivo"])
+	}
+
+	if m["version"] != 1.0 {
+		t.Errorf("Expected version to be '1'. Got '%v'", m["version"])
+	}
+
+	if _, ok := m["clave"]; ok {
+		t.Errorf("Expected clave not to be serialized. Got '%v'", m["clave"])
+	}
+}
+
+func TestCreateEntregaTipoNoPermitido(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedAlumnoExists()
+	trabajo := addTrabajoAbierto()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	// un ejecutable ELF renombrado como pdf
+	body, contentType := multipartArchivo("tarea.pdf", []byte("\x7fELF\x02\x01\x01\x00"))
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/trabajos/%d/entregas", trabajo.ID), body)
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", contentType)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusUnsupportedMediaType, response.Code)
+}
+
+func TestCreateEntregaFueraDePlazo(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	// los trabajos de prueba cerraron en junio de 2022
+	utils.AddTrabajos(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedAlumnoExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	body, contentType := multipartArchivo("informe.txt", []byte("tarde"))
+	req, _ := http.NewRequest("POST", "/trabajos/1/entregas", body)
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", contentType)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+}
+
+func TestDescargarEntrega(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddEntregas(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+	a.Storage.Put(context.Background(), "test/entrega_0.txt",
+		strings.NewReader("hola"), 4, "text/plain")
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	// un profesor que no es del curso no puede descargar la entrega
+	req, _ := http.NewRequest("GET", "/entregas/1/enlace", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	asignarProfesorPrueba(1)
+	req, _ = http.NewRequest("GET", "/entregas/1/enlace", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	url, _ := m["url"].(string)
+
+	// el enlace firmado no necesita la cabecera Authorization
+	req, _ = http.NewRequest("GET", url, nil)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	if response.Body.String() != "hola" {
+		t.Errorf("Se esperaba descargar 'hola'. Se obtuvo '%s'", response.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", url+"0", nil)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+}
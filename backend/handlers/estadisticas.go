@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+)
+
+// getEstadisticasExamenHandler devuelve el analisis de las preguntas del
+// examen: dificultad, discriminacion, frecuencia de cada alternativa y la
+// confiabilidad del examen. Solo lo ven los profesores del curso.
+func (a *App) getEstadisticasExamenHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de Examen invalido")
+		return
+	}
+	if _, ok := a.profesorDelExamen(w, r, id); !ok {
+		return
+	}
+
+	estadisticas, err := models.GetEstadisticasExamen(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetEstadisticasExamen", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, estadisticas)
+	return
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/blackadress/vaula/models"
 	"github.com/gorilla/mux"
@@ -21,6 +23,11 @@ func (a *App) getExamenByIdHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expansion, ok := expansionRespuesta(w, r, models.ExpandirCurso)
+	if !ok {
+		return
+	}
+
 	examen := models.Examen{ID: id}
 	err = examen.GetExamen(a.DB)
 	if err != nil {
@@ -36,13 +43,29 @@ func (a *App) getExamenByIdHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	examenes := []models.Examen{examen}
+	if err := models.ExpandirExamenes(a.DB, examenes, expansion); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.ExpandirExamenes", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
-	respondWithJSON(w, http.StatusOK, examen)
+	respondWithJSON(w, http.StatusOK, examenes[0])
 	return
 }
 
 func (a *App) getExamenesHandler(w http.ResponseWriter, r *http.Request) {
-	examenes, err := models.GetExamenes(a.DB)
+	c, ok := consultaListado(w, r, models.ListadoExamenes)
+	if !ok {
+		return
+	}
+	expansion, ok := expansionRespuesta(w, r, models.ExpandirCurso)
+	if !ok {
+		return
+	}
+
+	examenes, total, err := models.GetExamenes(a.DB, c)
 	if err != nil {
 		log.Printf("GET %s code: %d ERROR: %s -- models.GetExamenes", r.RequestURI,
 			http.StatusInternalServerError, err.Error())
@@ -50,8 +73,15 @@ func (a *App) getExamenesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := models.ExpandirExamenes(a.DB, examenes, expansion); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.ExpandirExamenes", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
-	respondWithJSON(w, http.StatusOK, examenes)
+	respondWithPagina(w, r, c, total, examenes)
 	return
 
 }
@@ -78,6 +108,9 @@ func (a *App) createExamenHandler(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if examen.Activo {
+		a.notificarExamen(examen)
+	}
 
 	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
 	respondWithJSON(w, http.StatusCreated, examen)
@@ -106,6 +139,9 @@ func (a *App) updateExamenHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	examen.ID = id
+	// solo se avisa a los alumnos cuando el examen pasa a estar activo
+	anterior := models.Examen{ID: id}
+	publicado := anterior.GetExamen(a.DB) == nil && !anterior.Activo && examen.Activo
 	err = examen.UpdateExamen(a.DB)
 	if err != nil {
 		log.Printf("PUT %s code: %d ERROR: %s examen.UpdateExamen", r.RequestURI,
@@ -113,6 +149,9 @@ func (a *App) updateExamenHandler(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if publicado {
+		a.notificarExamen(examen)
+	}
 
 	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
 	respondWithJSON(w, http.StatusOK, examen)
@@ -141,3 +180,91 @@ func (a *App) deleteExamenHandler(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": examen.ID})
 	return
 }
+
+// iniciarIntentoHandler abre un nuevo intento del alumno autenticado,
+// respetando la ventana del examen, la duracion y el numero de intentos
+// con la prorroga que el alumno tenga
+func (a *App) iniciarIntentoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de examen invalido")
+		return
+	}
+
+	alumno, ok := a.alumnoAutenticado(w, r)
+	if !ok {
+		return
+	}
+
+	examen := models.Examen{ID: id}
+	if err := examen.GetExamen(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("POST %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Examen no encontrado")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- examen.GetExamen", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	prorroga := models.Prorroga{AlumnoId: alumno.ID, ExamenId: examen.ID}
+	if err := prorroga.GetProrrogaExamen(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- prorroga.GetProrrogaExamen", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	condiciones := examen.CondicionesPara(prorroga)
+
+	now := time.Now()
+	if !examen.Activo || now.Before(condiciones.FechaInicio) || now.After(condiciones.FechaFinal) {
+		log.Printf("POST %s code: %d ERROR: examen fuera de plazo", r.RequestURI,
+			http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "El examen no esta disponible")
+		return
+	}
+
+	intento := models.AlumnoExamen{
+		AlumnoId:    alumno.ID,
+		ExamenId:    examen.ID,
+		FechaInicio: now,
+		FechaFinal:  condiciones.FinIntento(now),
+		Activo:      true,
+	}
+	if err := intento.IniciarIntento(a.DB, condiciones.Intentos); err != nil {
+		switch err {
+		case models.ErrIntentosAgotados:
+			log.Printf("POST %s code: %d ERROR: intentos agotados", r.RequestURI,
+				http.StatusForbidden)
+			respondWithError(w, http.StatusForbidden, "No quedan intentos disponibles")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- intento.IniciarIntento", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, intento)
+	return
+}
+
+// notificarExamen avisa a los alumnos del curso que el examen ya esta publicado
+func (a *App) notificarExamen(examen models.Examen) {
+	a.notificarCurso(examen.CursoId, models.Notificacion{
+		Tipo:   models.NotificacionExamen,
+		Titulo: "Nuevo examen: " + examen.Nombre,
+		Cuerpo: fmt.Sprintf("El examen %s estara disponible del %s al %s.",
+			examen.Nombre, examen.FechaInicio.Format(formatoFechaAviso),
+			examen.FechaFinal.Format(formatoFechaAviso)),
+		ReferenciaId: examen.ID,
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/blackadress/vaula/utils"
@@ -29,6 +30,81 @@ func TestEmptyExamenTable(t *testing.T) {
 	}
 }
 
+func TestGetExamenesPaginados(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddExamenes(3, a.DB)
+	ensureAuthorizedUserExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", "/examenes?activo=true&porPagina=1", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	if total := response.Header().Get("X-Total-Count"); total != "2" {
+		t.Errorf("Se esperaban 2 examenes activos. Se obtuvo %s", total)
+	}
+	if link := response.Header().Get("Link"); !strings.Contains(link,
+		`</examenes?activo=true&pagina=2&porPagina=1>; rel="next"`) {
+		t.Errorf("Se esperaba el enlace a la pagina 2. Se obtuvo %s", link)
+	}
+	var examenes []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &examenes)
+	if len(examenes) != 1 {
+		t.Errorf("Se esperaba un examen por pagina. Se obtuvo %v", examenes)
+	}
+
+	req, _ = http.NewRequest("GET", "/examenes?orden=password", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestGetExamenesExpandidos(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddExamenes(2, a.DB)
+	ensureAuthorizedUserExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", "/examenes", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	var examenes []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &examenes)
+	if _, ok := examenes[0]["curso"]; ok {
+		t.Errorf("El curso no expandido no deberia aparecer. Se obtuvo %v", examenes[0])
+	}
+
+	req, _ = http.NewRequest("GET", "/examenes?expand=curso", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+	examenes = nil
+	json.Unmarshal(response.Body.Bytes(), &examenes)
+	for _, e := range examenes {
+		curso, _ := e["curso"].(map[string]interface{})
+		if curso == nil || curso["id"] != e["cursoId"] {
+			t.Errorf("Se esperaba el curso %v expandido. Se obtuvo %v", e["cursoId"], e["curso"])
+		}
+	}
+
+	req, _ = http.NewRequest("GET", "/examenes/1?expand=usuario", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
 func TestGetNonExistentExamen(t *testing.T) {
 	utils.ClearTableExamen(a.DB)
 	utils.ClearTableUsuario(a.DB)
@@ -228,3 +304,93 @@ func TestDeleteExamen(t *testing.T) {
 	response = executeRequest(req, a)
 	checkResponseCode(t, http.StatusOK, response.Code)
 }
+
+func TestExportImportExamenQTI(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddExamenes(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	gift := []byte("::Capital:: Capital del Peru? {=Lima ~Cusco}\n\n" +
+		"::Agua:: El agua hierve a 100 C {T}\n")
+	req, _ := http.NewRequest("POST", "/preguntas/import?format=gift&examenId=1", bytes.NewBuffer(gift))
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	req, _ = http.NewRequest("GET", "/examenes/1/export?format=qti", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	if ct := response.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Expected Content-Type 'application/zip'. Got '%v'", ct)
+	}
+
+	req, _ = http.NewRequest("POST", "/examenes/import?format=qti&cursoId=1", bytes.NewBuffer(response.Body.Bytes()))
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m["importadas"] != 2.0 {
+		t.Errorf("Expected 2 importadas. Got '%v'", m["importadas"])
+	}
+}
+
+func TestExportExamenFormatoInvalido(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddExamenes(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", "/examenes/1/export?format=pdf", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestGetExamenPDF(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddExamenes(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	gift := []byte("::Capital:: Capital del Peru? {=Lima ~Cusco ~Arequipa}\n")
+	req, _ := http.NewRequest("POST", "/preguntas/import?format=gift&examenId=1", bytes.NewBuffer(gift))
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	req, _ = http.NewRequest("GET", "/examenes/1/pdf?version=clave&variantes=3", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	if !bytes.HasPrefix(response.Body.Bytes(), []byte("%PDF")) {
+		t.Errorf("Expected a PDF file")
+	}
+	if !bytes.Contains(response.Body.Bytes(), []byte("Variante C")) {
+		t.Errorf("Expected the PDF to contain variant C")
+	}
+
+	req, _ = http.NewRequest("GET", "/examenes/1/pdf?variantes=27", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
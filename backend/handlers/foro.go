@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// nuevoHilo es el cuerpo de POST /foros/{id}/hilos
+type nuevoHilo struct {
+	Titulo string `json:"titulo"`
+	Cuerpo string `json:"cuerpo"`
+}
+
+type hiloConPublicaciones struct {
+	models.Hilo
+	Publicaciones []models.Publicacion `json:"publicaciones"`
+}
+
+func (a *App) getForosCursoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+	if _, ok := a.rolEnCurso(w, r, id); !ok {
+		return
+	}
+
+	foros, err := models.GetForosCurso(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetForosCurso", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, foros)
+	return
+}
+
+func (a *App) createForoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+	if !a.profesorDelCurso(w, r, id) {
+		return
+	}
+
+	var foro models.Foro
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&foro); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if foro.Titulo == "" {
+		log.Printf("POST %s code: %d ERROR: foro sin titulo", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "El foro debe tener titulo")
+		return
+	}
+
+	foro.CursoId = id
+	if err := foro.CreateForo(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- foro.CreateForo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, foro)
+	return
+}
+
+func (a *App) deleteForoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de foro invalido")
+		return
+	}
+
+	foro, ok := a.cargarForo(w, r, id)
+	if !ok {
+		return
+	}
+	if !a.profesorDelCurso(w, r, foro.CursoId) {
+		return
+	}
+
+	if err := foro.DeleteForo(a.DB); err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- foro.DeleteForo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("DELETE %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": foro.ID})
+	return
+}
+
+func (a *App) getHilosForoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de foro invalido")
+		return
+	}
+
+	foro, ok := a.cargarForo(w, r, id)
+	if !ok {
+		return
+	}
+	if _, ok := a.rolEnCurso(w, r, foro.CursoId); !ok {
+		return
+	}
+
+	hilos, err := models.GetHilosForo(a.DB, foro.ID, getUserId(r))
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetHilosForo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, hilos)
+	return
+}
+
+// createHiloHandler abre un hilo con su primera publicacion. Cualquier
+// miembro del curso puede abrir hilos.
+func (a *App) createHiloHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de foro invalido")
+		return
+	}
+
+	foro, ok := a.cargarForo(w, r, id)
+	if !ok {
+		return
+	}
+	if _, ok := a.rolEnCurso(w, r, foro.CursoId); !ok {
+		return
+	}
+
+	var nuevo nuevoHilo
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&nuevo); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if nuevo.Titulo == "" || nuevo.Cuerpo == "" {
+		log.Printf("POST %s code: %d ERROR: hilo incompleto", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "El hilo debe tener titulo y cuerpo")
+		return
+	}
+
+	hilo := models.Hilo{ForoId: foro.ID, CursoId: foro.CursoId, AutorId: getUserId(r), Titulo: nuevo.Titulo}
+	primera := models.Publicacion{Cuerpo: nuevo.Cuerpo}
+	if err := hilo.CreateHilo(a.DB, &primera); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- hilo.CreateHilo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// el autor ya leyo lo que publico
+	if err := models.MarcarLeido(a.DB, hilo.AutorId, models.TipoHilo, hilo.ID); err != nil {
+		log.Printf("POST %s ERROR: %s -- models.MarcarLeido", r.RequestURI, err.Error())
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, hiloConPublicaciones{
+		Hilo:          hilo,
+		Publicaciones: []models.Publicacion{primera},
+	})
+	return
+}
+
+// getHiloByIdHandler devuelve el hilo con sus publicaciones y lo marca
+// como leido
+func (a *App) getHiloByIdHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de hilo invalido")
+		return
+	}
+
+	hilo, ok := a.cargarHilo(w, r, id)
+	if !ok {
+		return
+	}
+	if _, ok := a.rolEnCurso(w, r, hilo.CursoId); !ok {
+		return
+	}
+
+	publicaciones, err := models.GetPublicacionesHilo(a.DB, hilo.ID)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetPublicacionesHilo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := models.MarcarLeido(a.DB, getUserId(r), models.TipoHilo, hilo.ID); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.MarcarLeido", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, hiloConPublicaciones{Hilo: hilo, Publicaciones: publicaciones})
+	return
+}
+
+// moderarHiloHandler permite a los profesores del curso renombrar, fijar
+// y bloquear el hilo
+func (a *App) moderarHiloHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de hilo invalido")
+		return
+	}
+
+	actual, ok := a.cargarHilo(w, r, id)
+	if !ok {
+		return
+	}
+	if !a.profesorDelCurso(w, r, actual.CursoId) {
+		return
+	}
+
+	var hilo models.Hilo
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&hilo); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if hilo.Titulo == "" {
+		hilo.Titulo = actual.Titulo
+	}
+	hilo.ID = actual.ID
+	hilo.CursoId = actual.CursoId
+	if err := hilo.ModerarHilo(a.DB); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- hilo.ModerarHilo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, hilo)
+	return
+}
+
+func (a *App) deleteHiloHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de hilo invalido")
+		return
+	}
+
+	hilo, ok := a.cargarHilo(w, r, id)
+	if !ok {
+		return
+	}
+	if !a.profesorDelCurso(w, r, hilo.CursoId) {
+		return
+	}
+
+	if err := hilo.DeleteHilo(a.DB); err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- hilo.DeleteHilo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("DELETE %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": hilo.ID})
+	return
+}
+
+// createPublicacionHandler responde en el hilo. En un hilo bloqueado solo
+// publican los profesores del curso.
+func (a *App) createPublicacionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de hilo invalido")
+		return
+	}
+
+	hilo, ok := a.cargarHilo(w, r, id)
+	if !ok {
+		return
+	}
+	rol, ok := a.rolEnCurso(w, r, hilo.CursoId)
+	if !ok {
+		return
+	}
+	if hilo.Bloqueado && rol != models.RolProfesor {
+		log.Printf("POST %s code: %d ERROR: hilo bloqueado", r.RequestURI,
+			http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "El hilo esta bloqueado")
+		return
+	}
+
+	var publicacion models.Publicacion
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&publicacion); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if publicacion.Cuerpo == "" {
+		log.Printf("POST %s code: %d ERROR: publicacion vacia", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "La publicacion no puede estar vacia")
+		return
+	}
+
+	publicacion.HiloId = hilo.ID
+	publicacion.AutorId = getUserId(r)
+	publicacion.Editado = false
+	if err := publicacion.CreatePublicacion(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- publicacion.CreatePublicacion", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := models.MarcarLeido(a.DB, publicacion.AutorId, models.TipoHilo, hilo.ID); err != nil {
+		log.Printf("POST %s ERROR: %s -- models.MarcarLeido", r.RequestURI, err.Error())
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, publicacion)
+	return
+}
+
+// updatePublicacionHandler permite al autor editar su publicacion; la
+// version anterior queda en el historial
+func (a *App) updatePublicacionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de publicacion invalido")
+		return
+	}
+
+	actual, hilo, ok := a.cargarPublicacion(w, r, id)
+	if !ok {
+		return
+	}
+	rol, ok := a.rolEnCurso(w, r, hilo.CursoId)
+	if !ok {
+		return
+	}
+	if actual.AutorId != getUserId(r) {
+		log.Printf("PUT %s code: %d ERROR: usuario no es el autor", r.RequestURI,
+			http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "Solo el autor puede editar la publicacion")
+		return
+	}
+	if hilo.Bloqueado && rol != models.RolProfesor {
+		log.Printf("PUT %s code: %d ERROR: hilo bloqueado", r.RequestURI,
+			http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "El hilo esta bloqueado")
+		return
+	}
+
+	var publicacion models.Publicacion
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&publicacion); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if publicacion.Cuerpo == "" {
+		log.Printf("PUT %s code: %d ERROR: publicacion vacia", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "La publicacion no puede estar vacia")
+		return
+	}
+
+	publicacion.ID = actual.ID
+	if err := publicacion.EditarPublicacion(a.DB, getUserId(r)); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- publicacion.EditarPublicacion", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, publicacion)
+	return
+}
+
+func (a *App) getHistorialPublicacionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de publicacion invalido")
+		return
+	}
+
+	publicacion, hilo, ok := a.cargarPublicacion(w, r, id)
+	if !ok {
+		return
+	}
+	if _, ok := a.rolEnCurso(w, r, hilo.CursoId); !ok {
+		return
+	}
+
+	ediciones, err := models.GetEdiciones(a.DB, models.TipoPublicacion, publicacion.ID)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetEdiciones", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, ediciones)
+	return
+}
+
+func (a *App) cargarForo(w http.ResponseWriter, r *http.Request, id int) (models.Foro, bool) {
+	foro := models.Foro{ID: id}
+	if err := foro.GetForo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("%s %s code: %d ERROR: %s -- no rows", r.Method, r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Foro no encontrado")
+		default:
+			log.Printf("%s %s code: %d ERROR: %s -- foro.GetForo", r.Method, r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return foro, false
+	}
+	return foro, true
+}
+
+func (a *App) cargarHilo(w http.ResponseWriter, r *http.Request, id int) (models.Hilo, bool) {
+	hilo := models.Hilo{ID: id}
+	if err := hilo.GetHilo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("%s %s code: %d ERROR: %s -- no rows", r.Method, r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Hilo no encontrado")
+		default:
+			log.Printf("%s %s code: %d ERROR: %s -- hilo.GetHilo", r.Method, r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return hilo, false
+	}
+	return hilo, true
+}
+
+// cargarPublicacion carga la publicacion y el hilo al que pertenece
+func (a *App) cargarPublicacion(w http.ResponseWriter, r *http.Request, id int) (models.Publicacion, models.Hilo, bool) {
+	publicacion := models.Publicacion{ID: id}
+	if err := publicacion.GetPublicacion(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("%s %s code: %d ERROR: %s -- no rows", r.Method, r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Publicacion no encontrada")
+		default:
+			log.Printf("%s %s code: %d ERROR: %s -- publicacion.GetPublicacion", r.Method, r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return publicacion, models.Hilo{}, false
+	}
+	hilo, ok := a.cargarHilo(w, r, publicacion.HiloId)
+	return publicacion, hilo, ok
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/utils"
+)
+
+// asignarProfesorPrueba asigna al profesor de prueba al curso
+func asignarProfesorPrueba(cursoId int) {
+	profesor := models.Profesor{UsuarioId: getTestJWT().UserId}
+	if err := profesor.GetProfesorByUsuario(a.DB); err != nil {
+		log.Fatalf("Error no se encuentra el profesor de prueba, %s", err)
+	}
+	pc := models.ProfesorCurso{ProfesorId: profesor.ID, CursoId: cursoId}
+	if err := pc.CreateProfesorCurso(a.DB); err != nil {
+		log.Fatalf("Error en el metodo CreateProfesorCurso, %s", err)
+	}
+}
+
+// matricularAlumnoPrueba matricula al alumno de prueba en el curso
+func matricularAlumnoPrueba(alumno models.Alumno, cursoId int) {
+	ac := models.AlumnoCurso{AlumnoId: alumno.ID, CursoId: cursoId, Activo: true,
+		FechaInicio: time.Now(), FechaFinal: time.Now()}
+	if err := ac.CreateAlumnoCurso(a.DB); err != nil {
+		log.Fatalf("Error en el metodo CreateAlumnoCurso, %s", err)
+	}
+}
+
+func TestCreateAnuncio(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+	asignarProfesorPrueba(1)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"titulo": "Bienvenidos", "cuerpo": "Primera clase el lunes", "fijado": true}`)
+	req, _ := http.NewRequest("POST", "/cursos/1/anuncios", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["autorId"] != float64(token.UserId) || m["fijado"] != true {
+		t.Errorf("Expected a pinned anuncio by user %d. Got '%v'", token.UserId, m)
+	}
+
+	jsonStr = []byte(`{"titulo": "Bienvenidos", "cuerpo": "Primera clase el martes", "fijado": true}`)
+	req, _ = http.NewRequest("PUT", "/anuncios/1", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("GET", "/anuncios/1/historial", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var historial []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &historial)
+	if len(historial) != 1 || historial[0]["cuerpoAnterior"] != "Primera clase el lunes" {
+		t.Errorf("Se esperaba la version anterior en el historial. Se obtuvo %v", historial)
+	}
+}
+
+func TestAnunciosSoloMiembros(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(2, a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	matricularAlumnoPrueba(alumno, 2)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", "/cursos/1/anuncios", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	req, _ = http.NewRequest("GET", "/cursos/2/anuncios", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	// un alumno no publica anuncios
+	jsonStr := []byte(`{"titulo": "Hola", "cuerpo": "Soy alumno"}`)
+	req, _ = http.NewRequest("POST", "/cursos/2/anuncios", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+}
+
+func TestCreateHiloYLeer(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	matricularAlumnoPrueba(alumno, 1)
+	foro := models.Foro{CursoId: 1, Titulo: "Consultas"}
+	foro.CreateForo(a.DB)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"titulo": "Duda", "cuerpo": "Hasta cuando se entrega?"}`)
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/foros/%d/hilos", foro.ID), bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var hilo map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &hilo)
+	publicaciones, _ := hilo["publicaciones"].([]interface{})
+	if hilo["titulo"] != "Duda" || len(publicaciones) != 1 {
+		t.Fatalf("Expected hilo 'Duda' with its first publicacion. Got '%v'", hilo)
+	}
+
+	// las publicaciones propias no quedan como no leidas
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/foros/%d/hilos", foro.ID), nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var hilos []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &hilos)
+	if len(hilos) != 1 || hilos[0]["noLeidas"] != 0.0 {
+		t.Errorf("Se esperaba el hilo sin publicaciones por leer. Se obtuvo %v", hilos)
+	}
+}
+
+func TestPublicarEnHiloBloqueado(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	matricularAlumnoPrueba(alumno, 1)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	foro := models.Foro{CursoId: 1, Titulo: "Consultas"}
+	foro.CreateForo(a.DB)
+	hilo := models.Hilo{ForoId: foro.ID, AutorId: token.UserId, Titulo: "Cerrado"}
+	hilo.CreateHilo(a.DB, &models.Publicacion{Cuerpo: "Tema resuelto"})
+	hilo.Bloqueado = true
+	hilo.ModerarHilo(a.DB)
+
+	jsonStr := []byte(`{"cuerpo": "Una duda mas"}`)
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/hilos/%d/publicaciones", hilo.ID), bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	// un alumno no modera hilos
+	jsonStr = []byte(`{"bloqueado": false}`)
+	req, _ = http.NewRequest("PUT", fmt.Sprintf("/hilos/%d", hilo.ID), bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+}
+
+func TestEditarPublicacion(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	matricularAlumnoPrueba(alumno, 1)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	foro := models.Foro{CursoId: 1, Titulo: "General"}
+	foro.CreateForo(a.DB)
+	hilo := models.Hilo{ForoId: foro.ID, AutorId: token.UserId, Titulo: "Presentaciones"}
+	primera := models.Publicacion{Cuerpo: "Hola"}
+	hilo.CreateHilo(a.DB, &primera)
+
+	jsonStr := []byte(`{"cuerpo": "Hola a todos"}`)
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("/publicaciones/%d", primera.ID), bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["editado"] != true || m["cuerpo"] != "Hola a todos" {
+		t.Errorf("Expected the edited publicacion. Got '%v'", m)
+	}
+}
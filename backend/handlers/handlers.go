@@ -7,14 +7,21 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/blackadress/vaula/correo"
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/storage"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/rs/cors"
 )
 
 type App struct {
-	Router *mux.Router
-	DB     *pgxpool.Pool
+	Router  *mux.Router
+	DB      *pgxpool.Pool
+	Storage storage.Storage
+	Mailer  correo.Mailer
+
+	eventos *centralEventos
 }
 
 func (a *App) Initialize(user, password, dbname string) {
@@ -30,6 +37,27 @@ func (a *App) Initialize(user, password, dbname string) {
 	}
 	log.Print("Si conecta con db")
 
+	a.Storage, err = storage.FromEnv()
+	if err != nil {
+		log.Fatalf("No se pudo configurar el storage de archivos: %v", err)
+	}
+
+	a.Mailer, err = correo.FromEnv()
+	if err != nil {
+		log.Fatalf("No se pudo configurar el envio de correos: %v", err)
+	}
+	// los correos que se estaban enviando al detenerse vuelven a la cola
+	if err := models.ReanudarEnvios(a.DB); err != nil {
+		log.Printf("No se pudieron reanudar los envios de correo: %v", err)
+	}
+
+	// los analisis de similitud corren en goroutines que no sobreviven
+	// a un reinicio
+	if err := models.InterrumpirAnalisis(a.DB); err != nil {
+		log.Printf("No se pudieron cerrar los analisis de similitud pendientes: %v", err)
+	}
+
+	a.eventos = nuevaCentralEventos()
 	a.Router = mux.NewRouter()
 	a.initializeRoutes()
 }
@@ -57,6 +85,7 @@ func (a *App) initializeRoutes() {
 	a.Router.Handle("/alumnos/{id:[0-9]+}", isAuthorized(a.getAlumnoByIdHandler)).Methods("GET")
 	a.Router.Handle("/alumnos", isAuthorized(a.getAlumnosHandler)).Methods("GET")
 	a.Router.Handle("/alumnos", isAuthorized(a.createAlumnoHandler)).Methods("POST")
+	a.Router.Handle("/alumnos/import", isAuthorized(a.importarAlumnosHandler)).Methods("POST")
 	a.Router.Handle("/alumnos/{id:[0-9]+}", isAuthorized(a.updateAlumnoHandler)).Methods("PUT")
 	a.Router.Handle("/alumnos/{id:[0-9]+}", isAuthorized(a.deleteAlumnoHandler)).Methods("DELETE")
 
@@ -66,13 +95,69 @@ func (a *App) initializeRoutes() {
 	a.Router.Handle("/cursos", isAuthorized(a.createCursoHandler)).Methods("POST")
 	a.Router.Handle("/cursos/{id:[0-9]+}", isAuthorized(a.updateCursoHandler)).Methods("PUT")
 	a.Router.Handle("/cursos/{id:[0-9]+}", isAuthorized(a.deleteCursoHandler)).Methods("DELETE")
+	a.Router.Handle("/cursos/{id:[0-9]+}/alumnos", isAuthorized(a.matricularAlumnoHandler)).Methods("POST")
+	a.Router.Handle("/cursos/{id:[0-9]+}/calificaciones", isAuthorized(a.getLibretaHandler)).Methods("GET")
+	a.Router.Handle("/cursos/{id:[0-9]+}/calificaciones/export", isAuthorized(a.exportLibretaHandler)).Methods("GET")
+	a.Router.Handle("/cursos/{id:[0-9]+}/calificaciones/celdas", isAuthorized(a.guardarCeldaHandler)).Methods("PUT")
+	a.Router.Handle("/cursos/{id:[0-9]+}/categorias", isAuthorized(a.getCategoriasCursoHandler)).Methods("GET")
+	a.Router.Handle("/cursos/{id:[0-9]+}/categorias", isAuthorized(a.createCategoriaHandler)).Methods("POST")
+	a.Router.Handle("/cursos/{id:[0-9]+}/sesiones", isAuthorized(a.getSesionesCursoHandler)).Methods("GET")
+	a.Router.Handle("/cursos/{id:[0-9]+}/sesiones", isAuthorized(a.createSesionHandler)).Methods("POST")
+	a.Router.Handle("/cursos/{id:[0-9]+}/asistencia", isAuthorized(a.getAsistenciaCursoHandler)).Methods("GET")
+	a.Router.Handle("/cursos/{id:[0-9]+}/profesores", isAuthorized(a.asignarProfesorHandler)).Methods("POST")
+	a.Router.Handle("/cursos/{id:[0-9]+}/anuncios", isAuthorized(a.getAnunciosCursoHandler)).Methods("GET")
+	a.Router.Handle("/cursos/{id:[0-9]+}/anuncios", isAuthorized(a.createAnuncioHandler)).Methods("POST")
+	a.Router.Handle("/cursos/{id:[0-9]+}/foros", isAuthorized(a.getForosCursoHandler)).Methods("GET")
+	a.Router.Handle("/cursos/{id:[0-9]+}/foros", isAuthorized(a.createForoHandler)).Methods("POST")
+	a.Router.Handle("/cursos/{id:[0-9]+}/conversaciones", isAuthorized(a.createConversacionHandler)).Methods("POST")
+
+	// libreta
+	a.Router.Handle("/categorias/{id:[0-9]+}", isAuthorized(a.updateCategoriaHandler)).Methods("PUT")
+	a.Router.Handle("/categorias/{id:[0-9]+}", isAuthorized(a.deleteCategoriaHandler)).Methods("DELETE")
+	a.Router.Handle("/categorias/{id:[0-9]+}/evaluaciones", isAuthorized(a.createEvaluacionManualHandler)).Methods("POST")
+	a.Router.Handle("/evaluacionesManuales/{id:[0-9]+}", isAuthorized(a.deleteEvaluacionManualHandler)).Methods("DELETE")
+	a.Router.Handle("/celdasManuales/{id:[0-9]+}", isAuthorized(a.deleteCeldaHandler)).Methods("DELETE")
+
+	// asistencia
+	a.Router.Handle("/sesiones/{id:[0-9]+}", isAuthorized(a.getSesionByIdHandler)).Methods("GET")
+	a.Router.Handle("/sesiones/{id:[0-9]+}", isAuthorized(a.updateSesionHandler)).Methods("PUT")
+	a.Router.Handle("/sesiones/{id:[0-9]+}", isAuthorized(a.deleteSesionHandler)).Methods("DELETE")
+	a.Router.Handle("/sesiones/{id:[0-9]+}/asistencias", isAuthorized(a.getAsistenciasSesionHandler)).Methods("GET")
+	a.Router.Handle("/sesiones/{id:[0-9]+}/asistencias", isAuthorized(a.marcarAsistenciasHandler)).Methods("PUT")
+
+	// anuncios y foros
+	a.Router.Handle("/anuncios/{id:[0-9]+}", isAuthorized(a.getAnuncioByIdHandler)).Methods("GET")
+	a.Router.Handle("/anuncios/{id:[0-9]+}", isAuthorized(a.updateAnuncioHandler)).Methods("PUT")
+	a.Router.Handle("/anuncios/{id:[0-9]+}", isAuthorized(a.deleteAnuncioHandler)).Methods("DELETE")
+	a.Router.Handle("/anuncios/{id:[0-9]+}/historial", isAuthorized(a.getHistorialAnuncioHandler)).Methods("GET")
+	a.Router.Handle("/foros/{id:[0-9]+}", isAuthorized(a.deleteForoHandler)).Methods("DELETE")
+	a.Router.Handle("/foros/{id:[0-9]+}/hilos", isAuthorized(a.getHilosForoHandler)).Methods("GET")
+	a.Router.Handle("/foros/{id:[0-9]+}/hilos", isAuthorized(a.createHiloHandler)).Methods("POST")
+	a.Router.Handle("/hilos/{id:[0-9]+}", isAuthorized(a.getHiloByIdHandler)).Methods("GET")
+	a.Router.Handle("/hilos/{id:[0-9]+}", isAuthorized(a.moderarHiloHandler)).Methods("PUT")
+	a.Router.Handle("/hilos/{id:[0-9]+}", isAuthorized(a.deleteHiloHandler)).Methods("DELETE")
+	a.Router.Handle("/hilos/{id:[0-9]+}/publicaciones", isAuthorized(a.createPublicacionHandler)).Methods("POST")
+	a.Router.Handle("/publicaciones/{id:[0-9]+}", isAuthorized(a.updatePublicacionHandler)).Methods("PUT")
+	a.Router.Handle("/publicaciones/{id:[0-9]+}/historial", isAuthorized(a.getHistorialPublicacionHandler)).Methods("GET")
 
 	// examen
 	a.Router.Handle("/examenes/{id:[0-9]+}", isAuthorized(a.getExamenByIdHandler)).Methods("GET")
 	a.Router.Handle("/examenes", isAuthorized(a.getExamenesHandler)).Methods("GET")
 	a.Router.Handle("/examenes", isAuthorized(a.createExamenHandler)).Methods("POST")
+	a.Router.Handle("/examenes/import", isAuthorized(a.importarExamenHandler)).Methods("POST")
 	a.Router.Handle("/examenes/{id:[0-9]+}", isAuthorized(a.updateExamenHandler)).Methods("PUT")
 	a.Router.Handle("/examenes/{id:[0-9]+}", isAuthorized(a.deleteExamenHandler)).Methods("DELETE")
+	a.Router.Handle("/examenes/{id:[0-9]+}/intentos", isAuthorized(a.iniciarIntentoHandler)).Methods("POST")
+	a.Router.Handle("/examenes/{id:[0-9]+}/prorrogas", isAuthorized(a.getProrrogasExamenHandler)).Methods("GET")
+	a.Router.Handle("/examenes/{id:[0-9]+}/export", isAuthorized(a.exportExamenHandler)).Methods("GET")
+	a.Router.Handle("/examenes/{id:[0-9]+}/pdf", isAuthorized(a.getExamenPDFHandler)).Methods("GET")
+	a.Router.Handle("/examenes/{id:[0-9]+}/estadisticas", isAuthorized(a.getEstadisticasExamenHandler)).Methods("GET")
+	a.Router.Handle("/examenes/{id:[0-9]+}/supervision", isAuthorized(a.getSupervisionExamenHandler)).Methods("GET")
+	a.Router.Handle("/intentos/{id:[0-9]+}/respuestas", isAuthorized(a.guardarRespuestaHandler)).Methods("PUT")
+	a.Router.Handle("/intentos/{id:[0-9]+}/entregar", isAuthorized(a.entregarIntentoHandler)).Methods("POST")
+	a.Router.Handle("/intentos/{id:[0-9]+}/eventos", isAuthorized(a.registrarEventoHandler)).Methods("POST")
+	a.Router.Handle("/intentos/{id:[0-9]+}/eventos", isAuthorized(a.getLineaTiempoIntentoHandler)).Methods("GET")
+	a.Router.Handle("/intentos/{id:[0-9]+}/cerrar", isAuthorized(a.forzarEntregaHandler)).Methods("POST")
 
 	// pregunta
 	a.Router.Handle("/preguntas/{id:[0-9]+}", isAuthorized(a.getPreguntaByIdHandler)).Methods("GET")
@@ -80,6 +165,13 @@ func (a *App) initializeRoutes() {
 	a.Router.Handle("/preguntas", isAuthorized(a.createPreguntaHandler)).Methods("POST")
 	a.Router.Handle("/preguntas/{id:[0-9]+}", isAuthorized(a.updatePreguntaHandler)).Methods("PUT")
 	a.Router.Handle("/preguntas/{id:[0-9]+}", isAuthorized(a.deletePreguntaHandler)).Methods("DELETE")
+	a.Router.Handle("/preguntas/import", isAuthorized(a.importarPreguntasHandler)).Methods("POST")
+
+	// banco de preguntas
+	a.Router.Handle("/bancos", isAuthorized(a.getBancosHandler)).Methods("GET")
+	a.Router.Handle("/bancos", isAuthorized(a.createBancoHandler)).Methods("POST")
+	a.Router.Handle("/bancos/{id:[0-9]+}", isAuthorized(a.deleteBancoHandler)).Methods("DELETE")
+	a.Router.Handle("/bancos/{id:[0-9]+}/preguntas", isAuthorized(a.getPreguntasBancoHandler)).Methods("GET")
 
 	// profesor
 	a.Router.Handle("/profesores/{id:[0-9]+}", isAuthorized(a.getProfesorByIdHandler)).Methods("GET")
@@ -94,13 +186,97 @@ func (a *App) initializeRoutes() {
 	a.Router.Handle("/trabajos", isAuthorized(a.createTrabajoHandler)).Methods("POST")
 	a.Router.Handle("/trabajos/{id:[0-9]+}", isAuthorized(a.updateTrabajoHandler)).Methods("PUT")
 	a.Router.Handle("/trabajos/{id:[0-9]+}", isAuthorized(a.deleteTrabajoHandler)).Methods("DELETE")
+	a.Router.Handle("/trabajos/{id:[0-9]+}/prorrogas", isAuthorized(a.getProrrogasTrabajoHandler)).Methods("GET")
+	a.Router.Handle("/trabajos/{id:[0-9]+}/entregas", isAuthorized(a.getEntregasTrabajoHandler)).Methods("GET")
+	a.Router.Handle("/trabajos/{id:[0-9]+}/entregas", isAuthorized(a.createEntregaHandler)).Methods("POST")
+	a.Router.Handle("/trabajos/{id:[0-9]+}/calificaciones", isAuthorized(a.getCalificacionesTrabajoHandler)).Methods("GET")
+	a.Router.Handle("/trabajos/{id:[0-9]+}/rubricas", isAuthorized(a.getRubricasTrabajoHandler)).Methods("GET")
+	a.Router.Handle("/trabajos/{id:[0-9]+}/rubricas", isAuthorized(a.asignarRubricaTrabajoHandler)).Methods("POST")
+	a.Router.Handle("/trabajos/{id:[0-9]+}/similitud", isAuthorized(a.createAnalisisSimilitudHandler)).Methods("POST")
+	a.Router.Handle("/trabajos/{id:[0-9]+}/similitud", isAuthorized(a.getAnalisisSimilitudTrabajoHandler)).Methods("GET")
+	a.Router.Handle("/analisisSimilitud/{id:[0-9]+}", isAuthorized(a.getAnalisisSimilitudByIdHandler)).Methods("GET")
+
+	// pregunta trabajo
+	a.Router.Handle("/preguntasTrabajo/{id:[0-9]+}/rubricas", isAuthorized(a.asignarRubricaPreguntaTrabajoHandler)).Methods("POST")
+	a.Router.Handle("/preguntasTrabajo/{id:[0-9]+}/respuestas", isAuthorized(a.guardarRespuestaTrabajoHandler)).Methods("PUT")
+
+	// alumno trabajo
+	a.Router.Handle("/alumnoTrabajos/{id:[0-9]+}", isAuthorized(a.getAlumnoTrabajoByIdHandler)).Methods("GET")
+	a.Router.Handle("/alumnoTrabajos/{id:[0-9]+}/calificacion", isAuthorized(a.calificarAlumnoTrabajoHandler)).Methods("PUT")
+	a.Router.Handle("/alumnoTrabajos/{id:[0-9]+}/rubrica", isAuthorized(a.getEvaluacionRubricaHandler)).Methods("GET")
+	a.Router.Handle("/alumnoTrabajos/{id:[0-9]+}/rubrica", isAuthorized(a.evaluarRubricaHandler)).Methods("PUT")
+	a.Router.Handle("/alumnoTrabajos/{id:[0-9]+}/comentarios", isAuthorized(a.getComentariosHandler)).Methods("GET")
+	a.Router.Handle("/alumnoTrabajos/{id:[0-9]+}/comentarios", isAuthorized(a.createComentarioHandler)).Methods("POST")
+	a.Router.Handle("/alumnoTrabajos/{id:[0-9]+}/devolucion", isAuthorized(a.devolverAlumnoTrabajoHandler)).Methods("POST")
+	a.Router.Handle("/devoluciones", isAuthorized(a.getDevolucionesHandler)).Methods("GET")
+
+	// comentario
+	a.Router.Handle("/comentarios/{id:[0-9]+}/enlace", isAuthorized(a.getEnlaceComentarioHandler)).Methods("GET")
+	a.Router.Handle("/comentarios/{id:[0-9]+}/archivo", pass(a.descargarComentarioHandler)).Methods("GET")
+
+	// entrega
+	a.Router.Handle("/entregas/{id:[0-9]+}", isAuthorized(a.getEntregaByIdHandler)).Methods("GET")
+	a.Router.Handle("/entregas/{id:[0-9]+}/enlace", isAuthorized(a.getEnlaceEntregaHandler)).Methods("GET")
+	// el enlace firmado reemplaza al token, para poder abrirlo desde el navegador
+	a.Router.Handle("/entregas/{id:[0-9]+}/archivo", pass(a.descargarEntregaHandler)).Methods("GET")
+
+	// prorroga
+	a.Router.Handle("/prorrogas/{id:[0-9]+}", isAuthorized(a.getProrrogaByIdHandler)).Methods("GET")
+	a.Router.Handle("/prorrogas/{id:[0-9]+}/historial", isAuthorized(a.getProrrogaHistorialHandler)).Methods("GET")
+	a.Router.Handle("/prorrogas", isAuthorized(a.createProrrogaHandler)).Methods("POST")
+	a.Router.Handle("/prorrogas/{id:[0-9]+}", isAuthorized(a.updateProrrogaHandler)).Methods("PUT")
+	a.Router.Handle("/prorrogas/{id:[0-9]+}", isAuthorized(a.deleteProrrogaHandler)).Methods("DELETE")
+
+	// rubrica
+	a.Router.Handle("/rubricas/{id:[0-9]+}", isAuthorized(a.getRubricaByIdHandler)).Methods("GET")
+	a.Router.Handle("/rubricas", isAuthorized(a.getRubricasHandler)).Methods("GET")
+	a.Router.Handle("/rubricas", isAuthorized(a.createRubricaHandler)).Methods("POST")
+	a.Router.Handle("/rubricas/{id:[0-9]+}", isAuthorized(a.updateRubricaHandler)).Methods("PUT")
+	a.Router.Handle("/rubricas/{id:[0-9]+}", isAuthorized(a.deleteRubricaHandler)).Methods("DELETE")
+	a.Router.Handle("/rubricaAsignaciones/{id:[0-9]+}", isAuthorized(a.deleteRubricaAsignadaHandler)).Methods("DELETE")
+
+	// notificaciones
+	a.Router.Handle("/notificaciones", isAuthorized(a.getNotificacionesHandler)).Methods("GET")
+	a.Router.Handle("/notificaciones/leidas", isAuthorized(a.marcarNotificacionesLeidasHandler)).Methods("PUT")
+	a.Router.Handle("/notificaciones/{id:[0-9]+}/leida", isAuthorized(a.marcarNotificacionLeidaHandler)).Methods("PUT")
+	a.Router.Handle("/notificaciones/preferencias", isAuthorized(a.getPreferenciasNotificacionHandler)).Methods("GET")
+	a.Router.Handle("/notificaciones/preferencias", isAuthorized(a.updatePreferenciasNotificacionHandler)).Methods("PUT")
+
+	// mensajes
+	a.Router.Handle("/conversaciones", isAuthorized(a.getConversacionesHandler)).Methods("GET")
+	a.Router.Handle("/conversaciones/{id:[0-9]+}", isAuthorized(a.getConversacionByIdHandler)).Methods("GET")
+	a.Router.Handle("/conversaciones/{id:[0-9]+}/mensajes", isAuthorized(a.createMensajeHandler)).Methods("POST")
+	a.Router.Handle("/mensajes/{id:[0-9]+}/enlace", isAuthorized(a.getEnlaceMensajeHandler)).Methods("GET")
+	a.Router.Handle("/mensajes/{id:[0-9]+}/archivo", pass(a.descargarMensajeHandler)).Methods("GET")
+
+	// tiempo real, el JWT se valida en el handler
+	a.Router.Handle("/ws", pass(a.tiempoRealHandler)).Methods("GET")
+
+	// calendario
+	a.Router.Handle("/calendario", isAuthorized(a.getCalendarioHandler)).Methods("GET")
+	a.Router.Handle("/calendario/token", isAuthorized(a.regenerarTokenCalendarioHandler)).Methods("POST")
+	// el token secreto de la URL reemplaza a la cabecera Authorization
+	a.Router.Handle("/calendar/{token:[0-9a-f]+}.ics", pass(a.getCalendarioIcsHandler)).Methods("GET")
+
+	// busqueda
+	a.Router.Handle("/search", isAuthorized(a.buscarHandler)).Methods("GET")
+
+	// actualizaciones parciales, JSON Merge Patch sobre los recursos con PUT
+	a.registrarPatch()
 
 }
 
 func (a *App) Run(addr string) {
+	go a.despacharCorreos(context.Background())
+	go a.programarRecordatorios(context.Background())
+	go a.escucharEventos(context.Background())
+	go a.programarCierreIntentos(context.Background())
+
 	handler := cors.New(cors.Options{
 		AllowedHeaders: []string{"Accept", "Content-Type", "Authorization"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "HEAD"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"},
+		// metadatos de los listados paginados
+		ExposedHeaders: []string{"X-Total-Count", "Link"},
 	}).Handler(a.Router)
 	log.Fatal(http.ListenAndServe(addr, handler))
 }
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"io/ioutil"
 	"log"
 	"os"
 	"testing"
 
+	"github.com/blackadress/vaula/correo"
 	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/storage"
 	"github.com/blackadress/vaula/utils"
 	"github.com/joho/godotenv"
 )
@@ -13,6 +16,9 @@ import (
 var a App
 var BASE_URL string
 
+// buzon recibe los correos del despachador en lugar de un servidor SMTP
+var buzon correo.Memoria
+
 func TestMain(m *testing.M) {
 
 	if err := godotenv.Load("../.env"); err != nil {
@@ -25,15 +31,41 @@ func TestMain(m *testing.M) {
 		os.Getenv("APP_DB_PASSWORD"),
 		os.Getenv("APP_DB_NAME"))
 
+	// los archivos de prueba no deben quedar en el directorio del proyecto
+	dir, err := ioutil.TempDir("", "vaula-test")
+	if err != nil {
+		log.Fatalf("TEST: no se pudo crear directorio temporal %s", err)
+	}
+	a.Storage, err = storage.NewLocal(dir)
+	if err != nil {
+		log.Fatalf("TEST: no se pudo crear el storage local %s", err)
+	}
+	a.Mailer = &buzon
+
 	// asegurarse de que todas las tablas existen
 	utils.EnsureTableUsuarioExists(a.DB)
-	// utils.EnsureTableAlternativaExists(a.DB) TODO
 	utils.EnsureTableAlumnoExists(a.DB)
 	utils.EnsureTableCursoExists(a.DB)
 	utils.EnsureTableExamenExists(a.DB)
 	utils.EnsureTablePreguntaExists(a.DB)
+	utils.EnsureTableAlternativaExists(a.DB)
 	utils.EnsureTableProfesorExists(a.DB)
 	utils.EnsureTableTrabajoExists(a.DB)
+	utils.EnsureTableAlumnoExamenExists(a.DB)
+	utils.EnsureTableProrrogaExists(a.DB)
+	utils.EnsureTableAlumnoTrabajoExists(a.DB)
+	utils.EnsureTablePreguntaTrabajoExists(a.DB)
+	utils.EnsureTableRubricaExists(a.DB)
+	utils.EnsureTableAlumnoCursoExists(a.DB)
+	utils.EnsureTableLibretaExists(a.DB)
+	utils.EnsureTableAsistenciaExists(a.DB)
+	utils.EnsureTableProfesorCursoExists(a.DB)
+	utils.EnsureTableForoExists(a.DB)
+	utils.EnsureTableNotificacionExists(a.DB)
+	utils.EnsureTableRecordatorioExists(a.DB)
+	utils.EnsureTableTokenCalendarioExists(a.DB)
+	utils.EnsureTableMensajeExists(a.DB)
+	utils.EnsureBusquedaExists(a.DB)
 
 	code := m.Run()
 
@@ -47,6 +79,7 @@ func TestMain(m *testing.M) {
 	// ClearTablePregunta(db) ya reseteado por ClearTableCurso
 	// ClearTableTrabajo(db) ya reseteado por ClearTableCurso
 	// ClearTablePreguntaTrabajo(db) ya reseteado por ClearTableCurso
+	os.RemoveAll(dir)
 	os.Exit(code)
 }
 
@@ -75,3 +108,38 @@ func ensureAuthorizedUserExists() {
 		log.Fatalf("Error en el metodo CreateUser, %s", err)
 	}
 }
+
+// ensureAuthorizedProfesorExists registra al usuario de prueba como profesor
+func ensureAuthorizedProfesorExists() {
+	user := models.User{Username: "prueba"}
+	err := user.GetUserByUsername(a.DB)
+	if err != nil {
+		log.Fatalf("Error no se encuentra el usuario prueba, %s", err)
+	}
+
+	profesor := models.Profesor{
+		Nombres: "prof_prueba", Apellidos: "prof_prueba",
+		UsuarioId: user.ID, Activo: true}
+	err = profesor.CreateProfesor(a.DB)
+	if err != nil {
+		log.Fatalf("Error en el metodo CreateProfesor, %s", err)
+	}
+}
+
+// ensureAuthorizedAlumnoExists registra al usuario de prueba como alumno
+func ensureAuthorizedAlumnoExists() models.Alumno {
+	user := models.User{Username: "prueba"}
+	err := user.GetUserByUsername(a.DB)
+	if err != nil {
+		log.Fatalf("Error no se encuentra el usuario prueba, %s", err)
+	}
+
+	alumno := models.Alumno{
+		Nombres: "al_prueba", Apellidos: "al_prueba", Codigo: "99999999",
+		UsuarioId: user.ID, Activo: true}
+	err = alumno.CreateAlumno(a.DB)
+	if err != nil {
+		log.Fatalf("Error en el metodo CreateAlumno, %s", err)
+	}
+	return alumno
+}
@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/blackadress/vaula/formatos"
+	"github.com/blackadress/vaula/models"
+	"github.com/jackc/pgx/v4"
+)
+
+// tamano maximo de los archivos a importar
+const maxImportacionBytes = 5 << 20
+
+type reporteImportacion struct {
+	DryRun  bool                     `json:"dryRun"`
+	CursoId int                      `json:"cursoId"`
+	Total   int                      `json:"total"`
+	Creados int                      `json:"creados"`
+	Errores int                      `json:"errores"`
+	Filas   []models.FilaImportacion `json:"filas"`
+}
+
+// importarAlumnosHandler crea usuarios y alumnos a partir de un CSV con las
+// columnas codigo, apellidos, nombres, email. El CSV va en el cuerpo
+// (text/csv) o en el campo 'archivo' de un multipart. Con ?dryRun=true solo
+// valida, y con ?cursoId= matricula a los alumnos creados. El username de
+// cada usuario es su codigo y su password inicial es aleatoria; solo se
+// devuelve en el reporte de esta importacion.
+func (a *App) importarAlumnosHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	cursoId := 0
+	if s := r.URL.Query().Get("cursoId"); s != "" {
+		var err error
+		cursoId, err = strconv.Atoi(s)
+		if err != nil {
+			log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+				http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+			return
+		}
+		curso := models.Curso{ID: cursoId}
+		if err := curso.GetCurso(a.DB); err != nil {
+			switch err {
+			case pgx.ErrNoRows:
+				log.Printf("POST %s code: %d ERROR: %s -- no rows", r.RequestURI,
+					http.StatusNotFound, err.Error())
+				respondWithError(w, http.StatusNotFound, "Curso no encontrado")
+			default:
+				log.Printf("POST %s code: %d ERROR: %s -- curso.GetCurso", r.RequestURI,
+					http.StatusInternalServerError, err.Error())
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+			}
+			return
+		}
+	}
+
+	contenido, liberar, ok := recibirImportacion(w, r)
+	if !ok {
+		return
+	}
+	defer liberar()
+
+	filas, err := leerFilasImportacion(contenido)
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- leerFilasImportacion", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := models.ValidarExistentes(a.DB, filas); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- models.ValidarExistentes", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reporte := reporteImportacion{DryRun: dryRun, CursoId: cursoId, Total: len(filas), Filas: filas}
+	for _, f := range filas {
+		if !f.Valida() {
+			reporte.Errores++
+		}
+	}
+	if reporte.Errores > 0 {
+		log.Printf("POST %s code: %d ERROR: %d filas con errores", r.RequestURI,
+			http.StatusBadRequest, reporte.Errores)
+		respondWithJSON(w, http.StatusBadRequest, reporte)
+		return
+	}
+	if dryRun {
+		log.Printf("POST %s code: %d", r.RequestURI, http.StatusOK)
+		respondWithJSON(w, http.StatusOK, reporte)
+		return
+	}
+
+	for i := range filas {
+		password, err := passwordInicial()
+		if err != nil {
+			log.Printf("POST %s code: %d ERROR: %s -- passwordInicial", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		filas[i].PasswordInicial = password
+		filas[i].Password = hashAndSalt([]byte(password))
+	}
+	if err := models.ImportarAlumnos(a.DB, filas, cursoId); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- models.ImportarAlumnos", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	reporte.Creados = len(filas)
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, reporte)
+	return
+}
+
+// passwordInicial genera una password aleatoria para un usuario importado.
+// No se deriva de sus datos, que son publicos en las listas del curso.
+func passwordInicial() (string, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// recibirImportacion devuelve el archivo a importar, que viene en el cuerpo
+// o en el campo 'archivo' de un multipart. Quien llama debe llamar a
+// 'liberar' al terminar.
+func recibirImportacion(w http.ResponseWriter, r *http.Request) (io.Reader, func(), bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportacionBytes)
+	tipo, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if tipo != "multipart/form-data" {
+		return r.Body, func() { r.Body.Close() }, true
+	}
+
+	if err := r.ParseMultipartForm(maxImportacionBytes); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- ParseMultipartForm", r.RequestURI,
+			http.StatusRequestEntityTooLarge, err.Error())
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Archivo demasiado grande o payload invalido")
+		return nil, nil, false
+	}
+	archivo, _, err := r.FormFile("archivo")
+	if err != nil {
+		r.MultipartForm.RemoveAll()
+		log.Printf("POST %s code: %d ERROR: %s -- FormFile", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Falta el campo 'archivo'")
+		return nil, nil, false
+	}
+	return archivo, func() {
+		archivo.Close()
+		r.MultipartForm.RemoveAll()
+	}, true
+}
+
+// leerFilasImportacion lee y valida el CSV fila por fila. El separador
+// puede ser ',' o ';' (Excel en espanol) y la cabecera es opcional. Solo
+// devuelve error si el archivo no se puede leer como CSV.
+func leerFilasImportacion(r io.Reader) ([]models.FilaImportacion, error) {
+	br := bufio.NewReader(r)
+	// BOM que agrega Excel al guardar como CSV UTF-8
+	if b, err := br.Peek(3); err == nil && bytes.Equal(b, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+	// el separador se deduce de la primera linea
+	primera, _ := br.Peek(1024)
+	if i := bytes.IndexByte(primera, '\n'); i >= 0 {
+		primera = primera[:i]
+	}
+
+	lector := csv.NewReader(br)
+	if bytes.Count(primera, []byte(";")) > bytes.Count(primera, []byte(",")) {
+		lector.Comma = ';'
+	}
+	lector.FieldsPerRecord = -1
+	lector.TrimLeadingSpace = true
+
+	filas := []models.FilaImportacion{}
+	codigos := map[string]int{}
+	emails := map[string]int{}
+	for n := 1; ; n++ {
+		registro, err := lector.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV invalido: %s", err)
+		}
+		if n == 1 && strings.EqualFold(strings.TrimSpace(registro[0]), "codigo") {
+			continue
+		}
+		if len(registro) == 1 && strings.TrimSpace(registro[0]) == "" {
+			continue
+		}
+
+		f := models.FilaImportacion{Fila: n, Errores: []string{}}
+		if len(registro) != 4 {
+			f.Errores = append(f.Errores, fmt.Sprintf(
+				"Se esperaban 4 columnas (codigo, apellidos, nombres, email), hay %d", len(registro)))
+			filas = append(filas, f)
+			continue
+		}
+		f.Codigo = strings.TrimSpace(registro[0])
+		f.Apellidos = strings.TrimSpace(registro[1])
+		f.Nombres = strings.TrimSpace(registro[2])
+		f.Email = strings.ToLower(strings.TrimSpace(registro[3]))
+
+		if len(f.Codigo) != 8 {
+			f.Errores = append(f.Errores, "El codigo debe tener 8 caracteres")
+		}
+		if f.Apellidos == "" || len(f.Apellidos) > 200 {
+			f.Errores = append(f.Errores, "Los apellidos son obligatorios, maximo 200 caracteres")
+		}
+		if f.Nombres == "" || len(f.Nombres) > 200 {
+			f.Errores = append(f.Errores, "Los nombres son obligatorios, maximo 200 caracteres")
+		}
+		if addr, err := mail.ParseAddress(f.Email); err != nil || addr.Address != f.Email {
+			f.Errores = append(f.Errores, "Email invalido")
+		}
+		if otra, ok := codigos[f.Codigo]; ok && f.Codigo != "" {
+			f.Errores = append(f.Errores, fmt.Sprintf("Codigo repetido en la fila %d", otra))
+		} else {
+			codigos[f.Codigo] = n
+		}
+		if otra, ok := emails[f.Email]; ok && f.Email != "" {
+			f.Errores = append(f.Errores, fmt.Sprintf("Email repetido en la fila %d", otra))
+		} else {
+			emails[f.Email] = n
+		}
+		filas = append(filas, f)
+	}
+
+	if len(filas) == 0 {
+		return nil, fmt.Errorf("El CSV no tiene alumnos")
+	}
+	return filas, nil
+}
+
+type preguntaImportada struct {
+	ID     int    `json:"id"`
+	Nombre string `json:"nombre"`
+	Tipo   string `json:"tipo"`
+}
+
+type reporteImportacionPreguntas struct {
+	Formato    string              `json:"formato"`
+	ExamenId   int                 `json:"examenId"`
+	BancoId    int                 `json:"bancoId"`
+	Importadas int                 `json:"importadas"`
+	Preguntas  []preguntaImportada `json:"preguntas"`
+	Omitidas   []formatos.Omitida  `json:"omitidas"`
+}
+
+// importarPreguntasHandler crea preguntas a partir de un archivo Moodle XML
+// (?format=moodle), GIFT (?format=gift) o un paquete QTI 2.1 (?format=qti)
+// y las agrega al examen indicado
+// con ?examenId= o al banco indicado con ?bancoId=. Las preguntas que no se
+// pueden convertir se reportan como omitidas con su motivo.
+func (a *App) importarPreguntasHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	examenId, errExamen := strconv.Atoi(r.URL.Query().Get("examenId"))
+	bancoId, errBanco := strconv.Atoi(r.URL.Query().Get("bancoId"))
+	if (errExamen == nil) == (errBanco == nil) {
+		log.Printf("POST %s code: %d ERROR: destino de la importacion", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "Indique examenId o bancoId, solo uno")
+		return
+	}
+	if errExamen == nil {
+		examen := models.Examen{ID: examenId}
+		err := examen.GetExamen(a.DB)
+		if !a.existeDestinoImportacion(w, r, err, "Examen no encontrado") {
+			return
+		}
+		bancoId = 0
+	} else {
+		banco := models.BancoPreguntas{ID: bancoId}
+		err := banco.GetBanco(a.DB)
+		if !a.existeDestinoImportacion(w, r, err, "Banco de preguntas no encontrado") {
+			return
+		}
+		examenId = 0
+	}
+
+	formato := r.URL.Query().Get("format")
+	var parse func(io.Reader) ([]formatos.Pregunta, []formatos.Omitida, error)
+	switch formato {
+	case "moodle", "xml":
+		formato, parse = "moodle", formatos.ParseMoodleXML
+	case "gift":
+		parse = formatos.ParseGIFT
+	case "qti":
+		parse = func(r io.Reader) ([]formatos.Pregunta, []formatos.Omitida, error) {
+			paquete, omitidas, err := parseQTI(r)
+			return paquete.Preguntas, omitidas, err
+		}
+	default:
+		log.Printf("POST %s code: %d ERROR: formato %s", r.RequestURI,
+			http.StatusBadRequest, formato)
+		respondWithError(w, http.StatusBadRequest, "El formato debe ser 'moodle', 'gift' o 'qti'")
+		return
+	}
+
+	contenido, liberar, ok := recibirImportacion(w, r)
+	if !ok {
+		return
+	}
+	defer liberar()
+
+	leidas, omitidas, err := parse(contenido)
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- parse %s", r.RequestURI,
+			http.StatusBadRequest, err.Error(), formato)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	preguntas := make([]models.Pregunta, len(leidas))
+	for i, fp := range leidas {
+		preguntas[i] = preguntaDesdeFormato(fp, examenId, bancoId)
+	}
+	reporte := reporteImportacionPreguntas{
+		Formato: formato, ExamenId: examenId, BancoId: bancoId,
+		Preguntas: []preguntaImportada{}, Omitidas: omitidas,
+	}
+	if len(preguntas) == 0 {
+		log.Printf("POST %s code: %d ERROR: ninguna pregunta importable", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithJSON(w, http.StatusBadRequest, reporte)
+		return
+	}
+
+	if err := models.ImportarPreguntas(a.DB, preguntas); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- models.ImportarPreguntas", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for i, p := range preguntas {
+		reporte.Preguntas = append(reporte.Preguntas,
+			preguntaImportada{ID: p.ID, Nombre: leidas[i].Nombre, Tipo: p.Tipo})
+	}
+	reporte.Importadas = len(preguntas)
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, reporte)
+	return
+}
+
+// existeDestinoImportacion responde 404 o 500 segun el error de buscar el
+// examen o banco destino. Devuelve true si se encontro.
+func (a *App) existeDestinoImportacion(w http.ResponseWriter, r *http.Request, err error, noEncontrado string) bool {
+	switch err {
+	case nil:
+		return true
+	case pgx.ErrNoRows:
+		log.Printf("POST %s code: %d ERROR: %s -- no rows", r.RequestURI,
+			http.StatusNotFound, err.Error())
+		respondWithError(w, http.StatusNotFound, noEncontrado)
+	default:
+		log.Printf("POST %s code: %d ERROR: %s -- destino importacion", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+	return false
+}
+
+// preguntaDesdeFormato convierte una pregunta leida de un archivo. Los
+// tipos de formatos y de models usan los mismos nombres.
+func preguntaDesdeFormato(fp formatos.Pregunta, examenId, bancoId int) models.Pregunta {
+	p := models.Pregunta{
+		Enunciado:    fp.Enunciado,
+		Tipo:         fp.Tipo,
+		Puntaje:      float32(fp.Puntaje),
+		ExamenId:     examenId,
+		BancoId:      bancoId,
+		Activo:       true,
+		Alternativas: []models.Alternativa{},
+	}
+	for _, fa := range fp.Alternativas {
+		p.Alternativas = append(p.Alternativas, models.Alternativa{
+			Valor:      fa.Texto,
+			Correcto:   fa.Correcta(),
+			Tolerancia: float32(fa.Tolerancia),
+			Activo:     true,
+		})
+	}
+	return p
+}
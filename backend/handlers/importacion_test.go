@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestLeerFilasImportacion(t *testing.T) {
+	csv := "\ufeffcodigo;apellidos;nombres;email\n" +
+		"20210001;Quispe Huaman;Ana;ANA@test.ts\n" +
+		"2021;Mamani;Luis;luis@test.ts\n" +
+		"20210003;Rojas;Eva;ana@test.ts\n"
+	filas, err := leerFilasImportacion(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("Error inesperado leyendo el CSV %s", err)
+	}
+	if len(filas) != 3 {
+		t.Fatalf("Expected 3 filas. Got %d", len(filas))
+	}
+	if !filas[0].Valida() || filas[0].Fila != 2 || filas[0].Email != "ana@test.ts" {
+		t.Errorf("Expected fila 2 valida con email en minusculas. Got %v", filas[0])
+	}
+	if filas[1].Valida() {
+		t.Errorf("Expected error por codigo corto. Got %v", filas[1])
+	}
+	if filas[2].Valida() {
+		t.Errorf("Expected error por email repetido. Got %v", filas[2])
+	}
+}
+
+func TestImportarAlumnos(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	csv := []byte("codigo,apellidos,nombres,email\n" +
+		"20210001,Quispe,Ana,ana@test.ts\n" +
+		"20210002,Mamani,Luis,luis@test.ts\n")
+
+	// el dry run valida sin crear nada
+	req, _ := http.NewRequest("POST", "/alumnos/import?dryRun=true&cursoId=1", bytes.NewBuffer(csv))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "text/csv")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["total"] != 2.0 || m["creados"] != 0.0 {
+		t.Errorf("Expected total 2 and creados 0. Got '%v' and '%v'", m["total"], m["creados"])
+	}
+
+	req, _ = http.NewRequest("POST", "/alumnos/import?cursoId=1", bytes.NewBuffer(csv))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "text/csv")
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var reporte struct {
+		Creados int `json:"creados"`
+		Filas   []struct {
+			Codigo          string `json:"codigo"`
+			PasswordInicial string `json:"passwordInicial"`
+		} `json:"filas"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &reporte)
+	if reporte.Creados != 2 {
+		t.Errorf("Expected creados 2. Got '%v'", reporte.Creados)
+	}
+	// cada alumno recibe una password aleatoria, no su codigo
+	if len(reporte.Filas) != 2 || reporte.Filas[0].PasswordInicial == "" ||
+		reporte.Filas[0].PasswordInicial == reporte.Filas[0].Codigo ||
+		reporte.Filas[0].PasswordInicial == reporte.Filas[1].PasswordInicial {
+		t.Errorf("Expected a distinct random password per alumno. Got '%v'", reporte.Filas)
+	}
+
+	// la segunda vez los codigos ya existen y no se crea ninguno
+	req, _ = http.NewRequest("POST", "/alumnos/import", bytes.NewBuffer(csv))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "text/csv")
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["errores"] != 2.0 {
+		t.Errorf("Expected errores 2. Got '%v'", m["errores"])
+	}
+}
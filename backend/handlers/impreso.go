@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/blackadress/vaula/impreso"
+	"github.com/gorilla/mux"
+)
+
+// getExamenPDFHandler imprime el examen en PDF. ?version=alumno (por
+// defecto) da la hoja para el alumno y ?version=clave la clave del
+// profesor. Con ?variantes=N se generan N variantes barajadas en el mismo
+// archivo; la semilla es el id del examen, asi ambas versiones coinciden.
+func (a *App) getExamenPDFHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de Examen invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	version := r.URL.Query().Get("version")
+	if version == "" {
+		version = impreso.VersionAlumno
+	}
+	if version != impreso.VersionAlumno && version != impreso.VersionClave {
+		log.Printf("GET %s code: %d ERROR: version %s", r.RequestURI,
+			http.StatusBadRequest, version)
+		respondWithError(w, http.StatusBadRequest, "La version debe ser 'alumno' o 'clave'")
+		return
+	}
+	variantes := 1
+	if v := r.URL.Query().Get("variantes"); v != "" {
+		variantes, err = strconv.Atoi(v)
+		if err != nil || variantes < 1 || variantes > impreso.MaxVariantes {
+			log.Printf("GET %s code: %d ERROR: variantes %s", r.RequestURI,
+				http.StatusBadRequest, v)
+			respondWithError(w, http.StatusBadRequest,
+				fmt.Sprintf("Las variantes deben ser de 1 a %d", impreso.MaxVariantes))
+			return
+		}
+	}
+
+	examen, ok := a.cargarExamenFormato(w, r, id)
+	if !ok {
+		return
+	}
+
+	var pdf bytes.Buffer
+	err = impreso.EscribirPDF(&pdf, impreso.Variantes(examen, variantes, int64(id)), version)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- impreso.EscribirPDF", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="examen-%d-%s.pdf"`, id, version))
+	w.WriteHeader(http.StatusOK)
+	w.Write(pdf.Bytes())
+}
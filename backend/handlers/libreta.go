@@ -0,0 +1,640 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blackadress/vaula/exportar"
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// getLibretaHandler devuelve la matriz de notas del curso. El profesor ve
+// a todos los alumnos y el alumno solo su propia fila, sin las notas de
+// trabajos que todavia no se devuelven.
+func (a *App) getLibretaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+
+	var libreta models.Libreta
+	profesor := models.Profesor{UsuarioId: getUserId(r)}
+	if profesor.GetProfesorByUsuario(a.DB) == nil {
+		libreta, err = models.GetLibreta(a.DB, id)
+	} else {
+		alumno, ok := a.alumnoAutenticado(w, r)
+		if !ok {
+			return
+		}
+		libreta, err = models.GetLibretaAlumno(a.DB, id, alumno.ID)
+	}
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetLibreta", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, libreta)
+	return
+}
+
+// exportLibretaHandler envia la libreta como hoja de calculo, una fila por
+// alumno matriculado. ?format=csv|xlsx, y para CSV el separador decimal se
+// toma de ?locale= o del encabezado Accept-Language.
+func (a *App) exportLibretaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	formato := r.URL.Query().Get("format")
+	if formato == "" {
+		formato = exportar.FormatoCSV
+	}
+	if formato != exportar.FormatoCSV && formato != exportar.FormatoXLSX {
+		log.Printf("GET %s code: %d ERROR: formato %s", r.RequestURI,
+			http.StatusBadRequest, formato)
+		respondWithError(w, http.StatusBadRequest, "El formato debe ser 'csv' o 'xlsx'")
+		return
+	}
+	locale := r.URL.Query().Get("locale")
+	if locale == "" {
+		locale = r.Header.Get("Accept-Language")
+	}
+
+	libreta, err := models.GetLibreta(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetLibreta", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", exportar.ContentType(formato))
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="calificaciones-curso-%d.%s"`, id, formato))
+	hoja, err := exportar.Nueva(formato, w, exportar.ParseLocale(locale))
+	if err == nil {
+		err = escribirLibreta(hoja, libreta)
+	}
+	if err == nil {
+		err = hoja.Cerrar()
+	}
+	// con la respuesta ya empezada solo queda registrar el error
+	if err != nil {
+		log.Printf("GET %s ERROR: %s -- escribirLibreta", r.RequestURI, err.Error())
+		return
+	}
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+}
+
+// escribirLibreta escribe la cabecera y una fila por alumno: sus datos, la
+// nota de cada evaluacion, el promedio final y la nota redondeada
+func escribirLibreta(hoja exportar.Hoja, libreta models.Libreta) error {
+	cabecera := []exportar.Celda{
+		exportar.Texto("codigo"), exportar.Texto("apellidos"), exportar.Texto("nombres"),
+	}
+	for _, columna := range libreta.Categorias {
+		for _, ev := range columna.Evaluaciones {
+			cabecera = append(cabecera, exportar.Texto(ev.Nombre))
+		}
+	}
+	cabecera = append(cabecera, exportar.Texto("promedio"), exportar.Texto("final"))
+	if err := hoja.Fila(cabecera...); err != nil {
+		return err
+	}
+
+	for _, fila := range libreta.Filas {
+		celdas := []exportar.Celda{
+			exportar.Texto(fila.Alumno.Codigo),
+			exportar.Texto(fila.Alumno.Apellidos),
+			exportar.Texto(fila.Alumno.Nombres),
+		}
+		for _, nc := range fila.Categorias {
+			for _, celda := range nc.Celdas {
+				if celda.Calificacion == nil {
+					celdas = append(celdas, exportar.Vacia())
+				} else {
+					celdas = append(celdas, exportar.Numero(float64(*celda.Calificacion)))
+				}
+			}
+		}
+		celdas = append(celdas,
+			exportar.Numero(float64(fila.Final)),
+			exportar.Numero(float64(fila.Redondeado)))
+		if err := hoja.Fila(celdas...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *App) matricularAlumnoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var matricula models.AlumnoCurso
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&matricula); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if matricula.AlumnoId == 0 {
+		log.Printf("POST %s code: %d ERROR: matricula sin alumnoId", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "La matricula debe indicar el alumnoId")
+		return
+	}
+
+	now := time.Now()
+	matricula.CursoId = id
+	matricula.Activo = true
+	if matricula.FechaInicio.IsZero() {
+		matricula.FechaInicio = now
+	}
+	if matricula.FechaFinal.IsZero() {
+		matricula.FechaFinal = matricula.FechaInicio
+	}
+	if err := matricula.CreateAlumnoCurso(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- matricula.CreateAlumnoCurso", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, matricula)
+	return
+}
+
+func (a *App) getCategoriasCursoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+
+	columnas, err := models.GetColumnasLibreta(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetColumnasLibreta", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, columnas)
+	return
+}
+
+func (a *App) createCategoriaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var categoria models.CategoriaCalificacion
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&categoria); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	categoria.CursoId = id
+	if msg := validarCategoria(categoria); msg != "" {
+		log.Printf("POST %s code: %d ERROR: %s -- validarCategoria", r.RequestURI,
+			http.StatusBadRequest, msg)
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	code, msg, err := a.validarPesosCurso(categoria)
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- validarPesosCurso", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if msg != "" {
+		log.Printf("POST %s code: %d ERROR: %s -- validarPesosCurso", r.RequestURI, code, msg)
+		respondWithError(w, code, msg)
+		return
+	}
+
+	if err := categoria.CreateCategoria(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- categoria.CreateCategoria", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, categoria)
+	return
+}
+
+func (a *App) updateCategoriaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de categoria invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	actual := models.CategoriaCalificacion{ID: id}
+	if err := actual.GetCategoria(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("PUT %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Categoria no encontrada")
+		default:
+			log.Printf("PUT %s code: %d ERROR: %s -- categoria.GetCategoria", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	var categoria models.CategoriaCalificacion
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&categoria); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	// el curso y el tipo no cambian
+	categoria.ID = id
+	categoria.CursoId = actual.CursoId
+	categoria.Tipo = actual.Tipo
+	if msg := validarCategoria(categoria); msg != "" {
+		log.Printf("PUT %s code: %d ERROR: %s -- validarCategoria", r.RequestURI,
+			http.StatusBadRequest, msg)
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	code, msg, err := a.validarPesosCurso(categoria)
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- validarPesosCurso", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if msg != "" {
+		log.Printf("PUT %s code: %d ERROR: %s -- validarPesosCurso", r.RequestURI, code, msg)
+		respondWithError(w, code, msg)
+		return
+	}
+
+	if err := categoria.UpdateCategoria(a.DB); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- categoria.UpdateCategoria", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, categoria)
+	return
+}
+
+func (a *App) deleteCategoriaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de categoria invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	categoria := models.CategoriaCalificacion{ID: id}
+	if err := categoria.DeleteCategoria(a.DB); err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- categoria.DeleteCategoria", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("DELETE %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": categoria.ID})
+	return
+}
+
+// createEvaluacionManualHandler agrega una columna a una categoria manual,
+// por ejemplo la participacion de una semana
+func (a *App) createEvaluacionManualHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de categoria invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	categoria := models.CategoriaCalificacion{ID: id}
+	if err := categoria.GetCategoria(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("POST %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Categoria no encontrada")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- categoria.GetCategoria", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if categoria.Tipo != models.CategoriaManual {
+		log.Printf("POST %s code: %d ERROR: categoria de tipo %s", r.RequestURI,
+			http.StatusBadRequest, categoria.Tipo)
+		respondWithError(w, http.StatusBadRequest, "Solo las categorias manuales admiten evaluaciones manuales")
+		return
+	}
+
+	var evaluacion models.EvaluacionManual
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&evaluacion); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if evaluacion.Nombre == "" {
+		log.Printf("POST %s code: %d ERROR: evaluacion sin nombre", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "La evaluacion debe tener nombre")
+		return
+	}
+
+	evaluacion.CategoriaId = id
+	if err := evaluacion.CreateEvaluacionManual(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- evaluacion.CreateEvaluacionManual", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, evaluacion)
+	return
+}
+
+func (a *App) deleteEvaluacionManualHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de evaluacion invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	evaluacion := models.EvaluacionManual{ID: id}
+	if err := evaluacion.DeleteEvaluacionManual(a.DB); err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- evaluacion.DeleteEvaluacionManual", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("DELETE %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": evaluacion.ID})
+	return
+}
+
+// guardarCeldaHandler pone la nota de una evaluacion manual o reemplaza a
+// mano una celda calculada de la libreta
+func (a *App) guardarCeldaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var celda models.CeldaManual
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&celda); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if celda.Calificacion < calificacionMinima || celda.Calificacion > calificacionMaxima {
+		log.Printf("PUT %s code: %d ERROR: calificacion %v fuera de rango", r.RequestURI,
+			http.StatusBadRequest, celda.Calificacion)
+		respondWithError(w, http.StatusBadRequest, "La calificacion debe estar entre 0 y 20")
+		return
+	}
+
+	celda.CursoId = id
+	msg, err := a.validarCelda(celda)
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- validarCelda", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if msg != "" {
+		log.Printf("PUT %s code: %d ERROR: %s -- validarCelda", r.RequestURI,
+			http.StatusBadRequest, msg)
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	celda.UsuarioId = getUserId(r)
+	if err := celda.GuardarCeldaManual(a.DB); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- celda.GuardarCeldaManual", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, celda)
+	return
+}
+
+// deleteCeldaHandler quita una nota manual; si era un ajuste la celda
+// vuelve a su valor calculado
+func (a *App) deleteCeldaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de celda invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	celda := models.CeldaManual{ID: id}
+	if err := celda.DeleteCeldaManual(a.DB); err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- celda.DeleteCeldaManual", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("DELETE %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": celda.ID})
+	return
+}
+
+// validarCategoria devuelve un mensaje de error o "" si la categoria es valida
+func validarCategoria(c models.CategoriaCalificacion) string {
+	if c.Nombre == "" {
+		return "La categoria debe tener nombre"
+	}
+	switch c.Tipo {
+	case models.CategoriaExamen, models.CategoriaTrabajo, models.CategoriaManual,
+		models.CategoriaAsistencia:
+	default:
+		return "El tipo de categoria debe ser 'examen', 'trabajo', 'manual' o 'asistencia'"
+	}
+	if c.Peso <= 0 || c.Peso > 100 {
+		return "El peso debe ser mayor que 0 y como maximo 100"
+	}
+	if c.DescartarMenores < 0 {
+		return "descartarMenores no puede ser negativo"
+	}
+	return ""
+}
+
+// validarPesosCurso comprueba, contra las demas categorias del curso, que
+// los pesos no pasen de 100 y que no se repita la categoria de examenes,
+// de trabajos o de asistencia. Devuelve el codigo y el mensaje del error, o "".
+func (a *App) validarPesosCurso(c models.CategoriaCalificacion) (int, string, error) {
+	categorias, err := models.GetCategoriasCurso(a.DB, c.CursoId)
+	if err != nil {
+		return 0, "", err
+	}
+	total := c.Peso
+	for _, otra := range categorias {
+		if otra.ID == c.ID {
+			continue
+		}
+		if otra.Tipo == c.Tipo && c.Tipo != models.CategoriaManual {
+			return http.StatusConflict, fmt.Sprintf("El curso ya tiene una categoria de tipo '%s'", c.Tipo), nil
+		}
+		total += otra.Peso
+	}
+	if total > 100 {
+		return http.StatusBadRequest, fmt.Sprintf("Los pesos de las categorias suman %v, el maximo es 100", total), nil
+	}
+	return 0, "", nil
+}
+
+// validarCelda comprueba que el alumno este matriculado y que la celda
+// corresponda a una columna de la libreta del curso
+func (a *App) validarCelda(c models.CeldaManual) (string, error) {
+	alumnos, err := models.GetAlumnosCurso(a.DB, c.CursoId)
+	if err != nil {
+		return "", err
+	}
+	matriculado := false
+	for _, al := range alumnos {
+		matriculado = matriculado || al.ID == c.AlumnoId
+	}
+	if !matriculado {
+		return "El alumno no esta matriculado en el curso", nil
+	}
+
+	if c.Tipo == models.CeldaFinal {
+		if c.ReferenciaId != 0 {
+			return "La nota final no lleva referenciaId", nil
+		}
+		return "", nil
+	}
+
+	columnas, err := models.GetColumnasLibreta(a.DB, c.CursoId)
+	if err != nil {
+		return "", err
+	}
+	for _, columna := range columnas {
+		if c.Tipo == models.CeldaCategoria && columna.ID == c.ReferenciaId {
+			return "", nil
+		}
+		for _, ev := range columna.Evaluaciones {
+			if ev.Tipo == c.Tipo && ev.ID == c.ReferenciaId {
+				return "", nil
+			}
+		}
+	}
+	return "La celda no corresponde a ninguna columna de la libreta", nil
+}
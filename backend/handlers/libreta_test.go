@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestCreateCategoria(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"nombre": "examenes", "tipo": "examen", "peso": 60, "descartarMenores": 1}`)
+	req, _ := http.NewRequest("POST", "/cursos/1/categorias", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m["cursoId"] != 1.0 {
+		t.Errorf("Expected categoria cursoId to be '1'. Got '%v'", m["cursoId"])
+	}
+	if m["peso"] != 60.0 {
+		t.Errorf("Expected categoria peso to be '60'. Got '%v'", m["peso"])
+	}
+
+	// solo una categoria de examenes por curso
+	jsonStr = []byte(`{"nombre": "parciales", "tipo": "examen", "peso": 10}`)
+	req, _ = http.NewRequest("POST", "/cursos/1/categorias", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusConflict, response.Code)
+
+	// los pesos no pueden pasar de 100
+	jsonStr = []byte(`{"nombre": "trabajos", "tipo": "trabajo", "peso": 50}`)
+	req, _ = http.NewRequest("POST", "/cursos/1/categorias", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestGetLibreta(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddAlumnoCursos(2, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"nombre": "participacion", "tipo": "manual", "peso": 100}`)
+	req, _ := http.NewRequest("POST", "/cursos/1/categorias", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	jsonStr = []byte(`{"nombre": "semana 1"}`)
+	req, _ = http.NewRequest("POST", "/categorias/1/evaluaciones", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	jsonStr = []byte(`{"alumnoId": 1, "tipo": "manual", "referenciaId": 1, "calificacion": 17}`)
+	req, _ = http.NewRequest("PUT", "/cursos/1/calificaciones/celdas", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("GET", "/cursos/1/calificaciones", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	filas, _ := m["filas"].([]interface{})
+	if len(filas) != 2 {
+		t.Errorf("Expected 2 filas. Got '%v'", m["filas"])
+	}
+}
+
+func TestGuardarCeldaFueraDeRango(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddAlumnoCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"alumnoId": 1, "tipo": "final", "calificacion": 21}`)
+	req, _ := http.NewRequest("PUT", "/cursos/1/calificaciones/celdas", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestExportLibreta(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddAlumnoCursos(2, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", "/cursos/1/calificaciones/export?format=csv&locale=es-ES", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	if ct := response.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Expected Content-Type 'text/csv; charset=utf-8'. Got '%s'", ct)
+	}
+	lineas := strings.Split(strings.TrimSpace(response.Body.String()), "\n")
+	if len(lineas) != 3 {
+		t.Errorf("Expected cabecera and 2 filas. Got '%v'", lineas)
+	}
+	if !strings.HasSuffix(lineas[0], "codigo;apellidos;nombres;promedio;final") {
+		t.Errorf("Expected cabecera separada por ';'. Got '%s'", lineas[0])
+	}
+
+	req, _ = http.NewRequest("GET", "/cursos/1/calificaciones/export?format=ods", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/blackadress/vaula/consulta"
+	"github.com/blackadress/vaula/models"
+)
+
+// consultaListado lee la pagina, filtros y orden de la query string. Si
+// algun parametro no es valido responde 400 y devuelve false.
+func consultaListado(w http.ResponseWriter, r *http.Request, d consulta.Definicion) (consulta.Consulta, bool) {
+	c, err := d.Parse(r.URL.Query())
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- consulta.Parse", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return c, false
+	}
+	return c, true
+}
+
+// respondWithPagina responde la pagina como un array, igual que antes de
+// paginar, y deja el total y los enlaces a las demas paginas en las
+// cabeceras X-Total-Count y Link
+func respondWithPagina(w http.ResponseWriter, r *http.Request, c consulta.Consulta, total int, pagina interface{}) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.Header().Set("Link", c.Enlaces(r.URL, total))
+	respondWithJSON(w, http.StatusOK, pagina)
+}
+
+// expansionRespuesta lee las relaciones de ?expand=. Si pide una relacion
+// que el recurso no tiene responde 400 y devuelve false.
+func expansionRespuesta(w http.ResponseWriter, r *http.Request, permitidas ...string) (models.Expansion, bool) {
+	e, err := models.ParseExpansion(r.URL.Query().Get("expand"), permitidas...)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.ParseExpansion", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return e, false
+	}
+	return e, true
+}
@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// maxParticipantes limita el tamano de las conversaciones grupales,
+// contando al creador
+const maxParticipantes = 8
+
+type nuevaConversacion struct {
+	Asunto        string `json:"asunto"`
+	Participantes []int  `json:"participantes"` // usuarioIds, sin el creador
+	Texto         string `json:"texto"`         // primer mensaje, opcional
+}
+
+type conversacionConMensajes struct {
+	models.Conversacion
+	Mensajes []models.Mensaje `json:"mensajes"`
+}
+
+// getConversacionesHandler lista las conversaciones del usuario
+// autenticado. Con ?cursoId= solo las de ese curso.
+func (a *App) getConversacionesHandler(w http.ResponseWriter, r *http.Request) {
+	cursoId := 0
+	if v := r.URL.Query().Get("cursoId"); v != "" {
+		var err error
+		if cursoId, err = strconv.Atoi(v); err != nil {
+			log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+				http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+			return
+		}
+	}
+
+	conversaciones, err := models.GetConversacionesUsuario(a.DB, getUserId(r), cursoId)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetConversacionesUsuario", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, conversaciones)
+	return
+}
+
+// createConversacionHandler abre una conversacion en el curso. Los
+// profesores pueden escribir a cualquier miembro del curso; los alumnos
+// solo a los profesores del curso.
+func (a *App) createConversacionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+	rol, ok := a.rolEnCurso(w, r, id)
+	if !ok {
+		return
+	}
+
+	var payload nuevaConversacion
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&payload); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	payload.Asunto = strings.TrimSpace(payload.Asunto)
+	if payload.Asunto == "" {
+		log.Printf("POST %s code: %d ERROR: conversacion sin asunto", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "La conversacion debe tener asunto")
+		return
+	}
+	usuarioId := getUserId(r)
+	participantes, msg, err := a.validarParticipantes(id, rol, usuarioId, payload.Participantes)
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- validarParticipantes", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if msg != "" {
+		log.Printf("POST %s code: %d ERROR: %s -- validarParticipantes", r.RequestURI,
+			http.StatusForbidden, msg)
+		respondWithError(w, http.StatusForbidden, msg)
+		return
+	}
+
+	conversacion := models.Conversacion{CursoId: id, CreadaPor: usuarioId, Asunto: payload.Asunto}
+	if err := conversacion.CreateConversacion(a.DB, participantes); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- conversacion.CreateConversacion", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respuesta := conversacionConMensajes{Conversacion: conversacion, Mensajes: []models.Mensaje{}}
+	if texto := strings.TrimSpace(payload.Texto); texto != "" {
+		mensaje := models.Mensaje{ConversacionId: conversacion.ID, UsuarioId: usuarioId, Texto: texto}
+		if err := mensaje.CreateMensaje(a.DB); err != nil {
+			log.Printf("POST %s code: %d ERROR: %s -- mensaje.CreateMensaje", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		a.notificarMensaje(conversacion, mensaje)
+		respuesta.Mensajes = append(respuesta.Mensajes, mensaje)
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, respuesta)
+	return
+}
+
+// validarParticipantes aplica las reglas de quien puede escribir a quien y
+// devuelve los participantes sin repetir ni incluir al creador. Si la
+// conversacion no se permite devuelve el motivo.
+func (a *App) validarParticipantes(cursoId int, rolCreador string, creador int, usuarioIds []int) ([]int, string, error) {
+	vistos := map[int]bool{creador: true}
+	participantes := []int{}
+	for _, id := range usuarioIds {
+		if vistos[id] {
+			continue
+		}
+		vistos[id] = true
+		participantes = append(participantes, id)
+	}
+	if len(participantes) == 0 {
+		return nil, "La conversacion necesita al menos otro participante", nil
+	}
+	if len(participantes)+1 > maxParticipantes {
+		return nil, fmt.Sprintf("Una conversacion admite hasta %d participantes", maxParticipantes), nil
+	}
+
+	for _, id := range participantes {
+		rol, err := models.RolEnCurso(a.DB, id, cursoId)
+		if err != nil {
+			return nil, "", err
+		}
+		if rol == "" {
+			return nil, fmt.Sprintf("El usuario %d no pertenece al curso", id), nil
+		}
+		if rolCreador == models.RolAlumno && rol != models.RolProfesor {
+			return nil, "Los alumnos solo pueden escribir a los profesores del curso", nil
+		}
+	}
+	return participantes, "", nil
+}
+
+// getConversacionByIdHandler devuelve la conversacion con su historial y
+// la marca como leida
+func (a *App) getConversacionByIdHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de conversacion invalido")
+		return
+	}
+	conversacion, ok := a.cargarConversacion(w, r, id)
+	if !ok {
+		return
+	}
+
+	if err := conversacion.MarcarConversacionLeida(a.DB, getUserId(r)); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- conversacion.MarcarConversacionLeida", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	mensajes, err := conversacion.GetMensajes(a.DB)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- conversacion.GetMensajes", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, conversacionConMensajes{Conversacion: conversacion, Mensajes: mensajes})
+	return
+}
+
+// createMensajeHandler recibe JSON, o multipart si el mensaje lleva un
+// archivo adjunto en el campo 'archivo'
+func (a *App) createMensajeHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de conversacion invalido")
+		return
+	}
+	conversacion, ok := a.cargarConversacion(w, r, id)
+	if !ok {
+		return
+	}
+
+	var mensaje models.Mensaje
+	var archivo *archivoSubido
+	if tipo, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); tipo == "multipart/form-data" {
+		archivo, ok = recibirArchivo(w, r, false)
+		if !ok {
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		if archivo != nil {
+			defer archivo.Close()
+		}
+		mensaje.Texto = r.FormValue("texto")
+	} else {
+		var payload struct {
+			Texto string `json:"texto"`
+		}
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&payload); err != nil {
+			log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+				http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusBadRequest, "Invalid payload")
+			return
+		}
+		defer r.Body.Close()
+		mensaje.Texto = payload.Texto
+	}
+
+	if strings.TrimSpace(mensaje.Texto) == "" && archivo == nil {
+		log.Printf("POST %s code: %d ERROR: mensaje vacio", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "El mensaje debe tener texto o un archivo")
+		return
+	}
+
+	mensaje.ConversacionId = conversacion.ID
+	mensaje.UsuarioId = getUserId(r)
+	if archivo != nil {
+		mensaje.NombreArchivo = archivo.Nombre
+		mensaje.MimeType = archivo.MimeType
+		mensaje.Tamano = archivo.Tamano
+		mensaje.Clave = fmt.Sprintf("cursos/%d/conversaciones/%d/%d-%s",
+			conversacion.CursoId, conversacion.ID, time.Now().UnixNano(), archivo.Nombre)
+
+		err := a.Storage.Put(r.Context(), mensaje.Clave, archivo,
+			mensaje.Tamano, mensaje.MimeType)
+		if err != nil {
+			log.Printf("POST %s code: %d ERROR: %s -- Storage.Put", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, "No se pudo guardar el archivo")
+			return
+		}
+	}
+
+	if err := mensaje.CreateMensaje(a.DB); err != nil {
+		if mensaje.TieneAdjunto() {
+			a.Storage.Delete(r.Context(), mensaje.Clave)
+		}
+		log.Printf("POST %s code: %d ERROR: %s -- mensaje.CreateMensaje", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.notificarMensaje(conversacion, mensaje)
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, mensaje)
+	return
+}
+
+// getEnlaceMensajeHandler devuelve un enlace firmado para descargar el
+// adjunto de un mensaje
+func (a *App) getEnlaceMensajeHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de mensaje invalido")
+		return
+	}
+
+	mensaje := models.Mensaje{ID: id}
+	if err := mensaje.GetMensaje(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Mensaje no encontrado")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- mensaje.GetMensaje", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if _, ok := a.cargarConversacion(w, r, mensaje.ConversacionId); !ok {
+		return
+	}
+	if !mensaje.TieneAdjunto() {
+		log.Printf("GET %s code: %d ERROR: mensaje sin adjunto", r.RequestURI,
+			http.StatusNotFound)
+		respondWithError(w, http.StatusNotFound, "El mensaje no tiene archivo")
+		return
+	}
+
+	url, expira := enlaceFirmado(fmt.Sprintf("/mensajes/%d/archivo", mensaje.ID))
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"url":    url,
+		"expira": expira,
+	})
+	return
+}
+
+func (a *App) descargarMensajeHandler(w http.ResponseWriter, r *http.Request) {
+	if !validarEnlace(r) {
+		log.Printf("GET %s code: %d ERROR: enlace invalido o vencido", r.URL.Path,
+			http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "Enlace invalido o vencido")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	mensaje := models.Mensaje{ID: id}
+	if err := mensaje.GetMensaje(a.DB); err != nil || !mensaje.TieneAdjunto() {
+		log.Printf("GET %s code: %d ERROR: mensaje sin archivo", r.URL.Path,
+			http.StatusNotFound)
+		respondWithError(w, http.StatusNotFound, "Archivo no encontrado")
+		return
+	}
+
+	archivo, err := a.Storage.Get(r.Context(), mensaje.Clave)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- Storage.Get", r.URL.Path,
+			http.StatusNotFound, err.Error())
+		respondWithError(w, http.StatusNotFound, "Archivo no encontrado")
+		return
+	}
+	defer archivo.Close()
+
+	err = servirArchivo(w, archivo, mensaje.NombreArchivo, mensaje.MimeType, mensaje.Tamano)
+	if err != nil {
+		log.Printf("GET %s ERROR: %s -- servirArchivo", r.URL.Path, err.Error())
+		return
+	}
+	log.Printf("GET %s code: %d", r.URL.Path, http.StatusOK)
+}
+
+// cargarConversacion carga la conversacion si el usuario del token
+// participa en ella. A los demas se les responde 404, para no revelar
+// que existe.
+func (a *App) cargarConversacion(w http.ResponseWriter, r *http.Request, id int) (models.Conversacion, bool) {
+	conversacion := models.Conversacion{ID: id}
+	err := conversacion.GetConversacion(a.DB)
+	if err == nil && !conversacion.EsParticipante(getUserId(r)) {
+		err = pgx.ErrNoRows
+	}
+	if err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("%s %s code: %d ERROR: %s -- no rows", r.Method, r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Conversacion no encontrada")
+		default:
+			log.Printf("%s %s code: %d ERROR: %s -- conversacion.GetConversacion", r.Method, r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return conversacion, false
+	}
+	return conversacion, true
+}
+
+// notificarMensaje avisa del mensaje a los demas participantes
+func (a *App) notificarMensaje(c models.Conversacion, m models.Mensaje) {
+	destinatarios := []int{}
+	autor := ""
+	for _, p := range c.Participantes {
+		if p.UsuarioId == m.UsuarioId {
+			autor = p.Username
+			continue
+		}
+		destinatarios = append(destinatarios, p.UsuarioId)
+	}
+	cuerpo := m.Texto
+	if cuerpo == "" {
+		cuerpo = "Archivo adjunto: " + m.NombreArchivo
+	}
+	a.notificar(destinatarios, models.Notificacion{
+		Tipo:         models.NotificacionMensaje,
+		Titulo:       fmt.Sprintf("Mensaje de %s: %s", autor, c.Asunto),
+		Cuerpo:       cuerpo,
+		ReferenciaId: c.ID,
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/utils"
+)
+
+// miembroCursoPrueba crea otro usuario como profesor o alumno del curso y
+// devuelve su usuarioId
+func miembroCursoPrueba(username string, rol string, cursoId int) int {
+	user := models.User{Username: username, Password: "pass",
+		Email: username + "@pru.eba", Activo: true}
+	if err := user.CreateUser(a.DB); err != nil {
+		log.Fatalf("Error en el metodo CreateUser, %s", err)
+	}
+
+	switch rol {
+	case models.RolProfesor:
+		profesor := models.Profesor{Nombres: username, Apellidos: username,
+			UsuarioId: user.ID, Activo: true}
+		if err := profesor.CreateProfesor(a.DB); err != nil {
+			log.Fatalf("Error en el metodo CreateProfesor, %s", err)
+		}
+		pc := models.ProfesorCurso{ProfesorId: profesor.ID, CursoId: cursoId}
+		if err := pc.CreateProfesorCurso(a.DB); err != nil {
+			log.Fatalf("Error en el metodo CreateProfesorCurso, %s", err)
+		}
+	default:
+		alumno := models.Alumno{Nombres: username, Apellidos: username,
+			Codigo: "1" + username, UsuarioId: user.ID, Activo: true}
+		if err := alumno.CreateAlumno(a.DB); err != nil {
+			log.Fatalf("Error en el metodo CreateAlumno, %s", err)
+		}
+		ac := models.AlumnoCurso{AlumnoId: alumno.ID, CursoId: cursoId, Activo: true,
+			FechaInicio: time.Now(), FechaFinal: time.Now()}
+		if err := ac.CreateAlumnoCurso(a.DB); err != nil {
+			log.Fatalf("Error en el metodo CreateAlumnoCurso, %s", err)
+		}
+	}
+	return user.ID
+}
+
+func TestAlumnoEscribeAProfesor(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	matricularAlumnoPrueba(alumno, 1)
+	profesorId := miembroCursoPrueba("docente", models.RolProfesor, 1)
+	companeroId := miembroCursoPrueba("companero", models.RolAlumno, 1)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	payload := []byte(fmt.Sprintf(`{"asunto":"Tarea","participantes":[%d]}`, companeroId))
+	req, _ := http.NewRequest("POST", "/cursos/1/conversaciones", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	payload = []byte(fmt.Sprintf(`{"asunto":"Tarea","participantes":[%d],"texto":"hola"}`, profesorId))
+	req, _ = http.NewRequest("POST", "/cursos/1/conversaciones", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m conversacionConMensajes
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if len(m.Participantes) != 2 || len(m.Mensajes) != 1 {
+		t.Errorf("Se esperaban 2 participantes y 1 mensaje. Se obtuvo %v", m)
+	}
+
+	notificaciones, _ := models.GetNotificacionesUsuario(a.DB, profesorId, true)
+	if len(notificaciones) != 1 || notificaciones[0].Tipo != models.NotificacionMensaje {
+		t.Errorf("Se esperaba un aviso de mensaje para el profesor. Se obtuvo %v", notificaciones)
+	}
+}
+
+func TestConversacionSoloParticipantes(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	matricularAlumnoPrueba(alumno, 1)
+	profesorId := miembroCursoPrueba("docente", models.RolProfesor, 1)
+	companeroId := miembroCursoPrueba("companero", models.RolAlumno, 1)
+
+	conversacion := models.Conversacion{CursoId: 1, CreadaPor: profesorId, Asunto: "Notas"}
+	conversacion.CreateConversacion(a.DB, []int{companeroId})
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/conversaciones/%d", conversacion.ID), nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	payload := []byte(`{"texto":"hola"}`)
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/conversaciones/%d/mensajes", conversacion.ID),
+		bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blackadress/vaula/correo"
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// intervaloDespacho es cada cuanto el despachador revisa la cola
+	intervaloDespacho = 30 * time.Second
+	// correosPorDespacho limita los correos que se envian en cada vuelta
+	correosPorDespacho = 50
+	// maxIntentosCorreo es la cantidad de envios antes de dar un correo
+	// por fallido
+	maxIntentosCorreo = 5
+	// esperaMaximaCorreo acota el tiempo entre reintentos
+	esperaMaximaCorreo = time.Hour
+	// formatoFechaAviso es como se muestran las fechas en las notificaciones
+	formatoFechaAviso = "02/01/2006 15:04"
+)
+
+// getNotificacionesHandler devuelve la bandeja del usuario autenticado.
+// Con ?noLeidas=true solo las que no leyo.
+func (a *App) getNotificacionesHandler(w http.ResponseWriter, r *http.Request) {
+	soloNoLeidas := r.URL.Query().Get("noLeidas") == "true"
+
+	notificaciones, err := models.GetNotificacionesUsuario(a.DB, getUserId(r), soloNoLeidas)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetNotificacionesUsuario", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, notificaciones)
+	return
+}
+
+func (a *App) marcarNotificacionLeidaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de notificacion invalido")
+		return
+	}
+
+	// las notificaciones de otro usuario se tratan como inexistentes
+	notificacion := models.Notificacion{ID: id, UsuarioId: getUserId(r)}
+	if err := notificacion.MarcarLeida(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("PUT %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Notificacion no encontrada")
+		default:
+			log.Printf("PUT %s code: %d ERROR: %s -- notificacion.MarcarLeida", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, notificacion)
+	return
+}
+
+func (a *App) marcarNotificacionesLeidasHandler(w http.ResponseWriter, r *http.Request) {
+	marcadas, err := models.MarcarTodasLeidas(a.DB, getUserId(r))
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- models.MarcarTodasLeidas", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]int64{"marcadas": marcadas})
+	return
+}
+
+func (a *App) getPreferenciasNotificacionHandler(w http.ResponseWriter, r *http.Request) {
+	preferencias, err := models.GetPreferenciasNotificacion(a.DB, getUserId(r))
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetPreferenciasNotificacion", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, preferencias)
+	return
+}
+
+// updatePreferenciasNotificacionHandler guarda las preferencias enviadas;
+// los tipos que no vienen en el payload conservan su valor
+func (a *App) updatePreferenciasNotificacionHandler(w http.ResponseWriter, r *http.Request) {
+	var preferencias []models.PreferenciaNotificacion
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&preferencias); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	for _, p := range preferencias {
+		if !models.TipoNotificacionValido(p.Tipo) {
+			log.Printf("PUT %s code: %d ERROR: tipo %q desconocido", r.RequestURI,
+				http.StatusBadRequest, p.Tipo)
+			respondWithError(w, http.StatusBadRequest, "Tipo de notificacion desconocido: "+p.Tipo)
+			return
+		}
+	}
+
+	usuarioId := getUserId(r)
+	for _, p := range preferencias {
+		p.UsuarioId = usuarioId
+		if err := p.GuardarPreferencia(a.DB); err != nil {
+			log.Printf("PUT %s code: %d ERROR: %s -- p.GuardarPreferencia", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	a.getPreferenciasNotificacionHandler(w, r)
+}
+
+// notificar avisa a los usuarios sin interrumpir la request que la origina:
+// un fallo solo queda en el log
+func (a *App) notificar(usuarioIds []int, n models.Notificacion) {
+	if err := models.Notificar(a.DB, usuarioIds, n); err != nil {
+		log.Printf("NOTIFICACION %s ERROR: %s -- models.Notificar", n.Tipo, err.Error())
+	}
+}
+
+// notificarCurso avisa a todos los alumnos matriculados en el curso
+func (a *App) notificarCurso(cursoId int, n models.Notificacion) {
+	alumnos, err := models.GetAlumnosCurso(a.DB, cursoId)
+	if err != nil {
+		log.Printf("NOTIFICACION %s ERROR: %s -- models.GetAlumnosCurso", n.Tipo, err.Error())
+		return
+	}
+	a.notificar(models.UsuariosDeAlumnos(alumnos), n)
+}
+
+// despacharCorreos envia la cola de correos cada intervaloDespacho hasta
+// que se cancela el contexto
+func (a *App) despacharCorreos(ctx context.Context) {
+	ticker := time.NewTicker(intervaloDespacho)
+	defer ticker.Stop()
+	for {
+		a.despacharPendientes(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// despacharPendientes envia los correos cuyo turno ya llego y devuelve
+// cuantos salieron. Los que fallan se reintentan con espera exponencial.
+func (a *App) despacharPendientes(ctx context.Context) int {
+	envios, err := models.TomarEnviosPendientes(a.DB, correosPorDespacho)
+	if err != nil {
+		log.Printf("CORREO ERROR: %s -- models.TomarEnviosPendientes", err.Error())
+		return 0
+	}
+
+	enviados := 0
+	for _, e := range envios {
+		err := a.Mailer.Enviar(ctx, correo.Mensaje{Para: e.Destinatario, Asunto: e.Asunto, Cuerpo: e.Cuerpo})
+		if err != nil {
+			log.Printf("CORREO %d ERROR: %s -- a.Mailer.Enviar", e.ID, err.Error())
+			if err := e.RegistrarFallo(a.DB, err, maxIntentosCorreo, esperaReintento(e.Intentos+1)); err != nil {
+				log.Printf("CORREO %d ERROR: %s -- e.RegistrarFallo", e.ID, err.Error())
+			}
+			continue
+		}
+		if err := e.MarcarEnviado(a.DB); err != nil {
+			log.Printf("CORREO %d ERROR: %s -- e.MarcarEnviado", e.ID, err.Error())
+			continue
+		}
+		enviados++
+	}
+	return enviados
+}
+
+// esperaReintento duplica la espera con cada intento fallido: 1, 2, 4...
+// minutos, hasta esperaMaximaCorreo
+func esperaReintento(intentos int) time.Duration {
+	espera := time.Minute
+	for i := 1; i < intentos && espera < esperaMaximaCorreo; i++ {
+		espera *= 2
+	}
+	if espera > esperaMaximaCorreo {
+		espera = esperaMaximaCorreo
+	}
+	return espera
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestGetNotificaciones(t *testing.T) {
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	n := models.Notificacion{Tipo: models.NotificacionAnuncio, Titulo: "Feriado", Cuerpo: "No hay clase"}
+	models.Notificar(a.DB, []int{token.UserId}, n)
+
+	req, _ := http.NewRequest("GET", "/notificaciones?noLeidas=true", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var notificaciones []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &notificaciones)
+	if len(notificaciones) != 1 || notificaciones[0]["titulo"] != "Feriado" {
+		t.Fatalf("Se esperaba una notificacion sin leer. Se obtuvo %v", notificaciones)
+	}
+
+	req, _ = http.NewRequest("PUT", fmt.Sprintf("/notificaciones/%v/leida", notificaciones[0]["id"]), nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("GET", "/notificaciones?noLeidas=true", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	if body := response.Body.String(); body != "[]" {
+		t.Errorf("Se esperaba la bandeja sin notificaciones por leer. Se obtuvo %s", body)
+	}
+}
+
+func TestMarcarNotificacionInexistente(t *testing.T) {
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("PUT", "/notificaciones/99/leida", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+func TestPreferenciasNotificacion(t *testing.T) {
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`[{"tipo": "desconocido", "app": true, "email": true}]`)
+	req, _ := http.NewRequest("PUT", "/notificaciones/preferencias", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	jsonStr = []byte(`[{"tipo": "anuncio", "app": true, "email": false}]`)
+	req, _ = http.NewRequest("PUT", "/notificaciones/preferencias", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var preferencias []models.PreferenciaNotificacion
+	json.Unmarshal(response.Body.Bytes(), &preferencias)
+	for _, p := range preferencias {
+		if p.Tipo == models.NotificacionAnuncio && p.Email {
+			t.Errorf("Se esperaba el correo de anuncios desactivado. Se obtuvo %v", p)
+		}
+		if p.Tipo != models.NotificacionAnuncio && !p.Email {
+			t.Errorf("Se esperaba el correo activo por defecto. Se obtuvo %v", p)
+		}
+	}
+}
+
+func TestDespacharCorreos(t *testing.T) {
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+	buzon.Vaciar()
+
+	token := getTestJWT()
+	n := models.Notificacion{Tipo: models.NotificacionCalificacion, Titulo: "Calificacion publicada", Cuerpo: "Nota 18"}
+	models.Notificar(a.DB, []int{token.UserId}, n)
+
+	// el primer envio falla y el correo espera su reintento
+	buzon.Fallar = 1
+	if enviados := a.despacharPendientes(context.Background()); enviados != 0 {
+		t.Errorf("No se esperaban correos enviados. Se obtuvo %d", enviados)
+	}
+	envios, _ := models.GetEnviosUsuario(a.DB, token.UserId)
+	if len(envios) != 1 || envios[0].Estado != models.EnvioPendiente || envios[0].Intentos != 1 ||
+		!envios[0].ProximoIntento.After(time.Now()) {
+		t.Fatalf("Se esperaba el correo reprogramado. Se obtuvo %v", envios)
+	}
+	if enviados := a.despacharPendientes(context.Background()); enviados != 0 {
+		t.Errorf("El correo no debe reintentarse antes de tiempo. Se enviaron %d", enviados)
+	}
+
+	a.DB.Exec(context.Background(), "UPDATE enviosCorreo SET proximoIntento=$1", time.Now())
+	if enviados := a.despacharPendientes(context.Background()); enviados != 1 {
+		t.Errorf("Se esperaba 1 correo enviado. Se obtuvo %d", enviados)
+	}
+	recibidos := buzon.Enviados()
+	if len(recibidos) != 1 || recibidos[0].Para != "prueba@pru.eba" || recibidos[0].Asunto != n.Titulo {
+		t.Errorf("Se esperaba el correo en el buzon. Se obtuvo %v", recibidos)
+	}
+}
+
+func TestCreateExamenNotificaAlumnos(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	matricularAlumnoPrueba(alumno, 1)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`
+	{
+		"nombre": "parcial",
+		"fechaInicio": "2016-06-22T19:10:25-05:00",
+		"fechaFinal": "2016-06-24T19:10:25-05:00",
+		"cursoId": 1,
+		"activo": true
+	}`)
+	req, _ := http.NewRequest("POST", "/examenes", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	notificaciones, _ := models.GetNotificacionesUsuario(a.DB, token.UserId, true)
+	if len(notificaciones) != 1 || notificaciones[0].Tipo != models.NotificacionExamen {
+		t.Errorf("Se esperaba la notificacion del examen. Se obtuvo %v", notificaciones)
+	}
+}
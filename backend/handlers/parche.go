@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/parche"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// maxParcheBytes limita el cuerpo de los PATCH
+const maxParcheBytes = 1 << 20
+
+// recursoParcheable describe como se aplica un PATCH a un recurso
+type recursoParcheable struct {
+	nombre string // para los mensajes de error
+	// cargar devuelve un puntero al recurso actual
+	cargar func(db *pgxpool.Pool, id int) (interface{}, error)
+	// preparar ajusta el recurso parchado segun los campos presentes en
+	// el parche. Opcional.
+	preparar func(campos map[string]json.RawMessage, nuevo interface{})
+	// validar revisa el recurso ya parchado antes de guardarlo y devuelve
+	// el mensaje de error, o "" si es valido. Opcional.
+	validar func(actual, nuevo interface{}) string
+	// actualizar guarda el recurso parchado, que recibe como cuerpo de la
+	// request igual que un PUT
+	actualizar http.HandlerFunc
+}
+
+// patchHandler aplica un JSON Merge Patch (RFC 7396) sobre el recurso
+// actual: solo cambian los campos presentes en el parche y un null vuelve
+// el campo a su valor vacio. El resultado se valida y se entrega al handler
+// de PUT del recurso, asi pasa por los mismos permisos, validaciones y
+// avisos.
+func (a *App) patchHandler(rec recursoParcheable) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			log.Printf("PATCH %s code: %d ERROR: %s -- strconv", r.RequestURI,
+				http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusBadRequest, "ID de "+rec.nombre+" invalido")
+			return
+		}
+
+		tipo, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if tipo != parche.TipoContenido && tipo != "application/json" {
+			log.Printf("PATCH %s code: %d ERROR: Content-Type %q", r.RequestURI,
+				http.StatusUnsupportedMediaType, tipo)
+			respondWithError(w, http.StatusUnsupportedMediaType,
+				"Se esperaba Content-Type "+parche.TipoContenido)
+			return
+		}
+
+		cuerpo, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxParcheBytes))
+		if err != nil {
+			log.Printf("PATCH %s code: %d ERROR: %s -- ioutil.ReadAll", r.RequestURI,
+				http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusBadRequest, "Invalid payload")
+			return
+		}
+		r.Body.Close()
+
+		actual, err := rec.cargar(a.DB, id)
+		if err != nil {
+			switch err {
+			case pgx.ErrNoRows:
+				log.Printf("PATCH %s code: %d ERROR: %s -- no rows", r.RequestURI,
+					http.StatusNotFound, err.Error())
+				respondWithError(w, http.StatusNotFound, rec.nombre+" no encontrado")
+			default:
+				log.Printf("PATCH %s code: %d ERROR: %s -- cargar", r.RequestURI,
+					http.StatusInternalServerError, err.Error())
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+			}
+			return
+		}
+
+		original, err := json.Marshal(actual)
+		if err != nil {
+			log.Printf("PATCH %s code: %d ERROR: %s -- json.Marshal", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		parchado, err := parche.Aplicar(original, cuerpo)
+		if err != nil {
+			log.Printf("PATCH %s code: %d ERROR: %s -- parche.Aplicar", r.RequestURI,
+				http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusBadRequest, "Invalid payload")
+			return
+		}
+
+		// el parche no puede traer campos que el recurso no tiene ni valores
+		// de otro tipo
+		nuevo := reflect.New(reflect.TypeOf(actual).Elem()).Interface()
+		decoder := json.NewDecoder(bytes.NewReader(parchado))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(nuevo); err != nil {
+			log.Printf("PATCH %s code: %d ERROR: %s -- decoder", r.RequestURI,
+				http.StatusBadRequest, err.Error())
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if rec.preparar != nil {
+			// Aplicar ya comprobo que el parche es un objeto
+			var campos map[string]json.RawMessage
+			json.Unmarshal(cuerpo, &campos)
+			rec.preparar(campos, nuevo)
+		}
+		if rec.validar != nil {
+			if msg := rec.validar(actual, nuevo); msg != "" {
+				log.Printf("PATCH %s code: %d ERROR: %s", r.RequestURI,
+					http.StatusBadRequest, msg)
+				respondWithError(w, http.StatusBadRequest, msg)
+				return
+			}
+		}
+
+		completo, err := json.Marshal(nuevo)
+		if err != nil {
+			log.Printf("PATCH %s code: %d ERROR: %s -- json.Marshal", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(completo))
+		r.ContentLength = int64(len(completo))
+		r.Header.Set("Content-Type", "application/json")
+		rec.actualizar(w, r)
+	}
+}
+
+// registrarPatch agrega las rutas PATCH de los recursos que tienen PUT
+func (a *App) registrarPatch() {
+	recursos := map[string]recursoParcheable{
+		"/users/{id:[0-9]+}": {
+			nombre: "usuario",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				u := &models.User{ID: id}
+				return u, u.GetUser(db)
+			},
+			validar:    validarUsuarioParchado,
+			actualizar: a.guardarUsuarioParchadoHandler,
+		},
+		"/cursos/{id:[0-9]+}": {
+			nombre: "curso",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				c := &models.Curso{ID: id}
+				return c, c.GetCurso(db)
+			},
+			validar: func(_, nuevo interface{}) string {
+				c := nuevo.(*models.Curso)
+				if c.Nombre == "" || c.Siglas == "" {
+					return "El curso debe tener nombre y siglas"
+				}
+				return ""
+			},
+			actualizar: a.updateCursoHandler,
+		},
+		"/alumnos/{id:[0-9]+}": {
+			nombre: "alumno",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				al := &models.Alumno{ID: id}
+				return al, al.GetAlumno(db)
+			},
+			validar: func(_, nuevo interface{}) string {
+				al := nuevo.(*models.Alumno)
+				if al.Nombres == "" || al.Apellidos == "" || al.Codigo == "" {
+					return "El alumno debe tener nombres, apellidos y codigo"
+				}
+				return ""
+			},
+			actualizar: a.updateAlumnoHandler,
+		},
+		"/profesores/{id:[0-9]+}": {
+			nombre: "profesor",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				p := &models.Profesor{ID: id}
+				return p, p.GetProfesor(db)
+			},
+			validar: func(_, nuevo interface{}) string {
+				p := nuevo.(*models.Profesor)
+				if p.Nombres == "" || p.Apellidos == "" {
+					return "El profesor debe tener nombres y apellidos"
+				}
+				return ""
+			},
+			actualizar: a.updateProfesorHandler,
+		},
+		"/examenes/{id:[0-9]+}": {
+			nombre: "examen",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				e := &models.Examen{ID: id}
+				return e, e.GetExamen(db)
+			},
+			validar: func(_, nuevo interface{}) string {
+				e := nuevo.(*models.Examen)
+				if e.Nombre == "" {
+					return "El examen debe tener nombre"
+				}
+				if e.FechaFinal.Before(e.FechaInicio) {
+					return "La fecha final no puede ser anterior a la fecha de inicio"
+				}
+				return ""
+			},
+			actualizar: a.updateExamenHandler,
+		},
+		"/preguntas/{id:[0-9]+}": {
+			nombre: "pregunta",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				p := &models.Pregunta{ID: id}
+				return p, p.GetPregunta(db)
+			},
+			validar: func(_, nuevo interface{}) string {
+				if nuevo.(*models.Pregunta).Enunciado == "" {
+					return "La pregunta debe tener enunciado"
+				}
+				return ""
+			},
+			actualizar: a.updatePreguntaHandler,
+		},
+		"/alternativas/{id:[0-9]+}": {
+			nombre: "alternativa",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				al := &models.Alternativa{ID: id}
+				return al, al.GetAlternativa(db)
+			},
+			validar: func(_, nuevo interface{}) string {
+				if nuevo.(*models.Alternativa).Valor == "" {
+					return "La alternativa debe tener valor"
+				}
+				return ""
+			},
+			actualizar: a.updateAlternativaHandler,
+		},
+		"/trabajos/{id:[0-9]+}": {
+			nombre: "trabajo",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				t := &models.Trabajo{ID: id}
+				return t, t.GetTrabajo(db)
+			},
+			validar: func(_, nuevo interface{}) string {
+				t := nuevo.(*models.Trabajo)
+				if t.Descripcion == "" {
+					return "El trabajo debe tener descripcion"
+				}
+				if t.FechaFinal.Before(t.FechaInicio) {
+					return "La fecha final no puede ser anterior a la fecha de inicio"
+				}
+				return ""
+			},
+			actualizar: a.updateTrabajoHandler,
+		},
+		// los handlers de PUT de estos recursos ya validan el resultado
+		"/categorias/{id:[0-9]+}": {
+			nombre: "categoria",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				c := &models.CategoriaCalificacion{ID: id}
+				return c, c.GetCategoria(db)
+			},
+			actualizar: a.updateCategoriaHandler,
+		},
+		"/sesiones/{id:[0-9]+}": {
+			nombre: "sesion",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				s := &models.Sesion{ID: id}
+				return s, s.GetSesion(db)
+			},
+			actualizar: a.updateSesionHandler,
+		},
+		"/anuncios/{id:[0-9]+}": {
+			nombre: "anuncio",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				an := &models.Anuncio{ID: id}
+				return an, an.GetAnuncio(db)
+			},
+			actualizar: a.updateAnuncioHandler,
+		},
+		"/publicaciones/{id:[0-9]+}": {
+			nombre: "publicacion",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				p := &models.Publicacion{ID: id}
+				return p, p.GetPublicacion(db)
+			},
+			actualizar: a.updatePublicacionHandler,
+		},
+		"/hilos/{id:[0-9]+}": {
+			nombre: "hilo",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				h := &models.Hilo{ID: id}
+				return h, h.GetHilo(db)
+			},
+			actualizar: a.moderarHiloHandler,
+		},
+		"/prorrogas/{id:[0-9]+}": {
+			nombre: "prorroga",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				p := &models.Prorroga{ID: id}
+				return p, p.GetProrroga(db)
+			},
+			actualizar: a.updateProrrogaHandler,
+		},
+		"/rubricas/{id:[0-9]+}": {
+			nombre: "rubrica",
+			cargar: func(db *pgxpool.Pool, id int) (interface{}, error) {
+				rb := &models.Rubrica{ID: id}
+				return rb, rb.GetRubrica(db)
+			},
+			// el PUT reemplaza los criterios si vienen, y no se pueden
+			// reemplazar en una rubrica ya usada; solo se envian si el
+			// parche los cambia
+			preparar: func(campos map[string]json.RawMessage, nuevo interface{}) {
+				if _, ok := campos["criterios"]; !ok {
+					nuevo.(*models.Rubrica).Criterios = nil
+				}
+			},
+			actualizar: a.updateRubricaHandler,
+		},
+	}
+	for ruta, rec := range recursos {
+		a.Router.Handle(ruta, isAuthorized(a.patchHandler(rec))).Methods("PATCH")
+	}
+}
+
+// validarUsuarioParchado ademas hashea la password si el parche trae una
+// nueva; si no, se conserva el hash actual
+func validarUsuarioParchado(actual, nuevo interface{}) string {
+	u := nuevo.(*models.User)
+	if u.Username == "" || u.Email == "" {
+		return "El usuario debe tener username y email"
+	}
+	if u.Password == "" {
+		return "La password no puede estar vacia"
+	}
+	if u.Password != actual.(*models.User).Password {
+		u.Password = hashAndSalt([]byte(u.Password))
+	}
+	return ""
+}
+
+// guardarUsuarioParchadoHandler guarda el usuario parchado sin devolver el
+// hash de la password, a diferencia del PUT
+func (a *App) guardarUsuarioParchadoHandler(w http.ResponseWriter, r *http.Request) {
+	var u models.User
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		log.Printf("PATCH %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	u.ID, _ = strconv.Atoi(mux.Vars(r)["id"])
+	if err := u.UpdateUser(a.DB); err != nil {
+		log.Printf("PATCH %s code: %d ERROR: %s -- u.UpdateUser", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	u.Password = "" // no regresar la password hash en la respuesta
+
+	log.Printf("PATCH %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, u)
+	return
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/jackc/pgx/v4"
+)
+
+// profesorAutenticado obtiene el profesor asociado al usuario del token.
+// Si el usuario no es profesor responde 403 y devuelve false.
+func (a *App) profesorAutenticado(w http.ResponseWriter, r *http.Request) (models.Profesor, bool) {
+	profesor := models.Profesor{UsuarioId: getUserId(r)}
+	err := profesor.GetProfesorByUsuario(a.DB)
+	if err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("%s %s code: %d ERROR: %s -- usuario no es profesor", r.Method,
+				r.RequestURI, http.StatusForbidden, err.Error())
+			respondWithError(w, http.StatusForbidden, "Solo disponible para profesores")
+		default:
+			log.Printf("%s %s code: %d ERROR: %s -- profesor.GetProfesorByUsuario",
+				r.Method, r.RequestURI, http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return profesor, false
+	}
+	return profesor, true
+}
+
+// alumnoAutenticado obtiene el alumno asociado al usuario del token.
+// Si el usuario no es alumno responde 403 y devuelve false.
+func (a *App) alumnoAutenticado(w http.ResponseWriter, r *http.Request) (models.Alumno, bool) {
+	alumno := models.Alumno{UsuarioId: getUserId(r)}
+	err := alumno.GetAlumnoByUsuario(a.DB)
+	if err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("%s %s code: %d ERROR: %s -- usuario no es alumno", r.Method,
+				r.RequestURI, http.StatusForbidden, err.Error())
+			respondWithError(w, http.StatusForbidden, "Solo disponible para alumnos")
+		default:
+			log.Printf("%s %s code: %d ERROR: %s -- alumno.GetAlumnoByUsuario",
+				r.Method, r.RequestURI, http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return alumno, false
+	}
+	return alumno, true
+}
+
+// rolEnCurso verifica que el usuario del token sea un profesor asignado o
+// un alumno matriculado en el curso y devuelve su rol. Si no pertenece al
+// curso responde 403 y devuelve false.
+func (a *App) rolEnCurso(w http.ResponseWriter, r *http.Request, cursoId int) (string, bool) {
+	rol, err := models.RolEnCurso(a.DB, getUserId(r), cursoId)
+	if err != nil {
+		log.Printf("%s %s code: %d ERROR: %s -- models.RolEnCurso", r.Method,
+			r.RequestURI, http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return "", false
+	}
+	if rol == "" {
+		log.Printf("%s %s code: %d ERROR: usuario fuera del curso %d", r.Method,
+			r.RequestURI, http.StatusForbidden, cursoId)
+		respondWithError(w, http.StatusForbidden, "Solo disponible para miembros del curso")
+		return "", false
+	}
+	return rol, true
+}
+
+// profesorDelCurso es como rolEnCurso pero solo acepta a los profesores
+// asignados al curso
+func (a *App) profesorDelCurso(w http.ResponseWriter, r *http.Request, cursoId int) bool {
+	rol, ok := a.rolEnCurso(w, r, cursoId)
+	if !ok {
+		return false
+	}
+	if rol != models.RolProfesor {
+		log.Printf("%s %s code: %d ERROR: usuario no es profesor del curso %d", r.Method,
+			r.RequestURI, http.StatusForbidden, cursoId)
+		respondWithError(w, http.StatusForbidden, "Solo disponible para profesores del curso")
+		return false
+	}
+	return true
+}
+
+// profesorDelExamen carga el examen y verifica que el usuario sea profesor
+// de su curso. Si el examen no existe responde 404.
+func (a *App) profesorDelExamen(w http.ResponseWriter, r *http.Request, examenId int) (models.Examen, bool) {
+	examen := models.Examen{ID: examenId}
+	if err := examen.GetExamen(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("%s %s code: %d ERROR: %s -- no rows", r.Method, r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Examen no encontrado")
+		default:
+			log.Printf("%s %s code: %d ERROR: %s -- examen.GetExamen", r.Method,
+				r.RequestURI, http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return examen, false
+	}
+	return examen, a.profesorDelCurso(w, r, examen.CursoId)
+}
+
+// profesorDelTrabajo carga el trabajo y verifica que el usuario sea
+// profesor de su curso. Si el trabajo no existe responde 404.
+func (a *App) profesorDelTrabajo(w http.ResponseWriter, r *http.Request, trabajoId int) (models.Trabajo, bool) {
+	trabajo := models.Trabajo{ID: trabajoId}
+	if err := trabajo.GetTrabajo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("%s %s code: %d ERROR: %s -- no rows", r.Method, r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Trabajo no encontrado")
+		default:
+			log.Printf("%s %s code: %d ERROR: %s -- trabajo.GetTrabajo", r.Method,
+				r.RequestURI, http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return trabajo, false
+	}
+	return trabajo, a.profesorDelCurso(w, r, trabajo.CursoId)
+}
+
+// profesorDeProrroga verifica que el usuario sea profesor del curso del
+// examen o trabajo al que se aplica la prorroga
+func (a *App) profesorDeProrroga(w http.ResponseWriter, r *http.Request, p models.Prorroga) bool {
+	if p.ExamenId != 0 {
+		_, ok := a.profesorDelExamen(w, r, p.ExamenId)
+		return ok
+	}
+	_, ok := a.profesorDelTrabajo(w, r, p.TrabajoId)
+	return ok
+}
+
+// accesoEntregaAlumno verifica que el usuario pueda ver una entrega del
+// trabajo: un profesor del curso del trabajo o el alumno que la hizo
+func (a *App) accesoEntregaAlumno(w http.ResponseWriter, r *http.Request, trabajoId int, alumnoId int) (esProfesor bool, ok bool) {
+	trabajo := models.Trabajo{ID: trabajoId}
+	if err := trabajo.GetTrabajo(a.DB); err != nil {
+		log.Printf("%s %s code: %d ERROR: %s -- trabajo.GetTrabajo", r.Method,
+			r.RequestURI, http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return false, false
+	}
+	rol, err := models.RolEnCurso(a.DB, getUserId(r), trabajo.CursoId)
+	if err != nil {
+		log.Printf("%s %s code: %d ERROR: %s -- models.RolEnCurso", r.Method,
+			r.RequestURI, http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return false, false
+	}
+	if rol == models.RolProfesor {
+		return true, true
+	}
+	alumno, ok := a.alumnoAutenticado(w, r)
+	if !ok {
+		return false, false
+	}
+	if alumno.ID != alumnoId {
+		log.Printf("%s %s code: %d ERROR: entrega de otro alumno", r.Method, r.RequestURI,
+			http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "No puede ver esta entrega")
+		return false, false
+	}
+	return false, true
+}
@@ -21,6 +21,11 @@ func (a *App) getPreguntaByIdHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expansion, ok := expansionRespuesta(w, r, models.ExpandirExamen, models.ExpandirCurso)
+	if !ok {
+		return
+	}
+
 	pregunta := models.Pregunta{ID: id}
 	err = pregunta.GetPregunta(a.DB)
 	if err != nil {
@@ -36,13 +41,29 @@ func (a *App) getPreguntaByIdHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	preguntas := []models.Pregunta{pregunta}
+	if err := models.ExpandirPreguntas(a.DB, preguntas, expansion); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.ExpandirPreguntas", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
-	respondWithJSON(w, http.StatusOK, pregunta)
+	respondWithJSON(w, http.StatusOK, preguntas[0])
 	return
 }
 
 func (a *App) getPreguntasHandler(w http.ResponseWriter, r *http.Request) {
-	preguntas, err := models.GetPreguntas(a.DB)
+	c, ok := consultaListado(w, r, models.ListadoPreguntas)
+	if !ok {
+		return
+	}
+	expansion, ok := expansionRespuesta(w, r, models.ExpandirExamen, models.ExpandirCurso)
+	if !ok {
+		return
+	}
+
+	preguntas, total, err := models.GetPreguntas(a.DB, c)
 	if err != nil {
 		log.Printf("GET %s code: %d ERROR: %s -- models.GetPreguntas", r.RequestURI,
 			http.StatusInternalServerError, err.Error())
@@ -50,8 +71,15 @@ func (a *App) getPreguntasHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := models.ExpandirPreguntas(a.DB, preguntas, expansion); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.ExpandirPreguntas", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
-	respondWithJSON(w, http.StatusOK, preguntas)
+	respondWithPagina(w, r, c, total, preguntas)
 	return
 
 }
@@ -21,6 +21,11 @@ func (a *App) getProfesorByIdHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expansion, ok := expansionRespuesta(w, r, models.ExpandirUsuario)
+	if !ok {
+		return
+	}
+
 	profesor := models.Profesor{ID: id}
 	err = profesor.GetProfesor(a.DB)
 	if err != nil {
@@ -36,12 +41,24 @@ func (a *App) getProfesorByIdHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	profesores := []models.Profesor{profesor}
+	if err := models.ExpandirProfesores(a.DB, profesores, expansion); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.ExpandirProfesores", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
-	respondWithJSON(w, http.StatusOK, profesor)
+	respondWithJSON(w, http.StatusOK, profesores[0])
 	return
 }
 
 func (a *App) getProfesoresHandler(w http.ResponseWriter, r *http.Request) {
+	expansion, ok := expansionRespuesta(w, r, models.ExpandirUsuario)
+	if !ok {
+		return
+	}
+
 	profesores, err := models.GetProfesores(a.DB)
 	if err != nil {
 		log.Printf("GET %s code: %d ERROR: %s -- models.GetProfesores", r.RequestURI,
@@ -50,6 +67,13 @@ func (a *App) getProfesoresHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := models.ExpandirProfesores(a.DB, profesores, expansion); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.ExpandirProfesores", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
 	respondWithJSON(w, http.StatusOK, profesores)
 	return
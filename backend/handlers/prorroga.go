@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+func (a *App) getProrrogaByIdHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de prorroga invalido")
+		return
+	}
+
+	prorroga := models.Prorroga{ID: id}
+	err = prorroga.GetProrroga(a.DB)
+	if err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Prorroga no encontrada")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- prorroga.GetProrroga",
+				r.RequestURI, http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if !a.profesorDeProrroga(w, r, prorroga) {
+		return
+	}
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, prorroga)
+	return
+}
+
+func (a *App) getProrrogasExamenHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de examen invalido")
+		return
+	}
+	if _, ok := a.profesorDelExamen(w, r, id); !ok {
+		return
+	}
+
+	prorrogas, err := models.GetProrrogasExamen(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetProrrogasExamen", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, prorrogas)
+	return
+}
+
+func (a *App) getProrrogasTrabajoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de trabajo invalido")
+		return
+	}
+	if _, ok := a.profesorDelTrabajo(w, r, id); !ok {
+		return
+	}
+
+	prorrogas, err := models.GetProrrogasTrabajo(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetProrrogasTrabajo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, prorrogas)
+	return
+}
+
+func (a *App) getProrrogaHistorialHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de prorroga invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	historial, err := models.GetProrrogaHistorial(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetProrrogaHistorial", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// la prorroga pudo eliminarse, el examen o trabajo se toma del historial
+	if len(historial) > 0 {
+		var prorroga models.Prorroga
+		if err := json.Unmarshal(historial[0].Detalle, &prorroga); err != nil {
+			log.Printf("GET %s code: %d ERROR: %s -- json.Unmarshal", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !a.profesorDeProrroga(w, r, prorroga) {
+			return
+		}
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, historial)
+	return
+}
+
+func (a *App) createProrrogaHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var prorroga models.Prorroga
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&prorroga)
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if msg := validarProrroga(prorroga); msg != "" {
+		log.Printf("POST %s code: %d ERROR: %s -- validarProrroga", r.RequestURI,
+			http.StatusBadRequest, msg)
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if !a.profesorDeProrroga(w, r, prorroga) {
+		return
+	}
+
+	// quien otorga la prorroga es siempre el usuario autenticado
+	prorroga.OtorgadoPor = getUserId(r)
+	prorroga.Activo = true
+	err = prorroga.CreateProrroga(a.DB)
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- prorroga.CreateProrroga", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, prorroga)
+	return
+}
+
+func (a *App) updateProrrogaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de prorroga invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var prorroga models.Prorroga
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&prorroga)
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if msg := validarProrroga(prorroga); msg != "" {
+		log.Printf("PUT %s code: %d ERROR: %s -- validarProrroga", r.RequestURI,
+			http.StatusBadRequest, msg)
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	// el profesor debe serlo del curso de la prorroga y del nuevo examen o
+	// trabajo si se cambia
+	actual := models.Prorroga{ID: id}
+	if err := actual.GetProrroga(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("PUT %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Prorroga no encontrada")
+		default:
+			log.Printf("PUT %s code: %d ERROR: %s -- actual.GetProrroga", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if !a.profesorDeProrroga(w, r, actual) || !a.profesorDeProrroga(w, r, prorroga) {
+		return
+	}
+
+	prorroga.ID = id
+	err = prorroga.UpdateProrroga(a.DB, getUserId(r))
+	if err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("PUT %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Prorroga no encontrada")
+		default:
+			log.Printf("PUT %s code: %d ERROR: %s -- prorroga.UpdateProrroga", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, prorroga)
+	return
+}
+
+func (a *App) deleteProrrogaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de prorroga invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	// se carga la prorroga para que el historial guarde lo eliminado
+	prorroga := models.Prorroga{ID: id}
+	if err := prorroga.GetProrroga(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("DELETE %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Prorroga no encontrada")
+		default:
+			log.Printf("DELETE %s code: %d ERROR: %s -- prorroga.GetProrroga", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	if !a.profesorDeProrroga(w, r, prorroga) {
+		return
+	}
+
+	if err := prorroga.DeleteProrroga(a.DB, getUserId(r)); err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- prorroga.DeleteProrroga", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("DELETE %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": prorroga.ID})
+	return
+}
+
+// validarProrroga devuelve un mensaje de error o "" si la prorroga es valida
+func validarProrroga(p models.Prorroga) string {
+	if p.AlumnoId == 0 {
+		return "La prorroga debe indicar el alumnoId"
+	}
+	if (p.ExamenId == 0) == (p.TrabajoId == 0) {
+		return "La prorroga debe indicar solo uno de examenId o trabajoId"
+	}
+	if p.MinutosExtra < 0 || p.IntentosExtra < 0 {
+		return "Los minutos e intentos extra no pueden ser negativos"
+	}
+	if p.TrabajoId != 0 && (p.MinutosExtra != 0 || p.IntentosExtra != 0) {
+		return "Un trabajo solo admite extender la fechaFinal"
+	}
+	return ""
+}
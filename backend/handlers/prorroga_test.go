@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestProrrogaSoloProfesores(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddExamenes(1, a.DB)
+	ensureAuthorizedUserExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", "/examenes/1/prorrogas", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+}
+
+func TestCreateProrroga(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddAlumnos(1, a.DB)
+	utils.AddExamenes(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+	asignarProfesorPrueba(1)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	var jsonStr = []byte(`
+	{
+		"alumnoId": 1,
+		"examenId": 1,
+		"fechaFinal": "2022-06-23T18:00:00-05:00",
+		"minutosExtra": 30,
+		"intentosExtra": 1,
+		"motivo": "adecuacion aprobada"
+	}`)
+
+	req, _ := http.NewRequest("POST", "/prorrogas", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m["otorgadoPor"] != float64(token.UserId) {
+		t.Errorf("Expected otorgadoPor to be '%d'. Got '%v'", token.UserId, m["otorgadoPor"])
+	}
+
+	if m["minutosExtra"] != 30.0 {
+		t.Errorf("Expected minutosExtra to be '30'. Got '%v'", m["minutosExtra"])
+	}
+
+	if m["activo"] != true {
+		t.Errorf("Expected activo to be 'true'. Got '%v'", m["activo"])
+	}
+}
+
+func TestCreateProrrogaInvalida(t *testing.T) {
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	var jsonStr = []byte(`
+	{
+		"alumnoId": 1,
+		"examenId": 1,
+		"trabajoId": 1,
+		"motivo": "ambos"
+	}`)
+
+	req, _ := http.NewRequest("POST", "/prorrogas", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestGetProrrogasExamen(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddProrrogas(2, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+	asignarProfesorPrueba(2)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", "/examenes/2/prorrogas", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if len(m) != 1 {
+		t.Errorf("Se esperaba 1 prorroga para el examen 2. Se obtuvo %v", m)
+	}
+}
+
+func TestDeleteProrroga(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddProrrogas(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+	asignarProfesorPrueba(1)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("DELETE", "/prorrogas/1", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("GET", "/prorrogas/1/historial", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if len(m) != 1 || m[0]["accion"] != "eliminar" {
+		t.Errorf("Se esperaba registrar la eliminacion en el historial. Se obtuvo %v", m)
+	}
+}
+
+func TestProrrogaProfesorAjeno(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.AddProrrogas(2, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+	// el profesor de prueba solo dicta el curso 2
+	asignarProfesorPrueba(2)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	var jsonStr = []byte(`{"alumnoId": 1, "examenId": 1, "minutosExtra": 30, "motivo": "ajeno"}`)
+	req, _ := http.NewRequest("POST", "/prorrogas", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	req, _ = http.NewRequest("GET", "/prorrogas/1", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	req, _ = http.NewRequest("DELETE", "/prorrogas/1", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	// la prorroga 2 es del examen 2, que si dicta
+	req, _ = http.NewRequest("GET", "/prorrogas/2", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+}
+
+func TestIniciarIntentoFueraDePlazo(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	// los examenes de prueba cerraron en junio de 2022
+	utils.AddExamenes(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedAlumnoExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	req, _ := http.NewRequest("POST", "/examenes/1/intentos", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blackadress/vaula/formatos"
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// exportExamenHandler envia el examen como paquete QTI 2.1 (?format=qti)
+func (a *App) exportExamenHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de Examen invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+	if formato := r.URL.Query().Get("format"); formato != "qti" {
+		log.Printf("GET %s code: %d ERROR: formato %s", r.RequestURI,
+			http.StatusBadRequest, formato)
+		respondWithError(w, http.StatusBadRequest, "El formato debe ser 'qti'")
+		return
+	}
+
+	paquete, ok := a.cargarExamenFormato(w, r, id)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="examen-%d-qti.zip"`, id))
+	// con la respuesta ya empezada solo queda registrar el error
+	if err := formatos.EscribirQTI(w, paquete); err != nil {
+		log.Printf("GET %s ERROR: %s -- formatos.EscribirQTI", r.RequestURI, err.Error())
+		return
+	}
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+}
+
+type reporteImportacionExamen struct {
+	Examen     models.Examen      `json:"examen"`
+	Importadas int                `json:"importadas"`
+	Omitidas   []formatos.Omitida `json:"omitidas"`
+}
+
+// importarExamenHandler crea un examen en el curso ?cursoId= a partir de un
+// paquete QTI 2.1. QTI no guarda fechas ni intentos, asi que el examen se
+// crea inactivo, con un intento y sin fechas definidas, para que el
+// profesor lo programe antes de publicarlo.
+func (a *App) importarExamenHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+	if formato := r.URL.Query().Get("format"); formato != "qti" {
+		log.Printf("POST %s code: %d ERROR: formato %s", r.RequestURI,
+			http.StatusBadRequest, formato)
+		respondWithError(w, http.StatusBadRequest, "El formato debe ser 'qti'")
+		return
+	}
+	cursoId, err := strconv.Atoi(r.URL.Query().Get("cursoId"))
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de curso invalido")
+		return
+	}
+	curso := models.Curso{ID: cursoId}
+	if !a.existeDestinoImportacion(w, r, curso.GetCurso(a.DB), "Curso no encontrado") {
+		return
+	}
+
+	contenido, liberar, ok := recibirImportacion(w, r)
+	if !ok {
+		return
+	}
+	defer liberar()
+
+	paquete, omitidas, err := parseQTI(contenido)
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- parseQTI", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now()
+	examen := models.Examen{
+		Nombre:      paquete.Nombre,
+		FechaInicio: now,
+		FechaFinal:  now,
+		Duracion:    paquete.Duracion,
+		Intentos:    1,
+		CursoId:     cursoId,
+	}
+	if examen.Nombre == "" {
+		examen.Nombre = "Examen importado"
+	}
+	preguntas := make([]models.Pregunta, len(paquete.Preguntas))
+	for i, fp := range paquete.Preguntas {
+		preguntas[i] = preguntaDesdeFormato(fp, 0, 0)
+	}
+
+	if err := models.ImportarExamen(a.DB, &examen, preguntas); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- models.ImportarExamen", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, reporteImportacionExamen{
+		Examen:     examen,
+		Importadas: len(preguntas),
+		Omitidas:   omitidas,
+	})
+	return
+}
+
+// cargarExamenFormato lee el examen con sus preguntas y alternativas y lo
+// convierte para exportarlo. Si falla responde el error y devuelve false.
+func (a *App) cargarExamenFormato(w http.ResponseWriter, r *http.Request, id int) (formatos.Examen, bool) {
+	examen := models.Examen{ID: id}
+	if err := examen.GetExamen(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Examen no encontrado")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- examen.GetExamen", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return formatos.Examen{}, false
+	}
+	preguntas, err := models.GetPreguntasExamen(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetPreguntasExamen", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return formatos.Examen{}, false
+	}
+
+	paquete := formatos.Examen{Nombre: examen.Nombre, Duracion: examen.Duracion}
+	for i, p := range preguntas {
+		paquete.Preguntas = append(paquete.Preguntas, formatoDesdePregunta(p, i+1))
+	}
+	return paquete, true
+}
+
+// parseQTI lee el paquete completo en memoria, porque zip necesita acceso
+// aleatorio. El tamano ya esta limitado por recibirImportacion.
+func parseQTI(r io.Reader) (formatos.Examen, []formatos.Omitida, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return formatos.Examen{}, nil, err
+	}
+	return formatos.ParseQTI(bytes.NewReader(b), int64(len(b)))
+}
+
+// formatoDesdePregunta convierte una pregunta para exportarla. Las
+// preguntas no tienen nombre, se numeran segun su orden.
+func formatoDesdePregunta(p models.Pregunta, numero int) formatos.Pregunta {
+	fp := formatos.Pregunta{
+		Nombre:    fmt.Sprintf("Pregunta %d", numero),
+		Tipo:      p.Tipo,
+		Enunciado: p.Enunciado,
+		Puntaje:   float64(p.Puntaje),
+	}
+	for _, a := range p.Alternativas {
+		fa := formatos.Alternativa{Texto: a.Valor, Tolerancia: float64(a.Tolerancia)}
+		if a.Correcto {
+			fa.Fraccion = 100
+		}
+		fp.Alternativas = append(fp.Alternativas, fa)
+	}
+	return fp
+}
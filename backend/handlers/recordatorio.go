@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+)
+
+// intervaloRecordatorios es cada cuanto se revisan las fechas proximas.
+// Debe ser bastante menor que la menor anticipacion.
+const intervaloRecordatorios = time.Minute
+
+// programarRecordatorios revisa las fechas de examenes y trabajos cada
+// intervaloRecordatorios hasta que se cancela el contexto
+func (a *App) programarRecordatorios(ctx context.Context) {
+	ticker := time.NewTicker(intervaloRecordatorios)
+	defer ticker.Stop()
+	for {
+		a.enviarRecordatorios(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// enviarRecordatorios deja en la bandeja y en la cola de correos los
+// recordatorios que tocan en 'ahora' y devuelve cuantos emitio
+func (a *App) enviarRecordatorios(ahora time.Time) int {
+	emitidos, err := models.EmitirRecordatorios(a.DB, ahora, avisoRecordatorio)
+	if err != nil {
+		log.Printf("RECORDATORIOS ERROR: %s -- models.EmitirRecordatorios", err.Error())
+		return 0
+	}
+	if emitidos > 0 {
+		log.Printf("RECORDATORIOS %d emitidos", emitidos)
+	}
+	return emitidos
+}
+
+func avisoRecordatorio(r models.Recordatorio) models.Notificacion {
+	n := models.Notificacion{
+		Tipo:         models.NotificacionVencimiento,
+		Titulo:       "Recordatorio: " + r.Nombre,
+		ReferenciaId: r.ReferenciaId,
+	}
+	fecha := r.Fecha.Format(formatoFechaAviso)
+	switch r.Tipo {
+	case models.RecordatorioExamen:
+		n.Cuerpo = fmt.Sprintf("El examen %s empieza el %s.", r.Nombre, fecha)
+	default:
+		n.Cuerpo = fmt.Sprintf("El trabajo \"%s\" vence el %s y aun no tiene tu entrega.",
+			r.Nombre, fecha)
+	}
+	return n
+}
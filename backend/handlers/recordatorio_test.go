@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestEnviarRecordatorios(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddCursos(1, a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	matricularAlumnoPrueba(alumno, 1)
+
+	ahora := time.Now()
+	trabajo := models.Trabajo{Descripcion: "Informe final", CursoId: 1, Activo: true,
+		FechaInicio: ahora.Add(-48 * time.Hour), FechaFinal: ahora.Add(12 * time.Hour)}
+	trabajo.CreateTrabajo(a.DB)
+
+	if emitidos := a.enviarRecordatorios(ahora); emitidos != 1 {
+		t.Fatalf("Se esperaba 1 recordatorio. Se obtuvo %d", emitidos)
+	}
+	if emitidos := a.enviarRecordatorios(ahora); emitidos != 0 {
+		t.Errorf("No se esperaban recordatorios repetidos. Se obtuvo %d", emitidos)
+	}
+
+	token := getTestJWT()
+	notificaciones, _ := models.GetNotificacionesUsuario(a.DB, token.UserId, true)
+	if len(notificaciones) != 1 || notificaciones[0].Tipo != models.NotificacionVencimiento ||
+		!strings.Contains(notificaciones[0].Cuerpo, "Informe final") {
+		t.Errorf("Se esperaba el recordatorio del trabajo. Se obtuvo %v", notificaciones)
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// guardarRespuestaHandler crea o reemplaza la respuesta del alumno a una
+// pregunta del intento y la califica, registrando la ip y el navegador
+// desde donde se guardo. El puntaje no se devuelve para no revelar la
+// clave durante el examen.
+func (a *App) guardarRespuestaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de intento invalido")
+		return
+	}
+
+	intento, ok := a.intentoEnCurso(w, r, id)
+	if !ok {
+		return
+	}
+
+	var respuesta models.Respuesta
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&respuesta); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	preguntas, err := models.GetPreguntasExamen(a.DB, intento.ExamenId)
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- models.GetPreguntasExamen", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	pregunta, encontrada := models.Pregunta{}, false
+	for _, p := range preguntas {
+		if p.ID == respuesta.PreguntaId {
+			pregunta, encontrada = p, true
+		}
+	}
+	if !encontrada {
+		log.Printf("PUT %s code: %d ERROR: pregunta %d fuera del examen", r.RequestURI,
+			http.StatusBadRequest, respuesta.PreguntaId)
+		respondWithError(w, http.StatusBadRequest, "La pregunta no pertenece al examen")
+		return
+	}
+	if respuesta.AlternativaId != 0 {
+		valida := false
+		for _, alt := range pregunta.Alternativas {
+			valida = valida || alt.ID == respuesta.AlternativaId
+		}
+		if !valida {
+			log.Printf("PUT %s code: %d ERROR: alternativa %d fuera de la pregunta", r.RequestURI,
+				http.StatusBadRequest, respuesta.AlternativaId)
+			respondWithError(w, http.StatusBadRequest, "La alternativa no pertenece a la pregunta")
+			return
+		}
+	}
+
+	if err := a.registrarCambioIp(r, intento.ID); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- a.registrarCambioIp", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respuesta.AlumnoExamenId = intento.ID
+	respuesta.Ip = ipCliente(r)
+	respuesta.UserAgent = r.UserAgent()
+	respuesta.Calificar(pregunta)
+	if err := respuesta.GuardarRespuesta(a.DB); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- respuesta.GuardarRespuesta", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respuesta.Puntaje = 0
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, respuesta)
+	return
+}
+
+// entregarIntentoHandler termina el intento en curso del alumno antes de
+// que se acabe el tiempo y lo califica
+func (a *App) entregarIntentoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de intento invalido")
+		return
+	}
+
+	intento, ok := a.intentoEnCurso(w, r, id)
+	if !ok {
+		return
+	}
+
+	if err := intento.Entregar(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("POST %s code: %d ERROR: intento ya terminado", r.RequestURI,
+				http.StatusConflict)
+			respondWithError(w, http.StatusConflict, "El intento ya termino")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- intento.Entregar", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, intento)
+	return
+}
+
+// intentoEnCurso devuelve el intento si pertenece al alumno autenticado y
+// aun no termino. Si no, responde el error y devuelve false.
+func (a *App) intentoEnCurso(w http.ResponseWriter, r *http.Request, id int) (models.AlumnoExamen, bool) {
+	alumno, ok := a.alumnoAutenticado(w, r)
+	if !ok {
+		return models.AlumnoExamen{}, false
+	}
+
+	intento := models.AlumnoExamen{ID: id}
+	if err := intento.GetAlumnoExamen(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("%s %s code: %d ERROR: %s -- no rows", r.Method, r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Intento no encontrado")
+		default:
+			log.Printf("%s %s code: %d ERROR: %s -- intento.GetAlumnoExamen", r.Method,
+				r.RequestURI, http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return intento, false
+	}
+	if intento.AlumnoId != alumno.ID {
+		log.Printf("%s %s code: %d ERROR: intento de otro alumno", r.Method, r.RequestURI,
+			http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "El intento no pertenece al alumno")
+		return intento, false
+	}
+	if !intento.Activo || time.Now().After(intento.FechaFinal) {
+		log.Printf("%s %s code: %d ERROR: intento terminado", r.Method, r.RequestURI,
+			http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "El intento ya termino")
+		return intento, false
+	}
+	return intento, true
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+// prepararIntento deja al usuario de prueba como profesor y alumno, con
+// una pregunta en el examen 1 y un intento en curso con id 2. El intento
+// 1 es de otro alumno.
+func prepararIntento(t *testing.T) string {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddIntentos(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+	ensureAuthorizedAlumnoExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	gift := []byte("::Capital:: Capital del Peru? {=Lima ~Cusco}\n")
+	req, _ := http.NewRequest("POST", "/preguntas/import?format=gift&examenId=1", bytes.NewBuffer(gift))
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	req, _ = http.NewRequest("POST", "/examenes/1/intentos", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	return token_str
+}
+
+func TestGuardarRespuesta(t *testing.T) {
+	token_str := prepararIntento(t)
+
+	payload := []byte(`{"preguntaId":1,"alternativaId":1}`)
+	req, _ := http.NewRequest("PUT", "/intentos/2/respuestas", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m["alumnoExamenId"] != 2.0 {
+		t.Errorf("Expected alumnoExamenId to be '2'. Got '%v'", m["alumnoExamenId"])
+	}
+	if m["puntaje"] != 0.0 {
+		t.Errorf("Expected puntaje to be hidden. Got '%v'", m["puntaje"])
+	}
+
+	req, _ = http.NewRequest("PUT", "/intentos/1/respuestas", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	payload = []byte(`{"preguntaId":1,"alternativaId":99}`)
+	req, _ = http.NewRequest("PUT", "/intentos/2/respuestas", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestEntregarIntento(t *testing.T) {
+	token_str := prepararIntento(t)
+
+	payload := []byte(`{"preguntaId":1,"alternativaId":1}`)
+	req, _ := http.NewRequest("PUT", "/intentos/2/respuestas", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("POST", "/intentos/2/entregar", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m["activo"] != false || m["calificacion"] != 20.0 {
+		t.Errorf("Expected the intento closed with calificacion '20'. Got '%v'", m)
+	}
+
+	req, _ = http.NewRequest("POST", "/intentos/2/entregar", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+}
+
+func TestGetEstadisticasExamen(t *testing.T) {
+	token_str := prepararIntento(t)
+
+	payload := []byte(`{"preguntaId":1,"alternativaId":1}`)
+	req, _ := http.NewRequest("PUT", "/intentos/2/respuestas", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	// solo los profesores del curso ven las estadisticas
+	req, _ = http.NewRequest("GET", "/examenes/1/estadisticas", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	asignarProfesorPrueba(1)
+	req, _ = http.NewRequest("GET", "/examenes/1/estadisticas", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m struct {
+		Intentos  int `json:"intentos"`
+		Preguntas []struct {
+			Dificultad   float64 `json:"dificultad"`
+			Alternativas []struct {
+				Seleccionada int `json:"seleccionada"`
+			} `json:"alternativas"`
+		} `json:"preguntas"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m.Intentos != 1 || len(m.Preguntas) != 1 {
+		t.Fatalf("Expected 1 intento and 1 pregunta. Got %+v", m)
+	}
+	if m.Preguntas[0].Dificultad != 1 {
+		t.Errorf("Expected dificultad to be '1'. Got '%v'", m.Preguntas[0].Dificultad)
+	}
+	if len(m.Preguntas[0].Alternativas) != 2 || m.Preguntas[0].Alternativas[0].Seleccionada != 1 {
+		t.Errorf("Expected the correct alternativa to be selected once. Got %+v", m.Preguntas[0].Alternativas)
+	}
+}
@@ -0,0 +1,545 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+func (a *App) getRubricaByIdHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de rubrica invalido")
+		return
+	}
+
+	rubrica := models.Rubrica{ID: id}
+	if err := rubrica.GetRubrica(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Rubrica no encontrada")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- rubrica.GetRubrica", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, rubrica)
+	return
+}
+
+func (a *App) getRubricasHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	rubricas, err := models.GetRubricas(a.DB)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetRubricas", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, rubricas)
+	return
+}
+
+func (a *App) createRubricaHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var rubrica models.Rubrica
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&rubrica); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if rubrica.Criterios == nil {
+		rubrica.Criterios = []models.Criterio{}
+	}
+	if msg := validarRubrica(rubrica); msg != "" {
+		log.Printf("POST %s code: %d ERROR: %s -- validarRubrica", r.RequestURI,
+			http.StatusBadRequest, msg)
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	rubrica.CreadoPor = getUserId(r)
+	rubrica.Activo = true
+	if err := rubrica.CreateRubrica(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- rubrica.CreateRubrica", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, rubrica)
+	return
+}
+
+// updateRubricaHandler solo reemplaza los criterios si vienen en el
+// payload; omitirlos permite renombrar una rubrica ya usada
+func (a *App) updateRubricaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de rubrica invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var rubrica models.Rubrica
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&rubrica); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if msg := validarRubrica(rubrica); msg != "" {
+		log.Printf("PUT %s code: %d ERROR: %s -- validarRubrica", r.RequestURI,
+			http.StatusBadRequest, msg)
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	rubrica.ID = id
+	if err := rubrica.UpdateRubrica(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("PUT %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Rubrica no encontrada")
+		case models.ErrRubricaEnUso:
+			log.Printf("PUT %s code: %d ERROR: %s", r.RequestURI,
+				http.StatusConflict, err.Error())
+			respondWithError(w, http.StatusConflict, "La rubrica ya se uso para evaluar, no se pueden cambiar sus criterios")
+		default:
+			log.Printf("PUT %s code: %d ERROR: %s -- rubrica.UpdateRubrica", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	if err := rubrica.GetRubrica(a.DB); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- rubrica.GetRubrica", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, rubrica)
+	return
+}
+
+func (a *App) deleteRubricaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de rubrica invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	rubrica := models.Rubrica{ID: id}
+	enUso, err := rubrica.EnUso(a.DB)
+	if err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- rubrica.EnUso", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if enUso {
+		log.Printf("DELETE %s code: %d ERROR: rubrica en uso", r.RequestURI,
+			http.StatusConflict)
+		respondWithError(w, http.StatusConflict, "La rubrica ya se uso para evaluar, desactivela en su lugar")
+		return
+	}
+
+	if err := rubrica.DeleteRubrica(a.DB); err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- rubrica.DeleteRubrica", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("DELETE %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": rubrica.ID})
+	return
+}
+
+func (a *App) getRubricasTrabajoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de trabajo invalido")
+		return
+	}
+
+	asignaciones, err := models.GetRubricasTrabajo(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetRubricasTrabajo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, asignaciones)
+	return
+}
+
+func (a *App) asignarRubricaTrabajoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de trabajo invalido")
+		return
+	}
+
+	trabajo := models.Trabajo{ID: id}
+	if err := trabajo.GetTrabajo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("POST %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Trabajo no encontrado")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- trabajo.GetTrabajo", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	a.asignarRubrica(w, r, models.RubricaAsignada{TrabajoId: id})
+}
+
+func (a *App) asignarRubricaPreguntaTrabajoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de pregunta trabajo invalido")
+		return
+	}
+
+	pregunta := models.PreguntaTrabajo{ID: id}
+	if err := pregunta.GetPreguntaTrabajo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("POST %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Pregunta trabajo no encontrada")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- pregunta.GetPreguntaTrabajo", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	a.asignarRubrica(w, r, models.RubricaAsignada{PreguntaTrabajoId: id})
+}
+
+// asignarRubrica completa la asignacion con la rubrica del payload
+func (a *App) asignarRubrica(w http.ResponseWriter, r *http.Request, asignacion models.RubricaAsignada) {
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var payload struct {
+		RubricaId int `json:"rubricaId"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&payload); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	asignacion.RubricaId = payload.RubricaId
+	asignacion.Rubrica.ID = payload.RubricaId
+	if err := asignacion.Rubrica.GetRubrica(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("POST %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Rubrica no encontrada")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- rubrica.GetRubrica", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	if err := asignacion.CreateRubricaAsignada(a.DB); err != nil {
+		switch err {
+		case models.ErrRubricaYaAsignada:
+			log.Printf("POST %s code: %d ERROR: %s", r.RequestURI,
+				http.StatusConflict, err.Error())
+			respondWithError(w, http.StatusConflict, "La rubrica ya esta asignada")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- asignacion.CreateRubricaAsignada", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, asignacion)
+	return
+}
+
+func (a *App) deleteRubricaAsignadaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de asignacion invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	asignacion := models.RubricaAsignada{ID: id}
+	if err := asignacion.DeleteRubricaAsignada(a.DB); err != nil {
+		log.Printf("DELETE %s code: %d ERROR: %s -- asignacion.DeleteRubricaAsignada", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("DELETE %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": asignacion.ID})
+	return
+}
+
+// evaluacionRubrica es la respuesta de la evaluacion por rubrica
+type evaluacionRubrica struct {
+	AlumnoTrabajo models.AlumnoTrabajo `json:"alumnoTrabajo"`
+	Puntajes      []models.Puntaje     `json:"puntajes"`
+}
+
+func (a *App) getEvaluacionRubricaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de alumno trabajo invalido")
+		return
+	}
+
+	at := models.AlumnoTrabajo{ID: id}
+	if err := at.GetAlumnoTrabajo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Alumno trabajo no encontrado")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- at.GetAlumnoTrabajo", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	esProfesor, ok := a.accesoAlumnoTrabajo(w, r, at)
+	if !ok {
+		return
+	}
+
+	// el alumno no ve la nota ni los puntajes hasta la devolucion
+	puntajes := []models.Puntaje{}
+	if esProfesor || at.FechaDevolucion != nil {
+		puntajes, err = models.GetPuntajes(a.DB, id)
+		if err != nil {
+			log.Printf("GET %s code: %d ERROR: %s -- models.GetPuntajes", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if !esProfesor {
+		at.OcultarSinDevolver()
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, evaluacionRubrica{AlumnoTrabajo: at, Puntajes: puntajes})
+	return
+}
+
+// evaluarRubricaHandler guarda los puntajes por criterio de una entrega y
+// recalcula su calificacion sobre todas las rubricas asignadas al trabajo,
+// aplicando la politica de tardanza igual que la calificacion directa
+func (a *App) evaluarRubricaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de alumno trabajo invalido")
+		return
+	}
+	if _, ok := a.profesorAutenticado(w, r); !ok {
+		return
+	}
+
+	var payload struct {
+		Puntajes []models.Puntaje `json:"puntajes"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&payload); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	at := models.AlumnoTrabajo{ID: id}
+	if err := at.GetAlumnoTrabajo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("PUT %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Alumno trabajo no encontrado")
+		default:
+			log.Printf("PUT %s code: %d ERROR: %s -- at.GetAlumnoTrabajo", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	asignaciones, err := models.GetRubricasTrabajo(a.DB, at.TrabajoId)
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- models.GetRubricasTrabajo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(asignaciones) == 0 {
+		log.Printf("PUT %s code: %d ERROR: trabajo sin rubricas", r.RequestURI,
+			http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "El trabajo no tiene rubricas asignadas")
+		return
+	}
+
+	if err := models.CompletarPuntajes(asignaciones, payload.Puntajes); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- models.CompletarPuntajes", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Cada puntaje debe indicar un nivel de un criterio de las rubricas del trabajo")
+		return
+	}
+
+	err = models.GuardarPuntajes(a.DB, at.ID, getUserId(r), payload.Puntajes)
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- models.GuardarPuntajes", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	puntajes, err := models.GetPuntajes(a.DB, at.ID)
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- models.GetPuntajes", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	tardanza, err := a.tardanzaAlumnoTrabajo(at)
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- tardanzaAlumnoTrabajo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	bruta := models.CalcularCalificacionRubricas(asignaciones, puntajes, calificacionMaxima)
+	if err := at.Calificar(a.DB, bruta, tardanza); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- at.Calificar", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, evaluacionRubrica{AlumnoTrabajo: at, Puntajes: puntajes})
+	return
+}
+
+// validarRubrica devuelve un mensaje de error o "" si la rubrica es valida.
+// Criterios nil solo se admite al actualizar.
+func validarRubrica(rb models.Rubrica) string {
+	if rb.Nombre == "" {
+		return "La rubrica debe tener nombre"
+	}
+	if rb.Criterios == nil {
+		return ""
+	}
+	if len(rb.Criterios) == 0 {
+		return "La rubrica debe tener al menos un criterio"
+	}
+	for _, c := range rb.Criterios {
+		if c.Nombre == "" {
+			return "Cada criterio debe tener nombre"
+		}
+		if len(c.Niveles) == 0 {
+			return "Cada criterio debe tener al menos un nivel"
+		}
+		for _, n := range c.Niveles {
+			if n.Puntos < 0 {
+				return "Los puntos de un nivel no pueden ser negativos"
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestCreateRubrica(t *testing.T) {
+	utils.ClearTableRubrica(a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{
+		"nombre": "exposicion",
+		"criterios": [
+			{"nombre": "claridad", "niveles": [{"nombre": "bajo", "puntos": 0}, {"nombre": "alto", "puntos": 5}]}
+		]
+	}`)
+	req, _ := http.NewRequest("POST", "/rubricas", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m["nombre"] != "exposicion" {
+		t.Errorf("Expected rubrica nombre to be 'exposicion'. Got '%v'", m["nombre"])
+	}
+	criterios, _ := m["criterios"].([]interface{})
+	if len(criterios) != 1 {
+		t.Errorf("Expected 1 criterio. Got '%v'", m["criterios"])
+	}
+}
+
+func TestCreateRubricaSinNiveles(t *testing.T) {
+	utils.ClearTableRubrica(a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"nombre": "vacia", "criterios": [{"nombre": "claridad"}]}`)
+	req, _ := http.NewRequest("POST", "/rubricas", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestEvaluarRubrica(t *testing.T) {
+	utils.ClearTableRubrica(a.DB)
+	utils.ClearTableCurso(a.DB)
+	utils.AddEntregas(1, a.DB)
+	utils.AddRubricas(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"rubricaId": 1}`)
+	req, _ := http.NewRequest("POST", "/trabajos/1/rubricas", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	// criterio 1 en su nivel de 5 puntos y criterio 2 en su nivel de 2 puntos
+	jsonStr = []byte(`{"puntajes": [
+		{"asignacionId": 1, "criterioId": 1, "nivelId": 3, "comentario": "completo"},
+		{"asignacionId": 1, "criterioId": 2, "nivelId": 5}
+	]}`)
+	req, _ = http.NewRequest("PUT", "/alumnoTrabajos/1/rubrica", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m struct {
+		AlumnoTrabajo map[string]interface{}   `json:"alumnoTrabajo"`
+		Puntajes      []map[string]interface{} `json:"puntajes"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if m.AlumnoTrabajo["calificacion"] != 14.0 {
+		t.Errorf("Expected calificacion to be '14'. Got '%v'", m.AlumnoTrabajo["calificacion"])
+	}
+	if len(m.Puntajes) != 2 || m.Puntajes[0]["comentario"] != "completo" {
+		t.Errorf("Expected 2 puntajes with comentario. Got '%v'", m.Puntajes)
+	}
+
+	// la rubrica usada ya no admite cambios en sus criterios
+	jsonStr = []byte(`{"nombre": "otra", "criterios": [{"nombre": "c", "niveles": [{"puntos": 1}]}]}`)
+	req, _ = http.NewRequest("PUT", "/rubricas/1", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusConflict, response.Code)
+}
+
+func TestEvaluarRubricaNivelAjeno(t *testing.T) {
+	utils.ClearTableRubrica(a.DB)
+	utils.ClearTableCurso(a.DB)
+	utils.AddEntregas(1, a.DB)
+	utils.AddRubricas(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"rubricaId": 1}`)
+	req, _ := http.NewRequest("POST", "/trabajos/1/rubricas", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	executeRequest(req, a)
+
+	// el nivel 4 pertenece al criterio 2
+	jsonStr = []byte(`{"puntajes": [{"asignacionId": 1, "criterioId": 1, "nivelId": 4}]}`)
+	req, _ = http.NewRequest("PUT", "/alumnoTrabajos/1/rubrica", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+func TestEvaluacionRubricaOcultaHastaDevolucion(t *testing.T) {
+	utils.ClearTableRubrica(a.DB)
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+	alumno := ensureAuthorizedAlumnoExists()
+	utils.AddTrabajos(1, a.DB)
+	utils.AddRubricas(1, a.DB)
+
+	entrega := models.Entrega{TrabajoId: 1, AlumnoId: alumno.ID, NombreArchivo: "a.txt",
+		MimeType: "text/plain", Tamano: 1, Clave: "test/a.txt", FechaEntrega: time.Now()}
+	entrega.CreateEntrega(a.DB)
+	asignacion := models.RubricaAsignada{RubricaId: 1, TrabajoId: 1}
+	asignacion.CreateRubricaAsignada(a.DB)
+	models.GuardarPuntajes(a.DB, entrega.AlumnoTrabajoId, 1, []models.Puntaje{
+		{AsignacionId: asignacion.ID, CriterioId: 1, NivelId: 3, Puntos: 5, Comentario: "completo"},
+	})
+	at := models.AlumnoTrabajo{ID: entrega.AlumnoTrabajoId}
+	at.GetAlumnoTrabajo(a.DB)
+	at.Calificar(a.DB, 10, models.Tardanza{})
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	var m struct {
+		AlumnoTrabajo map[string]interface{}   `json:"alumnoTrabajo"`
+		Puntajes      []map[string]interface{} `json:"puntajes"`
+	}
+	consultar := func() {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/alumnoTrabajos/%d/rubrica", at.ID), nil)
+		req.Header.Set("Authorization", token_str)
+		response := executeRequest(req, a)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		m.AlumnoTrabajo, m.Puntajes = nil, nil
+		json.Unmarshal(response.Body.Bytes(), &m)
+	}
+
+	consultar()
+	if m.AlumnoTrabajo["calificacion"] != 0.0 || len(m.Puntajes) != 0 {
+		t.Errorf("La evaluacion no deberia verse antes de la devolucion. Se obtuvo %v", m)
+	}
+
+	at.Devolver(a.DB)
+
+	consultar()
+	if m.AlumnoTrabajo["calificacion"] != 10.0 {
+		t.Errorf("Se esperaba la nota 10 despues de la devolucion. Se obtuvo %v",
+			m.AlumnoTrabajo["calificacion"])
+	}
+	if len(m.Puntajes) != 1 || m.Puntajes[0]["comentario"] != "completo" {
+		t.Errorf("Se esperaba el puntaje con su comentario. Se obtuvo %v", m.Puntajes)
+	}
+}
+
+func TestPatchRubricaUsada(t *testing.T) {
+	utils.ClearTableRubrica(a.DB)
+	utils.ClearTableCurso(a.DB)
+	utils.AddEntregas(1, a.DB)
+	utils.AddRubricas(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	jsonStr := []byte(`{"rubricaId": 1}`)
+	req, _ := http.NewRequest("POST", "/trabajos/1/rubricas", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	executeRequest(req, a)
+	jsonStr = []byte(`{"puntajes": [{"asignacionId": 1, "criterioId": 1, "nivelId": 3}]}`)
+	req, _ = http.NewRequest("PUT", "/alumnoTrabajos/1/rubrica", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	// sin criterios en el parche la rubrica usada se puede renombrar y sus
+	// criterios no cambian
+	jsonStr = []byte(`{"nombre": "renombrada"}`)
+	req, _ = http.NewRequest("PATCH", "/rubricas/1", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["nombre"] != "renombrada" {
+		t.Errorf("Se esperaba el nombre 'renombrada'. Se obtuvo '%v'", m["nombre"])
+	}
+	criterios, _ := m["criterios"].([]interface{})
+	if len(criterios) != 2 {
+		t.Fatalf("Se esperaban los 2 criterios originales. Se obtuvo '%v'", m["criterios"])
+	}
+	if primero, _ := criterios[0].(map[string]interface{}); primero["id"] != 1.0 {
+		t.Errorf("Los criterios no deberian recrearse. Se obtuvo '%v'", primero)
+	}
+
+	// con criterios el parche si los reemplaza, y la rubrica usada no lo admite
+	jsonStr = []byte(`{"criterios": [{"nombre": "c", "niveles": [{"puntos": 1}]}]}`)
+	req, _ = http.NewRequest("PATCH", "/rubricas/1", bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusConflict, response.Code)
+}
+
+func TestPatchRubricaInvalida(t *testing.T) {
+	utils.ClearTableRubrica(a.DB)
+	utils.AddRubricas(1, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	casos := []struct {
+		ruta   string
+		parche string
+		codigo int
+	}{
+		{"/rubricas/99", `{"nombre": "x"}`, http.StatusNotFound},
+		{"/rubricas/1", `{"puntaje": 20}`, http.StatusBadRequest},
+		// un criterio sin niveles no pasa la validacion de la rubrica
+		{"/rubricas/1", `{"criterios": [{"nombre": "claridad"}]}`, http.StatusBadRequest},
+	}
+	for _, c := range casos {
+		req, _ := http.NewRequest("PATCH", c.ruta, bytes.NewBufferString(c.parche))
+		req.Header.Set("Authorization", token_str)
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		response := executeRequest(req, a)
+		checkResponseCode(t, c.codigo, response.Code)
+	}
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/similitud"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// minimoSimilitud es el porcentaje desde el que un par aparece en el
+// reporte; por debajo casi siempre son frases comunes del enunciado
+const minimoSimilitud = 5
+
+// analisisSimultaneos limita cuantos analisis corren a la vez, porque cada
+// uno lee todas las entregas del trabajo
+var analisisSimultaneos = make(chan struct{}, 2)
+
+// guardarRespuestaTrabajoHandler guarda la respuesta del alumno a una
+// pregunta de un trabajo que todavia recibe entregas
+func (a *App) guardarRespuestaTrabajoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de pregunta invalido")
+		return
+	}
+
+	alumno, ok := a.alumnoAutenticado(w, r)
+	if !ok {
+		return
+	}
+
+	pregunta := models.PreguntaTrabajo{ID: id}
+	if err := pregunta.GetPreguntaTrabajo(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("PUT %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "PreguntaTrabajo no encontrada")
+		default:
+			log.Printf("PUT %s code: %d ERROR: %s -- pregunta.GetPreguntaTrabajo", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	if _, _, ok := a.trabajoAbierto(w, r, pregunta.TrabajoId, alumno.ID); !ok {
+		return
+	}
+
+	var respuesta models.RespuestaTrabajo
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&respuesta); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	respuesta.PreguntaTrabajoId = pregunta.ID
+	respuesta.AlumnoId = alumno.ID
+	if err := respuesta.GuardarRespuestaTrabajo(a.DB); err != nil {
+		log.Printf("PUT %s code: %d ERROR: %s -- respuesta.GuardarRespuestaTrabajo", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("PUT %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, respuesta)
+	return
+}
+
+// createAnalisisSimilitudHandler encola un analisis de similitud del
+// trabajo y responde sin esperar a que termine. Los analisis solo los piden
+// y ven los profesores del curso del trabajo.
+func (a *App) createAnalisisSimilitudHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de trabajo invalido")
+		return
+	}
+	trabajo, ok := a.profesorDelTrabajo(w, r, id)
+	if !ok {
+		return
+	}
+
+	analisis := models.AnalisisSimilitud{TrabajoId: trabajo.ID, SolicitadoPor: getUserId(r)}
+	if err := analisis.CreateAnalisis(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("POST %s code: %d ERROR: analisis en curso", r.RequestURI,
+				http.StatusConflict)
+			respondWithError(w, http.StatusConflict, "Ya hay un analisis en curso para este trabajo")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- analisis.CreateAnalisis", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	go a.ejecutarAnalisis(analisis)
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusAccepted)
+	respondWithJSON(w, http.StatusAccepted, analisis)
+	return
+}
+
+// getAnalisisSimilitudTrabajoHandler devuelve el ultimo analisis del trabajo
+func (a *App) getAnalisisSimilitudTrabajoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de trabajo invalido")
+		return
+	}
+	if _, ok := a.profesorDelTrabajo(w, r, id); !ok {
+		return
+	}
+
+	analisis := models.AnalisisSimilitud{TrabajoId: id}
+	if err := analisis.GetUltimoAnalisis(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "El trabajo no tiene analisis de similitud")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- analisis.GetUltimoAnalisis", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, analisis)
+	return
+}
+
+func (a *App) getAnalisisSimilitudByIdHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de analisis invalido")
+		return
+	}
+
+	analisis := models.AnalisisSimilitud{ID: id}
+	if err := analisis.GetAnalisis(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Analisis no encontrado")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- analisis.GetAnalisis", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if _, ok := a.profesorDelTrabajo(w, r, analisis.TrabajoId); !ok {
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, analisis)
+	return
+}
+
+// ejecutarAnalisis corre en su propia goroutine y deja el resultado, o el
+// error, en la BD
+func (a *App) ejecutarAnalisis(analisis models.AnalisisSimilitud) {
+	analisisSimultaneos <- struct{}{}
+	defer func() { <-analisisSimultaneos }()
+
+	if err := analisis.MarcarProcesando(a.DB); err != nil {
+		log.Printf("ANALISIS %d ERROR: %s -- analisis.MarcarProcesando", analisis.ID, err.Error())
+	}
+	resultado, fallo := a.compararTrabajo(analisis.TrabajoId)
+	if fallo != nil {
+		log.Printf("ANALISIS %d ERROR: %s -- a.compararTrabajo", analisis.ID, fallo.Error())
+	}
+	if err := analisis.Terminar(a.DB, resultado, fallo); err != nil {
+		log.Printf("ANALISIS %d ERROR: %s -- analisis.Terminar", analisis.ID, err.Error())
+		return
+	}
+	log.Printf("ANALISIS %d %s", analisis.ID, analisis.Estado)
+}
+
+// compararTrabajo compara entre si la ultima entrega de cada alumno y,
+// por separado, las respuestas a cada pregunta del trabajo
+func (a *App) compararTrabajo(trabajoId int) (models.ResultadoSimilitud, error) {
+	resultado := models.ResultadoSimilitud{
+		Pares:    []models.ParSimilitud{},
+		Omitidos: []models.DocumentoOmitido{},
+	}
+
+	entregas, err := models.GetEntregasTrabajo(a.DB, trabajoId)
+	if err != nil {
+		return resultado, err
+	}
+	// vienen ordenadas por alumno y version, la ultima reemplaza a las demas
+	ultimas := map[int]models.Entrega{}
+	orden := []int{}
+	for _, e := range entregas {
+		if _, ok := ultimas[e.AlumnoId]; !ok {
+			orden = append(orden, e.AlumnoId)
+		}
+		ultimas[e.AlumnoId] = e
+	}
+
+	fuentes := []models.FuenteSimilitud{}
+	documentos := []*similitud.Documento{}
+	for _, alumnoId := range orden {
+		e := ultimas[alumnoId]
+		fuente := models.FuenteSimilitud{Tipo: models.FuenteEntrega, ID: e.ID, AlumnoId: e.AlumnoId}
+		texto, err := a.textoEntrega(e)
+		if err != nil {
+			resultado.Omitidos = append(resultado.Omitidos,
+				models.DocumentoOmitido{FuenteSimilitud: fuente, Motivo: err.Error()})
+			continue
+		}
+		doc := similitud.NuevoDocumento(texto)
+		if doc.Huellas() == 0 {
+			resultado.Omitidos = append(resultado.Omitidos,
+				models.DocumentoOmitido{FuenteSimilitud: fuente, Motivo: "no tiene texto suficiente"})
+			continue
+		}
+		fuentes = append(fuentes, fuente)
+		documentos = append(documentos, doc)
+	}
+	resultado.Documentos += len(documentos)
+	resultado.Pares = append(resultado.Pares, paresSimilitud(fuentes, documentos)...)
+
+	respuestas, err := models.GetRespuestasTrabajo(a.DB, trabajoId)
+	if err != nil {
+		return resultado, err
+	}
+	// solo se comparan respuestas de la misma pregunta
+	for i := 0; i < len(respuestas); {
+		j := i
+		fuentes = []models.FuenteSimilitud{}
+		documentos = []*similitud.Documento{}
+		for ; j < len(respuestas) && respuestas[j].PreguntaTrabajoId == respuestas[i].PreguntaTrabajoId; j++ {
+			rt := respuestas[j]
+			doc := similitud.NuevoDocumento(rt.Texto)
+			if doc.Huellas() == 0 {
+				continue
+			}
+			fuentes = append(fuentes, models.FuenteSimilitud{
+				Tipo:              models.FuenteRespuesta,
+				ID:                rt.ID,
+				AlumnoId:          rt.AlumnoId,
+				PreguntaTrabajoId: rt.PreguntaTrabajoId,
+			})
+			documentos = append(documentos, doc)
+		}
+		resultado.Documentos += len(documentos)
+		resultado.Pares = append(resultado.Pares, paresSimilitud(fuentes, documentos)...)
+		i = j
+	}
+	sort.SliceStable(resultado.Pares, func(i, j int) bool {
+		return resultado.Pares[i].Maxima() > resultado.Pares[j].Maxima()
+	})
+	return resultado, nil
+}
+
+func paresSimilitud(fuentes []models.FuenteSimilitud, documentos []*similitud.Documento) []models.ParSimilitud {
+	pares := []models.ParSimilitud{}
+	for _, p := range similitud.CompararTodos(documentos, minimoSimilitud) {
+		pares = append(pares, models.ParSimilitud{
+			A:           fuentes[p.A],
+			B:           fuentes[p.B],
+			Comparacion: p.Comparacion,
+		})
+	}
+	return pares
+}
+
+// textoEntrega lee el archivo de la entrega desde el storage y extrae su texto
+func (a *App) textoEntrega(e models.Entrega) (string, error) {
+	rc, err := a.Storage.Get(context.Background(), e.Clave)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	contenido, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return "", err
+	}
+	texto, err := similitud.ExtraerTexto(contenido, e.MimeType, e.NombreArchivo)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(texto) == "" {
+		return "", fmt.Errorf("no se encontro texto en %s", e.NombreArchivo)
+	}
+	return texto, nil
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/utils"
+)
+
+const textoCopiado = `La fotosintesis es el proceso por el cual las plantas
+transforman la energia de la luz en energia quimica, usando dioxido de
+carbono y agua para producir glucosa y liberar oxigeno a la atmosfera.`
+
+// addEntregaTexto guarda en el storage una entrega de texto del alumno
+func addEntregaTexto(t *testing.T, trabajoId, alumnoId int, texto string) {
+	e := models.Entrega{
+		TrabajoId:     trabajoId,
+		AlumnoId:      alumnoId,
+		NombreArchivo: "informe.txt",
+		MimeType:      "text/plain; charset=utf-8",
+		Tamano:        int64(len(texto)),
+		Clave:         fmt.Sprintf("trabajos/%d/alumnos/%d/informe.txt", trabajoId, alumnoId),
+	}
+	err := a.Storage.Put(context.Background(), e.Clave, strings.NewReader(texto), e.Tamano, e.MimeType)
+	if err != nil {
+		t.Fatalf("No se guardo el archivo %s", err)
+	}
+	if err := e.CreateEntrega(a.DB); err != nil {
+		t.Fatalf("No se creo la entrega %s", err)
+	}
+}
+
+func TestGuardarRespuestaTrabajo(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedAlumnoExists()
+	trabajo := addTrabajoAbierto()
+	pregunta := models.PreguntaTrabajo{Enunciado: "Explique la fotosintesis", TrabajoId: trabajo.ID}
+	pregunta.CreatePreguntaTrabajo(a.DB)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	var jsonStr = []byte(`{"texto": "Es el proceso de las plantas"}`)
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("/preguntasTrabajo/%d/respuestas", pregunta.ID),
+		bytes.NewBuffer(jsonStr))
+	req.Header.Set("Authorization", token_str)
+	req.Header.Set("Content-Type", "application/json")
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["texto"] != "Es el proceso de las plantas" {
+		t.Errorf("Expected texto to be 'Es el proceso de las plantas'. Got '%v'", m["texto"])
+	}
+}
+
+func TestCreateAnalisisSimilitud(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	utils.AddAlumnos(3, a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+	trabajo := addTrabajoAbierto()
+
+	addEntregaTexto(t, trabajo.ID, 1, "Introduccion propia. "+textoCopiado)
+	addEntregaTexto(t, trabajo.ID, 2, textoCopiado+" Conclusiones de otro alumno.")
+	addEntregaTexto(t, trabajo.ID, 3, `Un texto escrito de forma independiente que trata
+		sobre la historia del Peru y sus culturas preincaicas en la costa norte.`)
+
+	token := getTestJWT()
+	token_str := fmt.Sprintf("Bearer %s", token.AccessToken)
+
+	// solo los profesores del curso piden el analisis
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/trabajos/%d/similitud", trabajo.ID), nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	asignarProfesorPrueba(trabajo.CursoId)
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/trabajos/%d/similitud", trabajo.ID), nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusAccepted, response.Code)
+
+	var analisis models.AnalisisSimilitud
+	for i := 0; i < 50; i++ {
+		req, _ = http.NewRequest("GET", fmt.Sprintf("/trabajos/%d/similitud", trabajo.ID), nil)
+		req.Header.Set("Authorization", token_str)
+		response = executeRequest(req, a)
+		checkResponseCode(t, http.StatusOK, response.Code)
+		json.Unmarshal(response.Body.Bytes(), &analisis)
+		if analisis.Estado == models.AnalisisCompletado || analisis.Estado == models.AnalisisFallido {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if analisis.Estado != models.AnalisisCompletado {
+		t.Fatalf("Se esperaba el analisis completado. Se obtuvo %+v", analisis)
+	}
+
+	var resultado models.ResultadoSimilitud
+	json.Unmarshal(analisis.Resultado, &resultado)
+	if resultado.Documentos != 3 || len(resultado.Pares) != 1 {
+		t.Fatalf("Se esperaba 1 par entre 3 documentos. Se obtuvo %+v", resultado)
+	}
+	par := resultado.Pares[0]
+	if par.A.AlumnoId != 1 || par.B.AlumnoId != 2 || par.Maxima() < 50 || len(par.Pasajes) == 0 {
+		t.Errorf("Se esperaba un par de los alumnos 1 y 2 con pasajes. Se obtuvo %+v", par)
+	}
+}
+
+func TestCreateAnalisisSimilitudEnCurso(t *testing.T) {
+	utils.ClearTableCurso(a.DB)
+	utils.ClearTableUsuario(a.DB)
+	ensureAuthorizedUserExists()
+	ensureAuthorizedProfesorExists()
+	trabajo := addTrabajoAbierto()
+	asignarProfesorPrueba(trabajo.CursoId)
+
+	token := getTestJWT()
+	enCurso := models.AnalisisSimilitud{TrabajoId: trabajo.ID, SolicitadoPor: token.UserId}
+	enCurso.CreateAnalisis(a.DB)
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/trabajos/%d/similitud", trabajo.ID), nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusConflict, response.Code)
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+const maxDetalleEvento = 500
+
+// umbralesSupervision lee SUPERVISION_UMBRALES ("pegar=2,cambioIp=0"); los
+// tipos que no aparecen usan el umbral por defecto
+func umbralesSupervision() models.Umbrales {
+	umbrales, err := models.ParseUmbrales(os.Getenv("SUPERVISION_UMBRALES"))
+	if err != nil {
+		log.Printf("SUPERVISION_UMBRALES ERROR: %s, se usan los umbrales por defecto", err.Error())
+		return models.UmbralesPorDefecto
+	}
+	return umbrales
+}
+
+// ipCliente usa la direccion de la conexion. X-Forwarded-For no se toma en
+// cuenta porque el alumno lo puede falsificar.
+func ipCliente(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// registrarCambioIp agrega un evento cambioIp si la ip de la peticion no
+// es la ultima registrada en el intento
+func (a *App) registrarCambioIp(r *http.Request, intentoId int) error {
+	ip := ipCliente(r)
+	anterior, err := models.UltimaIpIntento(a.DB, intentoId)
+	if err != nil || anterior == "" || anterior == ip {
+		return err
+	}
+	evento := models.EventoIntento{
+		AlumnoExamenId: intentoId,
+		Tipo:           models.EventoCambioIp,
+		Detalle:        fmt.Sprintf("%s -> %s", anterior, ip),
+		Ip:             ip,
+		UserAgent:      r.UserAgent(),
+	}
+	return evento.CreateEvento(a.DB)
+}
+
+// registrarEventoHandler guarda un evento de supervision del intento en
+// curso del alumno
+func (a *App) registrarEventoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de intento invalido")
+		return
+	}
+
+	intento, ok := a.intentoEnCurso(w, r, id)
+	if !ok {
+		return
+	}
+
+	var evento models.EventoIntento
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&evento); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- decoder", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if !models.TiposEvento[evento.Tipo] {
+		log.Printf("POST %s code: %d ERROR: tipo de evento %s", r.RequestURI,
+			http.StatusBadRequest, evento.Tipo)
+		respondWithError(w, http.StatusBadRequest, "Tipo de evento invalido")
+		return
+	}
+	if len(evento.Detalle) > maxDetalleEvento {
+		log.Printf("POST %s code: %d ERROR: detalle de %d bytes", r.RequestURI,
+			http.StatusBadRequest, len(evento.Detalle))
+		respondWithError(w, http.StatusBadRequest,
+			fmt.Sprintf("El detalle no puede superar %d caracteres", maxDetalleEvento))
+		return
+	}
+
+	// si el cliente ya informa el cambio de ip no se registra dos veces
+	if evento.Tipo != models.EventoCambioIp {
+		if err := a.registrarCambioIp(r, intento.ID); err != nil {
+			log.Printf("POST %s code: %d ERROR: %s -- a.registrarCambioIp", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	evento.ID = 0
+	evento.AlumnoExamenId = intento.ID
+	evento.Ip = ipCliente(r)
+	evento.UserAgent = r.UserAgent()
+	if err := evento.CreateEvento(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- evento.CreateEvento", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusCreated)
+	respondWithJSON(w, http.StatusCreated, evento)
+	return
+}
+
+type lineaTiempoIntento struct {
+	models.ResumenSupervision
+	LineaTiempo []models.EntradaLineaTiempo `json:"lineaTiempo"`
+}
+
+// getLineaTiempoIntentoHandler muestra al profesor del curso los eventos
+// y respuestas del intento en orden, con el resumen de supervision
+func (a *App) getLineaTiempoIntentoHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de intento invalido")
+		return
+	}
+
+	intento := models.AlumnoExamen{ID: id}
+	if err := intento.GetAlumnoExamen(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("GET %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Intento no encontrado")
+		default:
+			log.Printf("GET %s code: %d ERROR: %s -- intento.GetAlumnoExamen", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if _, ok := a.profesorDelExamen(w, r, intento.ExamenId); !ok {
+		return
+	}
+
+	eventos, err := models.GetEventosIntento(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetEventosIntento", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respuestas, err := models.GetRespuestasIntento(a.DB, id)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetRespuestasIntento", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resumen := models.ResumenSupervision{
+		AlumnoExamenId: intento.ID,
+		AlumnoId:       intento.AlumnoId,
+		Intento:        intento.Intento,
+		Conteos:        map[string]int{},
+	}
+	for _, e := range eventos {
+		resumen.Conteos[e.Tipo]++
+	}
+	resumen.Motivos = umbralesSupervision().Evaluar(resumen.Conteos)
+	resumen.Marcado = len(resumen.Motivos) > 0
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, lineaTiempoIntento{
+		ResumenSupervision: resumen,
+		LineaTiempo:        models.LineaTiempo(eventos, respuestas),
+	})
+	return
+}
+
+// getSupervisionExamenHandler resume los eventos de cada intento del
+// examen y marca los que superan los umbrales
+func (a *App) getSupervisionExamenHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de Examen invalido")
+		return
+	}
+	if _, ok := a.profesorDelExamen(w, r, id); !ok {
+		return
+	}
+
+	resumenes, err := models.GetSupervisionExamen(a.DB, id, umbralesSupervision())
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.GetSupervisionExamen", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, resumenes)
+	return
+}
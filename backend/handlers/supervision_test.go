@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestRegistrarEvento(t *testing.T) {
+	token_str := prepararIntento(t)
+
+	req, _ := http.NewRequest("POST", "/intentos/2/eventos",
+		bytes.NewBufferString(`{"tipo":"pestanaOculta"}`))
+	req.Header.Set("Authorization", token_str)
+	req.RemoteAddr = "10.0.0.1:4000"
+	response := executeRequest(req, a)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["ip"] != "10.0.0.1" {
+		t.Errorf("Expected ip to be '10.0.0.1'. Got '%v'", m["ip"])
+	}
+
+	// guardar una respuesta desde otra ip registra el cambio
+	req, _ = http.NewRequest("PUT", "/intentos/2/respuestas",
+		bytes.NewBufferString(`{"preguntaId":1,"alternativaId":1}`))
+	req.Header.Set("Authorization", token_str)
+	req.RemoteAddr = "10.0.0.2:4000"
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("POST", "/intentos/2/eventos",
+		bytes.NewBufferString(`{"tipo":"copiar"}`))
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	// solo los profesores del curso supervisan
+	req, _ = http.NewRequest("GET", "/intentos/2/eventos", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	req, _ = http.NewRequest("GET", "/examenes/1/supervision", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	asignarProfesorPrueba(1)
+	req, _ = http.NewRequest("GET", "/intentos/2/eventos", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var linea struct {
+		Conteos     map[string]int `json:"conteos"`
+		Marcado     bool           `json:"marcado"`
+		LineaTiempo []struct {
+			Tipo string `json:"tipo"`
+			Ip   string `json:"ip"`
+		} `json:"lineaTiempo"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &linea)
+
+	if linea.Conteos["cambioIp"] != 1 || !linea.Marcado {
+		t.Errorf("Expected the ip change to flag the intento. Got %+v", linea)
+	}
+	if len(linea.LineaTiempo) != 3 {
+		t.Errorf("Expected 3 entries in the timeline. Got %+v", linea.LineaTiempo)
+	}
+
+	req, _ = http.NewRequest("GET", "/examenes/1/supervision", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var resumenes []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &resumenes)
+	if len(resumenes) != 2 {
+		t.Errorf("Expected 2 intentos. Got %v", resumenes)
+	}
+}
@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blackadress/vaula/models"
+	"github.com/blackadress/vaula/websocket"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// intervaloPing mantiene viva la conexion a traves de proxies; sin
+	// respuesta en dos intervalos se da por perdida
+	intervaloPing = 30 * time.Second
+	// intervaloTiempoExamen es cada cuanto se envia el tiempo restante de
+	// los intentos suscritos
+	intervaloTiempoExamen = 30 * time.Second
+	// intervaloCierreIntentos es cada cuanto se cierran los intentos vencidos
+	intervaloCierreIntentos = 15 * time.Second
+	// esperaReconexion antes de volver a escuchar si se pierde la conexion
+	esperaReconexion = 5 * time.Second
+	// pendientesCliente son los mensajes que se encolan por cliente; si se
+	// llena el cliente es demasiado lento y se desconecta
+	pendientesCliente = 32
+)
+
+// centralEventos reparte los eventos que llegan por LISTEN/NOTIFY a los
+// clientes conectados a esta instancia, segun sus canales
+type centralEventos struct {
+	mu        sync.RWMutex
+	suscritos map[string]map[*clienteTiempoReal]bool
+}
+
+func nuevaCentralEventos() *centralEventos {
+	return &centralEventos{suscritos: map[string]map[*clienteTiempoReal]bool{}}
+}
+
+func (c *centralEventos) suscribir(canal string, cliente *clienteTiempoReal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.suscritos[canal] == nil {
+		c.suscritos[canal] = map[*clienteTiempoReal]bool{}
+	}
+	c.suscritos[canal][cliente] = true
+}
+
+func (c *centralEventos) desuscribir(canal string, cliente *clienteTiempoReal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.suscritos[canal], cliente)
+	if len(c.suscritos[canal]) == 0 {
+		delete(c.suscritos, canal)
+	}
+}
+
+// quitar saca al cliente de todos sus canales
+func (c *centralEventos) quitar(cliente *clienteTiempoReal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for canal, clientes := range c.suscritos {
+		delete(clientes, cliente)
+		if len(clientes) == 0 {
+			delete(c.suscritos, canal)
+		}
+	}
+}
+
+func (c *centralEventos) repartir(e models.EventoTiempoReal) {
+	mensaje, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("TIEMPO REAL ERROR: %s -- json.Marshal", err.Error())
+		return
+	}
+	// se envia fuera del lock para que un cliente no retrase las
+	// suscripciones de los demas
+	c.mu.RLock()
+	clientes := make([]*clienteTiempoReal, 0, len(c.suscritos[e.Canal]))
+	for cliente := range c.suscritos[e.Canal] {
+		clientes = append(clientes, cliente)
+	}
+	c.mu.RUnlock()
+	for _, cliente := range clientes {
+		cliente.enviar(mensaje)
+	}
+}
+
+type clienteTiempoReal struct {
+	conn      *websocket.Conn
+	usuarioId int
+	salida    chan []byte
+	fin       chan struct{}
+	lento     sync.Once
+
+	// temporizadores de tiempo restante por intento. Solo los usa la
+	// goroutine que lee del cliente.
+	temporizadores map[int]chan struct{}
+}
+
+// enviar encola el mensaje sin bloquear. Un cliente que no alcanza a
+// recibir se desconecta para que al reconectar pida el estado de nuevo,
+// en lugar de perder eventos como una entrega forzada. El cierre espera a
+// que se libere la escritura, por eso se hace en otra goroutine y una sola vez.
+func (cl *clienteTiempoReal) enviar(mensaje []byte) {
+	select {
+	case cl.salida <- mensaje:
+	default:
+		cl.lento.Do(func() {
+			go cl.conn.Cerrar(websocket.CierrePolitica, "cliente lento")
+		})
+	}
+}
+
+func (cl *clienteTiempoReal) responder(tipo, canal string, datos interface{}) {
+	d, _ := json.Marshal(datos)
+	mensaje, _ := json.Marshal(models.EventoTiempoReal{Canal: canal, Tipo: tipo, Datos: d})
+	cl.enviar(mensaje)
+}
+
+// mensajeCliente es lo que el cliente puede pedir por el WebSocket
+type mensajeCliente struct {
+	Accion string `json:"accion"` // "suscribir" o "desuscribir"
+	Canal  string `json:"canal"`
+}
+
+type tiempoExamen struct {
+	IntentoId  int       `json:"intentoId"`
+	FechaFinal time.Time `json:"fechaFinal"`
+	Restante   int       `json:"restante"` // segundos
+}
+
+// tiempoRealHandler abre el WebSocket de eventos. Como los navegadores no
+// pueden enviar cabeceras en el handshake, el JWT tambien se acepta en
+// ?token=. El cliente queda suscrito a su canal de usuario.
+func (a *App) tiempoRealHandler(w http.ResponseWriter, r *http.Request) {
+	// el token puede venir en la URL, no se registra
+	usuarioId, err := usuarioWebSocket(r)
+	if err != nil {
+		log.Printf("GET %s code: %d ERROR: %s", r.URL.Path, http.StatusUnauthorized, err.Error())
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		log.Printf("GET %s ERROR: %s -- websocket.Upgrade", r.URL.Path, err.Error())
+		return
+	}
+	log.Printf("GET %s code: %d", r.URL.Path, http.StatusSwitchingProtocols)
+
+	cliente := &clienteTiempoReal{
+		conn:           conn,
+		usuarioId:      usuarioId,
+		salida:         make(chan []byte, pendientesCliente),
+		fin:            make(chan struct{}),
+		temporizadores: map[int]chan struct{}{},
+	}
+	a.eventos.suscribir(models.CanalUsuario(usuarioId), cliente)
+	go escribirCliente(cliente)
+
+	a.leerCliente(cliente)
+
+	a.eventos.quitar(cliente)
+	for _, parar := range cliente.temporizadores {
+		close(parar)
+	}
+	close(cliente.fin)
+	conn.Cerrar(websocket.CierreNormal, "")
+}
+
+func usuarioWebSocket(r *http.Request) (int, error) {
+	tkn := r.URL.Query().Get("token")
+	if h := r.Header.Get("Authorization"); h != "" {
+		captured := regexp.MustCompile(`Bearer\s(?P<token>.*)`).FindStringSubmatch(h)
+		if captured == nil {
+			return 0, fmt.Errorf("Wrong Authorization header format")
+		}
+		tkn = captured[1]
+	}
+	if tkn == "" {
+		return 0, fmt.Errorf("no hay token en la cabecera ni en la URL")
+	}
+	valido, claims, err := models.ValidateToken(tkn)
+	if err != nil {
+		return 0, err
+	}
+	if !valido {
+		return 0, fmt.Errorf("token invalido")
+	}
+	return claims.UserId, nil
+}
+
+// escribirCliente es la unica goroutine que envia mensajes de datos al
+// cliente, ademas de los ping
+func escribirCliente(cliente *clienteTiempoReal) {
+	ticker := time.NewTicker(intervaloPing)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cliente.fin:
+			return
+		case mensaje := <-cliente.salida:
+			if err := cliente.conn.EscribirTexto(mensaje); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := cliente.conn.Ping(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// leerCliente atiende las suscripciones hasta que se cierra la conexion
+func (a *App) leerCliente(cliente *clienteTiempoReal) {
+	cliente.conn.LimiteLectura(2 * intervaloPing)
+	for {
+		_, datos, err := cliente.conn.Leer()
+		if err != nil {
+			return
+		}
+
+		var m mensajeCliente
+		if err := json.Unmarshal(datos, &m); err != nil {
+			cliente.responder("error", "", map[string]string{"mensaje": "Invalid payload"})
+			continue
+		}
+		switch m.Accion {
+		case "suscribir":
+			motivo, err := a.suscribirCliente(cliente, m.Canal)
+			if err != nil {
+				log.Printf("TIEMPO REAL ERROR: %s -- suscribir %s", err.Error(), m.Canal)
+				motivo = "No se pudo suscribir al canal"
+			}
+			if motivo != "" {
+				cliente.responder("error", m.Canal, map[string]string{"mensaje": motivo})
+				continue
+			}
+			cliente.responder("suscrito", m.Canal, nil)
+		case "desuscribir":
+			a.eventos.desuscribir(m.Canal, cliente)
+			if id, ok := idCanal(m.Canal, "intento"); ok && cliente.temporizadores[id] != nil {
+				close(cliente.temporizadores[id])
+				delete(cliente.temporizadores, id)
+			}
+			cliente.responder("desuscrito", m.Canal, nil)
+		default:
+			cliente.responder("error", m.Canal, map[string]string{"mensaje": "Accion desconocida"})
+		}
+	}
+}
+
+// suscribirCliente revisa que el usuario pueda escuchar el canal y lo
+// suscribe. Si no puede devuelve el motivo.
+//   - usuario:<id> solo el propio usuario
+//   - curso:<id> profesores y alumnos del curso
+//   - intento:<id> el alumno que rinde y los profesores del curso, ademas
+//     recibe el tiempo restante
+func (a *App) suscribirCliente(cliente *clienteTiempoReal, canal string) (string, error) {
+	if id, ok := idCanal(canal, "usuario"); ok {
+		if id != cliente.usuarioId {
+			return "Solo puedes suscribirte a tu propio canal de usuario", nil
+		}
+	} else if id, ok := idCanal(canal, "curso"); ok {
+		rol, err := models.RolEnCurso(a.DB, cliente.usuarioId, id)
+		if err != nil {
+			return "", err
+		}
+		if rol == "" {
+			return "No perteneces al curso", nil
+		}
+	} else if id, ok := idCanal(canal, "intento"); ok {
+		motivo, err := a.puedeVerIntento(cliente.usuarioId, id)
+		if err != nil || motivo != "" {
+			return motivo, err
+		}
+		if cliente.temporizadores[id] == nil {
+			parar := make(chan struct{})
+			cliente.temporizadores[id] = parar
+			go a.enviarTiempoRestante(cliente, id, parar)
+		}
+	} else {
+		return "Canal desconocido", nil
+	}
+
+	a.eventos.suscribir(canal, cliente)
+	return "", nil
+}
+
+func (a *App) puedeVerIntento(usuarioId, intentoId int) (string, error) {
+	intento := models.AlumnoExamen{ID: intentoId}
+	if err := intento.GetAlumnoExamen(a.DB); err != nil {
+		if err == pgx.ErrNoRows {
+			return "Intento no encontrado", nil
+		}
+		return "", err
+	}
+	alumno := models.Alumno{ID: intento.AlumnoId}
+	if err := alumno.GetAlumno(a.DB); err != nil {
+		return "", err
+	}
+	if alumno.UsuarioId == usuarioId {
+		return "", nil
+	}
+
+	examen := models.Examen{ID: intento.ExamenId}
+	if err := examen.GetExamen(a.DB); err != nil {
+		return "", err
+	}
+	rol, err := models.RolEnCurso(a.DB, usuarioId, examen.CursoId)
+	if err != nil {
+		return "", err
+	}
+	if rol != models.RolProfesor {
+		return "El intento no pertenece al usuario", nil
+	}
+	return "", nil
+}
+
+// idCanal separa "<tipo>:<id>"
+func idCanal(canal, tipo string) (int, bool) {
+	partes := strings.SplitN(canal, ":", 2)
+	if len(partes) != 2 || partes[0] != tipo {
+		return 0, false
+	}
+	id, err := strconv.Atoi(partes[1])
+	return id, err == nil
+}
+
+// enviarTiempoRestante envia el tiempo que le queda al intento cada
+// intervaloTiempoExamen, hasta que termina o el cliente se desuscribe. Se
+// lee de la base en cada envio, asi todas las instancias informan lo mismo
+// aunque un profesor cierre el intento antes.
+func (a *App) enviarTiempoRestante(cliente *clienteTiempoReal, intentoId int, parar chan struct{}) {
+	ticker := time.NewTicker(intervaloTiempoExamen)
+	defer ticker.Stop()
+	for {
+		intento := models.AlumnoExamen{ID: intentoId}
+		if err := intento.GetAlumnoExamen(a.DB); err != nil {
+			log.Printf("TIEMPO REAL ERROR: %s -- intento.GetAlumnoExamen", err.Error())
+			return
+		}
+		restante := int(time.Until(intento.FechaFinal).Seconds())
+		if !intento.Activo || restante < 0 {
+			restante = 0
+		}
+		cliente.responder(models.EventoTiempoExamen, models.CanalIntento(intentoId), tiempoExamen{
+			IntentoId:  intentoId,
+			FechaFinal: intento.FechaFinal,
+			Restante:   restante,
+		})
+		if restante == 0 {
+			return
+		}
+
+		select {
+		case <-parar:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// escucharEventos recibe los eventos de todas las instancias y los reparte
+// a los clientes de esta. Si se pierde la conexion vuelve a escuchar.
+func (a *App) escucharEventos(ctx context.Context) {
+	for {
+		err := models.EscucharEventos(ctx, a.DB, a.eventos.repartir)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("TIEMPO REAL ERROR: %s -- models.EscucharEventos", err.Error())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(esperaReconexion):
+		}
+	}
+}
+
+// programarCierreIntentos cierra los intentos vencidos cada
+// intervaloCierreIntentos hasta que se cancela el contexto
+func (a *App) programarCierreIntentos(ctx context.Context) {
+	ticker := time.NewTicker(intervaloCierreIntentos)
+	defer ticker.Stop()
+	for {
+		a.cerrarIntentosVencidos(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *App) cerrarIntentosVencidos(ahora time.Time) int {
+	cerrados, err := models.CerrarIntentosVencidos(a.DB, ahora)
+	if err != nil {
+		log.Printf("INTENTOS ERROR: %s -- models.CerrarIntentosVencidos", err.Error())
+		return 0
+	}
+	if cerrados > 0 {
+		log.Printf("INTENTOS %d cerrados por tiempo", cerrados)
+	}
+	return cerrados
+}
+
+// forzarEntregaHandler permite a un profesor del curso terminar un intento
+// en curso; el alumno recibe la entrega forzada por el WebSocket
+func (a *App) forzarEntregaHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- strconv", r.RequestURI,
+			http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusBadRequest, "ID de intento invalido")
+		return
+	}
+
+	intento := models.AlumnoExamen{ID: id}
+	if err := intento.GetAlumnoExamen(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("POST %s code: %d ERROR: %s -- no rows", r.RequestURI,
+				http.StatusNotFound, err.Error())
+			respondWithError(w, http.StatusNotFound, "Intento no encontrado")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- intento.GetAlumnoExamen", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	examen := models.Examen{ID: intento.ExamenId}
+	if err := examen.GetExamen(a.DB); err != nil {
+		log.Printf("POST %s code: %d ERROR: %s -- examen.GetExamen", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !a.profesorDelCurso(w, r, examen.CursoId) {
+		return
+	}
+
+	if err := intento.ForzarEntrega(a.DB); err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Printf("POST %s code: %d ERROR: intento ya terminado", r.RequestURI,
+				http.StatusConflict)
+			respondWithError(w, http.StatusConflict, "El intento ya termino")
+		default:
+			log.Printf("POST %s code: %d ERROR: %s -- intento.ForzarEntrega", r.RequestURI,
+				http.StatusInternalServerError, err.Error())
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	log.Printf("POST %s code: %d", r.RequestURI, http.StatusOK)
+	respondWithJSON(w, http.StatusOK, intento)
+	return
+}
+
+// publicarEvento no interrumpe la request si falla, el evento es un aviso
+// adicional a la notificacion
+func (a *App) publicarEvento(canal, tipo string, datos interface{}) {
+	if err := models.PublicarEvento(a.DB, canal, tipo, datos); err != nil {
+		log.Printf("TIEMPO REAL %s ERROR: %s -- models.PublicarEvento", tipo, err.Error())
+	}
+}
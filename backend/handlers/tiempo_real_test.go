@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/blackadress/vaula/models"
+)
+
+func TestTiempoRealSinToken(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/ws", nil)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+
+	req, _ = http.NewRequest("GET", "/ws?token=invalido", nil)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestForzarEntregaHandler(t *testing.T) {
+	// el intento 2 es del usuario de prueba, que tambien es profesor
+	token_str := prepararIntento(t)
+	asignarProfesorPrueba(1)
+
+	req, _ := http.NewRequest("POST", "/intentos/2/cerrar", nil)
+	req.Header.Set("Authorization", token_str)
+	response := executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("POST", "/intentos/2/cerrar", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusConflict, response.Code)
+
+	// el alumno ya no puede responder
+	req, _ = http.NewRequest("PUT", "/intentos/2/respuestas", nil)
+	req.Header.Set("Authorization", token_str)
+	response = executeRequest(req, a)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+}
+
+func TestSuscribirCanales(t *testing.T) {
+	prepararIntento(t)
+	cliente := &clienteTiempoReal{usuarioId: getTestJWT().UserId,
+		salida:         make(chan []byte, pendientesCliente),
+		temporizadores: map[int]chan struct{}{}}
+
+	for canal, permitido := range map[string]bool{
+		models.CanalUsuario(cliente.usuarioId):     true,
+		models.CanalUsuario(cliente.usuarioId + 1): false,
+		models.CanalCurso(1):                       false, // aun no esta asignado
+		models.CanalIntento(1):                     false, // intento de otro alumno
+		"examen:1":                                 false,
+	} {
+		motivo, err := a.suscribirCliente(cliente, canal)
+		if err != nil {
+			t.Fatalf("Error inesperado en %s: %s", canal, err)
+		}
+		if (motivo == "") != permitido {
+			t.Errorf("%s: se esperaba permitido=%v. Se obtuvo %q", canal, permitido, motivo)
+		}
+	}
+
+	asignarProfesorPrueba(1)
+	if motivo, _ := a.suscribirCliente(cliente, models.CanalIntento(1)); motivo != "" {
+		t.Errorf("El profesor del curso deberia ver el intento. Se obtuvo %q", motivo)
+	}
+	a.eventos.quitar(cliente)
+	for _, parar := range cliente.temporizadores {
+		close(parar)
+	}
+}
@@ -21,6 +21,11 @@ func (a *App) getTrabajoByIdHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expansion, ok := expansionRespuesta(w, r, models.ExpandirCurso)
+	if !ok {
+		return
+	}
+
 	trabajo := models.Trabajo{ID: id}
 	err = trabajo.GetTrabajo(a.DB)
 	if err != nil {
@@ -36,13 +41,29 @@ func (a *App) getTrabajoByIdHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	trabajos := []models.Trabajo{trabajo}
+	if err := models.ExpandirTrabajos(a.DB, trabajos, expansion); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.ExpandirTrabajos", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
-	respondWithJSON(w, http.StatusOK, trabajo)
+	respondWithJSON(w, http.StatusOK, trabajos[0])
 	return
 }
 
 func (a *App) getTrabajosHandler(w http.ResponseWriter, r *http.Request) {
-	trabajos, err := models.GetTrabajos(a.DB)
+	c, ok := consultaListado(w, r, models.ListadoTrabajos)
+	if !ok {
+		return
+	}
+	expansion, ok := expansionRespuesta(w, r, models.ExpandirCurso)
+	if !ok {
+		return
+	}
+
+	trabajos, total, err := models.GetTrabajos(a.DB, c)
 	if err != nil {
 		log.Printf("GET %s code: %d ERROR: %s -- models.GetTrabajos", r.RequestURI,
 			http.StatusInternalServerError, err.Error())
@@ -50,8 +71,15 @@ func (a *App) getTrabajosHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := models.ExpandirTrabajos(a.DB, trabajos, expansion); err != nil {
+		log.Printf("GET %s code: %d ERROR: %s -- models.ExpandirTrabajos", r.RequestURI,
+			http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
-	respondWithJSON(w, http.StatusOK, trabajos)
+	respondWithPagina(w, r, c, total, trabajos)
 	return
 
 }
@@ -69,6 +97,13 @@ func (a *App) createTrabajoHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
+	if msg := validarPoliticaTardanza(trabajo); msg != "" {
+		log.Printf("POST %s code: %d ERROR: %s -- validarPoliticaTardanza", r.RequestURI,
+			http.StatusBadRequest, msg)
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
 	// hay la request debe especificamente settear el valor de trabajo.Activo,
 	// debido a que por defecto se inicializa en 'false'
 	err = trabajo.CreateTrabajo(a.DB)
@@ -105,6 +140,13 @@ func (a *App) updateTrabajoHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
+	if msg := validarPoliticaTardanza(trabajo); msg != "" {
+		log.Printf("PUT %s code: %d ERROR: %s -- validarPoliticaTardanza", r.RequestURI,
+			http.StatusBadRequest, msg)
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
 	trabajo.ID = id
 	err = trabajo.UpdateTrabajo(a.DB)
 	if err != nil {
@@ -141,3 +183,21 @@ func (a *App) deleteTrabajoHandler(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]int{"exito": 1, "id": trabajo.ID})
 	return
 }
+
+// validarPoliticaTardanza devuelve un mensaje de error o "" si la politica
+// de entregas tardias del trabajo es valida
+func validarPoliticaTardanza(t models.Trabajo) string {
+	switch t.PoliticaTardanza {
+	case "", models.PoliticaCorte, models.PoliticaGracia, models.PoliticaDescuento:
+	default:
+		return "politicaTardanza debe ser 'corte', 'gracia' o 'descuento'"
+	}
+	if t.MinutosGracia < 0 {
+		return "minutosGracia no puede ser negativo"
+	}
+	if t.DescuentoDiario < 0 || t.DescuentoDiario > 100 ||
+		t.DescuentoMaximo < 0 || t.DescuentoMaximo > 100 {
+		return "Los descuentos deben estar entre 0 y 100"
+	}
+	return ""
+}
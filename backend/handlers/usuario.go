@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -17,6 +18,10 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+type contextKey int
+
+const userIdKey contextKey = 0
+
 func (a *App) getUserByIdHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -47,7 +52,12 @@ func (a *App) getUserByIdHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *App) getUsersHandler(w http.ResponseWriter, r *http.Request) {
-	users, err := models.GetUsers(a.DB)
+	c, ok := consultaListado(w, r, models.ListadoUsuarios)
+	if !ok {
+		return
+	}
+
+	users, total, err := models.GetUsers(a.DB, c)
 
 	if err != nil {
 		log.Printf("GET %s code: %d ERROR: %s", r.RequestURI,
@@ -57,7 +67,7 @@ func (a *App) getUsersHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("GET %s code: %d", r.RequestURI, http.StatusOK)
-	respondWithJSON(w, http.StatusOK, users)
+	respondWithPagina(w, r, c, total, users)
 	return
 }
 
@@ -274,7 +284,7 @@ func isAuthorized(endpoint func(http.ResponseWriter, *http.Request)) http.Handle
 				respondWithError(w, http.StatusBadRequest, "Invalid user or password")
 			}
 
-			isTokenValid, _, err := models.ValidateToken(tkn)
+			isTokenValid, claims, err := models.ValidateToken(tkn)
 
 			if err != nil {
 				log.Printf("POST %s code: %d ERROR: %s", r.RequestURI,
@@ -283,7 +293,8 @@ func isAuthorized(endpoint func(http.ResponseWriter, *http.Request)) http.Handle
 			}
 
 			if isTokenValid {
-				endpoint(w, r)
+				ctx := context.WithValue(r.Context(), userIdKey, claims.UserId)
+				endpoint(w, r.WithContext(ctx))
 			}
 		} else {
 			var s string
@@ -297,6 +308,13 @@ func isAuthorized(endpoint func(http.ResponseWriter, *http.Request)) http.Handle
 	})
 }
 
+// getUserId devuelve el id del usuario autenticado, puesto en el
+// contexto de la request por isAuthorized
+func getUserId(r *http.Request) int {
+	userId, _ := r.Context().Value(userIdKey).(int)
+	return userId
+}
+
 func pass(endpoint func(http.ResponseWriter, *http.Request)) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		endpoint(w, r)
@@ -0,0 +1,210 @@
+// Package impreso genera examenes en PDF para tomarlos en papel: la hoja
+// del alumno y la clave del profesor, opcionalmente en varias variantes
+// barajadas. Trabaja con los tipos de formatos, sin base de datos, y
+// escribe el PDF directamente, sin programas externos.
+package impreso
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/blackadress/vaula/formatos"
+)
+
+// Versiones del examen impreso
+const (
+	VersionAlumno = "alumno"
+	VersionClave  = "clave"
+)
+
+// MaxVariantes es el maximo de variantes, una por letra
+const MaxVariantes = 26
+
+// ErrVersion se devuelve cuando la version pedida no existe
+var ErrVersion = errors.New("impreso: version no soportada")
+
+// Variante es el examen con las preguntas en el orden en que se imprime.
+// El codigo se imprime en cada pagina para saber con que clave corregirla.
+type Variante struct {
+	formatos.Examen
+	Codigo string
+}
+
+// Variantes genera 'n' variantes con codigos A, B, C... La variante A
+// conserva el orden original; las demas barajan las preguntas y las
+// alternativas de opcion multiple. El orden depende solo de 'semilla', asi
+// la hoja del alumno y la clave de una variante coinciden aunque se pidan
+// por separado.
+func Variantes(examen formatos.Examen, n int, semilla int64) []Variante {
+	variantes := make([]Variante, n)
+	for i := range variantes {
+		v := Variante{Examen: examen, Codigo: string(rune('A' + i))}
+		if i > 0 {
+			rnd := rand.New(rand.NewSource(semilla*MaxVariantes + int64(i)))
+			v.Preguntas = barajar(examen.Preguntas, rnd)
+		}
+		variantes[i] = v
+	}
+	return variantes
+}
+
+// barajar copia las preguntas en otro orden sin modificar las originales
+func barajar(preguntas []formatos.Pregunta, rnd *rand.Rand) []formatos.Pregunta {
+	barajadas := make([]formatos.Pregunta, len(preguntas))
+	for i, j := range rnd.Perm(len(preguntas)) {
+		p := preguntas[j]
+		if p.Tipo == formatos.OpcionMultiple {
+			alternativas := make([]formatos.Alternativa, len(p.Alternativas))
+			for k, l := range rnd.Perm(len(p.Alternativas)) {
+				alternativas[k] = p.Alternativas[l]
+			}
+			p.Alternativas = alternativas
+		}
+		barajadas[i] = p
+	}
+	return barajadas
+}
+
+// EscribirPDF escribe las variantes en un solo PDF, cada una desde una
+// pagina nueva. La clave marca las alternativas correctas y muestra las
+// respuestas aceptadas en lugar de los renglones para responder.
+func EscribirPDF(w io.Writer, variantes []Variante, version string) error {
+	if version != VersionAlumno && version != VersionClave {
+		return ErrVersion
+	}
+	clave := version == VersionClave
+
+	d := &documento{}
+	for _, v := range variantes {
+		d.pie = fmt.Sprintf("%s - Variante %s", v.Nombre, v.Codigo)
+		d.nuevaPagina()
+		encabezado(d, v, clave)
+		for i, p := range v.Preguntas {
+			pregunta(d, i+1, p, clave)
+		}
+	}
+	return d.escribir(w)
+}
+
+func encabezado(d *documento, v Variante, clave bool) {
+	d.parrafo(margen, 16, negrita, v.Nombre)
+	if clave {
+		d.parrafo(margen, 12, negrita, "CLAVE DE RESPUESTAS")
+	}
+
+	total := 0.0
+	for _, p := range v.Preguntas {
+		total += p.Puntaje
+	}
+	datos := fmt.Sprintf("Variante: %s    Preguntas: %d    Puntaje total: %s",
+		v.Codigo, len(v.Preguntas), puntaje(total))
+	if v.Duracion > 0 {
+		datos += fmt.Sprintf("    Duracion: %d minutos", v.Duracion)
+	}
+	d.saltar(4)
+	d.parrafo(margen, 10, normal, datos)
+
+	if !clave {
+		d.saltar(8)
+		d.parrafo(margen, 10, normal, "Apellidos y nombres:")
+		d.renglon(margen + 100)
+		d.parrafo(margen, 10, normal, "Codigo:")
+		d.renglon(margen + 100)
+	}
+	d.saltar(12)
+}
+
+// renglones para responder segun el tipo de pregunta
+var renglones = map[string]int{
+	formatos.RespuestaCorta: 1,
+	formatos.Numerica:       1,
+	formatos.Ensayo:         8,
+}
+
+const sangria = margen + 18
+
+func pregunta(d *documento, numero int, p formatos.Pregunta, clave bool) {
+	enunciado := fmt.Sprintf("%d. %s (%s pts.)", numero, p.Enunciado, puntaje(p.Puntaje))
+	opciones := opcionesPregunta(p, clave)
+
+	// se intenta no separar el enunciado de sus alternativas
+	necesario := alto(margen, 11, normal, enunciado)
+	for _, o := range opciones {
+		necesario += alto(sangria, 11, normal, o.texto)
+	}
+	if !clave {
+		necesario += float64(renglones[p.Tipo]) * 22
+	}
+	if necesario < altoPagina-2*margen {
+		d.espacio(necesario)
+	}
+
+	d.parrafo(margen, 11, normal, enunciado)
+	for _, o := range opciones {
+		f := normal
+		if o.marcada {
+			f = negrita
+		}
+		d.parrafo(sangria, 11, f, o.texto)
+	}
+	if !clave {
+		for i := 0; i < renglones[p.Tipo]; i++ {
+			d.renglon(sangria)
+		}
+	}
+	d.saltar(10)
+}
+
+type opcion struct {
+	texto   string
+	marcada bool
+}
+
+// opcionesPregunta son las lineas bajo el enunciado: las alternativas para
+// marcar y, en la clave, las respuestas esperadas
+func opcionesPregunta(p formatos.Pregunta, clave bool) []opcion {
+	var opciones []opcion
+	switch p.Tipo {
+	case formatos.OpcionMultiple, formatos.VerdaderoFalso:
+		for i, a := range p.Alternativas {
+			casilla := "( )"
+			if clave && a.Correcta() {
+				casilla = "(X)"
+			}
+			texto := fmt.Sprintf("%s %c) %s", casilla, 'a'+i, a.Texto)
+			if p.Tipo == formatos.VerdaderoFalso {
+				texto = fmt.Sprintf("%s %s", casilla, a.Texto)
+			}
+			opciones = append(opciones, opcion{texto, clave && a.Correcta()})
+		}
+	case formatos.RespuestaCorta, formatos.Numerica:
+		if !clave {
+			break
+		}
+		var aceptadas []string
+		for _, a := range p.Alternativas {
+			if !a.Correcta() {
+				continue
+			}
+			if a.Tolerancia > 0 {
+				aceptadas = append(aceptadas, fmt.Sprintf("%s ± %s", a.Texto, puntaje(a.Tolerancia)))
+			} else {
+				aceptadas = append(aceptadas, a.Texto)
+			}
+		}
+		opciones = append(opciones, opcion{"Respuesta: " + strings.Join(aceptadas, " / "), true})
+	case formatos.Ensayo:
+		if clave {
+			opciones = append(opciones, opcion{"Respuesta abierta, se corrige manualmente.", false})
+		}
+	}
+	return opciones
+}
+
+func puntaje(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
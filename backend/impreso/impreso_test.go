@@ -0,0 +1,127 @@
+package impreso
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/blackadress/vaula/formatos"
+)
+
+func examenPrueba() formatos.Examen {
+	return formatos.Examen{
+		Nombre:   "Primer parcial",
+		Duracion: 90,
+		Preguntas: []formatos.Pregunta{
+			{Tipo: formatos.OpcionMultiple, Enunciado: "Capital del Peru", Puntaje: 2,
+				Alternativas: []formatos.Alternativa{{Texto: "Lima", Fraccion: 100}, {Texto: "Cusco"}, {Texto: "Arequipa"}}},
+			{Tipo: formatos.VerdaderoFalso, Enunciado: "El agua hierve a 100 C", Puntaje: 1,
+				Alternativas: []formatos.Alternativa{{Texto: formatos.Verdadero, Fraccion: 100}, {Texto: formatos.Falso}}},
+			{Tipo: formatos.Numerica, Enunciado: "Valor de pi", Puntaje: 1,
+				Alternativas: []formatos.Alternativa{{Texto: "3.14", Fraccion: 100, Tolerancia: 0.01}}},
+			{Tipo: formatos.Ensayo, Enunciado: "Explique la fotosintesis (en detalle)", Puntaje: 4},
+		},
+	}
+}
+
+func TestVariantes(t *testing.T) {
+	examen := examenPrueba()
+	variantes := Variantes(examen, 3, 7)
+
+	if len(variantes) != 3 || variantes[2].Codigo != "C" {
+		t.Fatalf("Se esperaban las variantes A, B y C. Se obtuvo %+v", variantes)
+	}
+	if !reflect.DeepEqual(variantes[0].Preguntas, examen.Preguntas) {
+		t.Errorf("La variante A debe conservar el orden original")
+	}
+	if !reflect.DeepEqual(Variantes(examen, 3, 7), variantes) {
+		t.Errorf("Con la misma semilla se esperaban las mismas variantes")
+	}
+
+	for _, v := range variantes {
+		for _, p := range v.Preguntas {
+			if p.Enunciado != "Capital del Peru" {
+				continue
+			}
+			for _, a := range p.Alternativas {
+				if a.Correcta() != (a.Texto == "Lima") {
+					t.Errorf("Variante %s: la alternativa correcta cambio %+v", v.Codigo, p.Alternativas)
+				}
+			}
+		}
+	}
+	if examen.Preguntas[0].Alternativas[0].Texto != "Lima" {
+		t.Errorf("Barajar no debe modificar el examen original")
+	}
+}
+
+func TestEscribirPDF(t *testing.T) {
+	variantes := Variantes(examenPrueba(), 2, 1)
+
+	var alumno, clave bytes.Buffer
+	if err := EscribirPDF(&alumno, variantes, VersionAlumno); err != nil {
+		t.Fatalf("No se escribio el PDF %s", err)
+	}
+	if err := EscribirPDF(&clave, variantes, VersionClave); err != nil {
+		t.Fatalf("No se escribio la clave %s", err)
+	}
+
+	for nombre, pdf := range map[string]string{"alumno": alumno.String(), "clave": clave.String()} {
+		if !strings.HasPrefix(pdf, "%PDF-1.4") || !strings.HasSuffix(pdf, "%%EOF\n") {
+			t.Errorf("%s: el archivo no es un PDF completo", nombre)
+		}
+		if !strings.Contains(pdf, "Variante B") {
+			t.Errorf("%s: se esperaba el codigo de la variante B", nombre)
+		}
+		// los parentesis del enunciado deben ir escapados
+		if !strings.Contains(pdf, `fotosintesis \(en detalle\)`) {
+			t.Errorf("%s: no se encontro el enunciado escapado", nombre)
+		}
+		comprobarXref(t, nombre, pdf)
+	}
+
+	if strings.Contains(alumno.String(), `\(X\)`) || !strings.Contains(clave.String(), `\(X\) a\) Lima`) {
+		t.Errorf("Solo la clave debe marcar las alternativas correctas")
+	}
+	if !strings.Contains(clave.String(), "Respuesta: 3.14 \xb1 0.01") {
+		t.Errorf("La clave debe mostrar la respuesta numerica con su tolerancia")
+	}
+
+	if err := EscribirPDF(&bytes.Buffer{}, variantes, "borrador"); err != ErrVersion {
+		t.Errorf("Se esperaba ErrVersion. Se obtuvo %v", err)
+	}
+}
+
+// comprobarXref verifica que cada entrada de la tabla xref apunte al
+// inicio de su objeto
+func comprobarXref(t *testing.T, nombre, pdf string) {
+	entradas := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(pdf, -1)
+	if len(entradas) == 0 {
+		t.Errorf("%s: la tabla xref esta vacia", nombre)
+	}
+	for i, e := range entradas {
+		offset, _ := strconv.Atoi(e[1])
+		esperado := strconv.Itoa(i+1) + " 0 obj"
+		if !strings.HasPrefix(pdf[offset:], esperado) {
+			t.Errorf("%s: la entrada %d de xref no apunta a '%s'", nombre, i+1, esperado)
+		}
+	}
+}
+
+func TestPartirLineas(t *testing.T) {
+	lineas := partirLineas("uno dos tres\n\ncuatro", anchoTexto("uno dos", 10, normal), 10, normal)
+	esperado := []string{"uno dos", "tres", "", "cuatro"}
+	if !reflect.DeepEqual(lineas, esperado) {
+		t.Errorf("Se esperaba %q. Se obtuvo %q", esperado, lineas)
+	}
+
+	larga := strings.Repeat("m", 100)
+	for _, l := range partirLineas(larga, 100, 10, negrita) {
+		if anchoTexto(l, 10, negrita) > 100 {
+			t.Errorf("La linea '%s' excede el ancho", l)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package impreso
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Medidas de una hoja A4 en puntos
+const (
+	anchoPagina = 595.28
+	altoPagina  = 841.89
+	margen      = 56.0
+)
+
+type fuente int
+
+// Se usan las fuentes estandar de PDF, que todo lector trae, para no
+// tener que incrustar ninguna
+const (
+	normal fuente = iota
+	negrita
+)
+
+var nombresFuentes = []string{"Helvetica", "Helvetica-Bold"}
+
+// documento arma un PDF pagina por pagina. Solo tiene lo que necesita un
+// examen impreso: texto con saltos de linea automaticos y lineas para
+// escribir las respuestas.
+type documento struct {
+	paginas [][]byte
+	pagina  bytes.Buffer
+	abierta bool
+	y       float64 // posicion de la proxima linea, desde abajo
+	pie     string  // se escribe al final de cada pagina junto con su numero
+}
+
+func (d *documento) nuevaPagina() {
+	d.cerrarPagina()
+	d.abierta = true
+	d.y = altoPagina - margen
+}
+
+func (d *documento) cerrarPagina() {
+	if !d.abierta {
+		return
+	}
+	pie := fmt.Sprintf("Pagina %d", len(d.paginas)+1)
+	if d.pie != "" {
+		pie = d.pie + " - " + pie
+	}
+	d.texto(margen, margen/2, 8, normal, pie)
+	d.paginas = append(d.paginas, append([]byte(nil), d.pagina.Bytes()...))
+	d.pagina.Reset()
+	d.abierta = false
+}
+
+// espacio empieza una pagina nueva si en la actual no quedan 'alto'
+// puntos libres
+func (d *documento) espacio(alto float64) {
+	if !d.abierta || d.y-alto < margen {
+		d.nuevaPagina()
+	}
+}
+
+func (d *documento) saltar(alto float64) {
+	d.espacio(alto)
+	d.y -= alto
+}
+
+func (d *documento) texto(x, y, tam float64, f fuente, s string) {
+	fmt.Fprintf(&d.pagina, "BT /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		f+1, numeroPDF(tam), numeroPDF(x), numeroPDF(y), cadenaPDF(s))
+}
+
+func interlineado(tam float64) float64 {
+	return tam * 1.35
+}
+
+// parrafo escribe 's' desde 'x' hasta el margen derecho, partiendo las
+// lineas entre palabras
+func (d *documento) parrafo(x, tam float64, f fuente, s string) {
+	for _, linea := range partirLineas(s, anchoPagina-margen-x, tam, f) {
+		d.saltar(interlineado(tam))
+		d.texto(x, d.y, tam, f, linea)
+	}
+}
+
+// alto es lo que ocupara parrafo con los mismos argumentos
+func alto(x, tam float64, f fuente, s string) float64 {
+	return float64(len(partirLineas(s, anchoPagina-margen-x, tam, f))) * interlineado(tam)
+}
+
+// renglon dibuja una linea horizontal para escribir a mano
+func (d *documento) renglon(x float64) {
+	d.saltar(22)
+	fmt.Fprintf(&d.pagina, "0.5 w %s %s m %s %s l S\n",
+		numeroPDF(x), numeroPDF(d.y), numeroPDF(anchoPagina-margen), numeroPDF(d.y))
+}
+
+// escribir cierra la ultima pagina y escribe el archivo. Los objetos son
+// el catalogo (1), el arbol de paginas (2), las fuentes (3 y 4) y despues
+// cada pagina seguida de su contenido.
+func (d *documento) escribir(w io.Writer) error {
+	d.cerrarPagina()
+	if len(d.paginas) == 0 {
+		d.nuevaPagina()
+		d.cerrarPagina()
+	}
+
+	out := &contador{w: bufio.NewWriter(w)}
+	var offsets []int64
+	objeto := func(cuerpo string) {
+		offsets = append(offsets, out.n)
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", len(offsets), cuerpo)
+	}
+
+	// el comentario binario indica a los lectores que el archivo no es ASCII
+	fmt.Fprint(out, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	primera := 3 + len(nombresFuentes)
+	kids := make([]string, len(d.paginas))
+	for i := range d.paginas {
+		kids[i] = fmt.Sprintf("%d 0 R", primera+2*i)
+	}
+	objeto("<< /Type /Catalog /Pages 2 0 R >>")
+	objeto(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>",
+		strings.Join(kids, " "), len(d.paginas)))
+	for _, nombre := range nombresFuentes {
+		objeto(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", nombre))
+	}
+	fuentes := ""
+	for i := range nombresFuentes {
+		fuentes += fmt.Sprintf(" /F%d %d 0 R", i+1, 3+i)
+	}
+	for i, contenido := range d.paginas {
+		objeto(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font <<%s >> >> /Contents %d 0 R >>",
+			numeroPDF(anchoPagina), numeroPDF(altoPagina), fuentes, primera+2*i+1))
+		objeto(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(contenido), contenido))
+	}
+
+	xref := out.n
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(offsets)+1, xref)
+
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+// contador lleva la posicion en el archivo para la tabla xref y guarda
+// el primer error de escritura
+type contador struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *contador) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+func numeroPDF(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// cadenaPDF codifica 's' en WinAnsi, la codificacion de las fuentes
+// estandar, y escapa los caracteres especiales de las cadenas PDF. Lo que
+// no existe en WinAnsi se reemplaza por '?'.
+func cadenaPDF(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		c := winAnsi(r)
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94,
+	'•': 0x95, '–': 0x96, '—': 0x97,
+}
+
+func winAnsi(r rune) byte {
+	switch {
+	case r == '\t':
+		return ' '
+	case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+		return byte(r)
+	}
+	if c, ok := winAnsiExtra[r]; ok {
+		return c
+	}
+	return '?'
+}
+
+// partirLineas divide el texto en lineas de a lo mas 'ancho' puntos. Los
+// saltos de linea del texto se respetan y una palabra mas larga que el
+// ancho se corta donde haga falta.
+func partirLineas(s string, ancho, tam float64, f fuente) []string {
+	var lineas []string
+	for _, p := range strings.Split(s, "\n") {
+		linea := ""
+		for _, palabra := range strings.Fields(p) {
+			candidata := palabra
+			if linea != "" {
+				candidata = linea + " " + palabra
+			}
+			if anchoTexto(candidata, tam, f) <= ancho {
+				linea = candidata
+				continue
+			}
+			if linea != "" {
+				lineas = append(lineas, linea)
+			}
+			for anchoTexto(palabra, tam, f) > ancho {
+				corte := cortarPalabra(palabra, ancho, tam, f)
+				lineas = append(lineas, palabra[:corte])
+				palabra = palabra[corte:]
+			}
+			linea = palabra
+		}
+		lineas = append(lineas, linea)
+	}
+	return lineas
+}
+
+// cortarPalabra devuelve cuantos bytes de 'palabra' caben en 'ancho',
+// al menos una letra
+func cortarPalabra(palabra string, ancho, tam float64, f fuente) int {
+	corte := 0
+	for i, r := range palabra {
+		if i > 0 && anchoTexto(palabra[:i+len(string(r))], tam, f) > ancho {
+			break
+		}
+		corte = i + len(string(r))
+	}
+	return corte
+}
+
+func anchoTexto(s string, tam float64, f fuente) float64 {
+	total := 0
+	for _, r := range s {
+		total += anchoLetra(winAnsi(r), f)
+	}
+	return float64(total) * tam / 1000
+}
+
+// anchos de Helvetica y Helvetica-Bold para ' ' (32) a '~' (126), en
+// milesimas del tamano de la fuente
+var anchos = [2][95]int{
+	{278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584},
+	{278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584},
+}
+
+// letra sin tilde equivalente a cada caracter de 0xC0 a 0xFF, para usar
+// su ancho
+const latinaBase = "AAAAAAACEEEEIIIIDNOOOOOxOUUUUYPsaaaaaaaceeeeiiiidnooooo/ouuuuypy"
+
+func anchoLetra(c byte, f fuente) int {
+	if c >= 0xc0 {
+		c = latinaBase[c-0xc0]
+	}
+	if c >= 32 && c <= 126 {
+		return anchos[f][c-32]
+	}
+	return 556
+}
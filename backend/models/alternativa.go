@@ -5,13 +5,17 @@ import (
 	"log"
 	"time"
 
+	"github.com/blackadress/vaula/consulta"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 type Alternativa struct {
-	ID       int    `json:"id"`
-	Valor    string `json:"valor"`
-	Correcto bool   `json:"correcto"`
+	ID         int    `json:"id"`
+	Valor      string `json:"valor"`
+	Correcto   bool   `json:"correcto"`
+	PreguntaId int    `json:"preguntaId"`
+	// margen aceptado en las preguntas numericas
+	Tolerancia float32 `json:"tolerancia"`
 
 	Activo    bool      `json:"activo"`
 	CreatedAt time.Time `json:"createdAt"`
@@ -22,30 +26,51 @@ func (a *Alternativa) CreateAlternativa(db *pgxpool.Pool) error {
 	now := time.Now()
 	return db.QueryRow(
 		context.Background(),
-		`INSERT INTO alternativas(valor, correcto, activo, createdAt, updatedAt)
-		VALUES($1, $2, $3, $4, $5)
+		`INSERT INTO alternativas(valor, correcto, tolerancia, preguntaId,
+		activo, createdAt, updatedAt)
+		VALUES($1, $2, $3, NULLIF($4, 0), $5, $6, $7)
 		RETURNING id`,
-		a.Valor, a.Correcto, a.Activo, now, now,
+		a.Valor, a.Correcto, a.Tolerancia, a.PreguntaId, a.Activo, now, now,
 	).Scan(&a.ID)
 }
 
 func (a *Alternativa) GetAlternativa(db *pgxpool.Pool) error {
 	return db.QueryRow(
 		context.Background(),
-		`SELECT valor, correcto, activo, createdAt, updatedAt
+		`SELECT valor, correcto, tolerancia, COALESCE(preguntaId, 0),
+		activo, createdAt, updatedAt
 		FROM alternativas
 		WHERE id=$1
 		`,
 		a.ID,
-	).Scan(&a.Valor, &a.Correcto, &a.Activo, &a.CreatedAt, &a.UpdatedAt)
+	).Scan(&a.Valor, &a.Correcto, &a.Tolerancia, &a.PreguntaId,
+		&a.Activo, &a.CreatedAt, &a.UpdatedAt)
 }
 
-func GetAlternativas(db *pgxpool.Pool) ([]Alternativa, error) {
+// ListadoAlternativas son los filtros y ordenes de GET /alternativas
+var ListadoAlternativas = consulta.Definicion{
+	Campos: map[string]consulta.Campo{
+		"preguntaId": {Columna: "preguntaId", Tipo: consulta.Entero, Filtro: true, Orden: true},
+		"correcto":   {Columna: "correcto", Tipo: consulta.Booleano, Filtro: true},
+		"activo":     {Columna: "activo", Tipo: consulta.Booleano, Filtro: true},
+		"createdAt":  {Columna: "createdAt", Tipo: consulta.Fecha, Orden: true},
+	},
+}
+
+func GetAlternativas(db *pgxpool.Pool, c consulta.Consulta) ([]Alternativa, int, error) {
+	total, err := contar(db, "alternativas", c)
+	if err != nil {
+		return nil, 0, err
+	}
+	where, args := c.Filtro()
+
 	rows, err := db.Query(context.Background(),
-		`SELECT id, valor, correcto, activo, createdAt, updatedAt
-		FROM alternativas`)
+		`SELECT id, valor, correcto, tolerancia, COALESCE(preguntaId, 0),
+		activo, createdAt, updatedAt
+		FROM alternativas`+where+c.OrdenYPagina(),
+		args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -54,25 +79,27 @@ func GetAlternativas(db *pgxpool.Pool) ([]Alternativa, error) {
 	for rows.Next() {
 		var a Alternativa
 		err := rows.Scan(
-			&a.ID, &a.Valor, &a.Correcto, &a.Activo, &a.CreatedAt, &a.UpdatedAt)
+			&a.ID, &a.Valor, &a.Correcto, &a.Tolerancia, &a.PreguntaId,
+			&a.Activo, &a.CreatedAt, &a.UpdatedAt)
 		if err != nil {
 			log.Printf("Las filas obtenidas de la BD para Alternativa, no satisfacen a 'Scan' %s",
 				err)
-			return nil, err
+			return nil, 0, err
 		}
 		alternativas = append(alternativas, a)
 	}
 
-	return alternativas, nil
+	return alternativas, total, rows.Err()
 }
 
 func (a *Alternativa) UpdateAlternativa(db *pgxpool.Pool) error {
 	updTime := time.Now()
 	_, err := db.Exec(
 		context.Background(),
-		`UPDATE alternativas SET valor=$1, correcto=$2, activo=$3, updatedAt=$4
-		WHERE id=$5`,
-		a.Valor, a.Correcto, a.Activo, updTime, a.ID)
+		`UPDATE alternativas SET valor=$1, correcto=$2, tolerancia=$3,
+		preguntaId=NULLIF($4, 0), activo=$5, updatedAt=$6
+		WHERE id=$7`,
+		a.Valor, a.Correcto, a.Tolerancia, a.PreguntaId, a.Activo, updTime, a.ID)
 
 	return err
 }
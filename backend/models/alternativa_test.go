@@ -3,6 +3,7 @@ package models
 import (
 	"testing"
 
+	"github.com/blackadress/vaula/consulta"
 	"github.com/blackadress/vaula/utils"
 	"github.com/jackc/pgx/v4"
 )
@@ -48,7 +49,7 @@ func TestNotGetAlternativa(t *testing.T) {
 func TestGetAlternativas(t *testing.T) {
 	utils.ClearTableAlternativa(db)
 	utils.AddAlternativas(2, db)
-	cursos, err := GetAlternativas(db)
+	cursos, _, err := GetAlternativas(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
 	}
@@ -62,7 +63,7 @@ func TestGetAlternativas(t *testing.T) {
 func TestGetZeroAlternativas(t *testing.T) {
 	utils.ClearTableAlternativa(db)
 
-	cursos, err := GetAlternativas(db)
+	cursos, _, err := GetAlternativas(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
 	}
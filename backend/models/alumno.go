@@ -2,9 +2,12 @@ package models
 
 import (
 	"context"
+	"errors"
 	"log"
+	"math"
 	"time"
 
+	"github.com/blackadress/vaula/consulta"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
@@ -14,7 +17,7 @@ type Alumno struct {
 	Apellidos string `json:"apellidos"`
 	Codigo    string `json:"codigo"` // 8 characteres
 	UsuarioId int    `json:"usuarioId"`
-	Usuario   User   `json:"usuario"`
+	Usuario   *User  `json:"usuario,omitempty"`
 
 	Activo    bool      `json:"activo"`
 	CreatedAt time.Time `json:"createdAt"`
@@ -43,15 +46,45 @@ func (a *Alumno) GetAlumno(db *pgxpool.Pool) error {
 	).Scan(&a.Nombres, &a.Apellidos, &a.Codigo, &a.Activo, &a.UsuarioId, &a.CreatedAt, &a.UpdatedAt)
 }
 
-func GetAlumnos(db *pgxpool.Pool) ([]Alumno, error) {
+func (a *Alumno) GetAlumnoByUsuario(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT id, nombres, apellidos, codigo, activo,
+		createdAt, updatedAt
+		FROM alumnos
+		WHERE usuarioId=$1`,
+		a.UsuarioId,
+	).Scan(&a.ID, &a.Nombres, &a.Apellidos, &a.Codigo, &a.Activo, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// ListadoAlumnos son los filtros y ordenes de GET /alumnos
+var ListadoAlumnos = consulta.Definicion{
+	Campos: map[string]consulta.Campo{
+		"codigo":    {Columna: "codigo", Tipo: consulta.Texto, Filtro: true, Orden: true},
+		"nombres":   {Columna: "nombres", Tipo: consulta.Texto, Filtro: true, Parcial: true, Orden: true},
+		"apellidos": {Columna: "apellidos", Tipo: consulta.Texto, Filtro: true, Parcial: true, Orden: true},
+		"usuarioId": {Columna: "usuarioId", Tipo: consulta.Entero, Filtro: true},
+		"activo":    {Columna: "activo", Tipo: consulta.Booleano, Filtro: true},
+		"createdAt": {Columna: "createdAt", Tipo: consulta.Fecha, Orden: true},
+	},
+}
+
+func GetAlumnos(db *pgxpool.Pool, c consulta.Consulta) ([]Alumno, int, error) {
+	total, err := contar(db, "alumnos", c)
+	if err != nil {
+		return nil, 0, err
+	}
+	where, args := c.Filtro()
+
 	rows, err := db.Query(
 		context.Background(),
 		`SELECT id, nombres, apellidos, codigo,
 		usuarioId, activo, createdAt, updatedAt
-		FROM alumnos`)
+		FROM alumnos`+where+c.OrdenYPagina(),
+		args...)
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -64,11 +97,11 @@ func GetAlumnos(db *pgxpool.Pool) ([]Alumno, error) {
 			&a.UsuarioId, &a.Activo, &a.CreatedAt, &a.UpdatedAt)
 		if err != nil {
 			log.Println("Las filas obtenidas de la BD para Alumno, no satisfacen a 'Scan'")
-			return nil, err
+			return nil, 0, err
 		}
 		alumnos = append(alumnos, a)
 	}
-	return alumnos, nil
+	return alumnos, total, rows.Err()
 }
 
 func (a *Alumno) UpdateAlumno(db *pgxpool.Pool) error {
@@ -95,13 +128,15 @@ func (a *Alumno) DeleteAlumno(db *pgxpool.Pool) error {
 	return err
 }
 
+// AlumnoCurso es la matricula de un alumno en un curso
 type AlumnoCurso struct {
 	ID           int       `json:"id"`
 	Calificacion float32   `json:"calificacion"`
 	FechaInicio  time.Time `json:"fechaInicio"`
 	FechaFinal   time.Time `json:"fechaFinal"`
 	AlumnoId     int       `json:"alumnoId"`
-	Alumno       Alumno    `json:"alumno"`
+	Alumno       *Alumno   `json:"alumno,omitempty"`
+	CursoId      int       `json:"cursoId"`
 
 	Activo    bool      `json:"activo"`
 	CreatedAt time.Time `json:"createdAt"`
@@ -112,31 +147,32 @@ func (ac *AlumnoCurso) CreateAlumnoCurso(db *pgxpool.Pool) error {
 	now := time.Now()
 	return db.QueryRow(
 		context.Background(),
-		`INSERT INTO alumnoCurso(calificacion, fechaInicio, 
-		fechaFinal, alumnoId, activo, createdAt, updatedAt)
-		VALUES($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id`,
+		`INSERT INTO alumnoCurso(calificacion, fechaInicio,
+		fechaFinal, alumnoId, cursoId, activo, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, createdAt, updatedAt`,
 		ac.Calificacion, ac.FechaInicio, ac.FechaFinal,
-		ac.AlumnoId, now, now,
-	).Scan(&ac.Calificacion, &ac.FechaInicio, &ac.FechaFinal,
-		&ac.AlumnoId, &ac.CreatedAt, &ac.UpdatedAt)
+		ac.AlumnoId, ac.CursoId, ac.Activo, now, now,
+	).Scan(&ac.ID, &ac.CreatedAt, &ac.UpdatedAt)
 }
 
 func (ac *AlumnoCurso) GetAlumnoCurso(db *pgxpool.Pool) error {
 	return db.QueryRow(
 		context.Background(),
-		`SELECT calificacion, fechaInicio, fechaFinal, alumnoId, createdAt, updatedAt
+		`SELECT calificacion, fechaInicio, fechaFinal, alumnoId, cursoId,
+		activo, createdAt, updatedAt
 		FROM alumnoCurso
 		WHERE id=$1`,
 		ac.ID,
-	).Scan(&ac.Calificacion, &ac.FechaInicio, &ac.FechaFinal, &ac.AlumnoId, &ac.CreatedAt, &ac.UpdatedAt)
+	).Scan(&ac.Calificacion, &ac.FechaInicio, &ac.FechaFinal, &ac.AlumnoId,
+		&ac.CursoId, &ac.Activo, &ac.CreatedAt, &ac.UpdatedAt)
 }
 
 func (ac *AlumnoCurso) GetAlumnoCursos(db *pgxpool.Pool) ([]AlumnoCurso, error) {
 	rows, err := db.Query(
 		context.Background(),
 		`SELECT id, calificacion, fechaInicio, fechaFinal,
-		alumnoId, createdAt, updatedAt
+		alumnoId, cursoId, activo, createdAt, updatedAt
 		FROM alumnoCurso`)
 	if err != nil {
 		return nil, err
@@ -149,7 +185,7 @@ func (ac *AlumnoCurso) GetAlumnoCursos(db *pgxpool.Pool) ([]AlumnoCurso, error)
 		var ac AlumnoCurso
 		err := rows.Scan(
 			&ac.ID, &ac.Calificacion, &ac.FechaInicio, &ac.FechaFinal,
-			&ac.AlumnoId, &ac.CreatedAt, &ac.UpdatedAt)
+			&ac.AlumnoId, &ac.CursoId, &ac.Activo, &ac.CreatedAt, &ac.UpdatedAt)
 		if err != nil {
 			log.Printf("Las filas obtenidas de la BD para Alumno Curso, no satisfacen a 'Scan' %s",
 				err)
@@ -161,14 +197,46 @@ func (ac *AlumnoCurso) GetAlumnoCursos(db *pgxpool.Pool) ([]AlumnoCurso, error)
 	return alumnoCursos, nil
 }
 
+// GetAlumnosCurso lista los alumnos matriculados y activos en el curso
+func GetAlumnosCurso(db *pgxpool.Pool, cursoId int) ([]Alumno, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT al.id, al.apellidos, al.nombres, al.codigo, al.usuarioId,
+		al.activo, al.createdAt, al.updatedAt
+		FROM alumnoCurso ac
+		JOIN alumnos al ON al.id = ac.alumnoId
+		WHERE ac.cursoId=$1 AND ac.activo
+		ORDER BY al.apellidos, al.nombres`,
+		cursoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alumnos := []Alumno{}
+	for rows.Next() {
+		var al Alumno
+		err := rows.Scan(&al.ID, &al.Apellidos, &al.Nombres, &al.Codigo,
+			&al.UsuarioId, &al.Activo, &al.CreatedAt, &al.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Alumno, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		alumnos = append(alumnos, al)
+	}
+	return alumnos, nil
+}
+
 func (ac *AlumnoCurso) UpdateAlumnoCurso(db *pgxpool.Pool) error {
 	updTime := time.Now()
 	_, err := db.Exec(
 		context.Background(),
 		`UPDATE alumnoCurso SET calificacion=$1, fechaInicio=$2,
-		fechaFinal=$3, alumnoId=$4, updatedAt=$5
-		WHERE id=$1`,
-		ac.Calificacion, ac.FechaInicio, ac.FechaFinal, ac.AlumnoId, updTime,
+		fechaFinal=$3, alumnoId=$4, cursoId=$5, activo=$6, updatedAt=$7
+		WHERE id=$8`,
+		ac.Calificacion, ac.FechaInicio, ac.FechaFinal, ac.AlumnoId,
+		ac.CursoId, ac.Activo, updTime, ac.ID,
 	)
 	return err
 }
@@ -186,10 +254,12 @@ func (ac *AlumnoCurso) DeleteAlumnoCurso(db *pgxpool.Pool) error {
 type AlumnoExamen struct {
 	ID           int       `json:"id"`
 	Calificacion float32   `json:"calificacion"`
+	Intento      int       `json:"intento"`
 	FechaInicio  time.Time `json:"fechaInicio"`
 	FechaFinal   time.Time `json:"fechaFinal"`
 	AlumnoId     int       `json:"alumnoId"`
-	Alumno       Alumno    `json:"alumno"`
+	Alumno       *Alumno   `json:"alumno,omitempty"`
+	ExamenId     int       `json:"examenId"`
 
 	Activo    bool      `json:"activo"`
 	CreatedAt time.Time `json:"createdAt"`
@@ -200,31 +270,32 @@ func (ae *AlumnoExamen) CreateAlumnoExamen(db *pgxpool.Pool) error {
 	now := time.Now()
 	return db.QueryRow(
 		context.Background(),
-		`INSERT INTO alumnoExamen(calificacion, fechaInicio, 
-		fechaFinal, alumnoId, activo, createdAt, updatedAt)
-		VALUES($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id`,
-		ae.Calificacion, ae.FechaInicio, ae.FechaFinal,
-		ae.AlumnoId, now, now,
-	).Scan(&ae.Calificacion, &ae.FechaInicio, &ae.FechaFinal,
-		&ae.AlumnoId, &ae.CreatedAt, &ae.UpdatedAt)
+		`INSERT INTO alumnoExamen(calificacion, intento, fechaInicio,
+		fechaFinal, alumnoId, examenId, activo, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, createdAt, updatedAt`,
+		ae.Calificacion, ae.Intento, ae.FechaInicio, ae.FechaFinal,
+		ae.AlumnoId, ae.ExamenId, ae.Activo, now, now,
+	).Scan(&ae.ID, &ae.CreatedAt, &ae.UpdatedAt)
 }
 
 func (ae *AlumnoExamen) GetAlumnoExamen(db *pgxpool.Pool) error {
 	return db.QueryRow(
 		context.Background(),
-		`SELECT calificacion, fechaInicio, fechaFinal, alumnoId, createdAt, updatedAt
+		`SELECT calificacion, intento, fechaInicio, fechaFinal, alumnoId,
+		examenId, activo, createdAt, updatedAt
 		FROM alumnoExamen
 		WHERE id=$1`,
 		ae.ID,
-	).Scan(&ae.Calificacion, &ae.FechaInicio, &ae.FechaFinal, &ae.AlumnoId, &ae.CreatedAt, &ae.UpdatedAt)
+	).Scan(&ae.Calificacion, &ae.Intento, &ae.FechaInicio, &ae.FechaFinal,
+		&ae.AlumnoId, &ae.ExamenId, &ae.Activo, &ae.CreatedAt, &ae.UpdatedAt)
 }
 
 func (ae *AlumnoExamen) GetAlumnoExamenes(db *pgxpool.Pool) ([]AlumnoExamen, error) {
 	rows, err := db.Query(
 		context.Background(),
-		`SELECT id, calificacion, fechaInicio, fechaFinal,
-		alumnoId, createdAt, updatedAt
+		`SELECT id, calificacion, intento, fechaInicio, fechaFinal,
+		alumnoId, examenId, activo, createdAt, updatedAt
 		FROM alumnoExamen`)
 	if err != nil {
 		return nil, err
@@ -236,8 +307,8 @@ func (ae *AlumnoExamen) GetAlumnoExamenes(db *pgxpool.Pool) ([]AlumnoExamen, err
 	for rows.Next() {
 		var ae AlumnoExamen
 		err := rows.Scan(
-			&ae.ID, &ae.Calificacion, &ae.FechaInicio, &ae.FechaFinal,
-			&ae.AlumnoId, &ae.CreatedAt, &ae.UpdatedAt)
+			&ae.ID, &ae.Calificacion, &ae.Intento, &ae.FechaInicio, &ae.FechaFinal,
+			&ae.AlumnoId, &ae.ExamenId, &ae.Activo, &ae.CreatedAt, &ae.UpdatedAt)
 		if err != nil {
 			log.Printf("Las filas obtenidas de la BD para Alumno Curso, no satisfacen a 'Scan', %s",
 				err)
@@ -249,14 +320,69 @@ func (ae *AlumnoExamen) GetAlumnoExamenes(db *pgxpool.Pool) ([]AlumnoExamen, err
 	return alumnoExamens, nil
 }
 
+// ErrIntentosAgotados se devuelve al iniciar un intento cuando el alumno ya
+// uso todos los del examen
+var ErrIntentosAgotados = errors.New("no quedan intentos disponibles")
+
+// IniciarIntento numera y crea el intento en una sola transaccion. El
+// advisory lock por alumno y examen serializa los inicios simultaneos, asi
+// dos pedidos no cuentan los mismos intentos. maxIntentos 0 es ilimitado.
+func (ae *AlumnoExamen) IniciarIntento(db *pgxpool.Pool, maxIntentos int) error {
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	_, err = tx.Exec(
+		context.Background(),
+		`SELECT pg_advisory_xact_lock($1, $2)`,
+		ae.AlumnoId, ae.ExamenId)
+	if err != nil {
+		return err
+	}
+
+	var realizados int
+	err = tx.QueryRow(
+		context.Background(),
+		`SELECT COUNT(*) FROM alumnoExamen
+		WHERE alumnoId=$1 AND examenId=$2`,
+		ae.AlumnoId, ae.ExamenId,
+	).Scan(&realizados)
+	if err != nil {
+		return err
+	}
+	if maxIntentos > 0 && realizados >= maxIntentos {
+		return ErrIntentosAgotados
+	}
+
+	now := time.Now()
+	ae.Intento = realizados + 1
+	err = tx.QueryRow(
+		context.Background(),
+		`INSERT INTO alumnoExamen(calificacion, intento, fechaInicio,
+		fechaFinal, alumnoId, examenId, activo, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, createdAt, updatedAt`,
+		ae.Calificacion, ae.Intento, ae.FechaInicio, ae.FechaFinal,
+		ae.AlumnoId, ae.ExamenId, ae.Activo, now, now,
+	).Scan(&ae.ID, &ae.CreatedAt, &ae.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(context.Background())
+}
+
 func (ae *AlumnoExamen) UpdateAlumnoExamen(db *pgxpool.Pool) error {
 	updTime := time.Now()
 	_, err := db.Exec(
 		context.Background(),
-		`UPDATE alumnoExamen SET calificacion=$1, fechaInicio=$2,
-		fechaFinal=$3, alumnoId=$4, updatedAt=$5
-		WHERE id=$1`,
-		ae.Calificacion, ae.FechaInicio, ae.FechaFinal, ae.AlumnoId, updTime,
+		`UPDATE alumnoExamen SET calificacion=$1, intento=$2, fechaInicio=$3,
+		fechaFinal=$4, alumnoId=$5, examenId=$6, activo=$7, updatedAt=$8
+		WHERE id=$9`,
+		ae.Calificacion, ae.Intento, ae.FechaInicio, ae.FechaFinal,
+		ae.AlumnoId, ae.ExamenId, ae.Activo, updTime, ae.ID,
 	)
 	return err
 }
@@ -275,46 +401,77 @@ type AlumnoTrabajo struct {
 	ID           int       `json:"id"`
 	Calificacion float32   `json:"calificacion"`
 	Uri          string    `json:"uri"`
-	FechaInicio  time.Time `json:"fechaInicio"`
-	FechaFinal   time.Time `json:"fechaFinal"`
+	FechaInicio  time.Time `json:"fechaInicio"` // primera entrega
+	FechaFinal   time.Time `json:"fechaFinal"`  // ultima entrega
 	AlumnoId     int       `json:"alumnoId"`
-	Alumno       Alumno    `json:"alumno"`
+	Alumno       *Alumno   `json:"alumno,omitempty"`
+	TrabajoId    int       `json:"trabajoId"`
+
+	// nota antes de aplicar la politica de tardanza del trabajo
+	CalificacionBruta float32 `json:"calificacionBruta"`
+	Tardio            bool    `json:"tardio"`
+	Penalizacion      float32 `json:"penalizacion"` // porcentaje descontado
+	// mientras el profesor no califique, la calificacion no es una nota
+	Calificado bool `json:"calificado"`
+
+	// el profesor devuelve la entrega cuando la nota y los comentarios
+	// estan listos para el alumno
+	FechaDevolucion *time.Time `json:"fechaDevolucion"`
+	DevolucionVista bool       `json:"devolucionVista"`
 
 	Activo    bool      `json:"activo"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// OcultarSinDevolver quita la nota de la entrega mientras el profesor no
+// la devuelva, que es lo que ve el alumno
+func (at *AlumnoTrabajo) OcultarSinDevolver() {
+	if at.FechaDevolucion != nil {
+		return
+	}
+	at.Calificacion = 0
+	at.CalificacionBruta = 0
+	at.Penalizacion = 0
+	at.Calificado = false
+}
+
 func (at *AlumnoTrabajo) CreateAlumnoTrabajo(db *pgxpool.Pool) error {
 	now := time.Now()
 	return db.QueryRow(
 		context.Background(),
-		`INSERT INTO alumnoTrabajo(calificacion, uri, fechaInicio, 
-		fechaFinal, alumnoId, activo, createdAt, updatedAt)
-		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id`,
+		`INSERT INTO alumnoTrabajo(calificacion, uri, fechaInicio,
+		fechaFinal, alumnoId, trabajoId, calificacionBruta, tardio,
+		penalizacion, calificado, activo, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, createdAt, updatedAt`,
 		at.Calificacion, at.Uri, at.FechaInicio,
-		at.FechaFinal, at.AlumnoId, now, now,
-	).Scan(&at.Calificacion, &at.FechaInicio, &at.FechaFinal,
-		&at.AlumnoId, &at.CreatedAt, &at.UpdatedAt)
+		at.FechaFinal, at.AlumnoId, at.TrabajoId, at.CalificacionBruta,
+		at.Tardio, at.Penalizacion, at.Calificado, at.Activo, now, now,
+	).Scan(&at.ID, &at.CreatedAt, &at.UpdatedAt)
 }
 
 func (at *AlumnoTrabajo) GetAlumnoTrabajo(db *pgxpool.Pool) error {
 	return db.QueryRow(
 		context.Background(),
-		`SELECT calificacion, uri, fechaInicio, fechaFinal, alumnoId, createdAt, updatedAt
+		`SELECT calificacion, uri, fechaInicio, fechaFinal, alumnoId,
+		trabajoId, calificacionBruta, tardio, penalizacion, calificado,
+		fechaDevolucion, devolucionVista, activo, createdAt, updatedAt
 		FROM alumnoTrabajo
 		WHERE id=$1`,
 		at.ID,
-	).Scan(&at.Calificacion, &at.Uri, &at.FechaInicio,
-		&at.FechaFinal, &at.AlumnoId, &at.CreatedAt, &at.UpdatedAt)
+	).Scan(&at.Calificacion, &at.Uri, &at.FechaInicio, &at.FechaFinal,
+		&at.AlumnoId, &at.TrabajoId, &at.CalificacionBruta, &at.Tardio,
+		&at.Penalizacion, &at.Calificado, &at.FechaDevolucion, &at.DevolucionVista,
+		&at.Activo, &at.CreatedAt, &at.UpdatedAt)
 }
 
 func (at *AlumnoTrabajo) GetAlumnoTrabajoes(db *pgxpool.Pool) ([]AlumnoTrabajo, error) {
 	rows, err := db.Query(
 		context.Background(),
-		`SELECT id, calificacion, uri, fechaInicio,
-		fechaFinal, alumnoId, createdAt, updatedAt
+		`SELECT id, calificacion, uri, fechaInicio, fechaFinal,
+		alumnoId, trabajoId, calificacionBruta, tardio, penalizacion, calificado,
+		fechaDevolucion, devolucionVista, activo, createdAt, updatedAt
 		FROM alumnoTrabajo`)
 	if err != nil {
 		return nil, err
@@ -326,8 +483,10 @@ func (at *AlumnoTrabajo) GetAlumnoTrabajoes(db *pgxpool.Pool) ([]AlumnoTrabajo,
 	for rows.Next() {
 		var at AlumnoTrabajo
 		err := rows.Scan(
-			&at.ID, &at.Calificacion, &at.Uri, &at.FechaInicio,
-			&at.FechaFinal, &at.AlumnoId, &at.CreatedAt, &at.UpdatedAt)
+			&at.ID, &at.Calificacion, &at.Uri, &at.FechaInicio, &at.FechaFinal,
+			&at.AlumnoId, &at.TrabajoId, &at.CalificacionBruta, &at.Tardio,
+			&at.Penalizacion, &at.Calificado, &at.FechaDevolucion, &at.DevolucionVista,
+			&at.Activo, &at.CreatedAt, &at.UpdatedAt)
 		if err != nil {
 			log.Printf("Las filas obtenidas de la BD para Alumno Curso, no satisfacen a 'Scan' %s",
 				err)
@@ -339,18 +498,148 @@ func (at *AlumnoTrabajo) GetAlumnoTrabajoes(db *pgxpool.Pool) ([]AlumnoTrabajo,
 	return alumnoTrabajos, nil
 }
 
+// GetCalificacionesTrabajo es el reporte de notas de un trabajo, con los
+// datos del alumno de cada entrega
+func GetCalificacionesTrabajo(db *pgxpool.Pool, trabajoId int) ([]AlumnoTrabajo, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT at.id, at.calificacion, at.uri, at.fechaInicio, at.fechaFinal,
+		at.alumnoId, at.trabajoId, at.calificacionBruta, at.tardio,
+		at.penalizacion, at.calificado, at.fechaDevolucion, at.devolucionVista,
+		at.activo, at.createdAt, at.updatedAt,
+		al.nombres, al.apellidos, al.codigo
+		FROM alumnoTrabajo at
+		JOIN alumnos al ON al.id = at.alumnoId
+		WHERE at.trabajoId=$1
+		ORDER BY al.apellidos, al.nombres`,
+		trabajoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alumnoTrabajos := []AlumnoTrabajo{}
+
+	for rows.Next() {
+		at := AlumnoTrabajo{Alumno: &Alumno{}}
+		err := rows.Scan(
+			&at.ID, &at.Calificacion, &at.Uri, &at.FechaInicio, &at.FechaFinal,
+			&at.AlumnoId, &at.TrabajoId, &at.CalificacionBruta, &at.Tardio,
+			&at.Penalizacion, &at.Calificado, &at.FechaDevolucion, &at.DevolucionVista,
+			&at.Activo, &at.CreatedAt, &at.UpdatedAt,
+			&at.Alumno.Nombres, &at.Alumno.Apellidos, &at.Alumno.Codigo)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Alumno Trabajo, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		at.Alumno.ID = at.AlumnoId
+		alumnoTrabajos = append(alumnoTrabajos, at)
+	}
+
+	return alumnoTrabajos, nil
+}
+
 func (at *AlumnoTrabajo) UpdateAlumnoTrabajo(db *pgxpool.Pool) error {
 	updTime := time.Now()
 	_, err := db.Exec(
 		context.Background(),
 		`UPDATE alumnoTrabajo SET calificacion=$1, uri=$2, fechaInicio=$3,
-		fechaFinal=$4, alumnoId=$5, updatedAt=$6
-		WHERE id=$1`,
-		at.Calificacion, at.Uri, at.FechaInicio, at.FechaFinal, at.AlumnoId, updTime,
+		fechaFinal=$4, alumnoId=$5, trabajoId=$6, calificacionBruta=$7,
+		tardio=$8, penalizacion=$9, calificado=$10, activo=$11, updatedAt=$12
+		WHERE id=$13`,
+		at.Calificacion, at.Uri, at.FechaInicio, at.FechaFinal,
+		at.AlumnoId, at.TrabajoId, at.CalificacionBruta, at.Tardio,
+		at.Penalizacion, at.Calificado, at.Activo, updTime, at.ID,
+	)
+	return err
+}
+
+// Calificar guarda la nota 'bruta' descontando la penalizacion de la
+// tardanza. La nota final se redondea a dos decimales.
+func (at *AlumnoTrabajo) Calificar(db *pgxpool.Pool, bruta float32, tardanza Tardanza) error {
+	at.CalificacionBruta = bruta
+	at.Tardio = tardanza.Tardio
+	at.Penalizacion = tardanza.Penalizacion
+	final := float64(bruta) * (1 - float64(tardanza.Penalizacion)/100)
+	at.Calificacion = float32(math.Round(final*100) / 100)
+	at.Calificado = true
+
+	return db.QueryRow(
+		context.Background(),
+		`UPDATE alumnoTrabajo SET calificacion=$1, calificacionBruta=$2,
+		tardio=$3, penalizacion=$4, calificado=true, updatedAt=$5
+		WHERE id=$6
+		RETURNING updatedAt`,
+		at.Calificacion, at.CalificacionBruta, at.Tardio,
+		at.Penalizacion, time.Now(), at.ID,
+	).Scan(&at.UpdatedAt)
+}
+
+// Devolver marca la entrega como devuelta al alumno con su nota y
+// comentarios. Devolverla otra vez la vuelve a mostrar como no vista.
+func (at *AlumnoTrabajo) Devolver(db *pgxpool.Pool) error {
+	now := time.Now()
+	at.DevolucionVista = false
+	return db.QueryRow(
+		context.Background(),
+		`UPDATE alumnoTrabajo SET fechaDevolucion=$1, devolucionVista=false,
+		updatedAt=$1
+		WHERE id=$2
+		RETURNING fechaDevolucion, updatedAt`,
+		now, at.ID,
+	).Scan(&at.FechaDevolucion, &at.UpdatedAt)
+}
+
+// MarcarDevolucionVista registra que el alumno ya vio la devolucion
+func (at *AlumnoTrabajo) MarcarDevolucionVista(db *pgxpool.Pool) error {
+	at.DevolucionVista = true
+	_, err := db.Exec(
+		context.Background(),
+		`UPDATE alumnoTrabajo SET devolucionVista=true
+		WHERE id=$1 AND fechaDevolucion IS NOT NULL`,
+		at.ID,
 	)
 	return err
 }
 
+// GetDevolucionesPendientes lista los trabajos devueltos al alumno que
+// todavia no ha visto
+func GetDevolucionesPendientes(db *pgxpool.Pool, alumnoId int) ([]AlumnoTrabajo, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, calificacion, uri, fechaInicio, fechaFinal,
+		alumnoId, trabajoId, calificacionBruta, tardio, penalizacion, calificado,
+		fechaDevolucion, devolucionVista, activo, createdAt, updatedAt
+		FROM alumnoTrabajo
+		WHERE alumnoId=$1 AND fechaDevolucion IS NOT NULL AND NOT devolucionVista
+		ORDER BY fechaDevolucion DESC`,
+		alumnoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alumnoTrabajos := []AlumnoTrabajo{}
+
+	for rows.Next() {
+		var at AlumnoTrabajo
+		err := rows.Scan(
+			&at.ID, &at.Calificacion, &at.Uri, &at.FechaInicio, &at.FechaFinal,
+			&at.AlumnoId, &at.TrabajoId, &at.CalificacionBruta, &at.Tardio,
+			&at.Penalizacion, &at.Calificado, &at.FechaDevolucion, &at.DevolucionVista,
+			&at.Activo, &at.CreatedAt, &at.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Alumno Trabajo, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		alumnoTrabajos = append(alumnoTrabajos, at)
+	}
+
+	return alumnoTrabajos, nil
+}
+
 func (at *AlumnoTrabajo) DeleteAlumnoTrabajo(db *pgxpool.Pool) error {
 	_, err := db.Exec(
 		context.Background(),
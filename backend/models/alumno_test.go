@@ -1,8 +1,11 @@
 package models
 
 import (
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/blackadress/vaula/consulta"
 	"github.com/blackadress/vaula/utils"
 	"github.com/jackc/pgx/v4"
 )
@@ -52,7 +55,7 @@ func TestNotGetAlumno(t *testing.T) {
 func TestGetAlumnos(t *testing.T) {
 	utils.ClearTableAlumno(db)
 	utils.AddAlumnos(2, db)
-	alumnos, err := GetAlumnos(db)
+	alumnos, _, err := GetAlumnos(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("Metodo alumno.GetAlumnos no funciona %s", err)
 	}
@@ -65,7 +68,7 @@ func TestGetAlumnos(t *testing.T) {
 func TestGetZeroAlumnos(t *testing.T) {
 	utils.ClearTableAlumno(db)
 
-	alumnos, err := GetAlumnos(db)
+	alumnos, _, err := GetAlumnos(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("Metodo alumnos.GetAlumnos no funciona %s", err)
 	}
@@ -156,3 +159,65 @@ func TestDeleteAlumno(t *testing.T) {
 		t.Errorf("Ocurrio un error en el metodo DeleteAlumno")
 	}
 }
+
+func TestCalificarAlumnoTrabajo(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddEntregas(1, db)
+
+	at := AlumnoTrabajo{ID: 1}
+	if err := at.GetAlumnoTrabajo(db); err != nil {
+		t.Errorf("El metodo GetAlumnoTrabajo fallo %s", err)
+	}
+
+	err := at.Calificar(db, 16, Tardanza{Tardio: true, DiasTarde: 1, Penalizacion: 25})
+	if err != nil {
+		t.Errorf("El metodo Calificar fallo %s", err)
+	}
+
+	guardado := AlumnoTrabajo{ID: 1}
+	guardado.GetAlumnoTrabajo(db)
+	if guardado.Calificacion != 12 || guardado.CalificacionBruta != 16 {
+		t.Errorf("Se esperaba la nota 12 sobre una bruta de 16. Se obtuvo %v sobre %v",
+			guardado.Calificacion, guardado.CalificacionBruta)
+	}
+	if !guardado.Tardio || guardado.Penalizacion != 25 {
+		t.Errorf("Se esperaba guardar la tardanza. Se obtuvo tardio=%v penalizacion=%v",
+			guardado.Tardio, guardado.Penalizacion)
+	}
+}
+
+func TestIniciarIntentoConcurrente(t *testing.T) {
+	utils.ClearTableUsuario(db)
+	// el alumno 1 ya tiene un intento en curso del examen 1
+	utils.AddIntentos(1, db)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	intentos := make([]int, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			now := time.Now()
+			ae := AlumnoExamen{AlumnoId: 1, ExamenId: 1, FechaInicio: now,
+				FechaFinal: now.Add(time.Hour), Activo: true}
+			errs[i] = ae.IniciarIntento(db, 3)
+			intentos[i] = ae.Intento
+		}(i)
+	}
+	wg.Wait()
+
+	creados := map[int]bool{}
+	for i, err := range errs {
+		switch err {
+		case nil:
+			creados[intentos[i]] = true
+		case ErrIntentosAgotados:
+		default:
+			t.Errorf("Se esperaba crear el intento o ErrIntentosAgotados. Se obtuvo %v", err)
+		}
+	}
+	if len(creados) != 2 || !creados[2] || !creados[3] {
+		t.Errorf("Se esperaba crear solo los intentos 2 y 3. Se obtuvo %v", creados)
+	}
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// tipos de contenido de los anuncios y foros, para las ediciones y las
+// lecturas
+const (
+	TipoAnuncio     = "anuncio"
+	TipoHilo        = "hilo"
+	TipoPublicacion = "publicacion"
+)
+
+// Anuncio es un aviso del profesor a todo el curso. Leido indica si el
+// usuario que consulta ya lo leyo despues de su ultima modificacion.
+type Anuncio struct {
+	ID      int    `json:"id"`
+	CursoId int    `json:"cursoId"`
+	AutorId int    `json:"autorId"`
+	Titulo  string `json:"titulo"`
+	Cuerpo  string `json:"cuerpo"`
+	Fijado  bool   `json:"fijado"`
+	Leido   bool   `json:"leido"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Edicion guarda la version anterior de un anuncio o publicacion
+type Edicion struct {
+	ID             int       `json:"id"`
+	Tipo           string    `json:"tipo"`
+	ReferenciaId   int       `json:"referenciaId"`
+	UsuarioId      int       `json:"usuarioId"`
+	TituloAnterior string    `json:"tituloAnterior"`
+	CuerpoAnterior string    `json:"cuerpoAnterior"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+func (an *Anuncio) CreateAnuncio(db *pgxpool.Pool) error {
+	now := time.Now()
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO anuncios(cursoId, autorId, titulo, cuerpo, fijado,
+		createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $5, $6, $6)
+		RETURNING id, createdAt, updatedAt`,
+		an.CursoId, an.AutorId, an.Titulo, an.Cuerpo, an.Fijado, now,
+	).Scan(&an.ID, &an.CreatedAt, &an.UpdatedAt)
+}
+
+func (an *Anuncio) GetAnuncio(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT cursoId, autorId, titulo, cuerpo, fijado, createdAt, updatedAt
+		FROM anuncios
+		WHERE id=$1`,
+		an.ID).Scan(&an.CursoId, &an.AutorId, &an.Titulo, &an.Cuerpo,
+		&an.Fijado, &an.CreatedAt, &an.UpdatedAt)
+}
+
+// GetAnunciosCurso lista los anuncios del curso, primero los fijados y
+// luego los mas recientes, marcando los que el usuario ya leyo
+func GetAnunciosCurso(db *pgxpool.Pool, cursoId, usuarioId int) ([]Anuncio, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT an.id, an.cursoId, an.autorId, an.titulo, an.cuerpo, an.fijado,
+		COALESCE(l.leidoEn >= an.updatedAt, false), an.createdAt, an.updatedAt
+		FROM anuncios an
+		LEFT JOIN lecturas l ON l.tipo='anuncio' AND l.referenciaId = an.id
+			AND l.usuarioId=$2
+		WHERE an.cursoId=$1
+		ORDER BY an.fijado DESC, an.createdAt DESC, an.id DESC`,
+		cursoId, usuarioId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	anuncios := []Anuncio{}
+	for rows.Next() {
+		var an Anuncio
+		err := rows.Scan(&an.ID, &an.CursoId, &an.AutorId, &an.Titulo, &an.Cuerpo,
+			&an.Fijado, &an.Leido, &an.CreatedAt, &an.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Anuncio, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		anuncios = append(anuncios, an)
+	}
+	return anuncios, nil
+}
+
+// UpdateAnuncio guarda la version anterior en el historial si cambia el
+// titulo o el cuerpo. Fijar o desfijar no cuenta como edicion.
+func (an *Anuncio) UpdateAnuncio(db *pgxpool.Pool, usuarioId int) error {
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	var titulo, cuerpo string
+	var updatedAt time.Time
+	err = tx.QueryRow(
+		context.Background(),
+		`SELECT titulo, cuerpo, updatedAt FROM anuncios WHERE id=$1 FOR UPDATE`,
+		an.ID).Scan(&titulo, &cuerpo, &updatedAt)
+	if err != nil {
+		return err
+	}
+
+	if titulo != an.Titulo || cuerpo != an.Cuerpo {
+		updatedAt = time.Now()
+		_, err = tx.Exec(
+			context.Background(),
+			`INSERT INTO ediciones(tipo, referenciaId, usuarioId, tituloAnterior,
+			cuerpoAnterior, createdAt)
+			VALUES($1, $2, $3, $4, $5, $6)`,
+			TipoAnuncio, an.ID, usuarioId, titulo, cuerpo, updatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.QueryRow(
+		context.Background(),
+		`UPDATE anuncios SET titulo=$1, cuerpo=$2, fijado=$3, updatedAt=$4
+		WHERE id=$5
+		RETURNING cursoId, autorId, createdAt, updatedAt`,
+		an.Titulo, an.Cuerpo, an.Fijado, updatedAt, an.ID,
+	).Scan(&an.CursoId, &an.AutorId, &an.CreatedAt, &an.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+// DeleteAnuncio elimina el anuncio con su historial y lecturas
+func (an *Anuncio) DeleteAnuncio(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`WITH e AS (
+			DELETE FROM ediciones WHERE tipo='anuncio' AND referenciaId=$1),
+		l AS (
+			DELETE FROM lecturas WHERE tipo='anuncio' AND referenciaId=$1)
+		DELETE FROM anuncios WHERE id=$1`,
+		an.ID)
+	return err
+}
+
+// GetEdiciones devuelve las versiones anteriores, de la mas antigua a la
+// mas reciente
+func GetEdiciones(db *pgxpool.Pool, tipo string, referenciaId int) ([]Edicion, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, tipo, referenciaId, usuarioId, tituloAnterior,
+		cuerpoAnterior, createdAt
+		FROM ediciones
+		WHERE tipo=$1 AND referenciaId=$2
+		ORDER BY createdAt, id`,
+		tipo, referenciaId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ediciones := []Edicion{}
+	for rows.Next() {
+		var e Edicion
+		err := rows.Scan(&e.ID, &e.Tipo, &e.ReferenciaId, &e.UsuarioId,
+			&e.TituloAnterior, &e.CuerpoAnterior, &e.CreatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Edicion, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		ediciones = append(ediciones, e)
+	}
+	return ediciones, nil
+}
+
+// MarcarLeido registra que el usuario leyo el anuncio o el hilo ahora
+func MarcarLeido(db *pgxpool.Pool, usuarioId int, tipo string, referenciaId int) error {
+	_, err := db.Exec(
+		context.Background(),
+		`INSERT INTO lecturas(usuarioId, tipo, referenciaId, leidoEn)
+		VALUES($1, $2, $3, $4)
+		ON CONFLICT (usuarioId, tipo, referenciaId)
+		DO UPDATE SET leidoEn=EXCLUDED.leidoEn`,
+		usuarioId, tipo, referenciaId, time.Now())
+	return err
+}
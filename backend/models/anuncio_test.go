@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestUpdateAnuncioHistorial(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.ClearTableUsuario(db)
+	utils.AddUsers(1, db)
+	utils.AddCursos(1, db)
+
+	an := Anuncio{CursoId: 1, AutorId: 1, Titulo: "Bienvenidos", Cuerpo: "Primera clase el lunes"}
+	if err := an.CreateAnuncio(db); err != nil {
+		t.Fatalf("No se creo el anuncio %s", err)
+	}
+
+	// fijar no es una edicion
+	an.Fijado = true
+	if err := an.UpdateAnuncio(db, 1); err != nil {
+		t.Fatalf("El metodo UpdateAnuncio fallo %s", err)
+	}
+	an.Cuerpo = "Primera clase el martes"
+	if err := an.UpdateAnuncio(db, 1); err != nil {
+		t.Fatalf("El metodo UpdateAnuncio fallo %s", err)
+	}
+
+	ediciones, err := GetEdiciones(db, TipoAnuncio, an.ID)
+	if err != nil {
+		t.Fatalf("El metodo GetEdiciones fallo %s", err)
+	}
+	if len(ediciones) != 1 || ediciones[0].CuerpoAnterior != "Primera clase el lunes" {
+		t.Errorf("Se esperaba una edicion con el cuerpo anterior. Se obtuvo %v", ediciones)
+	}
+}
+
+func TestAnunciosLeidos(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.ClearTableUsuario(db)
+	utils.AddUsers(2, db)
+	utils.AddCursos(1, db)
+
+	viejo := Anuncio{CursoId: 1, AutorId: 1, Titulo: "Silabo", Cuerpo: "Revisen el silabo"}
+	viejo.CreateAnuncio(db)
+	fijado := Anuncio{CursoId: 1, AutorId: 1, Titulo: "Examen", Cuerpo: "El parcial es el 10", Fijado: true}
+	fijado.CreateAnuncio(db)
+	nuevo := Anuncio{CursoId: 1, AutorId: 1, Titulo: "Feriado", Cuerpo: "No hay clase el jueves"}
+	nuevo.CreateAnuncio(db)
+
+	if err := MarcarLeido(db, 2, TipoAnuncio, viejo.ID); err != nil {
+		t.Fatalf("El metodo MarcarLeido fallo %s", err)
+	}
+
+	anuncios, err := GetAnunciosCurso(db, 1, 2)
+	if err != nil {
+		t.Fatalf("El metodo GetAnunciosCurso fallo %s", err)
+	}
+	if len(anuncios) != 3 || anuncios[0].ID != fijado.ID || anuncios[1].ID != nuevo.ID {
+		t.Fatalf("Se esperaba primero el fijado y luego el mas reciente. Se obtuvo %v", anuncios)
+	}
+	if anuncios[0].Leido || !anuncios[2].Leido {
+		t.Errorf("Se esperaba leido solo el anuncio %d. Se obtuvo %v", viejo.ID, anuncios)
+	}
+
+	// editar el anuncio lo vuelve a marcar como no leido
+	viejo.Cuerpo = "Revisen el silabo actualizado"
+	viejo.UpdateAnuncio(db, 1)
+	anuncios, _ = GetAnunciosCurso(db, 1, 2)
+	if anuncios[2].Leido {
+		t.Errorf("Se esperaba el anuncio editado como no leido. Se obtuvo %v", anuncios[2])
+	}
+}
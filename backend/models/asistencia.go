@@ -0,0 +1,252 @@
+package models
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// estados de asistencia de un alumno a una sesion
+const (
+	AsistenciaPresente    = "presente"
+	AsistenciaTarde       = "tarde"
+	AsistenciaAusente     = "ausente"
+	AsistenciaJustificada = "justificado"
+)
+
+var EstadosAsistencia = map[string]bool{
+	AsistenciaPresente:    true,
+	AsistenciaTarde:       true,
+	AsistenciaAusente:     true,
+	AsistenciaJustificada: true,
+}
+
+// Sesion es una clase del curso en la que se toma asistencia
+type Sesion struct {
+	ID      int       `json:"id"`
+	CursoId int       `json:"cursoId"`
+	Fecha   time.Time `json:"fecha"`
+	Tema    string    `json:"tema"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type Asistencia struct {
+	ID          int       `json:"id"`
+	SesionId    int       `json:"sesionId"`
+	AlumnoId    int       `json:"alumnoId"`
+	Estado      string    `json:"estado"`
+	Observacion string    `json:"observacion"`
+	UsuarioId   int       `json:"usuarioId"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func (s *Sesion) CreateSesion(db *pgxpool.Pool) error {
+	now := time.Now()
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO sesiones(cursoId, fecha, tema, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $4)
+		RETURNING id, createdAt, updatedAt`,
+		s.CursoId, s.Fecha, s.Tema, now,
+	).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+}
+
+func (s *Sesion) GetSesion(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT cursoId, fecha, tema, createdAt, updatedAt
+		FROM sesiones
+		WHERE id=$1`,
+		s.ID).Scan(&s.CursoId, &s.Fecha, &s.Tema, &s.CreatedAt, &s.UpdatedAt)
+}
+
+func GetSesionesCurso(db *pgxpool.Pool, cursoId int) ([]Sesion, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, cursoId, fecha, tema, createdAt, updatedAt
+		FROM sesiones
+		WHERE cursoId=$1
+		ORDER BY fecha, id`,
+		cursoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sesiones := []Sesion{}
+	for rows.Next() {
+		var s Sesion
+		err := rows.Scan(&s.ID, &s.CursoId, &s.Fecha, &s.Tema, &s.CreatedAt, &s.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Sesion, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		sesiones = append(sesiones, s)
+	}
+	return sesiones, nil
+}
+
+// UpdateSesion no permite mover la sesion a otro curso
+func (s *Sesion) UpdateSesion(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`UPDATE sesiones SET fecha=$1, tema=$2, updatedAt=$3
+		WHERE id=$4
+		RETURNING cursoId, createdAt, updatedAt`,
+		s.Fecha, s.Tema, time.Now(), s.ID,
+	).Scan(&s.CursoId, &s.CreatedAt, &s.UpdatedAt)
+}
+
+func (s *Sesion) DeleteSesion(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`DELETE FROM sesiones WHERE id=$1`,
+		s.ID)
+	return err
+}
+
+// GuardarAsistencias crea o reemplaza la asistencia de varios alumnos a
+// la sesion en una sola transaccion
+func GuardarAsistencias(db *pgxpool.Pool, asistencias []Asistencia) error {
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	now := time.Now()
+	for i := range asistencias {
+		as := &asistencias[i]
+		err := tx.QueryRow(
+			context.Background(),
+			`INSERT INTO asistencias(sesionId, alumnoId, estado, observacion,
+			usuarioId, updatedAt)
+			VALUES($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (sesionId, alumnoId)
+			DO UPDATE SET estado=EXCLUDED.estado, observacion=EXCLUDED.observacion,
+			usuarioId=EXCLUDED.usuarioId, updatedAt=EXCLUDED.updatedAt
+			RETURNING id, updatedAt`,
+			as.SesionId, as.AlumnoId, as.Estado, as.Observacion, as.UsuarioId, now,
+		).Scan(&as.ID, &as.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(context.Background())
+}
+
+func GetAsistenciasSesion(db *pgxpool.Pool, sesionId int) ([]Asistencia, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, sesionId, alumnoId, estado, observacion, usuarioId, updatedAt
+		FROM asistencias
+		WHERE sesionId=$1
+		ORDER BY alumnoId`,
+		sesionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	asistencias := []Asistencia{}
+	for rows.Next() {
+		var as Asistencia
+		err := rows.Scan(&as.ID, &as.SesionId, &as.AlumnoId, &as.Estado,
+			&as.Observacion, &as.UsuarioId, &as.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Asistencia, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		asistencias = append(asistencias, as)
+	}
+	return asistencias, nil
+}
+
+// ResumenAsistencia cuenta la asistencia de un alumno matriculado a las
+// sesiones del curso en las que se registro su asistencia
+type ResumenAsistencia struct {
+	Alumno       *Alumno `json:"alumno,omitempty"`
+	Presentes    int     `json:"presentes"`
+	Tardes       int     `json:"tardes"`
+	Ausentes     int     `json:"ausentes"`
+	Justificadas int     `json:"justificadas"`
+	// nil si no hay sesiones que cuenten
+	Porcentaje *float32 `json:"porcentaje"`
+}
+
+// CalcularPorcentaje cuenta las tardanzas como asistencia y deja fuera
+// las faltas justificadas
+func (ra *ResumenAsistencia) CalcularPorcentaje() {
+	ra.Porcentaje = nil
+	total := ra.Presentes + ra.Tardes + ra.Ausentes
+	if total == 0 {
+		return
+	}
+	p := redondear(float64(ra.Presentes+ra.Tardes) * 100 / float64(total))
+	ra.Porcentaje = &p
+}
+
+// NotaAsistencia lleva el porcentaje de asistencia a la escala vigesimal
+func NotaAsistencia(porcentaje float32) float32 {
+	return redondear(float64(porcentaje) * 20 / 100)
+}
+
+// GetResumenAsistenciaCurso resume la asistencia de cada alumno matriculado
+func GetResumenAsistenciaCurso(db *pgxpool.Pool, cursoId int) ([]ResumenAsistencia, error) {
+	alumnos, err := GetAlumnosCurso(db, cursoId)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT a.alumnoId, a.estado, COUNT(*)
+		FROM asistencias a
+		JOIN sesiones s ON s.id = a.sesionId
+		WHERE s.cursoId=$1
+		GROUP BY a.alumnoId, a.estado`,
+		cursoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conteos := map[int]map[string]int{}
+	for rows.Next() {
+		var alumnoId, cantidad int
+		var estado string
+		if err := rows.Scan(&alumnoId, &estado, &cantidad); err != nil {
+			log.Printf("Las filas obtenidas de la BD para ResumenAsistencia, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		if conteos[alumnoId] == nil {
+			conteos[alumnoId] = map[string]int{}
+		}
+		conteos[alumnoId][estado] = cantidad
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	resumenes := []ResumenAsistencia{}
+	for i := range alumnos {
+		c := conteos[alumnos[i].ID]
+		ra := ResumenAsistencia{
+			Alumno:       &alumnos[i],
+			Presentes:    c[AsistenciaPresente],
+			Tardes:       c[AsistenciaTarde],
+			Ausentes:     c[AsistenciaAusente],
+			Justificadas: c[AsistenciaJustificada],
+		}
+		ra.CalcularPorcentaje()
+		resumenes = append(resumenes, ra)
+	}
+	return resumenes, nil
+}
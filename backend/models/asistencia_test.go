@@ -0,0 +1,95 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestCalcularPorcentajeAsistencia(t *testing.T) {
+	// las tardanzas cuentan como asistencia y las justificadas no cuentan
+	ra := ResumenAsistencia{Presentes: 6, Tardes: 2, Ausentes: 2, Justificadas: 3}
+	ra.CalcularPorcentaje()
+	if ra.Porcentaje == nil || *ra.Porcentaje != 80 {
+		t.Errorf("Se esperaba 80%% de asistencia. Se obtuvo %v", ra.Porcentaje)
+	}
+	if n := NotaAsistencia(*ra.Porcentaje); n != 16 {
+		t.Errorf("Se esperaba la nota 16. Se obtuvo %v", n)
+	}
+
+	solo := ResumenAsistencia{Justificadas: 2}
+	solo.CalcularPorcentaje()
+	if solo.Porcentaje != nil {
+		t.Errorf("Sin sesiones que cuenten no hay porcentaje. Se obtuvo %v", *solo.Porcentaje)
+	}
+}
+
+func TestGuardarAsistencias(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(2, db)
+	utils.AddSesiones(2, db)
+
+	asistencias := []Asistencia{
+		{SesionId: 1, AlumnoId: 1, Estado: AsistenciaPresente, UsuarioId: 1},
+		{SesionId: 1, AlumnoId: 2, Estado: AsistenciaAusente, UsuarioId: 1},
+		{SesionId: 2, AlumnoId: 1, Estado: AsistenciaTarde, UsuarioId: 1},
+		{SesionId: 2, AlumnoId: 2, Estado: AsistenciaAusente, UsuarioId: 1},
+	}
+	if err := GuardarAsistencias(db, asistencias); err != nil {
+		t.Fatalf("No se guardaron las asistencias %s", err)
+	}
+
+	// corregir una asistencia la reemplaza
+	correccion := []Asistencia{
+		{SesionId: 2, AlumnoId: 2, Estado: AsistenciaJustificada, Observacion: "certificado medico", UsuarioId: 1},
+	}
+	if err := GuardarAsistencias(db, correccion); err != nil {
+		t.Fatalf("No se corrigio la asistencia %s", err)
+	}
+	sesion2, _ := GetAsistenciasSesion(db, 2)
+	if len(sesion2) != 2 || sesion2[1].Estado != AsistenciaJustificada {
+		t.Errorf("Se esperaba la falta justificada en la sesion 2. Se obtuvo %v", sesion2)
+	}
+
+	resumenes, err := GetResumenAsistenciaCurso(db, 1)
+	if err != nil {
+		t.Fatalf("El metodo GetResumenAsistenciaCurso fallo %s", err)
+	}
+	porcentajes := map[int]float32{}
+	for _, ra := range resumenes {
+		if ra.Porcentaje != nil {
+			porcentajes[ra.Alumno.ID] = *ra.Porcentaje
+		}
+	}
+	if porcentajes[1] != 100 || porcentajes[2] != 0 {
+		t.Errorf("Se esperaba 100%% y 0%% de asistencia. Se obtuvo %v", porcentajes)
+	}
+}
+
+func TestLibretaConAsistencia(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(1, db)
+
+	c := CategoriaCalificacion{CursoId: 1, Nombre: "Asistencia",
+		Tipo: CategoriaAsistencia, Peso: 10}
+	if err := c.CreateCategoria(db); err != nil {
+		t.Fatalf("No se creo la categoria %s", err)
+	}
+	s1 := Sesion{CursoId: 1, Fecha: time.Now(), Tema: "introduccion"}
+	s1.CreateSesion(db)
+	s2 := Sesion{CursoId: 1, Fecha: time.Now(), Tema: "repaso"}
+	s2.CreateSesion(db)
+	GuardarAsistencias(db, []Asistencia{
+		{SesionId: s1.ID, AlumnoId: 1, Estado: AsistenciaPresente, UsuarioId: 1},
+		{SesionId: s2.ID, AlumnoId: 1, Estado: AsistenciaAusente, UsuarioId: 1},
+	})
+
+	libreta, err := GetLibreta(db, 1)
+	if err != nil {
+		t.Fatalf("El metodo GetLibreta fallo %s", err)
+	}
+	if len(libreta.Filas) != 1 || libreta.Filas[0].Final != 10 {
+		t.Errorf("Se esperaba la nota final 10 por 50%% de asistencia. Se obtuvo %+v", libreta.Filas)
+	}
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// BancoPreguntas agrupa preguntas reutilizables fuera de un examen. Sin
+// curso el banco es compartido por todos los profesores.
+type BancoPreguntas struct {
+	ID      int    `json:"id"`
+	Nombre  string `json:"nombre"`
+	CursoId int    `json:"cursoId"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (b *BancoPreguntas) CreateBanco(db *pgxpool.Pool) error {
+	now := time.Now()
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO bancosPreguntas(nombre, cursoId, createdAt, updatedAt)
+		VALUES($1, NULLIF($2, 0), $3, $3)
+		RETURNING id, createdAt, updatedAt`,
+		b.Nombre, b.CursoId, now,
+	).Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt)
+}
+
+func (b *BancoPreguntas) GetBanco(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT nombre, COALESCE(cursoId, 0), createdAt, updatedAt
+		FROM bancosPreguntas
+		WHERE id=$1`,
+		b.ID).Scan(&b.Nombre, &b.CursoId, &b.CreatedAt, &b.UpdatedAt)
+}
+
+func GetBancos(db *pgxpool.Pool) ([]BancoPreguntas, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, nombre, COALESCE(cursoId, 0), createdAt, updatedAt
+		FROM bancosPreguntas
+		ORDER BY nombre`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bancos := []BancoPreguntas{}
+	for rows.Next() {
+		var b BancoPreguntas
+		err := rows.Scan(&b.ID, &b.Nombre, &b.CursoId, &b.CreatedAt, &b.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para BancoPreguntas, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		bancos = append(bancos, b)
+	}
+	return bancos, nil
+}
+
+// DeleteBanco elimina el banco junto con sus preguntas
+func (b *BancoPreguntas) DeleteBanco(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`DELETE FROM bancosPreguntas WHERE id=$1`,
+		b.ID)
+	return err
+}
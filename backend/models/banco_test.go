@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestImportarPreguntas(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddBancosPreguntas(1, db)
+
+	preguntas := []Pregunta{
+		{Enunciado: "Capital del Peru", Tipo: TipoOpcionMultiple, BancoId: 1, Activo: true,
+			Alternativas: []Alternativa{
+				{Valor: "Lima", Correcto: true, Activo: true},
+				{Valor: "Cusco", Activo: true},
+			}},
+		{Enunciado: "Describe la conquista", Tipo: TipoEnsayo, BancoId: 1, Activo: true},
+	}
+	if err := ImportarPreguntas(db, preguntas); err != nil {
+		t.Fatalf("No se importaron las preguntas %s", err)
+	}
+	if preguntas[0].Alternativas[1].PreguntaId != preguntas[0].ID {
+		t.Errorf("Se esperaba que las alternativas apunten a su pregunta. Se obtuvo %v",
+			preguntas[0].Alternativas)
+	}
+
+	banco, err := GetPreguntasBanco(db, 1)
+	if err != nil {
+		t.Fatalf("Error inesperado en GetPreguntasBanco %s", err)
+	}
+	if len(banco) != 2 || len(banco[0].Alternativas) != 2 || len(banco[1].Alternativas) != 0 {
+		t.Errorf("Se esperaban 2 preguntas, con 2 y 0 alternativas. Se obtuvo %v", banco)
+	}
+	if banco[1].Puntaje != 1 {
+		t.Errorf("Se esperaba el puntaje por defecto 1. Se obtuvo %v", banco[1].Puntaje)
+	}
+}
+
+func TestDeleteBanco(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddBancosPreguntas(1, db)
+
+	p := Pregunta{Enunciado: "pregunta", BancoId: 1, Activo: true}
+	if err := p.CreatePregunta(db); err != nil {
+		t.Fatalf("No se creo la pregunta %s", err)
+	}
+
+	b := BancoPreguntas{ID: 1}
+	if err := b.DeleteBanco(db); err != nil {
+		t.Fatalf("No se elimino el banco %s", err)
+	}
+	if err := p.GetPregunta(db); err == nil {
+		t.Errorf("Se esperaba que las preguntas del banco se eliminen con el")
+	}
+}
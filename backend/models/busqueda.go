@@ -0,0 +1,157 @@
+package models
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	LimiteBusquedaDefecto = 10
+	LimiteBusquedaMax     = 50
+)
+
+// ResultadoBusqueda es una coincidencia de la busqueda. Titulo es el texto
+// principal del resultado y Detalle lo ubica: las siglas del curso, el
+// examen o banco de la pregunta, el codigo del alumno.
+type ResultadoBusqueda struct {
+	ID      int     `json:"id"`
+	Titulo  string  `json:"titulo"`
+	Detalle string  `json:"detalle"`
+	CursoId int     `json:"cursoId"`
+	Rango   float32 `json:"rango"`
+}
+
+// ResultadosBusqueda agrupa los resultados por tipo, cada grupo del mas
+// al menos relevante
+type ResultadosBusqueda struct {
+	Cursos     []ResultadoBusqueda `json:"cursos"`
+	Preguntas  []ResultadoBusqueda `json:"preguntas"`
+	Trabajos   []ResultadoBusqueda `json:"trabajos"`
+	Alumnos    []ResultadoBusqueda `json:"alumnos"`
+	Profesores []ResultadoBusqueda `json:"profesores"`
+}
+
+// en todas las consultas $1 es el texto, $2 el limite y $3 el usuario
+const (
+	consultaBusqueda = `WITH b AS (SELECT websearch_to_tsquery('es_unaccent', $1) AS q) `
+	cursosProfesor   = `(SELECT pc.cursoId FROM profesorCurso pc
+		JOIN profesores pr ON pr.id = pc.profesorId
+		WHERE pr.usuarioId = $3)`
+	cursosAlumno = `(SELECT ac.cursoId FROM alumnoCurso ac
+		JOIN alumnos al ON al.id = ac.alumnoId
+		WHERE al.usuarioId = $3 AND ac.activo)`
+)
+
+// los cursos son visibles para todos, como en GET /cursos
+const busquedaCursos = consultaBusqueda + `
+	SELECT c.id, c.nombre, c.siglas, c.id,
+	ts_rank(to_tsvector('es_unaccent', c.nombre || ' ' || c.siglas), b.q) AS rango
+	FROM b, cursos c
+	WHERE to_tsvector('es_unaccent', c.nombre || ' ' || c.siglas) @@ b.q
+	ORDER BY rango DESC, c.id
+	LIMIT $2`
+
+// las preguntas solo las ven los profesores: las de un examen o banco del
+// curso los profesores asignados, las de un banco compartido cualquiera
+const busquedaPreguntas = consultaBusqueda + `
+	SELECT p.id, p.enunciado, COALESCE(e.nombre, bp.nombre, ''),
+	COALESCE(e.cursoId, bp.cursoId, 0),
+	ts_rank(to_tsvector('es_unaccent', p.enunciado), b.q) AS rango
+	FROM b, preguntas p
+	LEFT JOIN examenes e ON e.id = p.examenId
+	LEFT JOIN bancosPreguntas bp ON bp.id = p.bancoId
+	WHERE to_tsvector('es_unaccent', p.enunciado) @@ b.q
+	AND (e.cursoId IN ` + cursosProfesor + `
+		OR bp.cursoId IN ` + cursosProfesor + `
+		OR (p.bancoId IS NOT NULL AND bp.cursoId IS NULL
+			AND EXISTS (SELECT 1 FROM profesores WHERE usuarioId = $3)))
+	ORDER BY rango DESC, p.id
+	LIMIT $2`
+
+// los trabajos los ven los profesores del curso, y los alumnos
+// matriculados si estan activos
+const busquedaTrabajos = consultaBusqueda + `
+	SELECT t.id, t.descripcion, c.siglas, t.cursoId,
+	ts_rank(to_tsvector('es_unaccent', t.descripcion), b.q) AS rango
+	FROM b, trabajos t
+	JOIN cursos c ON c.id = t.cursoId
+	WHERE to_tsvector('es_unaccent', t.descripcion) @@ b.q
+	AND (t.cursoId IN ` + cursosProfesor + `
+		OR (t.activo AND t.cursoId IN ` + cursosAlumno + `))
+	ORDER BY rango DESC, t.id
+	LIMIT $2`
+
+// los alumnos solo los ven los profesores de sus cursos
+const busquedaAlumnos = consultaBusqueda + `
+	SELECT a.id, a.nombres || ' ' || a.apellidos, a.codigo, 0,
+	ts_rank(to_tsvector('es_unaccent', a.nombres || ' ' || a.apellidos || ' ' || a.codigo), b.q) AS rango
+	FROM b, alumnos a
+	WHERE to_tsvector('es_unaccent', a.nombres || ' ' || a.apellidos || ' ' || a.codigo) @@ b.q
+	AND EXISTS (SELECT 1 FROM alumnoCurso ac
+		WHERE ac.alumnoId = a.id AND ac.activo AND ac.cursoId IN ` + cursosProfesor + `)
+	ORDER BY rango DESC, a.id
+	LIMIT $2`
+
+// los profesores los ven los profesores y alumnos de sus cursos
+const busquedaProfesores = consultaBusqueda + `
+	SELECT p.id, p.nombres || ' ' || p.apellidos, '', 0,
+	ts_rank(to_tsvector('es_unaccent', p.nombres || ' ' || p.apellidos), b.q) AS rango
+	FROM b, profesores p
+	WHERE to_tsvector('es_unaccent', p.nombres || ' ' || p.apellidos) @@ b.q
+	AND EXISTS (SELECT 1 FROM profesorCurso pc
+		WHERE pc.profesorId = p.id
+		AND (pc.cursoId IN ` + cursosProfesor + ` OR pc.cursoId IN ` + cursosAlumno + `))
+	ORDER BY rango DESC, p.id
+	LIMIT $2`
+
+// Buscar busca 'texto' con la sintaxis de websearch_to_tsquery (frases
+// entre comillas, -excluir, or) en todo lo que el usuario puede ver.
+// Devuelve hasta 'limite' resultados de cada tipo.
+func Buscar(db *pgxpool.Pool, usuarioId int, texto string, limite int) (ResultadosBusqueda, error) {
+	var resultados ResultadosBusqueda
+	grupos := []struct {
+		query   string
+		destino *[]ResultadoBusqueda
+	}{
+		{busquedaCursos, &resultados.Cursos},
+		{busquedaPreguntas, &resultados.Preguntas},
+		{busquedaTrabajos, &resultados.Trabajos},
+		{busquedaAlumnos, &resultados.Alumnos},
+		{busquedaProfesores, &resultados.Profesores},
+	}
+	for _, g := range grupos {
+		args := []interface{}{texto, limite}
+		// la consulta de cursos no depende del usuario
+		if g.query != busquedaCursos {
+			args = append(args, usuarioId)
+		}
+		encontrados, err := buscarGrupo(db, g.query, args...)
+		if err != nil {
+			return resultados, err
+		}
+		*g.destino = encontrados
+	}
+	return resultados, nil
+}
+
+func buscarGrupo(db *pgxpool.Pool, query string, args ...interface{}) ([]ResultadoBusqueda, error) {
+	rows, err := db.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	encontrados := []ResultadoBusqueda{}
+	for rows.Next() {
+		var r ResultadoBusqueda
+		if err := rows.Scan(&r.ID, &r.Titulo, &r.Detalle, &r.CursoId, &r.Rango); err != nil {
+			log.Printf("Las filas obtenidas de la BD para ResultadoBusqueda, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		encontrados = append(encontrados, r)
+	}
+	return encontrados, rows.Err()
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestBuscarCursoSinTildes(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(1, db)
+
+	curso := Curso{Nombre: "Matemática Básica", Siglas: "MA-101", Silabo: "silabo",
+		Semestre: "2022-I", Activo: true}
+	if err := curso.CreateCurso(db); err != nil {
+		t.Fatalf("El metodo CreateCurso fallo %s", err)
+	}
+
+	resultados, err := Buscar(db, 1, "matematicas basicas", LimiteBusquedaDefecto)
+	if err != nil {
+		t.Fatalf("El metodo Buscar fallo %s", err)
+	}
+	if len(resultados.Cursos) != 1 || resultados.Cursos[0].ID != curso.ID {
+		t.Errorf("Se esperaba encontrar el curso %d. Se obtuvo %v", curso.ID, resultados.Cursos)
+	}
+	if resultados.Preguntas == nil || resultados.Alumnos == nil {
+		t.Errorf("Los grupos sin resultados deben ser listas vacias. Se obtuvo %v", resultados)
+	}
+}
+
+func TestBuscarPreguntasSoloProfesores(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(1, db)
+
+	examen := Examen{Nombre: "Parcial", FechaInicio: time.Now(), FechaFinal: time.Now(),
+		CursoId: 1, Activo: true}
+	if err := examen.CreateExamen(db); err != nil {
+		t.Fatalf("El metodo CreateExamen fallo %s", err)
+	}
+	pregunta := Pregunta{Enunciado: "Calcule las derivadas parciales", ExamenId: examen.ID,
+		Activo: true}
+	if err := pregunta.CreatePregunta(db); err != nil {
+		t.Fatalf("El metodo CreatePregunta fallo %s", err)
+	}
+
+	// el usuario 1 solo es alumno del curso
+	resultados, err := Buscar(db, 1, "derivada", LimiteBusquedaDefecto)
+	if err != nil {
+		t.Fatalf("El metodo Buscar fallo %s", err)
+	}
+	if len(resultados.Preguntas) != 0 {
+		t.Errorf("Un alumno no deberia ver preguntas. Se obtuvo %v", resultados.Preguntas)
+	}
+
+	profesor := Profesor{Nombres: "Ana", Apellidos: "Quispe", UsuarioId: 1, Activo: true}
+	if err := profesor.CreateProfesor(db); err != nil {
+		t.Fatalf("El metodo CreateProfesor fallo %s", err)
+	}
+	pc := ProfesorCurso{ProfesorId: profesor.ID, CursoId: 1}
+	if err := pc.CreateProfesorCurso(db); err != nil {
+		t.Fatalf("El metodo CreateProfesorCurso fallo %s", err)
+	}
+
+	resultados, _ = Buscar(db, 1, "derivada", LimiteBusquedaDefecto)
+	if len(resultados.Preguntas) != 1 || resultados.Preguntas[0].ID != pregunta.ID {
+		t.Errorf("Se esperaba encontrar la pregunta %d. Se obtuvo %v", pregunta.ID, resultados.Preguntas)
+	}
+	if len(resultados.Alumnos) != 0 {
+		t.Errorf("No se esperaban alumnos. Se obtuvo %v", resultados.Alumnos)
+	}
+	resultados, _ = Buscar(db, 1, "nom_test_0", LimiteBusquedaDefecto)
+	if len(resultados.Alumnos) != 1 {
+		t.Errorf("El profesor deberia ver a su alumno. Se obtuvo %v", resultados.Alumnos)
+	}
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// TokenCalendario es el secreto de la URL del calendario de un usuario;
+// quien tenga la URL ve las fechas sin iniciar sesion
+type TokenCalendario struct {
+	UsuarioId int       `json:"usuarioId"`
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// EventoCalendario es un examen, con su ventana, o el vencimiento de un
+// trabajo. Para los alumnos las fechas ya incluyen su prorroga.
+type EventoCalendario struct {
+	Tipo      string
+	ID        int
+	Nombre    string
+	Curso     string
+	Inicio    time.Time
+	Fin       *time.Time
+	UpdatedAt time.Time
+}
+
+func nuevoTokenCalendario() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetTokenCalendario carga el token del usuario, creandolo la primera vez
+func (tc *TokenCalendario) GetTokenCalendario(db *pgxpool.Pool) error {
+	token, err := nuevoTokenCalendario()
+	if err != nil {
+		return err
+	}
+	// si ya existe el update no cambia nada y devuelve el token guardado
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO tokensCalendario(usuarioId, token, createdAt)
+		VALUES($1, $2, $3)
+		ON CONFLICT (usuarioId) DO UPDATE SET usuarioId=EXCLUDED.usuarioId
+		RETURNING token, createdAt`,
+		tc.UsuarioId, token, time.Now(),
+	).Scan(&tc.Token, &tc.CreatedAt)
+}
+
+// RegenerarTokenCalendario reemplaza el token del usuario; la URL anterior
+// deja de funcionar
+func (tc *TokenCalendario) RegenerarTokenCalendario(db *pgxpool.Pool) error {
+	token, err := nuevoTokenCalendario()
+	if err != nil {
+		return err
+	}
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO tokensCalendario(usuarioId, token, createdAt)
+		VALUES($1, $2, $3)
+		ON CONFLICT (usuarioId) DO UPDATE
+		SET token=EXCLUDED.token, createdAt=EXCLUDED.createdAt
+		RETURNING token, createdAt`,
+		tc.UsuarioId, token, time.Now(),
+	).Scan(&tc.Token, &tc.CreatedAt)
+}
+
+// GetUsuarioPorToken busca el usuario al que pertenece tc.Token
+func (tc *TokenCalendario) GetUsuarioPorToken(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT usuarioId, createdAt
+		FROM tokensCalendario
+		WHERE token=$1`,
+		tc.Token).Scan(&tc.UsuarioId, &tc.CreatedAt)
+}
+
+// cursos en que el usuario esta matriculado como alumno o asignado como
+// profesor. alumnoId queda en NULL para los profesores.
+const cursosUsuarioCTE = `
+WITH cursosUsuario AS (
+	SELECT ac.cursoId, ac.alumnoId
+	FROM alumnoCurso ac
+	JOIN alumnos al ON al.id = ac.alumnoId
+	WHERE al.usuarioId=$1 AND ac.activo
+	UNION
+	SELECT pc.cursoId, NULL
+	FROM profesorCurso pc
+	JOIN profesores p ON p.id = pc.profesorId
+	WHERE p.usuarioId=$1
+)
+`
+
+// GetEventosCalendario lista los examenes y trabajos activos de los cursos
+// del usuario, ordenados por fecha
+func GetEventosCalendario(db *pgxpool.Pool, usuarioId int) ([]EventoCalendario, error) {
+	rows, err := db.Query(
+		context.Background(),
+		cursosUsuarioCTE+`
+		SELECT tipo, id, nombre, curso, inicio, fin, updatedAt FROM (
+			SELECT DISTINCT ON (e.id) 'examen' AS tipo, e.id, e.nombre,
+			c.nombre AS curso, e.fechaInicio AS inicio,
+			COALESCE(p.fechaFinal, e.fechaFinal) AS fin,
+			GREATEST(e.updatedAt, p.updatedAt) AS updatedAt
+			FROM cursosUsuario cu
+			JOIN examenes e ON e.cursoId = cu.cursoId
+			JOIN cursos c ON c.id = e.cursoId
+			LEFT JOIN prorrogas p ON p.examenId = e.id
+				AND p.alumnoId = cu.alumnoId AND p.activo
+			WHERE e.activo
+			ORDER BY e.id, fin DESC
+		) examenes
+		UNION ALL
+		SELECT tipo, id, nombre, curso, inicio, fin, updatedAt FROM (
+			SELECT DISTINCT ON (t.id) 'trabajo' AS tipo, t.id,
+			t.descripcion AS nombre, c.nombre AS curso,
+			COALESCE(p.fechaFinal, t.fechaFinal) AS inicio,
+			NULL::TIMESTAMPTZ AS fin,
+			GREATEST(t.updatedAt, p.updatedAt) AS updatedAt
+			FROM cursosUsuario cu
+			JOIN trabajos t ON t.cursoId = cu.cursoId
+			JOIN cursos c ON c.id = t.cursoId
+			LEFT JOIN prorrogas p ON p.trabajoId = t.id
+				AND p.alumnoId = cu.alumnoId AND p.activo
+			WHERE t.activo
+			ORDER BY t.id, inicio DESC
+		) trabajos
+		ORDER BY inicio, tipo, id`,
+		usuarioId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	eventos := []EventoCalendario{}
+	for rows.Next() {
+		var e EventoCalendario
+		err := rows.Scan(&e.Tipo, &e.ID, &e.Nombre, &e.Curso, &e.Inicio, &e.Fin,
+			&e.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para EventoCalendario, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		eventos = append(eventos, e)
+	}
+	return eventos, rows.Err()
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestTokenCalendario(t *testing.T) {
+	utils.ClearTableUsuario(db)
+	utils.AddUsers(1, db)
+
+	primero := TokenCalendario{UsuarioId: 1}
+	if err := primero.GetTokenCalendario(db); err != nil {
+		t.Fatalf("El metodo GetTokenCalendario fallo %s", err)
+	}
+	segundo := TokenCalendario{UsuarioId: 1}
+	segundo.GetTokenCalendario(db)
+	if primero.Token == "" || primero.Token != segundo.Token {
+		t.Errorf("Se esperaba el mismo token. Se obtuvo %q y %q", primero.Token, segundo.Token)
+	}
+
+	nuevo := TokenCalendario{UsuarioId: 1}
+	if err := nuevo.RegenerarTokenCalendario(db); err != nil {
+		t.Fatalf("El metodo RegenerarTokenCalendario fallo %s", err)
+	}
+	if nuevo.Token == primero.Token {
+		t.Errorf("Se esperaba un token distinto al regenerar")
+	}
+
+	viejo := TokenCalendario{Token: primero.Token}
+	if err := viejo.GetUsuarioPorToken(db); err == nil {
+		t.Errorf("El token anterior no deberia funcionar")
+	}
+	vigente := TokenCalendario{Token: nuevo.Token}
+	if err := vigente.GetUsuarioPorToken(db); err != nil || vigente.UsuarioId != 1 {
+		t.Errorf("Se esperaba el usuario 1. Se obtuvo %d, %v", vigente.UsuarioId, err)
+	}
+}
+
+func TestEventosCalendario(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(2, db)
+	ahora := time.Now().Truncate(time.Second)
+
+	examen := Examen{Nombre: "Parcial", CursoId: 1, Activo: true,
+		FechaInicio: ahora.Add(24 * time.Hour), FechaFinal: ahora.Add(26 * time.Hour)}
+	examen.CreateExamen(db)
+	trabajo := Trabajo{Descripcion: "Informe", CursoId: 1, Activo: true,
+		FechaInicio: ahora, FechaFinal: ahora.Add(48 * time.Hour)}
+	trabajo.CreateTrabajo(db)
+	borrador := Trabajo{Descripcion: "Borrador", CursoId: 1, Activo: false,
+		FechaInicio: ahora, FechaFinal: ahora.Add(72 * time.Hour)}
+	borrador.CreateTrabajo(db)
+
+	nuevaFecha := ahora.Add(96 * time.Hour)
+	prorroga := Prorroga{AlumnoId: 1, TrabajoId: trabajo.ID, FechaFinal: &nuevaFecha,
+		Motivo: "Salud", OtorgadoPor: 1, Activo: true}
+	prorroga.CreateProrroga(db)
+
+	// los usuarios 1 y 2 son los alumnos 1 y 2
+	eventos, err := GetEventosCalendario(db, 1)
+	if err != nil {
+		t.Fatalf("El metodo GetEventosCalendario fallo %s", err)
+	}
+	if len(eventos) != 2 || eventos[0].Tipo != "examen" || eventos[0].Fin == nil ||
+		!eventos[1].Inicio.Equal(nuevaFecha) {
+		t.Errorf("Se esperaba el examen y el trabajo con prorroga. Se obtuvo %v", eventos)
+	}
+
+	eventos, _ = GetEventosCalendario(db, 2)
+	if len(eventos) != 2 || !eventos[1].Inicio.Equal(trabajo.FechaFinal) {
+		t.Errorf("Se esperaba el trabajo con su fecha original. Se obtuvo %v", eventos)
+	}
+
+	if eventos, _ := GetEventosCalendario(db, 99); len(eventos) != 0 {
+		t.Errorf("No se esperaban eventos para un usuario sin cursos. Se obtuvo %v", eventos)
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Comentario es la retroalimentacion sobre una entrega. Los comentarios
+// sin RespuestaA abren un hilo, general o sobre una PreguntaTrabajo, y las
+// respuestas heredan la pregunta del comentario al que responden.
+type Comentario struct {
+	ID                int    `json:"id"`
+	AlumnoTrabajoId   int    `json:"alumnoTrabajoId"`
+	PreguntaTrabajoId int    `json:"preguntaTrabajoId"`
+	RespuestaA        int    `json:"respuestaA"`
+	UsuarioId         int    `json:"usuarioId"`
+	Texto             string `json:"texto"`
+
+	// archivo adjunto opcional
+	NombreArchivo string `json:"nombreArchivo"`
+	MimeType      string `json:"mimeType"`
+	Tamano        int64  `json:"tamano"`
+	Clave         string `json:"-"` // clave en el storage
+
+	Respuestas []Comentario `json:"respuestas"`
+	CreatedAt  time.Time    `json:"createdAt"`
+}
+
+func (c *Comentario) TieneAdjunto() bool {
+	return c.Clave != ""
+}
+
+func (c *Comentario) CreateComentario(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO comentarios(alumnoTrabajoId, preguntaTrabajoId, respuestaA,
+		usuarioId, texto, nombreArchivo, mimeType, tamano, clave, createdAt)
+		VALUES($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, createdAt`,
+		c.AlumnoTrabajoId, c.PreguntaTrabajoId, c.RespuestaA, c.UsuarioId,
+		c.Texto, c.NombreArchivo, c.MimeType, c.Tamano, c.Clave, time.Now(),
+	).Scan(&c.ID, &c.CreatedAt)
+}
+
+func (c *Comentario) GetComentario(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT alumnoTrabajoId, COALESCE(preguntaTrabajoId, 0),
+		COALESCE(respuestaA, 0), usuarioId, texto, nombreArchivo,
+		mimeType, tamano, clave, createdAt
+		FROM comentarios
+		WHERE id=$1`,
+		c.ID).Scan(&c.AlumnoTrabajoId, &c.PreguntaTrabajoId, &c.RespuestaA,
+		&c.UsuarioId, &c.Texto, &c.NombreArchivo, &c.MimeType, &c.Tamano,
+		&c.Clave, &c.CreatedAt)
+}
+
+// GetComentarios obtiene los comentarios de una entrega en orden
+// cronologico, sin armar los hilos
+func GetComentarios(db *pgxpool.Pool, alumnoTrabajoId int) ([]Comentario, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, alumnoTrabajoId, COALESCE(preguntaTrabajoId, 0),
+		COALESCE(respuestaA, 0), usuarioId, texto, nombreArchivo,
+		mimeType, tamano, clave, createdAt
+		FROM comentarios
+		WHERE alumnoTrabajoId=$1
+		ORDER BY createdAt, id`,
+		alumnoTrabajoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comentarios := []Comentario{}
+	for rows.Next() {
+		var c Comentario
+		err := rows.Scan(&c.ID, &c.AlumnoTrabajoId, &c.PreguntaTrabajoId,
+			&c.RespuestaA, &c.UsuarioId, &c.Texto, &c.NombreArchivo,
+			&c.MimeType, &c.Tamano, &c.Clave, &c.CreatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Comentario, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		comentarios = append(comentarios, c)
+	}
+	return comentarios, nil
+}
+
+// ArmarHilos anida cada comentario en las Respuestas del comentario al que
+// responde y devuelve solo los que abren un hilo
+func ArmarHilos(comentarios []Comentario) []Comentario {
+	hijos := map[int][]Comentario{}
+	for _, c := range comentarios {
+		hijos[c.RespuestaA] = append(hijos[c.RespuestaA], c)
+	}
+
+	var armar func(padre int) []Comentario
+	armar = func(padre int) []Comentario {
+		hilo := []Comentario{}
+		for _, c := range hijos[padre] {
+			c.Respuestas = armar(c.ID)
+			hilo = append(hilo, c)
+		}
+		return hilo
+	}
+	return armar(0)
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestCreateComentario(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddEntregas(1, db)
+
+	c := Comentario{AlumnoTrabajoId: 1, UsuarioId: 1, Texto: "revisar la conclusion"}
+	if err := c.CreateComentario(db); err != nil {
+		t.Errorf("No se creo el comentario %s", err)
+	}
+	if c.ID != 1 {
+		t.Errorf("Se esperaba crear un comentario con ID 1. Se obtuvo %d", c.ID)
+	}
+
+	respuesta := Comentario{AlumnoTrabajoId: 1, RespuestaA: c.ID, UsuarioId: 1, Texto: "corregido"}
+	if err := respuesta.CreateComentario(db); err != nil {
+		t.Errorf("No se creo la respuesta %s", err)
+	}
+
+	leida := Comentario{ID: respuesta.ID}
+	if err := leida.GetComentario(db); err != nil {
+		t.Errorf("Se esperaba obtener el comentario. Se obtuvo %v", err)
+	}
+	if leida.RespuestaA != 1 || leida.PreguntaTrabajoId != 0 || leida.TieneAdjunto() {
+		t.Errorf("Se esperaba una respuesta al comentario 1 sin adjunto. Se obtuvo %v", leida)
+	}
+}
+
+func TestArmarHilos(t *testing.T) {
+	comentarios := []Comentario{
+		{ID: 1},
+		{ID: 2, RespuestaA: 1},
+		{ID: 3},
+		{ID: 4, RespuestaA: 2},
+		{ID: 5, RespuestaA: 1},
+	}
+
+	hilos := ArmarHilos(comentarios)
+	if len(hilos) != 2 || hilos[0].ID != 1 || hilos[1].ID != 3 {
+		t.Fatalf("Se esperaban los hilos 1 y 3. Se obtuvo %v", hilos)
+	}
+	if len(hilos[0].Respuestas) != 2 || hilos[0].Respuestas[1].ID != 5 {
+		t.Errorf("Se esperaban las respuestas 2 y 5 en el hilo 1. Se obtuvo %v", hilos[0].Respuestas)
+	}
+	if len(hilos[0].Respuestas[0].Respuestas) != 1 {
+		t.Errorf("Se esperaba la respuesta 4 anidada en la 2. Se obtuvo %v", hilos[0].Respuestas[0])
+	}
+	if hilos[1].Respuestas == nil {
+		t.Errorf("Se esperaba una lista vacia de respuestas, no nil")
+	}
+}
+
+func TestDevolverAlumnoTrabajo(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddEntregas(1, db)
+
+	at := AlumnoTrabajo{ID: 1}
+	if err := at.Devolver(db); err != nil {
+		t.Errorf("No se devolvio el trabajo %s", err)
+	}
+
+	pendientes, err := GetDevolucionesPendientes(db, 1)
+	if err != nil || len(pendientes) != 1 {
+		t.Errorf("Se esperaba una devolucion pendiente. Se obtuvo %v %v", pendientes, err)
+	}
+
+	if err := at.MarcarDevolucionVista(db); err != nil {
+		t.Errorf("No se marco la devolucion como vista %s", err)
+	}
+	pendientes, _ = GetDevolucionesPendientes(db, 1)
+	if len(pendientes) != 0 {
+		t.Errorf("No se esperaban devoluciones pendientes. Se obtuvo %v", pendientes)
+	}
+
+	// una reentrega anula la devolucion
+	e := Entrega{TrabajoId: 1, AlumnoId: 1, NombreArchivo: "v2.txt",
+		MimeType: "text/plain", Tamano: 2, Clave: "test/v2.txt", FechaEntrega: time.Now()}
+	if err := e.CreateEntrega(db); err != nil {
+		t.Errorf("No se creo la entrega %s", err)
+	}
+	if err := at.GetAlumnoTrabajo(db); err != nil || at.FechaDevolucion != nil {
+		t.Errorf("Se esperaba anular la devolucion. Se obtuvo %v %v", at.FechaDevolucion, err)
+	}
+}
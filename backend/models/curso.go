@@ -5,6 +5,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/blackadress/vaula/consulta"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
@@ -42,14 +43,32 @@ func (c *Curso) GetCurso(db *pgxpool.Pool) error {
 		&c.Activo, &c.CreatedAt, &c.UpdatedAt)
 }
 
-func GetCursos(db *pgxpool.Pool) ([]Curso, error) {
+// ListadoCursos son los filtros y ordenes de GET /cursos
+var ListadoCursos = consulta.Definicion{
+	Campos: map[string]consulta.Campo{
+		"siglas":    {Columna: "siglas", Tipo: consulta.Texto, Filtro: true, Orden: true},
+		"nombre":    {Columna: "nombre", Tipo: consulta.Texto, Filtro: true, Parcial: true, Orden: true},
+		"semestre":  {Columna: "semestre", Tipo: consulta.Texto, Filtro: true, Orden: true},
+		"activo":    {Columna: "activo", Tipo: consulta.Booleano, Filtro: true},
+		"createdAt": {Columna: "createdAt", Tipo: consulta.Fecha, Orden: true},
+	},
+}
+
+func GetCursos(db *pgxpool.Pool, c consulta.Consulta) ([]Curso, int, error) {
+	total, err := contar(db, "cursos", c)
+	if err != nil {
+		return nil, 0, err
+	}
+	where, args := c.Filtro()
+
 	rows, err := db.Query(
 		context.Background(),
 		`SELECT id, siglas, nombre, silabo, semestre, activo, createdAt, updatedAt
-		FROM cursos`)
+		FROM cursos`+where+c.OrdenYPagina(),
+		args...)
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -63,11 +82,11 @@ func GetCursos(db *pgxpool.Pool) ([]Curso, error) {
 		if err != nil {
 			log.Printf("Las filas obtenidas de la BD para Curso, no satisfacen a 'Scan', %s",
 				err)
-			return nil, err
+			return nil, 0, err
 		}
 		cursos = append(cursos, c)
 	}
-	return cursos, nil
+	return cursos, total, rows.Err()
 }
 
 func (c *Curso) UpdateCurso(db *pgxpool.Pool) error {
@@ -89,3 +108,32 @@ func (c *Curso) DeleteCurso(db *pgxpool.Pool) error {
 		c.ID)
 	return err
 }
+
+// roles de un usuario dentro de un curso
+const (
+	RolProfesor = "profesor"
+	RolAlumno   = "alumno"
+)
+
+// RolEnCurso devuelve RolProfesor si el usuario es un profesor asignado al
+// curso, RolAlumno si es un alumno con matricula activa, o "" si no
+// pertenece al curso
+func RolEnCurso(db *pgxpool.Pool, usuarioId, cursoId int) (string, error) {
+	var rol string
+	err := db.QueryRow(
+		context.Background(),
+		`SELECT CASE
+			WHEN EXISTS (
+				SELECT 1 FROM profesorCurso pc
+				JOIN profesores p ON p.id = pc.profesorId
+				WHERE p.usuarioId=$1 AND pc.cursoId=$2)
+			THEN 'profesor'
+			WHEN EXISTS (
+				SELECT 1 FROM alumnoCurso ac
+				JOIN alumnos al ON al.id = ac.alumnoId
+				WHERE al.usuarioId=$1 AND ac.cursoId=$2 AND ac.activo)
+			THEN 'alumno'
+			ELSE '' END`,
+		usuarioId, cursoId).Scan(&rol)
+	return rol, err
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"testing"
 
+	"github.com/blackadress/vaula/consulta"
 	"github.com/blackadress/vaula/utils"
 	"github.com/jackc/pgx/v4"
 )
@@ -50,7 +51,7 @@ func TestNotGetCurso(t *testing.T) {
 func TestGetCursos(t *testing.T) {
 	utils.ClearTableCurso(db)
 	utils.AddCursos(2, db)
-	cursos, err := GetCursos(db)
+	cursos, _, err := GetCursos(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
 	}
@@ -64,7 +65,7 @@ func TestGetCursos(t *testing.T) {
 func TestGetZeroCursos(t *testing.T) {
 	utils.ClearTableCurso(db)
 
-	cursos, err := GetCursos(db)
+	cursos, _, err := GetCursos(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
 	}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Entrega es cada archivo que un alumno sube para un Trabajo. Las
+// reentregas no reemplazan a las anteriores, se guardan como una nueva
+// version y el AlumnoTrabajo apunta a la ultima.
+type Entrega struct {
+	ID              int       `json:"id"`
+	AlumnoTrabajoId int       `json:"alumnoTrabajoId"`
+	TrabajoId       int       `json:"trabajoId"`
+	AlumnoId        int       `json:"alumnoId"`
+	Version         int       `json:"version"`
+	NombreArchivo   string    `json:"nombreArchivo"`
+	MimeType        string    `json:"mimeType"`
+	Tamano          int64     `json:"tamano"`
+	Clave           string    `json:"-"` // clave en el storage
+	FechaEntrega    time.Time `json:"fechaEntrega"`
+	Tardio          bool      `json:"tardio"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CreateEntrega registra la entrega y actualiza (o crea) el AlumnoTrabajo
+// del alumno en una sola transaccion. Una reentrega anula la devolucion.
+func (e *Entrega) CreateEntrega(db *pgxpool.Pool) error {
+	now := time.Now()
+	if e.FechaEntrega.IsZero() {
+		e.FechaEntrega = now
+	}
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	err = tx.QueryRow(
+		context.Background(),
+		`INSERT INTO alumnoTrabajo(calificacion, uri, fechaInicio,
+		fechaFinal, alumnoId, trabajoId, activo, createdAt, updatedAt)
+		VALUES(0, '', $1, $1, $2, $3, true, $4, $4)
+		ON CONFLICT (alumnoId, trabajoId)
+		DO UPDATE SET fechaFinal=EXCLUDED.fechaFinal, updatedAt=EXCLUDED.updatedAt,
+		fechaDevolucion=NULL, devolucionVista=false
+		RETURNING id`,
+		e.FechaEntrega, e.AlumnoId, e.TrabajoId, now,
+	).Scan(&e.AlumnoTrabajoId)
+	if err != nil {
+		return err
+	}
+
+	// con el alumnoTrabajo bloqueado hasta el commit, dos entregas
+	// simultaneas del alumno no pueden calcular la misma version
+	_, err = tx.Exec(
+		context.Background(),
+		`SELECT id FROM alumnoTrabajo WHERE id=$1 FOR UPDATE`,
+		e.AlumnoTrabajoId)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		context.Background(),
+		`INSERT INTO entregas(alumnoTrabajoId, trabajoId, alumnoId, version,
+		nombreArchivo, mimeType, tamano, clave, fechaEntrega, tardio, createdAt)
+		SELECT $1, $2, $3, COALESCE(MAX(version), 0) + 1, $4, $5, $6, $7, $8, $9, $10
+		FROM entregas WHERE alumnoTrabajoId=$1
+		RETURNING id, version, createdAt`,
+		e.AlumnoTrabajoId, e.TrabajoId, e.AlumnoId, e.NombreArchivo,
+		e.MimeType, e.Tamano, e.Clave, e.FechaEntrega, e.Tardio, now,
+	).Scan(&e.ID, &e.Version, &e.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		context.Background(),
+		`UPDATE alumnoTrabajo SET uri=$1 WHERE id=$2`,
+		fmt.Sprintf("/entregas/%d", e.ID), e.AlumnoTrabajoId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+func (e *Entrega) GetEntrega(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT alumnoTrabajoId, trabajoId, alumnoId, version, nombreArchivo,
+		mimeType, tamano, clave, fechaEntrega, tardio, createdAt
+		FROM entregas
+		WHERE id=$1`,
+		e.ID).Scan(&e.AlumnoTrabajoId, &e.TrabajoId, &e.AlumnoId, &e.Version,
+		&e.NombreArchivo, &e.MimeType, &e.Tamano, &e.Clave,
+		&e.FechaEntrega, &e.Tardio, &e.CreatedAt)
+}
+
+// GetEntregasTrabajo lista todas las entregas de un trabajo
+func GetEntregasTrabajo(db *pgxpool.Pool, trabajoId int) ([]Entrega, error) {
+	return getEntregas(db, `WHERE trabajoId=$1`, trabajoId)
+}
+
+// GetEntregasAlumno lista el historial de entregas de un alumno en un trabajo
+func GetEntregasAlumno(db *pgxpool.Pool, trabajoId, alumnoId int) ([]Entrega, error) {
+	return getEntregas(db, `WHERE trabajoId=$1 AND alumnoId=$2`, trabajoId, alumnoId)
+}
+
+func getEntregas(db *pgxpool.Pool, filtro string, args ...interface{}) ([]Entrega, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, alumnoTrabajoId, trabajoId, alumnoId, version, nombreArchivo,
+		mimeType, tamano, clave, fechaEntrega, tardio, createdAt
+		FROM entregas `+filtro+`
+		ORDER BY alumnoId, version`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entregas := []Entrega{}
+	for rows.Next() {
+		var e Entrega
+		err := rows.Scan(
+			&e.ID, &e.AlumnoTrabajoId, &e.TrabajoId, &e.AlumnoId, &e.Version,
+			&e.NombreArchivo, &e.MimeType, &e.Tamano, &e.Clave,
+			&e.FechaEntrega, &e.Tardio, &e.CreatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Entrega, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		entregas = append(entregas, e)
+	}
+	return entregas, nil
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestCreateEntrega(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnos(1, db)
+	utils.AddTrabajos(1, db)
+
+	e := Entrega{
+		TrabajoId:     1,
+		AlumnoId:      1,
+		NombreArchivo: "informe.pdf",
+		MimeType:      "application/pdf",
+		Tamano:        1024,
+		Clave:         "trabajos/1/alumnos/1/informe.pdf",
+	}
+	if err := e.CreateEntrega(db); err != nil {
+		t.Errorf("No se creo la entrega %s", err)
+	}
+	if e.ID != 1 || e.Version != 1 {
+		t.Errorf("Se esperaba la entrega 1 en version 1. Se obtuvo %d, version %d",
+			e.ID, e.Version)
+	}
+
+	// una reentrega crea una nueva version sobre el mismo AlumnoTrabajo
+	re := e
+	re.Clave = "trabajos/1/alumnos/1/informe_v2.pdf"
+	if err := re.CreateEntrega(db); err != nil {
+		t.Errorf("No se creo la reentrega %s", err)
+	}
+	if re.Version != 2 || re.AlumnoTrabajoId != e.AlumnoTrabajoId {
+		t.Errorf("Se esperaba la version 2 del AlumnoTrabajo %d. Se obtuvo version %d del %d",
+			e.AlumnoTrabajoId, re.Version, re.AlumnoTrabajoId)
+	}
+
+	at := AlumnoTrabajo{ID: re.AlumnoTrabajoId}
+	if err := at.GetAlumnoTrabajo(db); err != nil {
+		t.Errorf("El metodo GetAlumnoTrabajo fallo %s", err)
+	}
+	if at.Uri != "/entregas/2" {
+		t.Errorf("Se esperaba que el AlumnoTrabajo apunte a '/entregas/2'. Se obtuvo '%s'", at.Uri)
+	}
+}
+
+func TestCreateEntregasConcurrentes(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnos(1, db)
+	utils.AddTrabajos(1, db)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	versiones := make([]int, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			e := Entrega{
+				TrabajoId:     1,
+				AlumnoId:      1,
+				NombreArchivo: "informe.pdf",
+				MimeType:      "application/pdf",
+				Tamano:        1024,
+				Clave:         fmt.Sprintf("trabajos/1/alumnos/1/informe_%d.pdf", i),
+			}
+			errs[i] = e.CreateEntrega(db)
+			versiones[i] = e.Version
+		}(i)
+	}
+	wg.Wait()
+
+	creadas := map[int]bool{}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("No se creo la entrega %s", err)
+		}
+		creadas[versiones[i]] = true
+	}
+	if len(creadas) != 5 {
+		t.Errorf("Se esperaban 5 versiones distintas. Se obtuvo %v", versiones)
+	}
+}
+
+func TestGetEntrega(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddEntregas(1, db)
+
+	e := Entrega{ID: 1}
+	if err := e.GetEntrega(db); err != nil {
+		t.Errorf("Se esperaba obtener la entrega con ID 1. Se obtuvo %v", err)
+	}
+}
+
+func TestNotGetEntrega(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.ClearTableAlumnoTrabajo(db)
+
+	e := Entrega{ID: 1}
+	if err := e.GetEntrega(db); err != pgx.ErrNoRows {
+		t.Errorf("Se esperaba error ErrNoRows, se obtuvo diferente error. ERROR %v", err)
+	}
+}
+
+func TestGetEntregasAlumno(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddEntregas(2, db)
+
+	entregas, err := GetEntregasTrabajo(db, 1)
+	if err != nil {
+		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
+	}
+	if len(entregas) != 1 {
+		t.Errorf("Se esperaba 1 entrega para el trabajo 1. Se obtuvo: %v", entregas)
+	}
+
+	entregas, err = GetEntregasAlumno(db, 1, 2)
+	if err != nil {
+		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
+	}
+	if len(entregas) != 0 {
+		t.Errorf("Se esperaba que el alumno 2 no tenga entregas en el trabajo 1. Se obtuvo: %v",
+			entregas)
+	}
+}
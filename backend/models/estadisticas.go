@@ -0,0 +1,252 @@
+package models
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// FraccionGrupoExtremo es la parte de los intentos, ordenados por puntaje
+// total, que forma cada grupo para el indice de discriminacion
+const FraccionGrupoExtremo = 0.27
+
+// Metodos de confiabilidad. KR-20 es el alfa de Cronbach cuando todas las
+// preguntas se califican con todo o nada.
+const (
+	MetodoKR20 = "KR-20"
+	MetodoAlfa = "alfa de Cronbach"
+)
+
+// FrecuenciaAlternativa cuenta cuantos intentos eligieron la alternativa,
+// en total y dentro de los grupos superior e inferior
+type FrecuenciaAlternativa struct {
+	AlternativaId int     `json:"alternativaId"`
+	Valor         string  `json:"valor"`
+	Correcto      bool    `json:"correcto"`
+	Seleccionada  int     `json:"seleccionada"`
+	Proporcion    float64 `json:"proporcion"`
+	Superior      int     `json:"superior"`
+	Inferior      int     `json:"inferior"`
+}
+
+// EstadisticasPregunta es el analisis de una pregunta. Dificultad es la
+// fraccion promedio del puntaje obtenida (1 la respondieron todos bien) y
+// Discriminacion la diferencia de esa fraccion entre el grupo superior y
+// el inferior. Son nulas cuando no hay intentos suficientes.
+type EstadisticasPregunta struct {
+	PreguntaId     int                     `json:"preguntaId"`
+	Enunciado      string                  `json:"enunciado"`
+	Tipo           string                  `json:"tipo"`
+	Respondidas    int                     `json:"respondidas"`
+	Dificultad     *float64                `json:"dificultad"`
+	Discriminacion *float64                `json:"discriminacion"`
+	Alternativas   []FrecuenciaAlternativa `json:"alternativas"`
+}
+
+type EstadisticasExamen struct {
+	ExamenId            int                    `json:"examenId"`
+	Intentos            int                    `json:"intentos"`
+	Promedio            *float64               `json:"promedio"`
+	Confiabilidad       *float64               `json:"confiabilidad"`
+	MetodoConfiabilidad string                 `json:"metodoConfiabilidad"`
+	Preguntas           []EstadisticasPregunta `json:"preguntas"`
+}
+
+// CalcularEstadisticas analiza las preguntas con las respuestas de cada
+// intento, indexadas por pregunta. Una pregunta sin respuesta en un
+// intento cuenta con puntaje 0.
+func CalcularEstadisticas(examenId int, preguntas []Pregunta, intentos []map[int]Respuesta) EstadisticasExamen {
+	n, k := len(intentos), len(preguntas)
+	est := EstadisticasExamen{
+		ExamenId:  examenId,
+		Intentos:  n,
+		Preguntas: []EstadisticasPregunta{},
+	}
+
+	puntajes := make([][]float64, n)
+	totales := make([]float64, n)
+	dicotomicas := true
+	for i, respuestas := range intentos {
+		puntajes[i] = make([]float64, k)
+		for j, p := range preguntas {
+			puntaje := float64(respuestas[p.ID].Puntaje)
+			if puntaje != 0 && puntaje != float64(p.Puntaje) {
+				dicotomicas = false
+			}
+			puntajes[i][j] = puntaje
+			totales[i] += puntaje
+		}
+	}
+
+	// grupos superior e inferior por puntaje total
+	orden := make([]int, n)
+	for i := range orden {
+		orden[i] = i
+	}
+	sort.SliceStable(orden, func(x, y int) bool { return totales[orden[x]] > totales[orden[y]] })
+	g := int(math.Round(FraccionGrupoExtremo * float64(n)))
+	if g < 1 && n >= 2 {
+		g = 1
+	}
+	grupo := make([]int, n) // 1 superior, -1 inferior
+	for x := 0; x < g; x++ {
+		grupo[orden[x]] = 1
+		grupo[orden[n-1-x]] = -1
+	}
+
+	for j, p := range preguntas {
+		ep := EstadisticasPregunta{
+			PreguntaId:   p.ID,
+			Enunciado:    p.Enunciado,
+			Tipo:         p.Tipo,
+			Alternativas: []FrecuenciaAlternativa{},
+		}
+		fraccion := func(i int) float64 {
+			if p.Puntaje == 0 {
+				return 0
+			}
+			return puntajes[i][j] / float64(p.Puntaje)
+		}
+
+		var suma, superior, inferior float64
+		for i := range intentos {
+			if _, ok := intentos[i][p.ID]; ok {
+				ep.Respondidas++
+			}
+			suma += fraccion(i)
+			switch grupo[i] {
+			case 1:
+				superior += fraccion(i)
+			case -1:
+				inferior += fraccion(i)
+			}
+		}
+		if n > 0 {
+			ep.Dificultad = nuevoFloat(suma / float64(n))
+		}
+		if g > 0 {
+			ep.Discriminacion = nuevoFloat((superior - inferior) / float64(g))
+		}
+
+		if p.Tipo == TipoOpcionMultiple || p.Tipo == TipoVerdaderoFalso {
+			for _, a := range p.Alternativas {
+				f := FrecuenciaAlternativa{AlternativaId: a.ID, Valor: a.Valor, Correcto: a.Correcto}
+				for i, respuestas := range intentos {
+					if r, ok := respuestas[p.ID]; !ok || r.AlternativaId != a.ID {
+						continue
+					}
+					f.Seleccionada++
+					switch grupo[i] {
+					case 1:
+						f.Superior++
+					case -1:
+						f.Inferior++
+					}
+				}
+				if n > 0 {
+					f.Proporcion = float64(f.Seleccionada) / float64(n)
+				}
+				ep.Alternativas = append(ep.Alternativas, f)
+			}
+		}
+		est.Preguntas = append(est.Preguntas, ep)
+	}
+
+	if n > 0 {
+		est.Promedio = nuevoFloat(media(totales))
+	}
+
+	// alfa = k/(k-1) * (1 - suma de varianzas por pregunta / varianza total)
+	if k >= 2 && n >= 2 {
+		varianzaTotal := varianza(totales)
+		if varianzaTotal > 0 {
+			var sumaVarianzas float64
+			columna := make([]float64, n)
+			for j := range preguntas {
+				for i := range puntajes {
+					columna[i] = puntajes[i][j]
+				}
+				sumaVarianzas += varianza(columna)
+			}
+			alfa := float64(k) / float64(k-1) * (1 - sumaVarianzas/varianzaTotal)
+			est.Confiabilidad = &alfa
+			est.MetodoConfiabilidad = MetodoAlfa
+			if dicotomicas {
+				est.MetodoConfiabilidad = MetodoKR20
+			}
+		}
+	}
+	return est
+}
+
+func nuevoFloat(f float64) *float64 {
+	return &f
+}
+
+func media(valores []float64) float64 {
+	var suma float64
+	for _, v := range valores {
+		suma += v
+	}
+	return suma / float64(len(valores))
+}
+
+// varianza poblacional, la que usan KR-20 y el alfa de Cronbach
+func varianza(valores []float64) float64 {
+	m := media(valores)
+	var suma float64
+	for _, v := range valores {
+		suma += (v - m) * (v - m)
+	}
+	return suma / float64(len(valores))
+}
+
+// GetEstadisticasExamen analiza el primer intento de cada alumno que tenga
+// alguna respuesta. Los intentos siguientes no se usan porque el alumno
+// ya conoce las preguntas.
+func GetEstadisticasExamen(db *pgxpool.Pool, examenId int) (EstadisticasExamen, error) {
+	preguntas, err := GetPreguntasExamen(db, examenId)
+	if err != nil {
+		return EstadisticasExamen{}, err
+	}
+
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT r.id, r.alumnoExamenId, r.preguntaId, COALESCE(r.alternativaId, 0),
+		r.texto, r.puntaje
+		FROM respuestas r
+		JOIN alumnoExamen ae ON ae.id = r.alumnoExamenId
+		WHERE ae.examenId=$1 AND ae.intento=1
+		ORDER BY r.alumnoExamenId`,
+		examenId)
+	if err != nil {
+		return EstadisticasExamen{}, err
+	}
+	defer rows.Close()
+
+	intentos := []map[int]Respuesta{}
+	ultimo := 0
+	for rows.Next() {
+		var r Respuesta
+		err := rows.Scan(&r.ID, &r.AlumnoExamenId, &r.PreguntaId, &r.AlternativaId,
+			&r.Texto, &r.Puntaje)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Respuesta, no satisfacen a 'Scan' %s",
+				err)
+			return EstadisticasExamen{}, err
+		}
+		if r.AlumnoExamenId != ultimo {
+			intentos = append(intentos, map[int]Respuesta{})
+			ultimo = r.AlumnoExamenId
+		}
+		intentos[len(intentos)-1][r.PreguntaId] = r
+	}
+	if err := rows.Err(); err != nil {
+		return EstadisticasExamen{}, err
+	}
+
+	return CalcularEstadisticas(examenId, preguntas, intentos), nil
+}
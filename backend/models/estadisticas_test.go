@@ -0,0 +1,91 @@
+package models
+
+import (
+	"math"
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestCalcularEstadisticas(t *testing.T) {
+	preguntas := []Pregunta{
+		{ID: 1, Tipo: TipoOpcionMultiple, Puntaje: 1,
+			Alternativas: []Alternativa{{ID: 11, Correcto: true}, {ID: 12}}},
+		{ID: 2, Tipo: TipoOpcionMultiple, Puntaje: 1,
+			Alternativas: []Alternativa{{ID: 21, Correcto: true}, {ID: 22}}},
+	}
+	intentos := []map[int]Respuesta{
+		{1: {AlternativaId: 11, Puntaje: 1}, 2: {AlternativaId: 21, Puntaje: 1}},
+		{1: {AlternativaId: 11, Puntaje: 1}, 2: {AlternativaId: 21, Puntaje: 1}},
+		// el tercero no respondio la segunda pregunta
+		{1: {AlternativaId: 12}},
+		{1: {AlternativaId: 11, Puntaje: 1}, 2: {AlternativaId: 22}},
+	}
+
+	est := CalcularEstadisticas(1, preguntas, intentos)
+
+	if est.Intentos != 4 || *est.Promedio != 1.25 {
+		t.Errorf("Se esperaban 4 intentos con promedio 1.25. Se obtuvo %d y %v",
+			est.Intentos, *est.Promedio)
+	}
+
+	p1, p2 := est.Preguntas[0], est.Preguntas[1]
+	if *p1.Dificultad != 0.75 || *p2.Dificultad != 0.5 {
+		t.Errorf("Se esperaban dificultades 0.75 y 0.5. Se obtuvo %v y %v",
+			*p1.Dificultad, *p2.Dificultad)
+	}
+	// grupos de un intento: superior el primero, inferior el tercero
+	if *p1.Discriminacion != 1 || *p2.Discriminacion != 1 {
+		t.Errorf("Se esperaba discriminacion 1. Se obtuvo %v y %v",
+			*p1.Discriminacion, *p2.Discriminacion)
+	}
+	if p2.Respondidas != 3 {
+		t.Errorf("Se esperaban 3 respuestas a la segunda pregunta. Se obtuvo %d", p2.Respondidas)
+	}
+
+	distractor := p1.Alternativas[1]
+	if distractor.Seleccionada != 1 || distractor.Proporcion != 0.25 || distractor.Inferior != 1 {
+		t.Errorf("Frecuencia inesperada del distractor %+v", distractor)
+	}
+
+	// varianzas: total 0.6875, preguntas 0.1875 y 0.25
+	esperado := 2 * (1 - 0.4375/0.6875)
+	if est.Confiabilidad == nil || math.Abs(*est.Confiabilidad-esperado) > 1e-9 {
+		t.Errorf("Se esperaba confiabilidad %v. Se obtuvo %v", esperado, est.Confiabilidad)
+	}
+	if est.MetodoConfiabilidad != MetodoKR20 {
+		t.Errorf("Se esperaba el metodo KR-20. Se obtuvo '%s'", est.MetodoConfiabilidad)
+	}
+}
+
+func TestCalcularEstadisticasSinIntentos(t *testing.T) {
+	est := CalcularEstadisticas(1, []Pregunta{{ID: 1, Puntaje: 1}}, nil)
+
+	if est.Promedio != nil || est.Confiabilidad != nil || est.Preguntas[0].Dificultad != nil {
+		t.Errorf("Sin intentos no se esperaban estadisticas. Se obtuvo %+v", est)
+	}
+}
+
+func TestGetEstadisticasExamen(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddIntentos(2, db)
+	utils.AddPreguntas(1, db)
+
+	for i, puntaje := range []float32{1, 0} {
+		r := Respuesta{AlumnoExamenId: i + 1, PreguntaId: 1, Texto: "x", Puntaje: puntaje}
+		if err := r.GuardarRespuesta(db); err != nil {
+			t.Fatalf("No se guardo la respuesta %s", err)
+		}
+	}
+
+	est, err := GetEstadisticasExamen(db, 1)
+	if err != nil {
+		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
+	}
+	if est.Intentos != 2 || len(est.Preguntas) != 1 {
+		t.Fatalf("Se esperaban 2 intentos y una pregunta. Se obtuvo %+v", est)
+	}
+	if *est.Preguntas[0].Dificultad != 0.5 {
+		t.Errorf("Se esperaba dificultad 0.5. Se obtuvo %v", *est.Preguntas[0].Dificultad)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/blackadress/vaula/consulta"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
@@ -13,8 +14,10 @@ type Examen struct {
 	Nombre      string    `json:"nombre"`
 	FechaInicio time.Time `json:"fechaInicio"`
 	FechaFinal  time.Time `json:"fechaFinal"`
+	Duracion    int       `json:"duracion"` // minutos por intento, 0 sin limite
+	Intentos    int       `json:"intentos"` // 0 intentos ilimitados
 	CursoId     int       `json:"cursoId"`
-	Curso       Curso     `json:"curso"`
+	Curso       *Curso    `json:"curso,omitempty"`
 
 	Activo    bool      `json:"activo"`
 	CreatedAt time.Time `json:"createdAt"`
@@ -25,34 +28,53 @@ func (e *Examen) CreateExamen(db *pgxpool.Pool) error {
 	now := time.Now()
 	return db.QueryRow(
 		context.Background(),
-		`INSERT INTO examenes(nombre, fechaInicio, fechaFinal,
-		cursoId, activo, createdAt, updatedAt)
-		VALUES($1, $2, $3, $4, $5, $6, $7)
+		`INSERT INTO examenes(nombre, fechaInicio, fechaFinal, duracion,
+		intentos, cursoId, activo, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id`,
-		e.Nombre, e.FechaInicio, e.FechaFinal, e.CursoId,
-		e.Activo, now, now).Scan(&e.ID)
+		e.Nombre, e.FechaInicio, e.FechaFinal, e.Duracion, e.Intentos,
+		e.CursoId, e.Activo, now, now).Scan(&e.ID)
 }
 
 func (e *Examen) GetExamen(db *pgxpool.Pool) error {
 	return db.QueryRow(
 		context.Background(),
-		`SELECT nombre, fechaInicio, fechaFinal,
+		`SELECT nombre, fechaInicio, fechaFinal, duracion, intentos,
 		cursoId, activo, createdAt, updatedAt
 		FROM examenes
 		WHERE id=$1`,
 		e.ID,
-	).Scan(&e.Nombre, &e.FechaInicio, &e.FechaFinal,
+	).Scan(&e.Nombre, &e.FechaInicio, &e.FechaFinal, &e.Duracion, &e.Intentos,
 		&e.CursoId, &e.Activo, &e.CreatedAt, &e.UpdatedAt)
 }
 
-func GetExamenes(db *pgxpool.Pool) ([]Examen, error) {
+// ListadoExamenes son los filtros y ordenes de GET /examenes
+var ListadoExamenes = consulta.Definicion{
+	Campos: map[string]consulta.Campo{
+		"nombre":      {Columna: "nombre", Tipo: consulta.Texto, Filtro: true, Parcial: true, Orden: true},
+		"cursoId":     {Columna: "cursoId", Tipo: consulta.Entero, Filtro: true, Orden: true},
+		"activo":      {Columna: "activo", Tipo: consulta.Booleano, Filtro: true},
+		"fechaInicio": {Columna: "fechaInicio", Tipo: consulta.Fecha, Orden: true},
+		"fechaFinal":  {Columna: "fechaFinal", Tipo: consulta.Fecha, Orden: true},
+		"createdAt":   {Columna: "createdAt", Tipo: consulta.Fecha, Orden: true},
+	},
+}
+
+func GetExamenes(db *pgxpool.Pool, c consulta.Consulta) ([]Examen, int, error) {
+	total, err := contar(db, "examenes", c)
+	if err != nil {
+		return nil, 0, err
+	}
+	where, args := c.Filtro()
+
 	rows, err := db.Query(
 		context.Background(),
-		`SELECT id, nombre, fechaInicio, fechaFinal, cursoId,
-		activo, createdAt, updatedAt
-		FROM examenes`)
+		`SELECT id, nombre, fechaInicio, fechaFinal, duracion, intentos,
+		cursoId, activo, createdAt, updatedAt
+		FROM examenes`+where+c.OrdenYPagina(),
+		args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -61,16 +83,16 @@ func GetExamenes(db *pgxpool.Pool) ([]Examen, error) {
 	for rows.Next() {
 		var e Examen
 		err := rows.Scan(
-			&e.ID, &e.Nombre, &e.FechaInicio, &e.FechaFinal, &e.CursoId,
-			&e.Activo, &e.CreatedAt, &e.UpdatedAt)
+			&e.ID, &e.Nombre, &e.FechaInicio, &e.FechaFinal, &e.Duracion,
+			&e.Intentos, &e.CursoId, &e.Activo, &e.CreatedAt, &e.UpdatedAt)
 		if err != nil {
 			log.Printf("Las filas obtenidas de la BD para Examen, no satisfacen a 'Scan' %s",
 				err)
-			return nil, err
+			return nil, 0, err
 		}
 		examenes = append(examenes, e)
 	}
-	return examenes, nil
+	return examenes, total, rows.Err()
 }
 
 func (e *Examen) UpdateExamen(db *pgxpool.Pool) error {
@@ -78,8 +100,10 @@ func (e *Examen) UpdateExamen(db *pgxpool.Pool) error {
 	_, err := db.Exec(
 		context.Background(),
 		`UPDATE examenes SET nombre=$1, fechaInicio=$2, fechaFinal=$3,
-		cursoId=$4, activo=$5, updatedAt=$6`,
-		e.Nombre, e.FechaInicio, e.FechaFinal, e.CursoId, e.Activo, updTime)
+		duracion=$4, intentos=$5, cursoId=$6, activo=$7, updatedAt=$8
+		WHERE id=$9`,
+		e.Nombre, e.FechaInicio, e.FechaFinal, e.Duracion, e.Intentos,
+		e.CursoId, e.Activo, updTime, e.ID)
 	return err
 }
 
@@ -90,3 +114,82 @@ func (e *Examen) DeleteExamen(db *pgxpool.Pool) error {
 		e.ID)
 	return err
 }
+
+// CondicionesExamen son la ventana, duracion e intentos que aplican
+// a un alumno en particular, una vez sumada su prorroga
+type CondicionesExamen struct {
+	FechaInicio time.Time `json:"fechaInicio"`
+	FechaFinal  time.Time `json:"fechaFinal"`
+	Duracion    int       `json:"duracion"`
+	Intentos    int       `json:"intentos"`
+}
+
+// CondicionesPara aplica la prorroga 'p' sobre las condiciones generales
+// del examen. Con una prorroga vacia se obtienen las del examen.
+func (e *Examen) CondicionesPara(p Prorroga) CondicionesExamen {
+	c := CondicionesExamen{
+		FechaInicio: e.FechaInicio,
+		FechaFinal:  e.FechaFinal,
+		Duracion:    e.Duracion,
+		Intentos:    e.Intentos,
+	}
+	if !p.Activo {
+		return c
+	}
+	if p.FechaFinal != nil && p.FechaFinal.After(c.FechaFinal) {
+		c.FechaFinal = *p.FechaFinal
+	}
+	// la duracion e intentos ilimitados no se extienden
+	if c.Duracion > 0 {
+		c.Duracion += p.MinutosExtra
+	}
+	if c.Intentos > 0 {
+		c.Intentos += p.IntentosExtra
+	}
+	return c
+}
+
+// FinIntento calcula hasta cuando puede durar un intento que empieza
+// en 'inicio', sin pasar del cierre del examen
+func (c CondicionesExamen) FinIntento(inicio time.Time) time.Time {
+	if c.Duracion <= 0 {
+		return c.FechaFinal
+	}
+	fin := inicio.Add(time.Duration(c.Duracion) * time.Minute)
+	if fin.After(c.FechaFinal) {
+		return c.FechaFinal
+	}
+	return fin
+}
+
+// ImportarExamen crea el examen y sus preguntas en una sola transaccion
+func ImportarExamen(db *pgxpool.Pool, e *Examen, preguntas []Pregunta) error {
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	now := time.Now()
+	err = tx.QueryRow(
+		context.Background(),
+		`INSERT INTO examenes(nombre, fechaInicio, fechaFinal, duracion,
+		intentos, cursoId, activo, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		RETURNING id, createdAt, updatedAt`,
+		e.Nombre, e.FechaInicio, e.FechaFinal, e.Duracion, e.Intentos,
+		e.CursoId, e.Activo, now,
+	).Scan(&e.ID, &e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	for i := range preguntas {
+		preguntas[i].ExamenId = e.ID
+		preguntas[i].BancoId = 0
+		if err := crearPreguntaTx(tx, &preguntas[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(context.Background())
+}
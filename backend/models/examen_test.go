@@ -1,9 +1,11 @@
 package models
 
 import (
+	"net/url"
 	"testing"
 	"time"
 
+	"github.com/blackadress/vaula/consulta"
 	"github.com/blackadress/vaula/utils"
 	"github.com/jackc/pgx/v4"
 )
@@ -59,7 +61,7 @@ func TestNotGetExamen(t *testing.T) {
 func TestGetExamens(t *testing.T) {
 	utils.ClearTableExamen(db)
 	utils.AddExamenes(2, db)
-	examenes, err := GetExamenes(db)
+	examenes, _, err := GetExamenes(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("algo salio mal con la comunicacion con la DB %s", err)
 	}
@@ -73,7 +75,7 @@ func TestGetExamens(t *testing.T) {
 func TestGetZeroExamens(t *testing.T) {
 	utils.ClearTableExamen(db)
 
-	examenes, err := GetExamenes(db)
+	examenes, _, err := GetExamenes(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
 	}
@@ -83,6 +85,39 @@ func TestGetZeroExamens(t *testing.T) {
 	}
 }
 
+func TestGetExamenesFiltrados(t *testing.T) {
+	utils.ClearTableCurso(db)
+	// los examenes 1 y 3 son activos, cada uno en su curso
+	utils.AddExamenes(3, db)
+
+	c, err := ListadoExamenes.Parse(url.Values{
+		"activo":    {"true"},
+		"orden":     {"-cursoId"},
+		"porPagina": {"1"},
+	})
+	if err != nil {
+		t.Fatalf("Error inesperado %s", err)
+	}
+	examenes, total, err := GetExamenes(db, c)
+	if err != nil {
+		t.Fatalf("El metodo GetExamenes fallo %s", err)
+	}
+	if total != 2 || len(examenes) != 1 || examenes[0].ID != 3 {
+		t.Errorf("Se esperaba el examen 3 de 2 en total. Se obtuvo %d, %v", total, examenes)
+	}
+
+	c.Pagina = 2
+	examenes, _, _ = GetExamenes(db, c)
+	if len(examenes) != 1 || examenes[0].ID != 1 {
+		t.Errorf("Se esperaba el examen 1 en la segunda pagina. Se obtuvo %v", examenes)
+	}
+
+	c, _ = ListadoExamenes.Parse(url.Values{"cursoId": {"2"}})
+	if examenes, total, _ := GetExamenes(db, c); total != 1 || examenes[0].ID != 2 {
+		t.Errorf("Se esperaba solo el examen del curso 2. Se obtuvo %v", examenes)
+	}
+}
+
 func TestUpdateExamen(t *testing.T) {
 	utils.ClearTableCurso(db)
 	utils.AddExamenes(1, db)
@@ -0,0 +1,243 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// relaciones que se pueden pedir con ?expand=
+const (
+	ExpandirCurso   = "curso"
+	ExpandirExamen  = "examen"
+	ExpandirUsuario = "usuario"
+)
+
+// Expansion son las relaciones que se cargan junto con la respuesta. Las
+// que no se piden quedan en nil y no aparecen en el JSON.
+type Expansion map[string]bool
+
+// ParseExpansion lee la lista separada por comas de ?expand=. Devuelve
+// error si pide una relacion que el recurso no tiene.
+func ParseExpansion(v string, permitidas ...string) (Expansion, error) {
+	e := Expansion{}
+	if v == "" {
+		return e, nil
+	}
+	for _, rel := range strings.Split(v, ",") {
+		rel = strings.TrimSpace(rel)
+		valida := false
+		for _, p := range permitidas {
+			if rel == p {
+				valida = true
+				break
+			}
+		}
+		if !valida {
+			return e, fmt.Errorf("No se puede expandir '%s'", rel)
+		}
+		e[rel] = true
+	}
+	return e, nil
+}
+
+// cada relacion se carga con una sola consulta para todos los elementos,
+// sin importar cuantos sean
+
+func ExpandirExamenes(db *pgxpool.Pool, examenes []Examen, e Expansion) error {
+	if !e[ExpandirCurso] || len(examenes) == 0 {
+		return nil
+	}
+	ids := make([]int, len(examenes))
+	for i := range examenes {
+		ids[i] = examenes[i].CursoId
+	}
+	cursos, err := cursosPorId(db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range examenes {
+		if c, ok := cursos[examenes[i].CursoId]; ok {
+			examenes[i].Curso = &c
+		}
+	}
+	return nil
+}
+
+// ExpandirPreguntas carga el examen de cada pregunta; con "curso" tambien
+// el curso de ese examen. Las preguntas de un banco no tienen examen.
+func ExpandirPreguntas(db *pgxpool.Pool, preguntas []Pregunta, e Expansion) error {
+	if !e[ExpandirExamen] || len(preguntas) == 0 {
+		return nil
+	}
+	ids := []int{}
+	for _, p := range preguntas {
+		if p.ExamenId != 0 {
+			ids = append(ids, p.ExamenId)
+		}
+	}
+	examenes, err := examenesPorId(db, ids)
+	if err != nil {
+		return err
+	}
+	if err := ExpandirExamenes(db, examenes, e); err != nil {
+		return err
+	}
+	porId := make(map[int]Examen, len(examenes))
+	for _, ex := range examenes {
+		porId[ex.ID] = ex
+	}
+	for i := range preguntas {
+		if ex, ok := porId[preguntas[i].ExamenId]; ok {
+			preguntas[i].Examen = &ex
+		}
+	}
+	return nil
+}
+
+func ExpandirTrabajos(db *pgxpool.Pool, trabajos []Trabajo, e Expansion) error {
+	if !e[ExpandirCurso] || len(trabajos) == 0 {
+		return nil
+	}
+	ids := make([]int, len(trabajos))
+	for i := range trabajos {
+		ids[i] = trabajos[i].CursoId
+	}
+	cursos, err := cursosPorId(db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range trabajos {
+		if c, ok := cursos[trabajos[i].CursoId]; ok {
+			trabajos[i].Curso = &c
+		}
+	}
+	return nil
+}
+
+func ExpandirAlumnos(db *pgxpool.Pool, alumnos []Alumno, e Expansion) error {
+	if !e[ExpandirUsuario] || len(alumnos) == 0 {
+		return nil
+	}
+	ids := make([]int, len(alumnos))
+	for i := range alumnos {
+		ids[i] = alumnos[i].UsuarioId
+	}
+	usuarios, err := usuariosPorId(db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range alumnos {
+		if u, ok := usuarios[alumnos[i].UsuarioId]; ok {
+			alumnos[i].Usuario = &u
+		}
+	}
+	return nil
+}
+
+func ExpandirProfesores(db *pgxpool.Pool, profesores []Profesor, e Expansion) error {
+	if !e[ExpandirUsuario] || len(profesores) == 0 {
+		return nil
+	}
+	ids := make([]int, len(profesores))
+	for i := range profesores {
+		ids[i] = profesores[i].UsuarioId
+	}
+	usuarios, err := usuariosPorId(db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range profesores {
+		if u, ok := usuarios[profesores[i].UsuarioId]; ok {
+			profesores[i].Usuario = &u
+		}
+	}
+	return nil
+}
+
+func cursosPorId(db *pgxpool.Pool, ids []int) (map[int]Curso, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, nombre, siglas, silabo, semestre, activo, createdAt, updatedAt
+		FROM cursos
+		WHERE id = ANY($1)`,
+		ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cursos := map[int]Curso{}
+	for rows.Next() {
+		var c Curso
+		err := rows.Scan(&c.ID, &c.Nombre, &c.Siglas, &c.Silabo, &c.Semestre,
+			&c.Activo, &c.CreatedAt, &c.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Curso, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		cursos[c.ID] = c
+	}
+	return cursos, rows.Err()
+}
+
+func examenesPorId(db *pgxpool.Pool, ids []int) ([]Examen, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, nombre, fechaInicio, fechaFinal, duracion, intentos,
+		cursoId, activo, createdAt, updatedAt
+		FROM examenes
+		WHERE id = ANY($1)`,
+		ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	examenes := []Examen{}
+	for rows.Next() {
+		var e Examen
+		err := rows.Scan(
+			&e.ID, &e.Nombre, &e.FechaInicio, &e.FechaFinal, &e.Duracion,
+			&e.Intentos, &e.CursoId, &e.Activo, &e.CreatedAt, &e.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Examen, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		examenes = append(examenes, e)
+	}
+	return examenes, rows.Err()
+}
+
+// usuariosPorId nunca carga el password
+func usuariosPorId(db *pgxpool.Pool, ids []int) (map[int]User, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, username, email, activo, createdAt, updatedAt
+		FROM usuarios
+		WHERE id = ANY($1)`,
+		ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usuarios := map[int]User{}
+	for rows.Next() {
+		var u User
+		err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Activo,
+			&u.CreatedAt, &u.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para User, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		usuarios[u.ID] = u
+	}
+	return usuarios, rows.Err()
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/blackadress/vaula/consulta"
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestParseExpansion(t *testing.T) {
+	e, err := ParseExpansion("examen, curso", ExpandirExamen, ExpandirCurso)
+	if err != nil || !e[ExpandirExamen] || !e[ExpandirCurso] {
+		t.Errorf("Se esperaban examen y curso. Se obtuvo %v %v", e, err)
+	}
+	if _, err := ParseExpansion("usuario", ExpandirCurso); err == nil {
+		t.Errorf("Se esperaba un error al expandir una relacion inexistente")
+	}
+}
+
+func TestExpandirPreguntas(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddPreguntas(2, db)
+
+	preguntas, _, err := GetPreguntas(db, consulta.Consulta{})
+	if err != nil {
+		t.Fatalf("El metodo GetPreguntas fallo %s", err)
+	}
+	e, _ := ParseExpansion("examen,curso", ExpandirExamen, ExpandirCurso)
+	if err := ExpandirPreguntas(db, preguntas, e); err != nil {
+		t.Fatalf("El metodo ExpandirPreguntas fallo %s", err)
+	}
+	for _, p := range preguntas {
+		if p.Examen == nil || p.Examen.ID != p.ExamenId {
+			t.Fatalf("Se esperaba el examen %d expandido. Se obtuvo %v", p.ExamenId, p.Examen)
+		}
+		if p.Examen.Curso == nil || p.Examen.Curso.ID != p.Examen.CursoId {
+			t.Errorf("Se esperaba el curso %d expandido. Se obtuvo %v",
+				p.Examen.CursoId, p.Examen.Curso)
+		}
+	}
+
+	preguntas, _, _ = GetPreguntas(db, consulta.Consulta{})
+	ExpandirPreguntas(db, preguntas, Expansion{})
+	if preguntas[0].Examen != nil {
+		t.Errorf("Sin expand el examen deberia quedar en nil. Se obtuvo %v", preguntas[0].Examen)
+	}
+}
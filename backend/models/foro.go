@@ -0,0 +1,315 @@
+package models
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Foro agrupa hilos de discusion de un curso
+type Foro struct {
+	ID          int    `json:"id"`
+	CursoId     int    `json:"cursoId"`
+	Titulo      string `json:"titulo"`
+	Descripcion string `json:"descripcion"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Hilo es una discusion dentro de un foro. En un hilo bloqueado solo los
+// profesores pueden publicar. NoLeidas cuenta las publicaciones que el
+// usuario que consulta aun no leyo.
+type Hilo struct {
+	ID              int       `json:"id"`
+	ForoId          int       `json:"foroId"`
+	CursoId         int       `json:"cursoId"`
+	AutorId         int       `json:"autorId"`
+	Titulo          string    `json:"titulo"`
+	Fijado          bool      `json:"fijado"`
+	Bloqueado       bool      `json:"bloqueado"`
+	UltimaActividad time.Time `json:"ultimaActividad"`
+	NoLeidas        int       `json:"noLeidas"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Publicacion es un mensaje de un hilo; la primera es la que abre el hilo
+type Publicacion struct {
+	ID      int    `json:"id"`
+	HiloId  int    `json:"hiloId"`
+	AutorId int    `json:"autorId"`
+	Cuerpo  string `json:"cuerpo"`
+	Editado bool   `json:"editado"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (f *Foro) CreateForo(db *pgxpool.Pool) error {
+	now := time.Now()
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO foros(cursoId, titulo, descripcion, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $4)
+		RETURNING id, createdAt, updatedAt`,
+		f.CursoId, f.Titulo, f.Descripcion, now,
+	).Scan(&f.ID, &f.CreatedAt, &f.UpdatedAt)
+}
+
+func (f *Foro) GetForo(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT cursoId, titulo, descripcion, createdAt, updatedAt
+		FROM foros
+		WHERE id=$1`,
+		f.ID).Scan(&f.CursoId, &f.Titulo, &f.Descripcion, &f.CreatedAt, &f.UpdatedAt)
+}
+
+func GetForosCurso(db *pgxpool.Pool, cursoId int) ([]Foro, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, cursoId, titulo, descripcion, createdAt, updatedAt
+		FROM foros
+		WHERE cursoId=$1
+		ORDER BY id`,
+		cursoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	foros := []Foro{}
+	for rows.Next() {
+		var f Foro
+		err := rows.Scan(&f.ID, &f.CursoId, &f.Titulo, &f.Descripcion,
+			&f.CreatedAt, &f.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Foro, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		foros = append(foros, f)
+	}
+	return foros, nil
+}
+
+func (f *Foro) DeleteForo(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`DELETE FROM foros WHERE id=$1`,
+		f.ID)
+	return err
+}
+
+// CreateHilo crea el hilo junto con su primera publicacion
+func (h *Hilo) CreateHilo(db *pgxpool.Pool, primera *Publicacion) error {
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	now := time.Now()
+	err = tx.QueryRow(
+		context.Background(),
+		`INSERT INTO hilos(foroId, autorId, titulo, fijado, bloqueado,
+		ultimaActividad, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $5, $6, $6, $6)
+		RETURNING id, ultimaActividad, createdAt, updatedAt`,
+		h.ForoId, h.AutorId, h.Titulo, h.Fijado, h.Bloqueado, now,
+	).Scan(&h.ID, &h.UltimaActividad, &h.CreatedAt, &h.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	primera.HiloId = h.ID
+	primera.AutorId = h.AutorId
+	err = tx.QueryRow(
+		context.Background(),
+		`INSERT INTO publicaciones(hiloId, autorId, cuerpo, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $4)
+		RETURNING id, createdAt, updatedAt`,
+		primera.HiloId, primera.AutorId, primera.Cuerpo, now,
+	).Scan(&primera.ID, &primera.CreatedAt, &primera.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+// GetHilo carga el hilo con el curso de su foro
+func (h *Hilo) GetHilo(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT h.foroId, f.cursoId, h.autorId, h.titulo, h.fijado, h.bloqueado,
+		h.ultimaActividad, h.createdAt, h.updatedAt
+		FROM hilos h
+		JOIN foros f ON f.id = h.foroId
+		WHERE h.id=$1`,
+		h.ID).Scan(&h.ForoId, &h.CursoId, &h.AutorId, &h.Titulo, &h.Fijado,
+		&h.Bloqueado, &h.UltimaActividad, &h.CreatedAt, &h.UpdatedAt)
+}
+
+// GetHilosForo lista los hilos del foro, primero los fijados y luego los
+// de actividad mas reciente, con las publicaciones que el usuario no leyo
+func GetHilosForo(db *pgxpool.Pool, foroId, usuarioId int) ([]Hilo, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT h.id, h.foroId, f.cursoId, h.autorId, h.titulo, h.fijado,
+		h.bloqueado, h.ultimaActividad,
+		(SELECT COUNT(*) FROM publicaciones p
+			WHERE p.hiloId = h.id AND (l.leidoEn IS NULL OR p.createdAt > l.leidoEn)),
+		h.createdAt, h.updatedAt
+		FROM hilos h
+		JOIN foros f ON f.id = h.foroId
+		LEFT JOIN lecturas l ON l.tipo='hilo' AND l.referenciaId = h.id
+			AND l.usuarioId=$2
+		WHERE h.foroId=$1
+		ORDER BY h.fijado DESC, h.ultimaActividad DESC, h.id DESC`,
+		foroId, usuarioId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hilos := []Hilo{}
+	for rows.Next() {
+		var h Hilo
+		err := rows.Scan(&h.ID, &h.ForoId, &h.CursoId, &h.AutorId, &h.Titulo,
+			&h.Fijado, &h.Bloqueado, &h.UltimaActividad, &h.NoLeidas,
+			&h.CreatedAt, &h.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Hilo, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		hilos = append(hilos, h)
+	}
+	return hilos, nil
+}
+
+// ModerarHilo cambia el titulo, si esta fijado y si esta bloqueado
+func (h *Hilo) ModerarHilo(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`UPDATE hilos SET titulo=$1, fijado=$2, bloqueado=$3, updatedAt=$4
+		WHERE id=$5
+		RETURNING foroId, autorId, ultimaActividad, createdAt, updatedAt`,
+		h.Titulo, h.Fijado, h.Bloqueado, time.Now(), h.ID,
+	).Scan(&h.ForoId, &h.AutorId, &h.UltimaActividad, &h.CreatedAt, &h.UpdatedAt)
+}
+
+// DeleteHilo elimina el hilo con sus publicaciones, su historial y lecturas
+func (h *Hilo) DeleteHilo(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`WITH e AS (
+			DELETE FROM ediciones WHERE tipo='publicacion' AND referenciaId IN (
+				SELECT id FROM publicaciones WHERE hiloId=$1)),
+		l AS (
+			DELETE FROM lecturas WHERE tipo='hilo' AND referenciaId=$1)
+		DELETE FROM hilos WHERE id=$1`,
+		h.ID)
+	return err
+}
+
+// CreatePublicacion agrega la publicacion y actualiza la actividad del hilo
+func (p *Publicacion) CreatePublicacion(db *pgxpool.Pool) error {
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	now := time.Now()
+	err = tx.QueryRow(
+		context.Background(),
+		`INSERT INTO publicaciones(hiloId, autorId, cuerpo, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $4)
+		RETURNING id, createdAt, updatedAt`,
+		p.HiloId, p.AutorId, p.Cuerpo, now,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		context.Background(),
+		`UPDATE hilos SET ultimaActividad=$1 WHERE id=$2`,
+		now, p.HiloId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+func (p *Publicacion) GetPublicacion(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT hiloId, autorId, cuerpo, editado, createdAt, updatedAt
+		FROM publicaciones
+		WHERE id=$1`,
+		p.ID).Scan(&p.HiloId, &p.AutorId, &p.Cuerpo, &p.Editado,
+		&p.CreatedAt, &p.UpdatedAt)
+}
+
+func GetPublicacionesHilo(db *pgxpool.Pool, hiloId int) ([]Publicacion, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, hiloId, autorId, cuerpo, editado, createdAt, updatedAt
+		FROM publicaciones
+		WHERE hiloId=$1
+		ORDER BY createdAt, id`,
+		hiloId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	publicaciones := []Publicacion{}
+	for rows.Next() {
+		var p Publicacion
+		err := rows.Scan(&p.ID, &p.HiloId, &p.AutorId, &p.Cuerpo, &p.Editado,
+			&p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Publicacion, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		publicaciones = append(publicaciones, p)
+	}
+	return publicaciones, nil
+}
+
+// EditarPublicacion reemplaza el cuerpo y guarda el anterior en el historial
+func (p *Publicacion) EditarPublicacion(db *pgxpool.Pool, usuarioId int) error {
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	now := time.Now()
+	_, err = tx.Exec(
+		context.Background(),
+		`INSERT INTO ediciones(tipo, referenciaId, usuarioId, cuerpoAnterior, createdAt)
+		SELECT $1, id, $2, cuerpo, $3 FROM publicaciones WHERE id=$4`,
+		TipoPublicacion, usuarioId, now, p.ID)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(
+		context.Background(),
+		`UPDATE publicaciones SET cuerpo=$1, editado=true, updatedAt=$2
+		WHERE id=$3
+		RETURNING hiloId, autorId, editado, createdAt, updatedAt`,
+		p.Cuerpo, now, p.ID,
+	).Scan(&p.HiloId, &p.AutorId, &p.Editado, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
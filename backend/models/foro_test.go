@@ -0,0 +1,117 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestRolEnCurso(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(1, db)
+	usuario := User{Username: "profe", Password: "profe", Email: "profe@test.ts", Activo: true}
+	usuario.CreateUser(db)
+	profesor := Profesor{Nombres: "profe", Apellidos: "profe", UsuarioId: usuario.ID, Activo: true}
+	if err := profesor.CreateProfesor(db); err != nil {
+		t.Fatalf("No se creo el profesor %s", err)
+	}
+
+	pc := ProfesorCurso{ProfesorId: profesor.ID, CursoId: 1}
+	if err := pc.CreateProfesorCurso(db); err != nil {
+		t.Fatalf("No se asigno el profesor %s", err)
+	}
+	// asignarlo de nuevo no falla
+	if err := pc.CreateProfesorCurso(db); err != nil {
+		t.Errorf("Se esperaba que reasignar al profesor no falle. Se obtuvo %s", err)
+	}
+
+	alumno := Alumno{ID: 1}
+	alumno.GetAlumno(db)
+
+	casos := []struct {
+		usuarioId, cursoId int
+		rol                string
+	}{
+		{profesor.UsuarioId, 1, RolProfesor},
+		{alumno.UsuarioId, 1, RolAlumno},
+		{alumno.UsuarioId, 2, ""},
+	}
+	for _, c := range casos {
+		rol, err := RolEnCurso(db, c.usuarioId, c.cursoId)
+		if err != nil {
+			t.Fatalf("El metodo RolEnCurso fallo %s", err)
+		}
+		if rol != c.rol {
+			t.Errorf("Usuario %d en curso %d: se esperaba '%s'. Se obtuvo '%s'",
+				c.usuarioId, c.cursoId, c.rol, rol)
+		}
+	}
+}
+
+func TestHiloNoLeidas(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.ClearTableUsuario(db)
+	utils.AddUsers(2, db)
+	utils.AddCursos(1, db)
+
+	foro := Foro{CursoId: 1, Titulo: "Consultas"}
+	if err := foro.CreateForo(db); err != nil {
+		t.Fatalf("No se creo el foro %s", err)
+	}
+	hilo := Hilo{ForoId: foro.ID, AutorId: 1, Titulo: "Duda del trabajo 1"}
+	primera := Publicacion{Cuerpo: "Hasta cuando se entrega?"}
+	if err := hilo.CreateHilo(db, &primera); err != nil {
+		t.Fatalf("No se creo el hilo %s", err)
+	}
+	if primera.HiloId != hilo.ID || primera.AutorId != 1 {
+		t.Errorf("Se esperaba la primera publicacion del hilo. Se obtuvo %+v", primera)
+	}
+
+	MarcarLeido(db, 2, TipoHilo, hilo.ID)
+	respuesta := Publicacion{HiloId: hilo.ID, AutorId: 1, Cuerpo: "Hasta el viernes"}
+	if err := respuesta.CreatePublicacion(db); err != nil {
+		t.Fatalf("No se creo la publicacion %s", err)
+	}
+
+	hilos, err := GetHilosForo(db, foro.ID, 2)
+	if err != nil {
+		t.Fatalf("El metodo GetHilosForo fallo %s", err)
+	}
+	if len(hilos) != 1 || hilos[0].NoLeidas != 1 || hilos[0].CursoId != 1 {
+		t.Errorf("Se esperaba 1 publicacion sin leer. Se obtuvo %+v", hilos)
+	}
+}
+
+func TestEditarPublicacion(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.ClearTableUsuario(db)
+	utils.AddUsers(1, db)
+	utils.AddCursos(1, db)
+
+	foro := Foro{CursoId: 1, Titulo: "General"}
+	foro.CreateForo(db)
+	hilo := Hilo{ForoId: foro.ID, AutorId: 1, Titulo: "Presentaciones"}
+	primera := Publicacion{Cuerpo: "Hola a todos"}
+	hilo.CreateHilo(db, &primera)
+
+	primera.Cuerpo = "Hola a todos, soy el delegado"
+	if err := primera.EditarPublicacion(db, 1); err != nil {
+		t.Fatalf("El metodo EditarPublicacion fallo %s", err)
+	}
+	if !primera.Editado {
+		t.Errorf("Se esperaba la publicacion marcada como editada")
+	}
+
+	ediciones, _ := GetEdiciones(db, TipoPublicacion, primera.ID)
+	if len(ediciones) != 1 || ediciones[0].CuerpoAnterior != "Hola a todos" {
+		t.Errorf("Se esperaba el cuerpo anterior en el historial. Se obtuvo %v", ediciones)
+	}
+
+	if err := hilo.DeleteHilo(db); err != nil {
+		t.Fatalf("El metodo DeleteHilo fallo %s", err)
+	}
+	ediciones, _ = GetEdiciones(db, TipoPublicacion, primera.ID)
+	if len(ediciones) != 0 {
+		t.Errorf("Se esperaba borrar el historial con el hilo. Se obtuvo %v", ediciones)
+	}
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// FilaImportacion es una fila del CSV de alumnos. Errores junta los
+// problemas de validacion; basta una fila con errores para que no se
+// importe ninguna.
+type FilaImportacion struct {
+	Fila      int      `json:"fila"`
+	Codigo    string   `json:"codigo"`
+	Apellidos string   `json:"apellidos"`
+	Nombres   string   `json:"nombres"`
+	Email     string   `json:"email"`
+	Errores   []string `json:"errores"`
+	Password  string   `json:"-"` // hash de la password inicial
+	// la password inicial en claro, solo en el reporte de la importacion
+	PasswordInicial string `json:"passwordInicial,omitempty"`
+	UsuarioId       int    `json:"usuarioId"`
+	AlumnoId        int    `json:"alumnoId"`
+}
+
+func (f *FilaImportacion) Valida() bool {
+	return len(f.Errores) == 0
+}
+
+// ValidarExistentes agrega un error a las filas cuyo codigo ya es de un
+// alumno o cuyo username (el codigo) o email ya es de un usuario
+func ValidarExistentes(db *pgxpool.Pool, filas []FilaImportacion) error {
+	codigos := make([]string, len(filas))
+	emails := make([]string, len(filas))
+	for i, f := range filas {
+		codigos[i] = f.Codigo
+		emails[i] = f.Email
+	}
+
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT 'codigo', TRIM(codigo) FROM alumnos WHERE TRIM(codigo) = ANY($1)
+		UNION
+		SELECT 'codigo', username FROM usuarios WHERE username = ANY($1)
+		UNION
+		SELECT 'email', LOWER(email) FROM usuarios WHERE LOWER(email) = ANY($2)`,
+		codigos, emails)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existentes := map[string]map[string]bool{"codigo": {}, "email": {}}
+	for rows.Next() {
+		var campo, valor string
+		if err := rows.Scan(&campo, &valor); err != nil {
+			return err
+		}
+		existentes[campo][valor] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range filas {
+		if existentes["codigo"][filas[i].Codigo] {
+			filas[i].Errores = append(filas[i].Errores, "El codigo ya esta registrado")
+		}
+		if existentes["email"][filas[i].Email] {
+			filas[i].Errores = append(filas[i].Errores, "El email ya esta registrado")
+		}
+	}
+	return nil
+}
+
+// ImportarAlumnos crea el usuario y el alumno de cada fila, y si cursoId no
+// es 0 los matricula en el curso. Todo se hace en una transaccion: si una
+// fila falla no se crea ninguna.
+func ImportarAlumnos(db *pgxpool.Pool, filas []FilaImportacion, cursoId int) error {
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	now := time.Now()
+	for i := range filas {
+		f := &filas[i]
+		err := tx.QueryRow(
+			context.Background(),
+			`INSERT INTO usuarios(username, password, email, activo, createdAt, updatedAt)
+			VALUES($1, $2, $3, true, $4, $4)
+			RETURNING id`,
+			f.Codigo, f.Password, f.Email, now,
+		).Scan(&f.UsuarioId)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(
+			context.Background(),
+			`INSERT INTO alumnos(nombres, apellidos, codigo, usuarioId, activo, createdAt, updatedAt)
+			VALUES($1, $2, $3, $4, true, $5, $5)
+			RETURNING id`,
+			f.Nombres, f.Apellidos, f.Codigo, f.UsuarioId, now,
+		).Scan(&f.AlumnoId)
+		if err != nil {
+			return err
+		}
+
+		if cursoId != 0 {
+			_, err = tx.Exec(
+				context.Background(),
+				`INSERT INTO alumnoCurso(fechaInicio, fechaFinal, alumnoId, cursoId,
+				activo, createdAt, updatedAt)
+				VALUES($1, $1, $2, $3, true, $1, $1)`,
+				now, f.AlumnoId, cursoId)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit(context.Background())
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestValidarExistentes(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnos(1, db)
+
+	filas := []FilaImportacion{
+		{Fila: 1, Codigo: "00000000", Email: "nuevo@test.ts", Errores: []string{}},
+		{Fila: 2, Codigo: "20210001", Email: "em0@test.ts", Errores: []string{}},
+		{Fila: 3, Codigo: "20210002", Email: "otro@test.ts", Errores: []string{}},
+	}
+	if err := ValidarExistentes(db, filas); err != nil {
+		t.Fatalf("Error inesperado en ValidarExistentes %s", err)
+	}
+	if filas[0].Valida() || filas[1].Valida() || !filas[2].Valida() {
+		t.Errorf("Se esperaban errores solo en las filas 1 y 2. Se obtuvo %v", filas)
+	}
+}
+
+func TestImportarAlumnos(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.ClearTableUsuario(db)
+	utils.AddCursos(1, db)
+
+	filas := []FilaImportacion{
+		{Codigo: "20210001", Apellidos: "Quispe", Nombres: "Ana", Email: "ana@test.ts", Password: "x"},
+		{Codigo: "20210002", Apellidos: "Mamani", Nombres: "Luis", Email: "luis@test.ts", Password: "x"},
+	}
+	if err := ImportarAlumnos(db, filas, 1); err != nil {
+		t.Fatalf("No se importaron los alumnos %s", err)
+	}
+	if filas[1].AlumnoId == 0 || filas[1].UsuarioId == 0 {
+		t.Errorf("Se esperaba que las filas tengan los IDs creados. Se obtuvo %v", filas[1])
+	}
+
+	alumnos, err := GetAlumnosCurso(db, 1)
+	if err != nil {
+		t.Fatalf("Error inesperado en GetAlumnosCurso %s", err)
+	}
+	if len(alumnos) != 2 || alumnos[0].Apellidos != "Mamani" {
+		t.Errorf("Se esperaban 2 alumnos matriculados ordenados por apellidos. Se obtuvo %v", alumnos)
+	}
+}
@@ -0,0 +1,546 @@
+package models
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// tipos de categoria de la libreta de notas. Los examenes y trabajos del
+// curso entran solos en su categoria; las manuales (participacion, etc)
+// tienen evaluaciones que el profesor crea y califica a mano. La de
+// asistencia tiene una sola columna con el porcentaje llevado a nota.
+const (
+	CategoriaExamen     = "examen"
+	CategoriaTrabajo    = "trabajo"
+	CategoriaManual     = "manual"
+	CategoriaAsistencia = "asistencia"
+)
+
+// tipos de celda manual. Una celda CeldaEvaluacion es la nota de una
+// evaluacion manual; las demas reemplazan el valor calculado de esa celda.
+const (
+	CeldaExamen     = "examen"
+	CeldaTrabajo    = "trabajo"
+	CeldaEvaluacion = "manual"
+	CeldaCategoria  = "categoria"
+	CeldaFinal      = "final"
+	CeldaAsistencia = "asistencia" // referenciaId es la categoria
+)
+
+// NotaAprobatoria es la nota minima aprobatoria en la escala vigesimal
+const NotaAprobatoria = 11
+
+// CategoriaCalificacion agrupa evaluaciones del curso con un peso en
+// porcentaje sobre la nota final
+type CategoriaCalificacion struct {
+	ID               int     `json:"id"`
+	CursoId          int     `json:"cursoId"`
+	Nombre           string  `json:"nombre"`
+	Tipo             string  `json:"tipo"`
+	Peso             float32 `json:"peso"`
+	DescartarMenores int     `json:"descartarMenores"` // cuantas notas mas bajas no cuentan
+	Orden            int     `json:"orden"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type EvaluacionManual struct {
+	ID          int       `json:"id"`
+	CategoriaId int       `json:"categoriaId"`
+	Nombre      string    `json:"nombre"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// CeldaManual es una nota puesta a mano por el profesor en la libreta
+type CeldaManual struct {
+	ID           int       `json:"id"`
+	CursoId      int       `json:"cursoId"`
+	AlumnoId     int       `json:"alumnoId"`
+	Tipo         string    `json:"tipo"`
+	ReferenciaId int       `json:"referenciaId"` // 0 para la nota final
+	Calificacion float32   `json:"calificacion"`
+	Motivo       string    `json:"motivo"`
+	UsuarioId    int       `json:"usuarioId"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+func (c *CategoriaCalificacion) CreateCategoria(db *pgxpool.Pool) error {
+	now := time.Now()
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO categoriasCalificacion(cursoId, nombre, tipo, peso,
+		descartarMenores, orden, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, createdAt, updatedAt`,
+		c.CursoId, c.Nombre, c.Tipo, c.Peso, c.DescartarMenores, c.Orden, now, now,
+	).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+}
+
+func (c *CategoriaCalificacion) GetCategoria(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT cursoId, nombre, tipo, peso, descartarMenores, orden,
+		createdAt, updatedAt
+		FROM categoriasCalificacion
+		WHERE id=$1`,
+		c.ID).Scan(&c.CursoId, &c.Nombre, &c.Tipo, &c.Peso,
+		&c.DescartarMenores, &c.Orden, &c.CreatedAt, &c.UpdatedAt)
+}
+
+func GetCategoriasCurso(db *pgxpool.Pool, cursoId int) ([]CategoriaCalificacion, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, cursoId, nombre, tipo, peso, descartarMenores, orden,
+		createdAt, updatedAt
+		FROM categoriasCalificacion
+		WHERE cursoId=$1
+		ORDER BY orden, id`,
+		cursoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categorias := []CategoriaCalificacion{}
+	for rows.Next() {
+		var c CategoriaCalificacion
+		err := rows.Scan(&c.ID, &c.CursoId, &c.Nombre, &c.Tipo, &c.Peso,
+			&c.DescartarMenores, &c.Orden, &c.CreatedAt, &c.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para CategoriaCalificacion, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		categorias = append(categorias, c)
+	}
+	return categorias, nil
+}
+
+// UpdateCategoria no permite cambiar el curso ni el tipo de la categoria
+func (c *CategoriaCalificacion) UpdateCategoria(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`UPDATE categoriasCalificacion SET nombre=$1, peso=$2,
+		descartarMenores=$3, orden=$4, updatedAt=$5
+		WHERE id=$6
+		RETURNING cursoId, tipo, createdAt, updatedAt`,
+		c.Nombre, c.Peso, c.DescartarMenores, c.Orden, time.Now(), c.ID,
+	).Scan(&c.CursoId, &c.Tipo, &c.CreatedAt, &c.UpdatedAt)
+}
+
+func (c *CategoriaCalificacion) DeleteCategoria(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`DELETE FROM categoriasCalificacion WHERE id=$1`,
+		c.ID)
+	return err
+}
+
+func (e *EvaluacionManual) CreateEvaluacionManual(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO evaluacionesManuales(categoriaId, nombre, createdAt)
+		VALUES($1, $2, $3)
+		RETURNING id, createdAt`,
+		e.CategoriaId, e.Nombre, time.Now(),
+	).Scan(&e.ID, &e.CreatedAt)
+}
+
+func (e *EvaluacionManual) DeleteEvaluacionManual(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`DELETE FROM evaluacionesManuales WHERE id=$1`,
+		e.ID)
+	return err
+}
+
+// GuardarCeldaManual crea o reemplaza la celda del alumno
+func (c *CeldaManual) GuardarCeldaManual(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO celdasManuales(cursoId, alumnoId, tipo, referenciaId,
+		calificacion, motivo, usuarioId, updatedAt)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (cursoId, alumnoId, tipo, referenciaId)
+		DO UPDATE SET calificacion=EXCLUDED.calificacion, motivo=EXCLUDED.motivo,
+		usuarioId=EXCLUDED.usuarioId, updatedAt=EXCLUDED.updatedAt
+		RETURNING id, updatedAt`,
+		c.CursoId, c.AlumnoId, c.Tipo, c.ReferenciaId, c.Calificacion,
+		c.Motivo, c.UsuarioId, time.Now(),
+	).Scan(&c.ID, &c.UpdatedAt)
+}
+
+func (c *CeldaManual) GetCeldaManual(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT cursoId, alumnoId, tipo, referenciaId, calificacion, motivo,
+		usuarioId, updatedAt
+		FROM celdasManuales
+		WHERE id=$1`,
+		c.ID).Scan(&c.CursoId, &c.AlumnoId, &c.Tipo, &c.ReferenciaId,
+		&c.Calificacion, &c.Motivo, &c.UsuarioId, &c.UpdatedAt)
+}
+
+func GetCeldasManualesCurso(db *pgxpool.Pool, cursoId int) ([]CeldaManual, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, cursoId, alumnoId, tipo, referenciaId, calificacion,
+		motivo, usuarioId, updatedAt
+		FROM celdasManuales
+		WHERE cursoId=$1
+		ORDER BY alumnoId, tipo, referenciaId`,
+		cursoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	celdas := []CeldaManual{}
+	for rows.Next() {
+		var c CeldaManual
+		err := rows.Scan(&c.ID, &c.CursoId, &c.AlumnoId, &c.Tipo, &c.ReferenciaId,
+			&c.Calificacion, &c.Motivo, &c.UsuarioId, &c.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para CeldaManual, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		celdas = append(celdas, c)
+	}
+	return celdas, nil
+}
+
+func (c *CeldaManual) DeleteCeldaManual(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`DELETE FROM celdasManuales WHERE id=$1`,
+		c.ID)
+	return err
+}
+
+// EvaluacionLibreta es una columna de la libreta
+type EvaluacionLibreta struct {
+	Tipo   string `json:"tipo"` // CeldaExamen, CeldaTrabajo, CeldaEvaluacion o CeldaAsistencia
+	ID     int    `json:"id"`
+	Nombre string `json:"nombre"`
+	// una evaluacion vencida sin nota cuenta como 0, una que aun no
+	// vence no se considera en el promedio
+	Vencida bool `json:"vencida"`
+}
+
+type ColumnaCategoria struct {
+	CategoriaCalificacion
+	Evaluaciones []EvaluacionLibreta `json:"evaluaciones"`
+}
+
+type CeldaLibreta struct {
+	Tipo         string   `json:"tipo"`
+	ReferenciaId int      `json:"referenciaId"`
+	Calificacion *float32 `json:"calificacion"`
+	Ajustada     bool     `json:"ajustada"`
+	Descartada   bool     `json:"descartada"`
+}
+
+type NotaCategoria struct {
+	CategoriaId  int            `json:"categoriaId"`
+	Calificacion *float32       `json:"calificacion"`
+	Ajustada     bool           `json:"ajustada"`
+	Celdas       []CeldaLibreta `json:"celdas"`
+}
+
+type FilaLibreta struct {
+	Alumno     Alumno          `json:"alumno"`
+	Categorias []NotaCategoria `json:"categorias"`
+	Final      float32         `json:"final"`
+	Redondeado int             `json:"redondeado"`
+	Aprobado   bool            `json:"aprobado"`
+	Ajustada   bool            `json:"ajustada"`
+}
+
+type Libreta struct {
+	CursoId    int                `json:"cursoId"`
+	Categorias []ColumnaCategoria `json:"categorias"`
+	Filas      []FilaLibreta      `json:"filas"`
+}
+
+// ClaveCelda identifica una celda de la libreta
+type ClaveCelda struct {
+	AlumnoId     int
+	Tipo         string
+	ReferenciaId int
+}
+
+// CalcularLibreta arma la matriz de notas. 'notas' son las calificaciones
+// obtenidas y 'ajustes' las celdas que el profesor reemplazo a mano.
+// El promedio de cada categoria descarta sus notas mas bajas y la nota
+// final pondera las categorias que tienen notas, normalizando los pesos.
+func CalcularLibreta(cursoId int, columnas []ColumnaCategoria, alumnos []Alumno,
+	notas, ajustes map[ClaveCelda]float32) Libreta {
+
+	libreta := Libreta{CursoId: cursoId, Categorias: columnas, Filas: []FilaLibreta{}}
+	for _, alumno := range alumnos {
+		fila := FilaLibreta{Alumno: alumno, Categorias: []NotaCategoria{}}
+		var suma, pesos float64
+
+		for _, columna := range columnas {
+			nc := calcularCategoria(alumno.ID, columna, notas, ajustes)
+			fila.Categorias = append(fila.Categorias, nc)
+			if nc.Calificacion != nil {
+				suma += float64(*nc.Calificacion) * float64(columna.Peso)
+				pesos += float64(columna.Peso)
+			}
+		}
+
+		if pesos > 0 {
+			fila.Final = redondear(suma / pesos)
+		}
+		if v, ok := ajustes[ClaveCelda{alumno.ID, CeldaFinal, 0}]; ok {
+			fila.Final = v
+			fila.Ajustada = true
+		}
+		fila.Redondeado = RedondearVigesimal(fila.Final)
+		fila.Aprobado = fila.Redondeado >= NotaAprobatoria
+		libreta.Filas = append(libreta.Filas, fila)
+	}
+	return libreta
+}
+
+func calcularCategoria(alumnoId int, columna ColumnaCategoria,
+	notas, ajustes map[ClaveCelda]float32) NotaCategoria {
+
+	nc := NotaCategoria{CategoriaId: columna.ID, Celdas: []CeldaLibreta{}}
+	// indices de las celdas que entran al promedio
+	cuentan := []int{}
+	for _, ev := range columna.Evaluaciones {
+		clave := ClaveCelda{alumnoId, ev.Tipo, ev.ID}
+		celda := CeldaLibreta{Tipo: ev.Tipo, ReferenciaId: ev.ID}
+		if v, ok := ajustes[clave]; ok {
+			celda.Calificacion = &v
+			celda.Ajustada = true
+		} else if v, ok := notas[clave]; ok {
+			celda.Calificacion = &v
+		}
+		if celda.Calificacion != nil || ev.Vencida {
+			cuentan = append(cuentan, len(nc.Celdas))
+		}
+		nc.Celdas = append(nc.Celdas, celda)
+	}
+
+	valor := func(i int) float32 {
+		if nc.Celdas[i].Calificacion == nil {
+			return 0
+		}
+		return *nc.Celdas[i].Calificacion
+	}
+
+	// siempre queda al menos una nota en el promedio
+	descartar := columna.DescartarMenores
+	if descartar > len(cuentan)-1 {
+		descartar = len(cuentan) - 1
+	}
+	if descartar > 0 {
+		sort.SliceStable(cuentan, func(a, b int) bool {
+			return valor(cuentan[a]) < valor(cuentan[b])
+		})
+		for _, i := range cuentan[:descartar] {
+			nc.Celdas[i].Descartada = true
+		}
+		cuentan = cuentan[descartar:]
+	}
+
+	if len(cuentan) > 0 {
+		var suma float64
+		for _, i := range cuentan {
+			suma += float64(valor(i))
+		}
+		promedio := redondear(suma / float64(len(cuentan)))
+		nc.Calificacion = &promedio
+	}
+	if v, ok := ajustes[ClaveCelda{alumnoId, CeldaCategoria, columna.ID}]; ok {
+		nc.Calificacion = &v
+		nc.Ajustada = true
+	}
+	return nc
+}
+
+func redondear(v float64) float32 {
+	return float32(math.Round(v*100) / 100)
+}
+
+// RedondearVigesimal redondea la nota al entero, con 0.5 a favor del
+// alumno (10.5 es 11)
+func RedondearVigesimal(nota float32) int {
+	return int(math.Floor(float64(nota) + 0.5))
+}
+
+// GetColumnasLibreta arma las columnas de la libreta del curso: cada
+// categoria con sus examenes, trabajos o evaluaciones manuales
+func GetColumnasLibreta(db *pgxpool.Pool, cursoId int) ([]ColumnaCategoria, error) {
+	categorias, err := GetCategoriasCurso(db, cursoId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	columnas := []ColumnaCategoria{}
+	for _, c := range categorias {
+		if c.Tipo == CategoriaAsistencia {
+			// sin sesiones registradas el alumno no tiene nota y la
+			// columna no cuenta en el promedio
+			columnas = append(columnas, ColumnaCategoria{
+				CategoriaCalificacion: c,
+				Evaluaciones: []EvaluacionLibreta{
+					{Tipo: CeldaAsistencia, ID: c.ID, Nombre: c.Nombre},
+				},
+			})
+			continue
+		}
+
+		var rows pgx.Rows
+		switch c.Tipo {
+		case CategoriaExamen:
+			rows, err = db.Query(context.Background(),
+				`SELECT 'examen', id, nombre, fechaFinal < $2
+				FROM examenes WHERE cursoId=$1 ORDER BY fechaInicio, id`,
+				cursoId, now)
+		case CategoriaTrabajo:
+			rows, err = db.Query(context.Background(),
+				`SELECT 'trabajo', id, descripcion, fechaFinal < $2
+				FROM trabajos WHERE cursoId=$1 ORDER BY fechaInicio, id`,
+				cursoId, now)
+		default:
+			rows, err = db.Query(context.Background(),
+				`SELECT 'manual', id, nombre, true
+				FROM evaluacionesManuales WHERE categoriaId=$1 ORDER BY id`,
+				c.ID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		columna := ColumnaCategoria{CategoriaCalificacion: c, Evaluaciones: []EvaluacionLibreta{}}
+		for rows.Next() {
+			var ev EvaluacionLibreta
+			if err := rows.Scan(&ev.Tipo, &ev.ID, &ev.Nombre, &ev.Vencida); err != nil {
+				rows.Close()
+				log.Printf("Las filas obtenidas de la BD para EvaluacionLibreta, no satisfacen a 'Scan' %s",
+					err)
+				return nil, err
+			}
+			columna.Evaluaciones = append(columna.Evaluaciones, ev)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		columnas = append(columnas, columna)
+	}
+	return columnas, nil
+}
+
+// GetLibreta calcula la libreta completa del curso. De los examenes con
+// varios intentos se toma la mejor nota y la asistencia entra como su
+// porcentaje en escala vigesimal. Los intentos en curso y las entregas
+// sin calificar no tienen nota todavia.
+func GetLibreta(db *pgxpool.Pool, cursoId int) (Libreta, error) {
+	return getLibreta(db, cursoId, 0)
+}
+
+// GetLibretaAlumno calcula solo la fila del alumno. A diferencia de la
+// libreta del profesor, las entregas calificadas cuentan recien cuando se
+// devuelven.
+func GetLibretaAlumno(db *pgxpool.Pool, cursoId, alumnoId int) (Libreta, error) {
+	return getLibreta(db, cursoId, alumnoId)
+}
+
+// getLibreta calcula la libreta de todo el curso con alumnoId 0
+func getLibreta(db *pgxpool.Pool, cursoId, alumnoId int) (Libreta, error) {
+	columnas, err := GetColumnasLibreta(db, cursoId)
+	if err != nil {
+		return Libreta{}, err
+	}
+	alumnos, err := GetAlumnosCurso(db, cursoId)
+	if err != nil {
+		return Libreta{}, err
+	}
+	if alumnoId != 0 {
+		filtrados := []Alumno{}
+		for _, al := range alumnos {
+			if al.ID == alumnoId {
+				filtrados = append(filtrados, al)
+			}
+		}
+		alumnos = filtrados
+	}
+
+	notas := map[ClaveCelda]float32{}
+	ajustes := map[ClaveCelda]float32{}
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT ae.alumnoId, 'examen', ae.examenId, MAX(ae.calificacion)
+		FROM alumnoExamen ae
+		JOIN examenes e ON e.id = ae.examenId
+		WHERE e.cursoId=$1 AND NOT ae.activo
+		GROUP BY ae.alumnoId, ae.examenId
+		UNION ALL
+		SELECT at.alumnoId, 'trabajo', at.trabajoId, at.calificacion
+		FROM alumnoTrabajo at
+		JOIN trabajos t ON t.id = at.trabajoId
+		WHERE t.cursoId=$1 AND at.calificado
+		AND ($2 = 0 OR at.fechaDevolucion IS NOT NULL)`,
+		cursoId, alumnoId)
+	if err != nil {
+		return Libreta{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var clave ClaveCelda
+		var calificacion float32
+		if err := rows.Scan(&clave.AlumnoId, &clave.Tipo, &clave.ReferenciaId, &calificacion); err != nil {
+			log.Printf("Las filas obtenidas de la BD para la libreta, no satisfacen a 'Scan' %s",
+				err)
+			return Libreta{}, err
+		}
+		notas[clave] = calificacion
+	}
+	if err := rows.Err(); err != nil {
+		return Libreta{}, err
+	}
+
+	for _, columna := range columnas {
+		if columna.Tipo != CategoriaAsistencia {
+			continue
+		}
+		resumenes, err := GetResumenAsistenciaCurso(db, cursoId)
+		if err != nil {
+			return Libreta{}, err
+		}
+		for _, ra := range resumenes {
+			if ra.Porcentaje != nil {
+				notas[ClaveCelda{ra.Alumno.ID, CeldaAsistencia, columna.ID}] = NotaAsistencia(*ra.Porcentaje)
+			}
+		}
+	}
+
+	celdas, err := GetCeldasManualesCurso(db, cursoId)
+	if err != nil {
+		return Libreta{}, err
+	}
+	// las celdas de evaluaciones manuales son notas, las demas son ajustes
+	for _, c := range celdas {
+		clave := ClaveCelda{c.AlumnoId, c.Tipo, c.ReferenciaId}
+		if c.Tipo == CeldaEvaluacion {
+			notas[clave] = c.Calificacion
+		} else {
+			ajustes[clave] = c.Calificacion
+		}
+	}
+
+	return CalcularLibreta(cursoId, columnas, alumnos, notas, ajustes), nil
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestCalcularLibreta(t *testing.T) {
+	columnas := []ColumnaCategoria{
+		{
+			CategoriaCalificacion: CategoriaCalificacion{ID: 1, Tipo: CategoriaExamen, Peso: 60, DescartarMenores: 1},
+			Evaluaciones: []EvaluacionLibreta{
+				{Tipo: CeldaExamen, ID: 1, Vencida: true},
+				{Tipo: CeldaExamen, ID: 2, Vencida: true},
+				{Tipo: CeldaExamen, ID: 3, Vencida: true},
+			},
+		},
+		{
+			CategoriaCalificacion: CategoriaCalificacion{ID: 2, Tipo: CategoriaTrabajo, Peso: 40},
+			Evaluaciones: []EvaluacionLibreta{
+				{Tipo: CeldaTrabajo, ID: 1, Vencida: true},
+				{Tipo: CeldaTrabajo, ID: 2, Vencida: false},
+			},
+		},
+	}
+	alumnos := []Alumno{{ID: 1}, {ID: 2}}
+	notas := map[ClaveCelda]float32{
+		{1, CeldaExamen, 1}:  8,
+		{1, CeldaExamen, 2}:  14,
+		{1, CeldaExamen, 3}:  16,
+		{1, CeldaTrabajo, 1}: 12,
+		{2, CeldaExamen, 1}:  10,
+	}
+	ajustes := map[ClaveCelda]float32{
+		{2, CeldaFinal, 0}: 11,
+	}
+
+	libreta := CalcularLibreta(1, columnas, alumnos, notas, ajustes)
+	if len(libreta.Filas) != 2 {
+		t.Fatalf("Se esperaban 2 filas. Se obtuvo %d", len(libreta.Filas))
+	}
+
+	// examenes: se descarta el 8, promedio 15; trabajos: el trabajo 2 no
+	// vence y no cuenta, promedio 12; final 15*0.6 + 12*0.4 = 13.8
+	fila := libreta.Filas[0]
+	if !fila.Categorias[0].Celdas[0].Descartada {
+		t.Errorf("Se esperaba descartar la nota mas baja. Se obtuvo %v", fila.Categorias[0].Celdas)
+	}
+	if *fila.Categorias[0].Calificacion != 15 || *fila.Categorias[1].Calificacion != 12 {
+		t.Errorf("Se esperaban promedios 15 y 12. Se obtuvo %v y %v",
+			*fila.Categorias[0].Calificacion, *fila.Categorias[1].Calificacion)
+	}
+	if fila.Final != 13.8 || fila.Redondeado != 14 || !fila.Aprobado {
+		t.Errorf("Se esperaba final 13.8 aprobado. Se obtuvo %v", fila)
+	}
+
+	// el ajuste manual reemplaza la nota final calculada
+	fila = libreta.Filas[1]
+	if fila.Final != 11 || !fila.Ajustada || !fila.Aprobado {
+		t.Errorf("Se esperaba final ajustada a 11. Se obtuvo %v", fila)
+	}
+}
+
+func TestRedondearVigesimal(t *testing.T) {
+	if RedondearVigesimal(10.5) != 11 {
+		t.Errorf("Se esperaba que 10.5 redondee a 11. Se obtuvo %d", RedondearVigesimal(10.5))
+	}
+	if RedondearVigesimal(10.49) != 10 {
+		t.Errorf("Se esperaba que 10.49 redondee a 10. Se obtuvo %d", RedondearVigesimal(10.49))
+	}
+}
+
+func TestGetLibreta(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(2, db)
+
+	categoria := CategoriaCalificacion{CursoId: 1, Nombre: "participacion",
+		Tipo: CategoriaManual, Peso: 100}
+	if err := categoria.CreateCategoria(db); err != nil {
+		t.Fatalf("No se creo la categoria %s", err)
+	}
+	evaluacion := EvaluacionManual{CategoriaId: categoria.ID, Nombre: "semana 1"}
+	if err := evaluacion.CreateEvaluacionManual(db); err != nil {
+		t.Fatalf("No se creo la evaluacion manual %s", err)
+	}
+	celda := CeldaManual{CursoId: 1, AlumnoId: 1, Tipo: CeldaEvaluacion,
+		ReferenciaId: evaluacion.ID, Calificacion: 15, UsuarioId: 1}
+	if err := celda.GuardarCeldaManual(db); err != nil {
+		t.Fatalf("No se guardo la celda %s", err)
+	}
+
+	libreta, err := GetLibreta(db, 1)
+	if err != nil {
+		t.Fatalf("No se obtuvo la libreta %s", err)
+	}
+	if len(libreta.Filas) != 2 {
+		t.Fatalf("Se esperaban 2 filas. Se obtuvo %d", len(libreta.Filas))
+	}
+	for _, fila := range libreta.Filas {
+		esperado := float32(0)
+		if fila.Alumno.ID == 1 {
+			esperado = 15
+		}
+		if fila.Final != esperado {
+			t.Errorf("Se esperaba final %v para el alumno %d. Se obtuvo %v",
+				esperado, fila.Alumno.ID, fila.Final)
+		}
+	}
+}
+
+func TestGetLibretaSinCalificar(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(1, db)
+	now := time.Now()
+
+	for _, tipo := range []string{CategoriaExamen, CategoriaTrabajo} {
+		categoria := CategoriaCalificacion{CursoId: 1, Nombre: tipo, Tipo: tipo, Peso: 50}
+		if err := categoria.CreateCategoria(db); err != nil {
+			t.Fatalf("No se creo la categoria %s", err)
+		}
+	}
+	examen := Examen{Nombre: "parcial", FechaInicio: now.Add(-time.Hour),
+		FechaFinal: now.Add(time.Hour), CursoId: 1, Activo: true}
+	examen.CreateExamen(db)
+	trabajo := Trabajo{Descripcion: "ensayo", FechaInicio: now.Add(-time.Hour),
+		FechaFinal: now.Add(time.Hour), CursoId: 1, Activo: true}
+	trabajo.CreateTrabajo(db)
+
+	// un intento en curso y una entrega sin calificar
+	intento := AlumnoExamen{Intento: 1, FechaInicio: now, FechaFinal: now.Add(time.Hour),
+		AlumnoId: 1, ExamenId: examen.ID, Activo: true}
+	intento.CreateAlumnoExamen(db)
+	at := AlumnoTrabajo{Uri: "a.txt", FechaInicio: now, FechaFinal: now,
+		AlumnoId: 1, TrabajoId: trabajo.ID, Activo: true}
+	at.CreateAlumnoTrabajo(db)
+
+	libreta, err := GetLibreta(db, 1)
+	if err != nil {
+		t.Fatalf("No se obtuvo la libreta %s", err)
+	}
+	for _, nc := range libreta.Filas[0].Categorias {
+		if nc.Calificacion != nil || nc.Celdas[0].Calificacion != nil {
+			t.Errorf("Sin calificar no deberia haber nota. Se obtuvo %v", nc)
+		}
+	}
+
+	at.Calificar(db, 16, Tardanza{})
+	libreta, _ = GetLibreta(db, 1)
+	trabajos := libreta.Filas[0].Categorias[1]
+	if trabajos.Calificacion == nil || *trabajos.Calificacion != 16 {
+		t.Errorf("Se esperaba la nota 16 del trabajo calificado. Se obtuvo %v", trabajos)
+	}
+
+	// el alumno no ve la nota hasta que se devuelve el trabajo
+	libreta, _ = GetLibretaAlumno(db, 1, 1)
+	if len(libreta.Filas) != 1 || libreta.Filas[0].Categorias[1].Calificacion != nil {
+		t.Errorf("El alumno no deberia ver la nota sin devolver. Se obtuvo %v", libreta.Filas)
+	}
+	at.Devolver(db)
+	libreta, _ = GetLibretaAlumno(db, 1, 1)
+	trabajos = libreta.Filas[0].Categorias[1]
+	if trabajos.Calificacion == nil || *trabajos.Calificacion != 16 {
+		t.Errorf("Se esperaba la nota 16 del trabajo devuelto. Se obtuvo %v", trabajos)
+	}
+}
+
+func TestGetLibretaExamenRendido(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(1, db)
+	now := time.Now()
+
+	categoria := CategoriaCalificacion{CursoId: 1, Nombre: "examenes",
+		Tipo: CategoriaExamen, Peso: 100}
+	if err := categoria.CreateCategoria(db); err != nil {
+		t.Fatalf("No se creo la categoria %s", err)
+	}
+	examen := Examen{Nombre: "parcial", FechaInicio: now.Add(-time.Hour),
+		FechaFinal: now.Add(time.Hour), CursoId: 1, Activo: true}
+	examen.CreateExamen(db)
+
+	// dos preguntas de 2 y 3 puntos, el alumno solo acierta la primera
+	preguntas := []Pregunta{
+		{Enunciado: "Capital del Peru", Tipo: TipoRespuestaCorta, Puntaje: 2,
+			ExamenId: examen.ID, Activo: true},
+		{Enunciado: "Capital del Tahuantinsuyo", Tipo: TipoRespuestaCorta, Puntaje: 3,
+			ExamenId: examen.ID, Activo: true},
+	}
+	correctas := []string{"Lima", "Cusco"}
+	for i := range preguntas {
+		preguntas[i].CreatePregunta(db)
+		alt := Alternativa{Valor: correctas[i], Correcto: true,
+			PreguntaId: preguntas[i].ID, Activo: true}
+		alt.CreateAlternativa(db)
+		preguntas[i].Alternativas = []Alternativa{alt}
+	}
+
+	intento := AlumnoExamen{AlumnoId: 1, ExamenId: examen.ID, FechaInicio: now,
+		FechaFinal: now.Add(time.Hour), Activo: true}
+	if err := intento.IniciarIntento(db, 0); err != nil {
+		t.Fatalf("No se inicio el intento %s", err)
+	}
+	for i, texto := range []string{"lima", "Arequipa"} {
+		r := Respuesta{AlumnoExamenId: intento.ID, PreguntaId: preguntas[i].ID, Texto: texto}
+		r.Calificar(preguntas[i])
+		if err := r.GuardarRespuesta(db); err != nil {
+			t.Fatalf("No se guardo la respuesta %s", err)
+		}
+	}
+	if err := intento.Entregar(db); err != nil {
+		t.Fatalf("No se entrego el intento %s", err)
+	}
+	if intento.Calificacion != 8 {
+		t.Errorf("Se esperaba calificar el intento con 8. Se obtuvo %v", intento.Calificacion)
+	}
+
+	libreta, err := GetLibreta(db, 1)
+	if err != nil {
+		t.Fatalf("No se obtuvo la libreta %s", err)
+	}
+	if fila := libreta.Filas[0]; fila.Final != 8 {
+		t.Errorf("Se esperaba final 8 del examen rendido. Se obtuvo %v", fila)
+	}
+}
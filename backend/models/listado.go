@@ -0,0 +1,20 @@
+package models
+
+import (
+	"context"
+
+	"github.com/blackadress/vaula/consulta"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// contar devuelve cuantas filas de 'tabla' cumplen los filtros de 'c', sin
+// paginar, para informar el total del listado
+func contar(db *pgxpool.Pool, tabla string, c consulta.Consulta) (int, error) {
+	where, args := c.Filtro()
+	var total int
+	err := db.QueryRow(
+		context.Background(),
+		`SELECT COUNT(*) FROM `+tabla+where,
+		args...).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,297 @@
+package models
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Conversacion es un intercambio privado de mensajes entre miembros de un
+// curso. NoLeidos cuenta los mensajes de otros que el usuario que consulta
+// aun no leyo.
+type Conversacion struct {
+	ID            int            `json:"id"`
+	CursoId       int            `json:"cursoId"`
+	CreadaPor     int            `json:"creadaPor"`
+	Asunto        string         `json:"asunto"`
+	Participantes []Participante `json:"participantes"`
+	NoLeidos      int            `json:"noLeidos"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Participante guarda hasta que mensaje leyo cada usuario, para los avisos
+// de lectura
+type Participante struct {
+	UsuarioId  int        `json:"usuarioId"`
+	Username   string     `json:"username"`
+	LeidoHasta int        `json:"leidoHasta"`
+	LeidoEn    *time.Time `json:"leidoEn"`
+}
+
+// Mensaje de una conversacion. LeidoPor lista a los demas participantes
+// que ya lo leyeron.
+type Mensaje struct {
+	ID             int    `json:"id"`
+	ConversacionId int    `json:"conversacionId"`
+	UsuarioId      int    `json:"usuarioId"`
+	Texto          string `json:"texto"`
+
+	// archivo adjunto opcional
+	NombreArchivo string `json:"nombreArchivo"`
+	MimeType      string `json:"mimeType"`
+	Tamano        int64  `json:"tamano"`
+	Clave         string `json:"-"` // clave en el storage
+
+	LeidoPor  []int     `json:"leidoPor"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (m *Mensaje) TieneAdjunto() bool {
+	return m.Clave != ""
+}
+
+// CreateConversacion crea la conversacion con sus participantes; el
+// creador siempre participa aunque no este en 'usuarioIds'
+func (c *Conversacion) CreateConversacion(db *pgxpool.Pool, usuarioIds []int) error {
+	ctx := context.Background()
+	now := time.Now()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO conversaciones(cursoId, creadaPor, asunto, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $4)
+		RETURNING id, createdAt, updatedAt`,
+		c.CursoId, c.CreadaPor, c.Asunto, now,
+	).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO participantes(conversacionId, usuarioId)
+		SELECT $1, u FROM unnest($2::INT[]) u
+		UNION
+		SELECT $1, $3::INT
+		ON CONFLICT DO NOTHING`,
+		c.ID, usuarioIds, c.CreadaPor)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	return c.cargarParticipantes(db)
+}
+
+func (c *Conversacion) GetConversacion(db *pgxpool.Pool) error {
+	err := db.QueryRow(
+		context.Background(),
+		`SELECT cursoId, creadaPor, asunto, createdAt, updatedAt
+		FROM conversaciones
+		WHERE id=$1`,
+		c.ID).Scan(&c.CursoId, &c.CreadaPor, &c.Asunto, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return c.cargarParticipantes(db)
+}
+
+func (c *Conversacion) cargarParticipantes(db *pgxpool.Pool) error {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT p.usuarioId, u.username, p.leidoHasta, p.leidoEn
+		FROM participantes p
+		JOIN usuarios u ON u.id = p.usuarioId
+		WHERE p.conversacionId=$1
+		ORDER BY p.usuarioId`,
+		c.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	c.Participantes = []Participante{}
+	for rows.Next() {
+		var p Participante
+		if err := rows.Scan(&p.UsuarioId, &p.Username, &p.LeidoHasta, &p.LeidoEn); err != nil {
+			log.Printf("Las filas obtenidas de la BD para Participante, no satisfacen a 'Scan' %s",
+				err)
+			return err
+		}
+		c.Participantes = append(c.Participantes, p)
+	}
+	return rows.Err()
+}
+
+// EsParticipante indica si el usuario pertenece a la conversacion. Requiere
+// los participantes ya cargados.
+func (c *Conversacion) EsParticipante(usuarioId int) bool {
+	for _, p := range c.Participantes {
+		if p.UsuarioId == usuarioId {
+			return true
+		}
+	}
+	return false
+}
+
+// GetConversacionesUsuario lista las conversaciones del usuario, las de
+// actividad mas reciente primero. Con cursoId 0 incluye todos los cursos.
+func GetConversacionesUsuario(db *pgxpool.Pool, usuarioId, cursoId int) ([]Conversacion, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT c.id, c.cursoId, c.creadaPor, c.asunto, c.createdAt, c.updatedAt,
+		(SELECT COUNT(*) FROM mensajes m
+			WHERE m.conversacionId = c.id AND m.id > p.leidoHasta
+			AND m.usuarioId <> p.usuarioId)
+		FROM conversaciones c
+		JOIN participantes p ON p.conversacionId = c.id AND p.usuarioId=$1
+		WHERE $2 = 0 OR c.cursoId = $2
+		ORDER BY c.updatedAt DESC, c.id DESC`,
+		usuarioId, cursoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversaciones := []Conversacion{}
+	for rows.Next() {
+		var c Conversacion
+		err := rows.Scan(&c.ID, &c.CursoId, &c.CreadaPor, &c.Asunto, &c.CreatedAt,
+			&c.UpdatedAt, &c.NoLeidos)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Conversacion, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		conversaciones = append(conversaciones, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range conversaciones {
+		if err := conversaciones[i].cargarParticipantes(db); err != nil {
+			return nil, err
+		}
+	}
+	return conversaciones, nil
+}
+
+// CreateMensaje agrega el mensaje a la conversacion. El autor queda como
+// lector de su propio mensaje.
+func (m *Mensaje) CreateMensaje(db *pgxpool.Pool) error {
+	ctx := context.Background()
+	now := time.Now()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO mensajes(conversacionId, usuarioId, texto, nombreArchivo,
+		mimeType, tamano, clave, createdAt)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, createdAt`,
+		m.ConversacionId, m.UsuarioId, m.Texto, m.NombreArchivo, m.MimeType,
+		m.Tamano, m.Clave, now,
+	).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE conversaciones SET updatedAt=$1 WHERE id=$2`,
+		now, m.ConversacionId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE participantes SET leidoHasta=$1, leidoEn=$2
+		WHERE conversacionId=$3 AND usuarioId=$4`,
+		m.ID, now, m.ConversacionId, m.UsuarioId)
+	if err != nil {
+		return err
+	}
+	m.LeidoPor = []int{}
+	return tx.Commit(ctx)
+}
+
+func (m *Mensaje) GetMensaje(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT conversacionId, usuarioId, texto, nombreArchivo, mimeType,
+		tamano, clave, createdAt
+		FROM mensajes
+		WHERE id=$1`,
+		m.ID).Scan(&m.ConversacionId, &m.UsuarioId, &m.Texto, &m.NombreArchivo,
+		&m.MimeType, &m.Tamano, &m.Clave, &m.CreatedAt)
+}
+
+// GetMensajes devuelve el historial de la conversacion en orden, con los
+// avisos de lectura segun los participantes de 'c'
+func (c *Conversacion) GetMensajes(db *pgxpool.Pool) ([]Mensaje, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, conversacionId, usuarioId, texto, nombreArchivo, mimeType,
+		tamano, clave, createdAt
+		FROM mensajes
+		WHERE conversacionId=$1
+		ORDER BY id`,
+		c.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mensajes := []Mensaje{}
+	for rows.Next() {
+		var m Mensaje
+		err := rows.Scan(&m.ID, &m.ConversacionId, &m.UsuarioId, &m.Texto,
+			&m.NombreArchivo, &m.MimeType, &m.Tamano, &m.Clave, &m.CreatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Mensaje, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		m.LeidoPor = []int{}
+		for _, p := range c.Participantes {
+			if p.UsuarioId != m.UsuarioId && p.LeidoHasta >= m.ID {
+				m.LeidoPor = append(m.LeidoPor, p.UsuarioId)
+			}
+		}
+		mensajes = append(mensajes, m)
+	}
+	return mensajes, rows.Err()
+}
+
+// MarcarConversacionLeida registra que el usuario leyo hasta el ultimo
+// mensaje de la conversacion
+func (c *Conversacion) MarcarConversacionLeida(db *pgxpool.Pool, usuarioId int) error {
+	_, err := db.Exec(
+		context.Background(),
+		`UPDATE participantes p SET leidoHasta=ultimo.id, leidoEn=$1
+		FROM (SELECT COALESCE(MAX(id), 0) AS id FROM mensajes
+			WHERE conversacionId=$2) ultimo
+		WHERE p.conversacionId=$2 AND p.usuarioId=$3
+		AND p.leidoHasta < ultimo.id`,
+		time.Now(), c.ID, usuarioId)
+	if err != nil {
+		return err
+	}
+	return c.cargarParticipantes(db)
+}
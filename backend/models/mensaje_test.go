@@ -0,0 +1,68 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestConversacionNoLeidos(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(2, db)
+
+	conversacion := Conversacion{CursoId: 1, CreadaPor: 1, Asunto: "Consulta"}
+	if err := conversacion.CreateConversacion(db, []int{2, 2}); err != nil {
+		t.Fatalf("El metodo CreateConversacion fallo %s", err)
+	}
+	if len(conversacion.Participantes) != 2 {
+		t.Fatalf("Se esperaban 2 participantes. Se obtuvo %v", conversacion.Participantes)
+	}
+
+	for _, texto := range []string{"hola", "una duda"} {
+		m := Mensaje{ConversacionId: conversacion.ID, UsuarioId: 1, Texto: texto}
+		if err := m.CreateMensaje(db); err != nil {
+			t.Fatalf("El metodo CreateMensaje fallo %s", err)
+		}
+	}
+
+	conversaciones, err := GetConversacionesUsuario(db, 2, 0)
+	if err != nil {
+		t.Fatalf("El metodo GetConversacionesUsuario fallo %s", err)
+	}
+	if len(conversaciones) != 1 || conversaciones[0].NoLeidos != 2 {
+		t.Errorf("Se esperaban 2 mensajes no leidos. Se obtuvo %v", conversaciones)
+	}
+	conversaciones, _ = GetConversacionesUsuario(db, 1, 0)
+	if len(conversaciones) != 1 || conversaciones[0].NoLeidos != 0 {
+		t.Errorf("Los mensajes propios no cuentan como no leidos. Se obtuvo %v", conversaciones)
+	}
+	if conversaciones, _ := GetConversacionesUsuario(db, 2, 99); len(conversaciones) != 0 {
+		t.Errorf("No se esperaban conversaciones en otro curso. Se obtuvo %v", conversaciones)
+	}
+}
+
+func TestMarcarConversacionLeida(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(2, db)
+
+	conversacion := Conversacion{CursoId: 1, CreadaPor: 1, Asunto: "Consulta"}
+	conversacion.CreateConversacion(db, []int{2})
+	m := Mensaje{ConversacionId: conversacion.ID, UsuarioId: 1, Texto: "hola"}
+	m.CreateMensaje(db)
+
+	mensajes, _ := conversacion.GetMensajes(db)
+	if len(mensajes) != 1 || len(mensajes[0].LeidoPor) != 0 {
+		t.Fatalf("El mensaje aun no deberia estar leido. Se obtuvo %v", mensajes)
+	}
+
+	if err := conversacion.MarcarConversacionLeida(db, 2); err != nil {
+		t.Fatalf("El metodo MarcarConversacionLeida fallo %s", err)
+	}
+	mensajes, _ = conversacion.GetMensajes(db)
+	if len(mensajes[0].LeidoPor) != 1 || mensajes[0].LeidoPor[0] != 2 {
+		t.Errorf("Se esperaba que el usuario 2 lo haya leido. Se obtuvo %v", mensajes[0].LeidoPor)
+	}
+	if conversaciones, _ := GetConversacionesUsuario(db, 2, 1); conversaciones[0].NoLeidos != 0 {
+		t.Errorf("Se esperaban 0 no leidos. Se obtuvo %d", conversaciones[0].NoLeidos)
+	}
+}
@@ -40,6 +40,20 @@ func TestMain(m *testing.M) {
 	utils.EnsureTableTrabajoExists(db)
 	utils.EnsureTablePreguntaTrabajoExists(db)
 	utils.EnsureTableAlternativaExists(db)
+	utils.EnsureTableAlumnoExamenExists(db)
+	utils.EnsureTableProrrogaExists(db)
+	utils.EnsureTableAlumnoTrabajoExists(db)
+	utils.EnsureTableRubricaExists(db)
+	utils.EnsureTableAlumnoCursoExists(db)
+	utils.EnsureTableLibretaExists(db)
+	utils.EnsureTableAsistenciaExists(db)
+	utils.EnsureTableProfesorCursoExists(db)
+	utils.EnsureTableForoExists(db)
+	utils.EnsureTableNotificacionExists(db)
+	utils.EnsureTableRecordatorioExists(db)
+	utils.EnsureTableTokenCalendarioExists(db)
+	utils.EnsureTableMensajeExists(db)
+	utils.EnsureBusquedaExists(db)
 
 	code := m.Run()
 
@@ -0,0 +1,374 @@
+package models
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// tipos de notificacion, cada usuario elige por tipo en que canales recibirlas
+const (
+	NotificacionExamen       = "examen"
+	NotificacionVencimiento  = "vencimiento"
+	NotificacionCalificacion = "calificacion"
+	NotificacionAnuncio      = "anuncio"
+	NotificacionMensaje      = "mensaje"
+)
+
+// TiposNotificacion en el orden en que se muestran las preferencias
+var TiposNotificacion = []string{
+	NotificacionExamen,
+	NotificacionVencimiento,
+	NotificacionCalificacion,
+	NotificacionAnuncio,
+	NotificacionMensaje,
+}
+
+// estados de un EnvioCorreo
+const (
+	EnvioPendiente = "pendiente"
+	EnvioEnviando  = "enviando"
+	EnvioEnviado   = "enviado"
+	EnvioFallido   = "fallido"
+)
+
+// Notificacion es un aviso en la bandeja de un usuario. ReferenciaId es el
+// id del examen, trabajo o anuncio que la origino, segun el tipo.
+type Notificacion struct {
+	ID           int        `json:"id"`
+	UsuarioId    int        `json:"usuarioId"`
+	Tipo         string     `json:"tipo"`
+	Titulo       string     `json:"titulo"`
+	Cuerpo       string     `json:"cuerpo"`
+	ReferenciaId int        `json:"referenciaId"`
+	LeidaEn      *time.Time `json:"leidaEn"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// PreferenciaNotificacion indica por que canales recibe el usuario un tipo
+// de notificacion
+type PreferenciaNotificacion struct {
+	UsuarioId int    `json:"usuarioId"`
+	Tipo      string `json:"tipo"`
+	App       bool   `json:"app"`
+	Email     bool   `json:"email"`
+}
+
+// EnvioCorreo es un correo en la cola del despachador
+type EnvioCorreo struct {
+	ID             int        `json:"id"`
+	UsuarioId      int        `json:"usuarioId"`
+	Destinatario   string     `json:"destinatario"`
+	Asunto         string     `json:"asunto"`
+	Cuerpo         string     `json:"cuerpo"`
+	Estado         string     `json:"estado"`
+	Intentos       int        `json:"intentos"`
+	Error          string     `json:"error"`
+	ProximoIntento time.Time  `json:"proximoIntento"`
+	EnviadoEn      *time.Time `json:"enviadoEn"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TipoNotificacionValido indica si 'tipo' es uno de TiposNotificacion
+func TipoNotificacionValido(tipo string) bool {
+	for _, t := range TiposNotificacion {
+		if t == tipo {
+			return true
+		}
+	}
+	return false
+}
+
+// Notificar deja la notificacion en la bandeja de cada usuario y encola su
+// correo, segun las preferencias de cada uno para el tipo de 'n'. Los
+// usuarios inactivos o sin email no reciben correo.
+func Notificar(db *pgxpool.Pool, usuarioIds []int, n Notificacion) error {
+	if len(usuarioIds) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := notificarTx(ctx, tx, usuarioIds, n); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// notificarTx es Notificar dentro de una transaccion ya abierta, para que
+// la notificacion se confirme junto con lo que la origina
+func notificarTx(ctx context.Context, tx pgx.Tx, usuarioIds []int, n Notificacion) error {
+	now := time.Now()
+	_, err := tx.Exec(
+		ctx,
+		`INSERT INTO notificaciones(usuarioId, tipo, titulo, cuerpo,
+		referenciaId, createdAt)
+		SELECT u.id, $2, $3, $4, $5, $6
+		FROM usuarios u
+		LEFT JOIN preferenciasNotificacion p ON p.usuarioId = u.id AND p.tipo = $2
+		WHERE u.id = ANY($1) AND COALESCE(p.app, true)`,
+		usuarioIds, n.Tipo, n.Titulo, n.Cuerpo, n.ReferenciaId, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO enviosCorreo(usuarioId, destinatario, asunto, cuerpo,
+		estado, proximoIntento, createdAt)
+		SELECT u.id, u.email, $3, $4, $5, $6, $6
+		FROM usuarios u
+		LEFT JOIN preferenciasNotificacion p ON p.usuarioId = u.id AND p.tipo = $2
+		WHERE u.id = ANY($1) AND u.activo AND u.email <> ''
+		AND COALESCE(p.email, true)`,
+		usuarioIds, n.Tipo, n.Titulo, n.Cuerpo, EnvioPendiente, now)
+	return err
+}
+
+// GetNotificacionesUsuario lista la bandeja del usuario, las mas recientes
+// primero
+func GetNotificacionesUsuario(db *pgxpool.Pool, usuarioId int, soloNoLeidas bool) ([]Notificacion, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, usuarioId, tipo, titulo, cuerpo, referenciaId, leidaEn,
+		createdAt
+		FROM notificaciones
+		WHERE usuarioId=$1 AND ($2 = false OR leidaEn IS NULL)
+		ORDER BY createdAt DESC, id DESC`,
+		usuarioId, soloNoLeidas)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notificaciones := []Notificacion{}
+	for rows.Next() {
+		var n Notificacion
+		err := rows.Scan(&n.ID, &n.UsuarioId, &n.Tipo, &n.Titulo, &n.Cuerpo,
+			&n.ReferenciaId, &n.LeidaEn, &n.CreatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Notificacion, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		notificaciones = append(notificaciones, n)
+	}
+	return notificaciones, nil
+}
+
+// MarcarLeida marca la notificacion como leida si pertenece a n.UsuarioId,
+// si no devuelve pgx.ErrNoRows. Una notificacion ya leida conserva su fecha.
+func (n *Notificacion) MarcarLeida(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`UPDATE notificaciones SET leidaEn=COALESCE(leidaEn, $1)
+		WHERE id=$2 AND usuarioId=$3
+		RETURNING tipo, titulo, cuerpo, referenciaId, leidaEn, createdAt`,
+		time.Now(), n.ID, n.UsuarioId,
+	).Scan(&n.Tipo, &n.Titulo, &n.Cuerpo, &n.ReferenciaId, &n.LeidaEn, &n.CreatedAt)
+}
+
+// MarcarTodasLeidas marca toda la bandeja del usuario como leida y devuelve
+// cuantas notificaciones cambiaron
+func MarcarTodasLeidas(db *pgxpool.Pool, usuarioId int) (int64, error) {
+	tag, err := db.Exec(
+		context.Background(),
+		`UPDATE notificaciones SET leidaEn=$1
+		WHERE usuarioId=$2 AND leidaEn IS NULL`,
+		time.Now(), usuarioId)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetPreferenciasNotificacion devuelve una preferencia por cada tipo de
+// TiposNotificacion, con ambos canales activos si el usuario no la cambio
+func GetPreferenciasNotificacion(db *pgxpool.Pool, usuarioId int) ([]PreferenciaNotificacion, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT tipo, app, email
+		FROM preferenciasNotificacion
+		WHERE usuarioId=$1`,
+		usuarioId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	guardadas := map[string]PreferenciaNotificacion{}
+	for rows.Next() {
+		p := PreferenciaNotificacion{UsuarioId: usuarioId}
+		if err := rows.Scan(&p.Tipo, &p.App, &p.Email); err != nil {
+			log.Printf("Las filas obtenidas de la BD para PreferenciaNotificacion, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		guardadas[p.Tipo] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	preferencias := []PreferenciaNotificacion{}
+	for _, tipo := range TiposNotificacion {
+		p, ok := guardadas[tipo]
+		if !ok {
+			p = PreferenciaNotificacion{UsuarioId: usuarioId, Tipo: tipo, App: true, Email: true}
+		}
+		preferencias = append(preferencias, p)
+	}
+	return preferencias, nil
+}
+
+func (p *PreferenciaNotificacion) GuardarPreferencia(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`INSERT INTO preferenciasNotificacion(usuarioId, tipo, app, email)
+		VALUES($1, $2, $3, $4)
+		ON CONFLICT (usuarioId, tipo) DO UPDATE
+		SET app=EXCLUDED.app, email=EXCLUDED.email`,
+		p.UsuarioId, p.Tipo, p.App, p.Email)
+	return err
+}
+
+// TomarEnviosPendientes reserva hasta 'limite' correos cuyo proximo intento
+// ya llego, pasandolos a enviando. SKIP LOCKED permite que varias
+// instancias del servidor despachen la misma cola sin repetir correos.
+func TomarEnviosPendientes(db *pgxpool.Pool, limite int) ([]EnvioCorreo, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`UPDATE enviosCorreo SET estado=$1
+		WHERE id IN (
+			SELECT id FROM enviosCorreo
+			WHERE estado=$2 AND proximoIntento <= $3
+			ORDER BY proximoIntento, id
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id, usuarioId, destinatario, asunto, cuerpo, estado,
+		intentos, error, proximoIntento, enviadoEn, createdAt`,
+		EnvioEnviando, EnvioPendiente, time.Now(), limite)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	envios := []EnvioCorreo{}
+	for rows.Next() {
+		var e EnvioCorreo
+		err := rows.Scan(&e.ID, &e.UsuarioId, &e.Destinatario, &e.Asunto,
+			&e.Cuerpo, &e.Estado, &e.Intentos, &e.Error, &e.ProximoIntento,
+			&e.EnviadoEn, &e.CreatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para EnvioCorreo, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		envios = append(envios, e)
+	}
+	return envios, rows.Err()
+}
+
+func (e *EnvioCorreo) GetEnvioCorreo(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT usuarioId, destinatario, asunto, cuerpo, estado, intentos,
+		error, proximoIntento, enviadoEn, createdAt
+		FROM enviosCorreo
+		WHERE id=$1`,
+		e.ID).Scan(&e.UsuarioId, &e.Destinatario, &e.Asunto, &e.Cuerpo,
+		&e.Estado, &e.Intentos, &e.Error, &e.ProximoIntento, &e.EnviadoEn,
+		&e.CreatedAt)
+}
+
+func (e *EnvioCorreo) MarcarEnviado(db *pgxpool.Pool) error {
+	now := time.Now()
+	e.Estado = EnvioEnviado
+	e.Intentos++
+	e.EnviadoEn = &now
+	_, err := db.Exec(
+		context.Background(),
+		`UPDATE enviosCorreo SET estado=$1, intentos=$2, error='', enviadoEn=$3
+		WHERE id=$4`,
+		e.Estado, e.Intentos, now, e.ID)
+	return err
+}
+
+// RegistrarFallo cuenta el intento fallido y reprograma el correo para
+// dentro de 'espera', o lo da por fallido al llegar a 'maxIntentos'
+func (e *EnvioCorreo) RegistrarFallo(db *pgxpool.Pool, fallo error, maxIntentos int, espera time.Duration) error {
+	e.Intentos++
+	e.Error = fallo.Error()
+	e.Estado = EnvioPendiente
+	e.ProximoIntento = time.Now().Add(espera)
+	if e.Intentos >= maxIntentos {
+		e.Estado = EnvioFallido
+	}
+	_, err := db.Exec(
+		context.Background(),
+		`UPDATE enviosCorreo SET estado=$1, intentos=$2, error=$3,
+		proximoIntento=$4
+		WHERE id=$5`,
+		e.Estado, e.Intentos, e.Error, e.ProximoIntento, e.ID)
+	return err
+}
+
+// ReanudarEnvios devuelve a la cola los correos que quedaron reservados
+// cuando se detuvo el servidor. Puede reenviar alguno que si llego a salir.
+func ReanudarEnvios(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`UPDATE enviosCorreo SET estado=$1 WHERE estado=$2`,
+		EnvioPendiente, EnvioEnviando)
+	return err
+}
+
+// GetEnviosUsuario lista los correos encolados para el usuario
+func GetEnviosUsuario(db *pgxpool.Pool, usuarioId int) ([]EnvioCorreo, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, usuarioId, destinatario, asunto, cuerpo, estado,
+		intentos, error, proximoIntento, enviadoEn, createdAt
+		FROM enviosCorreo
+		WHERE usuarioId=$1
+		ORDER BY id`,
+		usuarioId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	envios := []EnvioCorreo{}
+	for rows.Next() {
+		var e EnvioCorreo
+		err := rows.Scan(&e.ID, &e.UsuarioId, &e.Destinatario, &e.Asunto,
+			&e.Cuerpo, &e.Estado, &e.Intentos, &e.Error, &e.ProximoIntento,
+			&e.EnviadoEn, &e.CreatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para EnvioCorreo, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		envios = append(envios, e)
+	}
+	return envios, rows.Err()
+}
+
+// UsuariosDeAlumnos devuelve el usuario de cada alumno, para notificarles
+func UsuariosDeAlumnos(alumnos []Alumno) []int {
+	ids := make([]int, 0, len(alumnos))
+	for _, al := range alumnos {
+		ids = append(ids, al.UsuarioId)
+	}
+	return ids
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestNotificarPreferencias(t *testing.T) {
+	utils.ClearTableUsuario(db)
+	// el usuario 2 esta inactivo
+	utils.AddUsers(3, db)
+
+	sinApp := PreferenciaNotificacion{UsuarioId: 1, Tipo: NotificacionExamen, App: false, Email: true}
+	sinApp.GuardarPreferencia(db)
+	sinEmail := PreferenciaNotificacion{UsuarioId: 3, Tipo: NotificacionExamen, App: true, Email: false}
+	sinEmail.GuardarPreferencia(db)
+
+	n := Notificacion{Tipo: NotificacionExamen, Titulo: "Nuevo examen", Cuerpo: "Parcial", ReferenciaId: 7}
+	if err := Notificar(db, []int{1, 2, 3}, n); err != nil {
+		t.Fatalf("El metodo Notificar fallo %s", err)
+	}
+
+	for usuarioId, esperadas := range map[int]int{1: 0, 2: 1, 3: 1} {
+		notificaciones, err := GetNotificacionesUsuario(db, usuarioId, false)
+		if err != nil {
+			t.Fatalf("El metodo GetNotificacionesUsuario fallo %s", err)
+		}
+		if len(notificaciones) != esperadas {
+			t.Errorf("Se esperaban %d notificaciones para el usuario %d. Se obtuvo %v",
+				esperadas, usuarioId, notificaciones)
+		}
+	}
+
+	// solo el usuario 1 esta activo y acepta correos de examenes
+	for usuarioId, esperados := range map[int]int{1: 1, 2: 0, 3: 0} {
+		envios, err := GetEnviosUsuario(db, usuarioId)
+		if err != nil {
+			t.Fatalf("El metodo GetEnviosUsuario fallo %s", err)
+		}
+		if len(envios) != esperados {
+			t.Errorf("Se esperaban %d correos para el usuario %d. Se obtuvo %v",
+				esperados, usuarioId, envios)
+		}
+	}
+
+	// las demas preferencias quedan por defecto
+	preferencias, err := GetPreferenciasNotificacion(db, 1)
+	if err != nil {
+		t.Fatalf("El metodo GetPreferenciasNotificacion fallo %s", err)
+	}
+	if len(preferencias) != len(TiposNotificacion) || preferencias[0].App || !preferencias[1].App {
+		t.Errorf("Se esperaban las preferencias guardadas y las por defecto. Se obtuvo %v", preferencias)
+	}
+}
+
+func TestMarcarNotificacionLeida(t *testing.T) {
+	utils.ClearTableUsuario(db)
+	utils.AddUsers(2, db)
+
+	n := Notificacion{Tipo: NotificacionAnuncio, Titulo: "Feriado", Cuerpo: "No hay clase"}
+	Notificar(db, []int{1}, n)
+	Notificar(db, []int{1}, n)
+
+	notificaciones, _ := GetNotificacionesUsuario(db, 1, true)
+	if len(notificaciones) != 2 {
+		t.Fatalf("Se esperaban 2 notificaciones sin leer. Se obtuvo %v", notificaciones)
+	}
+
+	// otro usuario no puede marcarla
+	ajena := Notificacion{ID: notificaciones[0].ID, UsuarioId: 2}
+	if err := ajena.MarcarLeida(db); err == nil {
+		t.Errorf("Se esperaba un error al marcar una notificacion ajena")
+	}
+
+	propia := Notificacion{ID: notificaciones[0].ID, UsuarioId: 1}
+	if err := propia.MarcarLeida(db); err != nil || propia.LeidaEn == nil {
+		t.Fatalf("El metodo MarcarLeida fallo %s", err)
+	}
+
+	marcadas, err := MarcarTodasLeidas(db, 1)
+	if err != nil || marcadas != 1 {
+		t.Errorf("Se esperaba marcar 1 notificacion. Se obtuvo %d, %v", marcadas, err)
+	}
+	if notificaciones, _ := GetNotificacionesUsuario(db, 1, true); len(notificaciones) != 0 {
+		t.Errorf("Se esperaba la bandeja leida. Se obtuvo %v", notificaciones)
+	}
+}
+
+func TestEnviosReintentos(t *testing.T) {
+	utils.ClearTableUsuario(db)
+	utils.AddUsers(1, db)
+
+	Notificar(db, []int{1}, Notificacion{Tipo: NotificacionCalificacion, Titulo: "Nota", Cuerpo: "18"})
+
+	envios, err := TomarEnviosPendientes(db, 10)
+	if err != nil || len(envios) != 1 {
+		t.Fatalf("Se esperaba un correo pendiente. Se obtuvo %v, %v", envios, err)
+	}
+	// reservado, no se vuelve a tomar
+	if otros, _ := TomarEnviosPendientes(db, 10); len(otros) != 0 {
+		t.Errorf("No se esperaban correos libres. Se obtuvo %v", otros)
+	}
+
+	e := envios[0]
+	if err := e.RegistrarFallo(db, errors.New("sin conexion"), 2, -time.Second); err != nil {
+		t.Fatalf("El metodo RegistrarFallo fallo %s", err)
+	}
+	if e.Estado != EnvioPendiente {
+		t.Errorf("Se esperaba el correo pendiente tras el primer fallo. Se obtuvo %s", e.Estado)
+	}
+
+	envios, _ = TomarEnviosPendientes(db, 10)
+	if len(envios) != 1 {
+		t.Fatalf("Se esperaba el correo de nuevo en la cola. Se obtuvo %v", envios)
+	}
+	e = envios[0]
+	e.RegistrarFallo(db, errors.New("sin conexion"), 2, -time.Second)
+	e.GetEnvioCorreo(db)
+	if e.Estado != EnvioFallido || e.Intentos != 2 || e.Error != "sin conexion" {
+		t.Errorf("Se esperaba el correo fallido tras 2 intentos. Se obtuvo %v", e)
+	}
+}
+
+func TestReanudarEnvios(t *testing.T) {
+	utils.ClearTableUsuario(db)
+	utils.AddUsers(1, db)
+
+	Notificar(db, []int{1}, Notificacion{Tipo: NotificacionAnuncio, Titulo: "Aviso", Cuerpo: "Hola"})
+	TomarEnviosPendientes(db, 10)
+
+	if err := ReanudarEnvios(db); err != nil {
+		t.Fatalf("El metodo ReanudarEnvios fallo %s", err)
+	}
+	envios, _ := TomarEnviosPendientes(db, 10)
+	if len(envios) != 1 {
+		t.Fatalf("Se esperaba el correo de nuevo en la cola. Se obtuvo %v", envios)
+	}
+	if err := envios[0].MarcarEnviado(db); err != nil {
+		t.Fatalf("El metodo MarcarEnviado fallo %s", err)
+	}
+	envios[0].GetEnvioCorreo(db)
+	if envios[0].Estado != EnvioEnviado || envios[0].EnviadoEn == nil {
+		t.Errorf("Se esperaba el correo enviado. Se obtuvo %v", envios[0])
+	}
+}
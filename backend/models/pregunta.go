@@ -5,47 +5,98 @@ import (
 	"log"
 	"time"
 
+	"github.com/blackadress/vaula/consulta"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+// Tipos de pregunta, con los mismos nombres que usa Moodle
+const (
+	TipoOpcionMultiple = "multichoice"
+	TipoVerdaderoFalso = "truefalse"
+	TipoRespuestaCorta = "shortanswer"
+	TipoNumerica       = "numerical"
+	TipoEnsayo         = "essay"
+)
+
+// Pregunta pertenece a un examen o a un banco de preguntas
 type Pregunta struct {
-	ID        int    `json:"id"`
-	Enunciado string `json:"enunciado"`
-	ExamenId  int    `json:"examenId"`
-	Examen    Examen `json:"examen"`
+	ID        int     `json:"id"`
+	Enunciado string  `json:"enunciado"`
+	Tipo      string  `json:"tipo"`
+	Puntaje   float32 `json:"puntaje"`
+	ExamenId  int     `json:"examenId"`
+	Examen    *Examen `json:"examen,omitempty"`
+	BancoId   int     `json:"bancoId"`
+
+	Alternativas []Alternativa `json:"alternativas"`
 
 	Activo    bool      `json:"activo"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// valores por defecto de las preguntas creadas sin tipo o sin puntaje
+func (p *Pregunta) completar() {
+	if p.Tipo == "" {
+		p.Tipo = TipoOpcionMultiple
+	}
+	if p.Puntaje == 0 {
+		p.Puntaje = 1
+	}
+}
+
 func (p *Pregunta) CreatePregunta(db *pgxpool.Pool) error {
+	p.completar()
 	now := time.Now()
 	return db.QueryRow(
 		context.Background(),
-		`INSERT INTO preguntas(enunciado, examenId, activo, createdAt, updatedAt)
-		VALUES($1, $2, $3, $4, $5)
+		`INSERT INTO preguntas(enunciado, tipo, puntaje, examenId, bancoId,
+		activo, createdAt, updatedAt)
+		VALUES($1, $2, $3, NULLIF($4, 0), NULLIF($5, 0), $6, $7, $8)
 		RETURNING id`,
-		p.Enunciado, p.ExamenId, p.Activo, now, now).Scan(&p.ID)
+		p.Enunciado, p.Tipo, p.Puntaje, p.ExamenId, p.BancoId,
+		p.Activo, now, now).Scan(&p.ID)
 }
 
 func (p *Pregunta) GetPregunta(db *pgxpool.Pool) error {
 	return db.QueryRow(
 		context.Background(),
-		`SELECT enunciado, examenId, activo, createdAt, updatedAt
+		`SELECT enunciado, tipo, puntaje, COALESCE(examenId, 0),
+		COALESCE(bancoId, 0), activo, createdAt, updatedAt
 		FROM preguntas
 		WHERE id=$1`,
-		p.ID).Scan(&p.Enunciado, &p.ExamenId, &p.Activo,
-		&p.CreatedAt, &p.UpdatedAt)
+		p.ID).Scan(&p.Enunciado, &p.Tipo, &p.Puntaje, &p.ExamenId,
+		&p.BancoId, &p.Activo, &p.CreatedAt, &p.UpdatedAt)
 }
 
-func GetPreguntas(db *pgxpool.Pool) ([]Pregunta, error) {
+// ListadoPreguntas son los filtros y ordenes de GET /preguntas
+var ListadoPreguntas = consulta.Definicion{
+	Campos: map[string]consulta.Campo{
+		"examenId":  {Columna: "examenId", Tipo: consulta.Entero, Filtro: true, Orden: true},
+		"bancoId":   {Columna: "bancoId", Tipo: consulta.Entero, Filtro: true, Orden: true},
+		"tipo":      {Columna: "tipo", Tipo: consulta.Texto, Filtro: true, Orden: true},
+		"puntaje":   {Columna: "puntaje", Tipo: consulta.Decimal, Orden: true},
+		"activo":    {Columna: "activo", Tipo: consulta.Booleano, Filtro: true},
+		"createdAt": {Columna: "createdAt", Tipo: consulta.Fecha, Orden: true},
+	},
+}
+
+func GetPreguntas(db *pgxpool.Pool, c consulta.Consulta) ([]Pregunta, int, error) {
+	total, err := contar(db, "preguntas", c)
+	if err != nil {
+		return nil, 0, err
+	}
+	where, args := c.Filtro()
+
 	rows, err := db.Query(
 		context.Background(),
-		`SELECT id, enunciado, examenId, activo, createdAt, updatedAt
-		FROM preguntas`)
+		`SELECT id, enunciado, tipo, puntaje, COALESCE(examenId, 0),
+		COALESCE(bancoId, 0), activo, createdAt, updatedAt
+		FROM preguntas`+where+c.OrdenYPagina(),
+		args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -53,26 +104,29 @@ func GetPreguntas(db *pgxpool.Pool) ([]Pregunta, error) {
 	for rows.Next() {
 		var p Pregunta
 		err := rows.Scan(
-			&p.ID, &p.Enunciado, &p.ExamenId,
-			&p.Activo, &p.CreatedAt, &p.UpdatedAt)
+			&p.ID, &p.Enunciado, &p.Tipo, &p.Puntaje, &p.ExamenId,
+			&p.BancoId, &p.Activo, &p.CreatedAt, &p.UpdatedAt)
 		if err != nil {
 			log.Printf("Las filas obtenidas de la BD para Pregunta, no satisfacen a 'Scan' %s",
 				err)
-			return nil, err
+			return nil, 0, err
 		}
 		preguntas = append(preguntas, p)
 	}
-	return preguntas, nil
+	return preguntas, total, rows.Err()
 }
 
 func (p *Pregunta) UpdatePregunta(db *pgxpool.Pool) error {
+	p.completar()
 	updTime := time.Now()
 	_, err := db.Exec(
 		context.Background(),
-		`UPDATE preguntas SET enunciado=$1, examenId=$2,
-		activo=$3, updatedAt=$4
-		WHERE id=$5`,
-		p.Enunciado, p.ExamenId, p.Activo, updTime, p.ID)
+		`UPDATE preguntas SET enunciado=$1, tipo=$2, puntaje=$3,
+		examenId=NULLIF($4, 0), bancoId=NULLIF($5, 0),
+		activo=$6, updatedAt=$7
+		WHERE id=$8`,
+		p.Enunciado, p.Tipo, p.Puntaje, p.ExamenId, p.BancoId,
+		p.Activo, updTime, p.ID)
 
 	return err
 }
@@ -85,3 +139,106 @@ func (p *Pregunta) DeletePregunta(db *pgxpool.Pool) error {
 
 	return err
 }
+
+// GetPreguntasExamen obtiene las preguntas del examen con sus alternativas
+func GetPreguntasExamen(db *pgxpool.Pool, examenId int) ([]Pregunta, error) {
+	return getPreguntasCompletas(db, `p.examenId=$1`, examenId)
+}
+
+// GetPreguntasBanco obtiene las preguntas del banco con sus alternativas
+func GetPreguntasBanco(db *pgxpool.Pool, bancoId int) ([]Pregunta, error) {
+	return getPreguntasCompletas(db, `p.bancoId=$1`, bancoId)
+}
+
+func getPreguntasCompletas(db *pgxpool.Pool, filtro string, id int) ([]Pregunta, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT p.id, p.enunciado, p.tipo, p.puntaje, COALESCE(p.examenId, 0),
+		COALESCE(p.bancoId, 0), p.activo, p.createdAt, p.updatedAt,
+		COALESCE(a.id, 0), COALESCE(a.valor, ''), COALESCE(a.correcto, false),
+		COALESCE(a.tolerancia, 0), COALESCE(a.activo, false)
+		FROM preguntas p
+		LEFT JOIN alternativas a ON a.preguntaId = p.id
+		WHERE `+filtro+`
+		ORDER BY p.id, a.id`,
+		id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	preguntas := []Pregunta{}
+	for rows.Next() {
+		var p Pregunta
+		var a Alternativa
+		err := rows.Scan(
+			&p.ID, &p.Enunciado, &p.Tipo, &p.Puntaje, &p.ExamenId,
+			&p.BancoId, &p.Activo, &p.CreatedAt, &p.UpdatedAt,
+			&a.ID, &a.Valor, &a.Correcto, &a.Tolerancia, &a.Activo)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Pregunta, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		if n := len(preguntas); n == 0 || preguntas[n-1].ID != p.ID {
+			p.Alternativas = []Alternativa{}
+			preguntas = append(preguntas, p)
+		}
+		if a.ID != 0 {
+			a.PreguntaId = p.ID
+			ultima := &preguntas[len(preguntas)-1]
+			ultima.Alternativas = append(ultima.Alternativas, a)
+		}
+	}
+	return preguntas, rows.Err()
+}
+
+// ImportarPreguntas crea las preguntas con sus alternativas en una sola
+// transaccion
+func ImportarPreguntas(db *pgxpool.Pool, preguntas []Pregunta) error {
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	for i := range preguntas {
+		if err := crearPreguntaTx(tx, &preguntas[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(context.Background())
+}
+
+func crearPreguntaTx(tx pgx.Tx, p *Pregunta) error {
+	p.completar()
+	now := time.Now()
+	err := tx.QueryRow(
+		context.Background(),
+		`INSERT INTO preguntas(enunciado, tipo, puntaje, examenId, bancoId,
+		activo, createdAt, updatedAt)
+		VALUES($1, $2, $3, NULLIF($4, 0), NULLIF($5, 0), $6, $7, $7)
+		RETURNING id`,
+		p.Enunciado, p.Tipo, p.Puntaje, p.ExamenId, p.BancoId, p.Activo, now,
+	).Scan(&p.ID)
+	if err != nil {
+		return err
+	}
+
+	for j := range p.Alternativas {
+		a := &p.Alternativas[j]
+		a.PreguntaId = p.ID
+		err := tx.QueryRow(
+			context.Background(),
+			`INSERT INTO alternativas(valor, correcto, tolerancia, preguntaId,
+			activo, createdAt, updatedAt)
+			VALUES($1, $2, $3, $4, $5, $6, $6)
+			RETURNING id`,
+			a.Valor, a.Correcto, a.Tolerancia, a.PreguntaId, a.Activo, now,
+		).Scan(&a.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
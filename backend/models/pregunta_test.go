@@ -3,6 +3,7 @@ package models
 import (
 	"testing"
 
+	"github.com/blackadress/vaula/consulta"
 	"github.com/blackadress/vaula/utils"
 	"github.com/jackc/pgx/v4"
 )
@@ -49,7 +50,7 @@ func TestNotGetPregunta(t *testing.T) {
 func TestGetPreguntas(t *testing.T) {
 	utils.ClearTablePregunta(db)
 	utils.AddPreguntas(2, db)
-	preguntas, err := GetPreguntas(db)
+	preguntas, _, err := GetPreguntas(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("algo salio mal con la comunicacion con la DB %s", err)
 	}
@@ -63,7 +64,7 @@ func TestGetPreguntas(t *testing.T) {
 func TestGetZeroPreguntas(t *testing.T) {
 	utils.ClearTablePregunta(db)
 
-	preguntas, err := GetPreguntas(db)
+	preguntas, _, err := GetPreguntas(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
 	}
@@ -9,10 +9,10 @@ import (
 )
 
 type PreguntaTrabajo struct {
-	ID        int     `json:"id"`
-	Enunciado string  `json:"Enunciado"`
-	TrabajoId int     `json:"trabajoId"`
-	Trabajo   Trabajo `json:"trabajo"`
+	ID        int      `json:"id"`
+	Enunciado string   `json:"Enunciado"`
+	TrabajoId int      `json:"trabajoId"`
+	Trabajo   *Trabajo `json:"trabajo,omitempty"`
 
 	Activo    bool      `json:"activo"`
 	CreatedAt time.Time `json:"createdAt"`
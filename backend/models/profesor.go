@@ -13,7 +13,7 @@ type Profesor struct {
 	Nombres   string `json:"nombres"`
 	Apellidos string `json:"apellidos"`
 	UsuarioId int    `json:"usuarioId"`
-	Usuario   User   `json:"usuario"`
+	Usuario   *User  `json:"usuario,omitempty"`
 
 	Activo    bool      `json:"activo"`
 	CreatedAt time.Time `json:"createdAt"`
@@ -43,6 +43,17 @@ func (p *Profesor) GetProfesor(db *pgxpool.Pool) error {
 		&p.Activo, &p.CreatedAt, &p.UpdatedAt)
 }
 
+func (p *Profesor) GetProfesorByUsuario(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT id, nombres, apellidos,
+		activo, createdAt, updatedAt
+		FROM profesores
+		WHERE usuarioId=$1`,
+		p.UsuarioId).Scan(&p.ID, &p.Nombres, &p.Apellidos,
+		&p.Activo, &p.CreatedAt, &p.UpdatedAt)
+}
+
 func GetProfesores(db *pgxpool.Pool) ([]Profesor, error) {
 	rows, err := db.Query(
 		context.Background(),
@@ -92,3 +103,35 @@ func (p *Profesor) DeleteProfesor(db *pgxpool.Pool) error {
 
 	return err
 }
+
+// ProfesorCurso asigna un profesor a un curso
+type ProfesorCurso struct {
+	ID         int       `json:"id"`
+	ProfesorId int       `json:"profesorId"`
+	CursoId    int       `json:"cursoId"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// CreateProfesorCurso asigna el profesor; si ya estaba asignado no hace nada
+func (pc *ProfesorCurso) CreateProfesorCurso(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO profesorCurso(profesorId, cursoId, createdAt)
+		VALUES($1, $2, $3)
+		ON CONFLICT (profesorId, cursoId)
+		DO UPDATE SET profesorId=EXCLUDED.profesorId
+		RETURNING id, createdAt`,
+		pc.ProfesorId, pc.CursoId, time.Now(),
+	).Scan(&pc.ID, &pc.CreatedAt)
+}
+
+// CursoTieneProfesores indica si el curso ya tiene algun profesor asignado
+func CursoTieneProfesores(db *pgxpool.Pool, cursoId int) (bool, error) {
+	var tiene bool
+	err := db.QueryRow(
+		context.Background(),
+		`SELECT EXISTS (SELECT 1 FROM profesorCurso WHERE cursoId=$1)`,
+		cursoId,
+	).Scan(&tiene)
+	return tiene, err
+}
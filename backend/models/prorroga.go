@@ -0,0 +1,251 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Prorroga es una excepcion a las condiciones de un Examen o Trabajo
+// para un alumno: adecuaciones aprobadas (tiempo extra) o extensiones
+// individuales. Solo uno de ExamenId o TrabajoId debe ser distinto de 0.
+type Prorroga struct {
+	ID            int        `json:"id"`
+	AlumnoId      int        `json:"alumnoId"`
+	ExamenId      int        `json:"examenId"`
+	TrabajoId     int        `json:"trabajoId"`
+	FechaFinal    *time.Time `json:"fechaFinal"`
+	MinutosExtra  int        `json:"minutosExtra"`
+	IntentosExtra int        `json:"intentosExtra"`
+	Motivo        string     `json:"motivo"`
+	OtorgadoPor   int        `json:"otorgadoPor"` // usuarioId
+
+	Activo    bool      `json:"activo"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ProrrogaHistorial registra quien creo, modifico o elimino una prorroga
+type ProrrogaHistorial struct {
+	ID         int             `json:"id"`
+	ProrrogaId int             `json:"prorrogaId"`
+	UsuarioId  int             `json:"usuarioId"`
+	Accion     string          `json:"accion"`
+	Detalle    json.RawMessage `json:"detalle"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+const (
+	AccionCrear      = "crear"
+	AccionActualizar = "actualizar"
+	AccionEliminar   = "eliminar"
+)
+
+func (p *Prorroga) CreateProrroga(db *pgxpool.Pool) error {
+	now := time.Now()
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	err = tx.QueryRow(
+		context.Background(),
+		`INSERT INTO prorrogas(alumnoId, examenId, trabajoId, fechaFinal,
+		minutosExtra, intentosExtra, motivo, otorgadoPor,
+		activo, createdAt, updatedAt)
+		VALUES($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, createdAt, updatedAt`,
+		p.AlumnoId, p.ExamenId, p.TrabajoId, p.FechaFinal,
+		p.MinutosExtra, p.IntentosExtra, p.Motivo, p.OtorgadoPor,
+		p.Activo, now, now).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err = p.registrar(tx, p.OtorgadoPor, AccionCrear); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+func (p *Prorroga) GetProrroga(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT alumnoId, COALESCE(examenId, 0), COALESCE(trabajoId, 0),
+		fechaFinal, minutosExtra, intentosExtra, motivo, otorgadoPor,
+		activo, createdAt, updatedAt
+		FROM prorrogas
+		WHERE id=$1`,
+		p.ID).Scan(&p.AlumnoId, &p.ExamenId, &p.TrabajoId,
+		&p.FechaFinal, &p.MinutosExtra, &p.IntentosExtra, &p.Motivo, &p.OtorgadoPor,
+		&p.Activo, &p.CreatedAt, &p.UpdatedAt)
+}
+
+// GetProrrogaExamen obtiene la prorroga activa del alumno para el examen.
+// Si no existe se deja la prorroga vacia y no se devuelve error.
+func (p *Prorroga) GetProrrogaExamen(db *pgxpool.Pool) error {
+	return p.getActiva(db, `examenId=$2`, p.ExamenId)
+}
+
+// GetProrrogaTrabajo obtiene la prorroga activa del alumno para el trabajo.
+// Si no existe se deja la prorroga vacia y no se devuelve error.
+func (p *Prorroga) GetProrrogaTrabajo(db *pgxpool.Pool) error {
+	return p.getActiva(db, `trabajoId=$2`, p.TrabajoId)
+}
+
+func (p *Prorroga) getActiva(db *pgxpool.Pool, filtro string, id int) error {
+	err := db.QueryRow(
+		context.Background(),
+		`SELECT id, COALESCE(examenId, 0), COALESCE(trabajoId, 0),
+		fechaFinal, minutosExtra, intentosExtra, motivo, otorgadoPor,
+		activo, createdAt, updatedAt
+		FROM prorrogas
+		WHERE alumnoId=$1 AND activo AND `+filtro+`
+		ORDER BY updatedAt DESC
+		LIMIT 1`,
+		p.AlumnoId, id).Scan(&p.ID, &p.ExamenId, &p.TrabajoId,
+		&p.FechaFinal, &p.MinutosExtra, &p.IntentosExtra, &p.Motivo, &p.OtorgadoPor,
+		&p.Activo, &p.CreatedAt, &p.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+// GetProrrogasExamen lista las prorrogas otorgadas para un examen
+func GetProrrogasExamen(db *pgxpool.Pool, examenId int) ([]Prorroga, error) {
+	return getProrrogas(db, `WHERE examenId=$1`, examenId)
+}
+
+// GetProrrogasTrabajo lista las prorrogas otorgadas para un trabajo
+func GetProrrogasTrabajo(db *pgxpool.Pool, trabajoId int) ([]Prorroga, error) {
+	return getProrrogas(db, `WHERE trabajoId=$1`, trabajoId)
+}
+
+func getProrrogas(db *pgxpool.Pool, filtro string, args ...interface{}) ([]Prorroga, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, alumnoId, COALESCE(examenId, 0), COALESCE(trabajoId, 0),
+		fechaFinal, minutosExtra, intentosExtra, motivo, otorgadoPor,
+		activo, createdAt, updatedAt
+		FROM prorrogas `+filtro+`
+		ORDER BY id`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prorrogas := []Prorroga{}
+	for rows.Next() {
+		var p Prorroga
+		err := rows.Scan(
+			&p.ID, &p.AlumnoId, &p.ExamenId, &p.TrabajoId,
+			&p.FechaFinal, &p.MinutosExtra, &p.IntentosExtra, &p.Motivo, &p.OtorgadoPor,
+			&p.Activo, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Prorroga, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		prorrogas = append(prorrogas, p)
+	}
+	return prorrogas, nil
+}
+
+// UpdateProrroga actualiza la prorroga dejando constancia de 'usuarioId'
+// en el historial
+func (p *Prorroga) UpdateProrroga(db *pgxpool.Pool, usuarioId int) error {
+	updTime := time.Now()
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	err = tx.QueryRow(
+		context.Background(),
+		`UPDATE prorrogas SET alumnoId=$1, examenId=NULLIF($2, 0),
+		trabajoId=NULLIF($3, 0), fechaFinal=$4, minutosExtra=$5,
+		intentosExtra=$6, motivo=$7, activo=$8, updatedAt=$9
+		WHERE id=$10
+		RETURNING otorgadoPor, createdAt, updatedAt`,
+		p.AlumnoId, p.ExamenId, p.TrabajoId, p.FechaFinal, p.MinutosExtra,
+		p.IntentosExtra, p.Motivo, p.Activo, updTime, p.ID,
+	).Scan(&p.OtorgadoPor, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err = p.registrar(tx, usuarioId, AccionActualizar); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+// DeleteProrroga elimina la prorroga. El historial se conserva.
+func (p *Prorroga) DeleteProrroga(db *pgxpool.Pool, usuarioId int) error {
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if err = p.registrar(tx, usuarioId, AccionEliminar); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		context.Background(),
+		`DELETE FROM prorrogas WHERE id=$1`,
+		p.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+func (p *Prorroga) registrar(tx pgx.Tx, usuarioId int, accion string) error {
+	detalle, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		context.Background(),
+		`INSERT INTO prorrogasHistorial(prorrogaId, usuarioId, accion, detalle, createdAt)
+		VALUES($1, $2, $3, $4, $5)`,
+		p.ID, usuarioId, accion, detalle, time.Now())
+	return err
+}
+
+func GetProrrogaHistorial(db *pgxpool.Pool, prorrogaId int) ([]ProrrogaHistorial, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, prorrogaId, usuarioId, accion, detalle, createdAt
+		FROM prorrogasHistorial
+		WHERE prorrogaId=$1
+		ORDER BY id`,
+		prorrogaId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	historial := []ProrrogaHistorial{}
+	for rows.Next() {
+		var h ProrrogaHistorial
+		err := rows.Scan(&h.ID, &h.ProrrogaId, &h.UsuarioId,
+			&h.Accion, &h.Detalle, &h.CreatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para ProrrogaHistorial, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		historial = append(historial, h)
+	}
+	return historial, nil
+}
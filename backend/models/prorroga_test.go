@@ -0,0 +1,168 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/utils"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestCreateProrroga(t *testing.T) {
+	utils.ClearTableProrroga(db)
+	utils.AddAlumnos(1, db)
+	utils.ClearTableCurso(db)
+	utils.AddExamenes(1, db)
+
+	p := Prorroga{
+		AlumnoId:      1,
+		ExamenId:      1,
+		MinutosExtra:  30,
+		IntentosExtra: 1,
+		Motivo:        "adecuacion aprobada",
+		OtorgadoPor:   1,
+		Activo:        true,
+	}
+	err := p.CreateProrroga(db)
+	if err != nil {
+		t.Errorf("No se creo la prorroga %s", err)
+	}
+
+	if p.ID != 1 {
+		t.Errorf("Se esperaba crear una prorroga con ID 1. Se obtuvo %d", p.ID)
+	}
+
+	historial, err := GetProrrogaHistorial(db, p.ID)
+	if err != nil {
+		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
+	}
+	if len(historial) != 1 || historial[0].Accion != AccionCrear {
+		t.Errorf("Se esperaba una entrada 'crear' en el historial. Se obtuvo %v", historial)
+	}
+}
+
+func TestGetProrroga(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddProrrogas(1, db)
+
+	p := Prorroga{ID: 1}
+	err := p.GetProrroga(db)
+	if err != nil {
+		t.Errorf("Se esperaba obtener la prorroga con ID 1. Se obtuvo %v", err)
+	}
+	if p.ExamenId != 1 || p.TrabajoId != 0 {
+		t.Errorf("Se esperaba una prorroga del examen 1. Se obtuvo %v", p)
+	}
+}
+
+func TestNotGetProrroga(t *testing.T) {
+	utils.ClearTableProrroga(db)
+	p := Prorroga{ID: 1}
+	err := p.GetProrroga(db)
+	if err != pgx.ErrNoRows {
+		t.Errorf("Se esperaba error ErrNoRows, se obtuvo diferente error. ERROR %v", err)
+	}
+}
+
+func TestGetProrrogaExamenSinProrroga(t *testing.T) {
+	utils.ClearTableProrroga(db)
+
+	p := Prorroga{AlumnoId: 1, ExamenId: 1}
+	err := p.GetProrrogaExamen(db)
+	if err != nil {
+		t.Errorf("No se esperaba error si el alumno no tiene prorroga. ERROR %v", err)
+	}
+	if p.ID != 0 || p.Activo {
+		t.Errorf("Se esperaba una prorroga vacia. Se obtuvo %v", p)
+	}
+}
+
+func TestGetProrrogasExamen(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddProrrogas(2, db)
+
+	prorrogas, err := GetProrrogasExamen(db, 1)
+	if err != nil {
+		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
+	}
+	if len(prorrogas) != 1 {
+		t.Errorf("Se esperaba obtener un array de 1 elemento. Se obtuvo: %v", prorrogas)
+	}
+}
+
+func TestUpdateProrroga(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddProrrogas(1, db)
+
+	original := Prorroga{ID: 1}
+	if err := original.GetProrroga(db); err != nil {
+		t.Errorf("El metodo GetProrroga fallo %s", err)
+	}
+
+	upd := original
+	upd.MinutosExtra = 45
+	upd.Motivo = "motivo_upd"
+	if err := upd.UpdateProrroga(db, 2); err != nil {
+		t.Errorf("El metodo UpdateProrroga fallo %s", err)
+	}
+
+	if upd.OtorgadoPor != original.OtorgadoPor {
+		t.Errorf("Se esperaba que otorgadoPor no cambiara de '%d'. Se obtuvo '%d'",
+			original.OtorgadoPor, upd.OtorgadoPor)
+	}
+
+	historial, err := GetProrrogaHistorial(db, 1)
+	if err != nil {
+		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
+	}
+	if len(historial) != 1 || historial[0].UsuarioId != 2 {
+		t.Errorf("Se esperaba registrar la actualizacion del usuario 2. Se obtuvo %v", historial)
+	}
+}
+
+func TestDeleteProrroga(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddProrrogas(1, db)
+
+	p := Prorroga{ID: 1}
+	p.GetProrroga(db)
+	err := p.DeleteProrroga(db, 1)
+	if err != nil {
+		t.Errorf("Ocurrio un error en el metodo DeleteProrroga %s", err)
+	}
+
+	historial, _ := GetProrrogaHistorial(db, 1)
+	if len(historial) != 1 || historial[0].Accion != AccionEliminar {
+		t.Errorf("Se esperaba que el historial se conserve. Se obtuvo %v", historial)
+	}
+}
+
+func TestCondicionesPara(t *testing.T) {
+	inicio := time.Date(2022, time.June, 20, 18, 0, 0, 0, time.UTC)
+	final := inicio.Add(2 * time.Hour)
+	extendida := final.Add(24 * time.Hour)
+	e := Examen{FechaInicio: inicio, FechaFinal: final, Duracion: 60, Intentos: 1}
+
+	c := e.CondicionesPara(Prorroga{})
+	if c.Duracion != 60 || c.Intentos != 1 || c.FechaFinal != final {
+		t.Errorf("Sin prorroga se esperaban las condiciones del examen. Se obtuvo %v", c)
+	}
+
+	c = e.CondicionesPara(Prorroga{
+		FechaFinal: &extendida, MinutosExtra: 30, IntentosExtra: 2, Activo: true})
+	if c.Duracion != 90 || c.Intentos != 3 || c.FechaFinal != extendida {
+		t.Errorf("Se esperaban las condiciones extendidas. Se obtuvo %v", c)
+	}
+
+	// el intento no puede terminar despues del cierre del examen
+	fin := c.FinIntento(extendida.Add(-10 * time.Minute))
+	if fin != extendida {
+		t.Errorf("Se esperaba que el intento termine en '%v'. Se obtuvo '%v'", extendida, fin)
+	}
+
+	sinLimite := Examen{FechaFinal: final}
+	c = sinLimite.CondicionesPara(Prorroga{MinutosExtra: 30, IntentosExtra: 2, Activo: true})
+	if c.Duracion != 0 || c.Intentos != 0 {
+		t.Errorf("Se esperaba que duracion e intentos siguieran ilimitados. Se obtuvo %v", c)
+	}
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// tipos de fecha que se recuerdan
+const (
+	RecordatorioExamen  = "examen"
+	RecordatorioTrabajo = "trabajo"
+)
+
+// AnticipacionesRecordatorio son los avisos antes de cada fecha, de mayor a
+// menor. Cada uno cubre desde su anticipacion hasta la siguiente, asi una
+// fecha cercana no recibe dos avisos a la vez.
+var AnticipacionesRecordatorio = []time.Duration{24 * time.Hour, time.Hour}
+
+// bloqueoRecordatorios es la clave del advisory lock de Postgres que
+// impide que dos instancias del servidor revisen las fechas a la vez
+const bloqueoRecordatorios = 430001
+
+// Recordatorio es un aviso pendiente para un alumno: el inicio de un examen
+// o el vencimiento de un trabajo, con su prorroga si la tiene
+type Recordatorio struct {
+	Tipo         string
+	ReferenciaId int
+	Nombre       string
+	AlumnoId     int
+	UsuarioId    int
+	Anticipacion time.Duration
+	Fecha        time.Time
+}
+
+// examenes activos que empiezan en la ventana, para los alumnos del curso
+// que aun no tienen intentos
+const recordatoriosExamenQuery = `
+WITH nuevos AS (
+	INSERT INTO recordatorios(tipo, referenciaId, alumnoId, anticipacion,
+	fecha, createdAt)
+	SELECT 'examen', e.id, ac.alumnoId, $3, e.fechaInicio, $4
+	FROM examenes e
+	JOIN alumnoCurso ac ON ac.cursoId = e.cursoId AND ac.activo
+	WHERE e.activo AND e.fechaInicio > $1 AND e.fechaInicio <= $2
+	AND NOT EXISTS (
+		SELECT 1 FROM alumnoExamen ae
+		WHERE ae.examenId = e.id AND ae.alumnoId = ac.alumnoId)
+	ON CONFLICT DO NOTHING
+	RETURNING referenciaId, alumnoId, fecha)
+SELECT n.referenciaId, e.nombre, n.alumnoId, al.usuarioId, n.fecha
+FROM nuevos n
+JOIN examenes e ON e.id = n.referenciaId
+JOIN alumnos al ON al.id = n.alumnoId
+ORDER BY n.fecha, n.referenciaId, n.alumnoId
+`
+
+// trabajos activos que vencen en la ventana, contando la prorroga de cada
+// alumno, para los que no entregaron archivo ni respuestas
+const recordatoriosTrabajoQuery = `
+WITH nuevos AS (
+	INSERT INTO recordatorios(tipo, referenciaId, alumnoId, anticipacion,
+	fecha, createdAt)
+	SELECT 'trabajo', t.id, ac.alumnoId, $3,
+		COALESCE(p.fechaFinal, t.fechaFinal), $4
+	FROM trabajos t
+	JOIN alumnoCurso ac ON ac.cursoId = t.cursoId AND ac.activo
+	LEFT JOIN prorrogas p ON p.trabajoId = t.id AND p.alumnoId = ac.alumnoId
+		AND p.activo
+	WHERE t.activo
+	AND COALESCE(p.fechaFinal, t.fechaFinal) > $1
+	AND COALESCE(p.fechaFinal, t.fechaFinal) <= $2
+	AND NOT EXISTS (
+		SELECT 1 FROM entregas en
+		WHERE en.trabajoId = t.id AND en.alumnoId = ac.alumnoId)
+	AND NOT EXISTS (
+		SELECT 1 FROM respuestasTrabajo rt
+		JOIN preguntasTrabajo pt ON pt.id = rt.preguntaTrabajoId
+		WHERE pt.trabajoId = t.id AND rt.alumnoId = ac.alumnoId)
+	ON CONFLICT DO NOTHING
+	RETURNING referenciaId, alumnoId, fecha)
+SELECT n.referenciaId, t.descripcion, n.alumnoId, al.usuarioId, n.fecha
+FROM nuevos n
+JOIN trabajos t ON t.id = n.referenciaId
+JOIN alumnos al ON al.id = n.alumnoId
+ORDER BY n.fecha, n.referenciaId, n.alumnoId
+`
+
+// EmitirRecordatorios registra y notifica los recordatorios que tocan en
+// 'ahora', con el texto que arma 'aviso'. Cada recordatorio se registra una
+// sola vez aunque el servidor se reinicie, y si otra instancia esta
+// revisando las fechas no hace nada. Devuelve cuantos recordatorios emitio.
+func EmitirRecordatorios(db *pgxpool.Pool, ahora time.Time, aviso func(Recordatorio) Notificacion) (int, error) {
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// el lock se libera al terminar la transaccion
+	var libre bool
+	if err := tx.QueryRow(ctx, "SELECT pg_try_advisory_xact_lock($1)",
+		bloqueoRecordatorios).Scan(&libre); err != nil {
+		return 0, err
+	}
+	if !libre {
+		return 0, nil
+	}
+
+	recordatorios := []Recordatorio{}
+	for i, anticipacion := range AnticipacionesRecordatorio {
+		desde := ahora
+		if i+1 < len(AnticipacionesRecordatorio) {
+			desde = ahora.Add(AnticipacionesRecordatorio[i+1])
+		}
+		hasta := ahora.Add(anticipacion)
+
+		for _, tipo := range []string{RecordatorioExamen, RecordatorioTrabajo} {
+			query := recordatoriosExamenQuery
+			if tipo == RecordatorioTrabajo {
+				query = recordatoriosTrabajoQuery
+			}
+			nuevos, err := reclamarRecordatorios(ctx, tx, query, tipo, anticipacion, desde, hasta, ahora)
+			if err != nil {
+				return 0, err
+			}
+			recordatorios = append(recordatorios, nuevos...)
+		}
+	}
+
+	for _, r := range recordatorios {
+		if err := notificarTx(ctx, tx, []int{r.UsuarioId}, aviso(r)); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(recordatorios), nil
+}
+
+// reclamarRecordatorios registra los recordatorios de la ventana
+// (desde, hasta] que aun no se enviaron y los devuelve
+func reclamarRecordatorios(ctx context.Context, tx pgx.Tx, query, tipo string,
+	anticipacion time.Duration, desde, hasta, ahora time.Time) ([]Recordatorio, error) {
+	horas := int(anticipacion / time.Hour)
+	rows, err := tx.Query(ctx, query, desde, hasta, horas, ahora)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recordatorios := []Recordatorio{}
+	for rows.Next() {
+		r := Recordatorio{Tipo: tipo, Anticipacion: anticipacion}
+		err := rows.Scan(&r.ReferenciaId, &r.Nombre, &r.AlumnoId, &r.UsuarioId, &r.Fecha)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Recordatorio, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		recordatorios = append(recordatorios, r)
+	}
+	return recordatorios, rows.Err()
+}
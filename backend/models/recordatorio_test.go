@@ -0,0 +1,86 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func avisoPrueba(r Recordatorio) Notificacion {
+	return Notificacion{Tipo: NotificacionVencimiento, Titulo: r.Nombre, ReferenciaId: r.ReferenciaId}
+}
+
+func TestEmitirRecordatorios(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(3, db)
+	ahora := time.Now().Truncate(time.Second)
+
+	trabajo := Trabajo{Descripcion: "Informe", CursoId: 1, Activo: true,
+		FechaInicio: ahora.Add(-48 * time.Hour), FechaFinal: ahora.Add(20 * time.Hour)}
+	trabajo.CreateTrabajo(db)
+	examen := Examen{Nombre: "Parcial", CursoId: 1, Activo: true,
+		FechaInicio: ahora.Add(30 * time.Minute), FechaFinal: ahora.Add(3 * time.Hour)}
+	examen.CreateExamen(db)
+
+	// el alumno 2 ya respondio el trabajo
+	pregunta := PreguntaTrabajo{Enunciado: "Resumen", TrabajoId: trabajo.ID}
+	pregunta.CreatePreguntaTrabajo(db)
+	respuesta := RespuestaTrabajo{PreguntaTrabajoId: pregunta.ID, AlumnoId: 2, Texto: "Listo"}
+	respuesta.GuardarRespuestaTrabajo(db)
+
+	emitidos, err := EmitirRecordatorios(db, ahora, avisoPrueba)
+	if err != nil {
+		t.Fatalf("El metodo EmitirRecordatorios fallo %s", err)
+	}
+	// examen para los 3 alumnos, trabajo para los alumnos 1 y 3
+	if emitidos != 5 {
+		t.Errorf("Se esperaban 5 recordatorios. Se obtuvo %d", emitidos)
+	}
+
+	// un reinicio no repite los recordatorios
+	if emitidos, _ := EmitirRecordatorios(db, ahora.Add(time.Minute), avisoPrueba); emitidos != 0 {
+		t.Errorf("No se esperaban recordatorios repetidos. Se obtuvo %d", emitidos)
+	}
+
+	// a una hora del vencimiento toca el segundo aviso del trabajo
+	emitidos, _ = EmitirRecordatorios(db, ahora.Add(19*time.Hour+30*time.Minute), avisoPrueba)
+	if emitidos != 2 {
+		t.Errorf("Se esperaban 2 recordatorios de una hora. Se obtuvo %d", emitidos)
+	}
+
+	notificaciones, _ := GetNotificacionesUsuario(db, 2, false)
+	if len(notificaciones) != 1 || notificaciones[0].Titulo != "Parcial" {
+		t.Errorf("Se esperaba solo el recordatorio del examen. Se obtuvo %v", notificaciones)
+	}
+}
+
+func TestRecordatorioConProrroga(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnoCursos(2, db)
+	ahora := time.Now().Truncate(time.Second)
+
+	trabajo := Trabajo{Descripcion: "Informe", CursoId: 1, Activo: true,
+		FechaInicio: ahora.Add(-48 * time.Hour), FechaFinal: ahora.Add(30 * time.Minute)}
+	trabajo.CreateTrabajo(db)
+
+	// el alumno 2 tiene tres dias mas
+	nuevaFecha := ahora.Add(72 * time.Hour)
+	prorroga := Prorroga{AlumnoId: 2, TrabajoId: trabajo.ID, FechaFinal: &nuevaFecha,
+		Motivo: "Salud", OtorgadoPor: 1, Activo: true}
+	if err := prorroga.CreateProrroga(db); err != nil {
+		t.Fatalf("El metodo CreateProrroga fallo %s", err)
+	}
+
+	if emitidos, _ := EmitirRecordatorios(db, ahora, avisoPrueba); emitidos != 1 {
+		t.Errorf("Se esperaba solo el recordatorio del alumno 1. Se obtuvo %d", emitidos)
+	}
+	if notificaciones, _ := GetNotificacionesUsuario(db, 2, false); len(notificaciones) != 0 {
+		t.Errorf("No se esperaban recordatorios para el alumno con prorroga. Se obtuvo %v", notificaciones)
+	}
+
+	// dos dias despues vence su prorroga
+	if emitidos, _ := EmitirRecordatorios(db, ahora.Add(50*time.Hour), avisoPrueba); emitidos != 1 {
+		t.Errorf("Se esperaba el recordatorio de la prorroga. Se obtuvo %d", emitidos)
+	}
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Respuesta de un alumno a una pregunta durante un intento. Las preguntas
+// de opcion multiple y verdadero/falso se responden con AlternativaId, las
+// demas con Texto.
+type Respuesta struct {
+	ID             int     `json:"id"`
+	AlumnoExamenId int     `json:"alumnoExamenId"`
+	PreguntaId     int     `json:"preguntaId"`
+	AlternativaId  int     `json:"alternativaId"`
+	Texto          string  `json:"texto"`
+	Puntaje        float32 `json:"puntaje"`
+	// desde donde se guardo, lo registra el servidor
+	Ip        string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// GuardarRespuesta crea o reemplaza la respuesta del intento a la pregunta
+func (r *Respuesta) GuardarRespuesta(db *pgxpool.Pool) error {
+	now := time.Now()
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO respuestas(alumnoExamenId, preguntaId, alternativaId,
+		texto, puntaje, ip, userAgent, createdAt, updatedAt)
+		VALUES($1, $2, NULLIF($3, 0), $4, $5, $6, $7, $8, $8)
+		ON CONFLICT (alumnoExamenId, preguntaId)
+		DO UPDATE SET alternativaId=EXCLUDED.alternativaId, texto=EXCLUDED.texto,
+		puntaje=EXCLUDED.puntaje, ip=EXCLUDED.ip, userAgent=EXCLUDED.userAgent,
+		updatedAt=EXCLUDED.updatedAt
+		RETURNING id, createdAt, updatedAt`,
+		r.AlumnoExamenId, r.PreguntaId, r.AlternativaId, r.Texto, r.Puntaje,
+		r.Ip, r.UserAgent, now,
+	).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+}
+
+func GetRespuestasIntento(db *pgxpool.Pool, alumnoExamenId int) ([]Respuesta, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, alumnoExamenId, preguntaId, COALESCE(alternativaId, 0),
+		texto, puntaje, ip, userAgent, createdAt, updatedAt
+		FROM respuestas
+		WHERE alumnoExamenId=$1
+		ORDER BY preguntaId`,
+		alumnoExamenId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	respuestas := []Respuesta{}
+	for rows.Next() {
+		var r Respuesta
+		err := rows.Scan(&r.ID, &r.AlumnoExamenId, &r.PreguntaId, &r.AlternativaId,
+			&r.Texto, &r.Puntaje, &r.Ip, &r.UserAgent, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Respuesta, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		respuestas = append(respuestas, r)
+	}
+	return respuestas, nil
+}
+
+// Calificar pone el puntaje de la respuesta segun las alternativas
+// correctas de la pregunta. Los ensayos quedan en 0 hasta que el profesor
+// los califique.
+func (r *Respuesta) Calificar(p Pregunta) {
+	r.Puntaje = 0
+	for _, a := range p.Alternativas {
+		if a.Correcto && respuestaCoincide(p.Tipo, *r, a) {
+			r.Puntaje = p.Puntaje
+			return
+		}
+	}
+}
+
+const margenNumerico = 1e-6
+
+func respuestaCoincide(tipo string, r Respuesta, a Alternativa) bool {
+	switch tipo {
+	case TipoOpcionMultiple, TipoVerdaderoFalso:
+		return r.AlternativaId == a.ID
+	case TipoRespuestaCorta:
+		return strings.EqualFold(strings.TrimSpace(r.Texto), strings.TrimSpace(a.Valor))
+	case TipoNumerica:
+		valor, err := parseDecimal(r.Texto)
+		if err != nil {
+			return false
+		}
+		esperado, err := parseDecimal(a.Valor)
+		if err != nil {
+			return false
+		}
+		// la tolerancia se guarda como REAL, el margen evita que una
+		// respuesta justo en el limite quede fuera por redondeo
+		return math.Abs(valor-esperado) <= float64(a.Tolerancia)+margenNumerico
+	}
+	return false
+}
+
+// parseDecimal acepta coma o punto decimal
+func parseDecimal(s string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(strings.TrimSpace(s), ",", ".", 1), 64)
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestCalificarRespuesta(t *testing.T) {
+	casos := []struct {
+		pregunta  Pregunta
+		respuesta Respuesta
+		puntaje   float32
+	}{
+		{Pregunta{Tipo: TipoOpcionMultiple, Puntaje: 2, Alternativas: []Alternativa{{ID: 1, Correcto: true}, {ID: 2}}},
+			Respuesta{AlternativaId: 1}, 2},
+		{Pregunta{Tipo: TipoOpcionMultiple, Puntaje: 2, Alternativas: []Alternativa{{ID: 1, Correcto: true}, {ID: 2}}},
+			Respuesta{AlternativaId: 2}, 0},
+		{Pregunta{Tipo: TipoRespuestaCorta, Puntaje: 1, Alternativas: []Alternativa{{Valor: "Lima", Correcto: true}}},
+			Respuesta{Texto: " lima "}, 1},
+		{Pregunta{Tipo: TipoNumerica, Puntaje: 1, Alternativas: []Alternativa{{Valor: "3.14", Correcto: true, Tolerancia: 0.01}}},
+			Respuesta{Texto: "3,15"}, 1},
+		{Pregunta{Tipo: TipoNumerica, Puntaje: 1, Alternativas: []Alternativa{{Valor: "3.14", Correcto: true, Tolerancia: 0.01}}},
+			Respuesta{Texto: "3.2"}, 0},
+		{Pregunta{Tipo: TipoEnsayo, Puntaje: 4}, Respuesta{Texto: "desarrollo"}, 0},
+	}
+	for i, c := range casos {
+		c.respuesta.Calificar(c.pregunta)
+		if c.respuesta.Puntaje != c.puntaje {
+			t.Errorf("Caso %d: se esperaba puntaje %v. Se obtuvo %v", i, c.puntaje, c.respuesta.Puntaje)
+		}
+	}
+}
+
+func TestGuardarRespuesta(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddIntentos(1, db)
+	utils.AddPreguntas(1, db)
+
+	r := Respuesta{AlumnoExamenId: 1, PreguntaId: 1, Texto: "primera"}
+	if err := r.GuardarRespuesta(db); err != nil {
+		t.Fatalf("No se guardo la respuesta %s", err)
+	}
+
+	// guardar otra vez la misma pregunta reemplaza la respuesta
+	r2 := Respuesta{AlumnoExamenId: 1, PreguntaId: 1, Texto: "segunda", Puntaje: 1}
+	if err := r2.GuardarRespuesta(db); err != nil {
+		t.Fatalf("No se reemplazo la respuesta %s", err)
+	}
+	if r2.ID != r.ID {
+		t.Errorf("Se esperaba reemplazar la respuesta %d. Se obtuvo %d", r.ID, r2.ID)
+	}
+
+	respuestas, err := GetRespuestasIntento(db, 1)
+	if err != nil {
+		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
+	}
+	if len(respuestas) != 1 || respuestas[0].Texto != "segunda" {
+		t.Errorf("Se esperaba solo la respuesta 'segunda'. Se obtuvo %v", respuestas)
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// RespuestaTrabajo es lo que el alumno escribe para una PreguntaTrabajo.
+// Cada alumno tiene una sola respuesta por pregunta, que puede reemplazar
+// mientras el trabajo recibe entregas.
+type RespuestaTrabajo struct {
+	ID                int    `json:"id"`
+	PreguntaTrabajoId int    `json:"preguntaTrabajoId"`
+	AlumnoId          int    `json:"alumnoId"`
+	Texto             string `json:"texto"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// GuardarRespuestaTrabajo crea o reemplaza la respuesta del alumno
+func (r *RespuestaTrabajo) GuardarRespuestaTrabajo(db *pgxpool.Pool) error {
+	now := time.Now()
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO respuestasTrabajo(preguntaTrabajoId, alumnoId, texto,
+		createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $4)
+		ON CONFLICT (preguntaTrabajoId, alumnoId)
+		DO UPDATE SET texto=EXCLUDED.texto, updatedAt=EXCLUDED.updatedAt
+		RETURNING id, createdAt, updatedAt`,
+		r.PreguntaTrabajoId, r.AlumnoId, r.Texto, now,
+	).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// GetRespuestasTrabajo devuelve las respuestas a todas las preguntas del
+// trabajo, agrupadas por pregunta
+func GetRespuestasTrabajo(db *pgxpool.Pool, trabajoId int) ([]RespuestaTrabajo, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT r.id, r.preguntaTrabajoId, r.alumnoId, r.texto, r.createdAt,
+		r.updatedAt
+		FROM respuestasTrabajo r
+		JOIN preguntasTrabajo p ON p.id = r.preguntaTrabajoId
+		WHERE p.trabajoId=$1
+		ORDER BY r.preguntaTrabajoId, r.alumnoId`,
+		trabajoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	respuestas := []RespuestaTrabajo{}
+	for rows.Next() {
+		var r RespuestaTrabajo
+		err := rows.Scan(&r.ID, &r.PreguntaTrabajoId, &r.AlumnoId, &r.Texto,
+			&r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para RespuestaTrabajo, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		respuestas = append(respuestas, r)
+	}
+	return respuestas, nil
+}
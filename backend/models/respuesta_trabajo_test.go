@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestGuardarRespuestaTrabajo(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddAlumnos(1, db)
+	utils.AddPreguntaTrabajos(1, db)
+
+	rt := RespuestaTrabajo{PreguntaTrabajoId: 1, AlumnoId: 1, Texto: "primera version"}
+	if err := rt.GuardarRespuestaTrabajo(db); err != nil {
+		t.Fatalf("No se guardo la respuesta %s", err)
+	}
+
+	// guardar de nuevo reemplaza la respuesta del alumno
+	otra := RespuestaTrabajo{PreguntaTrabajoId: 1, AlumnoId: 1, Texto: "segunda version"}
+	if err := otra.GuardarRespuestaTrabajo(db); err != nil {
+		t.Fatalf("No se reemplazo la respuesta %s", err)
+	}
+	if otra.ID != rt.ID {
+		t.Errorf("Se esperaba reemplazar la respuesta %d. Se obtuvo %d", rt.ID, otra.ID)
+	}
+
+	respuestas, err := GetRespuestasTrabajo(db, 1)
+	if err != nil {
+		t.Fatalf("El metodo GetRespuestasTrabajo fallo %s", err)
+	}
+	if len(respuestas) != 1 || respuestas[0].Texto != "segunda version" {
+		t.Errorf("Se esperaba solo la segunda version. Se obtuvo %v", respuestas)
+	}
+}
@@ -0,0 +1,509 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Rubrica es una plantilla de evaluacion reutilizable entre cursos:
+// cada criterio tiene niveles con un puntaje
+type Rubrica struct {
+	ID          int        `json:"id"`
+	Nombre      string     `json:"nombre"`
+	Descripcion string     `json:"descripcion"`
+	CreadoPor   int        `json:"creadoPor"` // usuarioId
+	Criterios   []Criterio `json:"criterios"`
+
+	Activo    bool      `json:"activo"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type Criterio struct {
+	ID          int     `json:"id"`
+	RubricaId   int     `json:"rubricaId"`
+	Nombre      string  `json:"nombre"`
+	Descripcion string  `json:"descripcion"`
+	Orden       int     `json:"orden"`
+	Niveles     []Nivel `json:"niveles"`
+}
+
+type Nivel struct {
+	ID          int     `json:"id"`
+	CriterioId  int     `json:"criterioId"`
+	Nombre      string  `json:"nombre"`
+	Descripcion string  `json:"descripcion"`
+	Puntos      float32 `json:"puntos"`
+}
+
+// RubricaAsignada une una rubrica con un Trabajo completo o con una de sus
+// PreguntaTrabajo. Solo uno de TrabajoId o PreguntaTrabajoId es distinto de 0.
+type RubricaAsignada struct {
+	ID                int     `json:"id"`
+	RubricaId         int     `json:"rubricaId"`
+	Rubrica           Rubrica `json:"rubrica"`
+	TrabajoId         int     `json:"trabajoId"`
+	PreguntaTrabajoId int     `json:"preguntaTrabajoId"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Puntaje es el nivel elegido en un criterio al evaluar una entrega
+type Puntaje struct {
+	ID              int       `json:"id"`
+	AlumnoTrabajoId int       `json:"alumnoTrabajoId"`
+	AsignacionId    int       `json:"asignacionId"`
+	CriterioId      int       `json:"criterioId"`
+	NivelId         int       `json:"nivelId"`
+	Puntos          float32   `json:"puntos"`
+	Comentario      string    `json:"comentario"`
+	EvaluadoPor     int       `json:"evaluadoPor"` // usuarioId
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// ErrRubricaEnUso se devuelve al intentar cambiar los criterios de una
+// rubrica con la que ya se evaluaron entregas
+var ErrRubricaEnUso = errors.New("la rubrica ya se uso para evaluar entregas")
+
+// ErrRubricaYaAsignada se devuelve al asignar dos veces la misma rubrica
+// al mismo trabajo o pregunta
+var ErrRubricaYaAsignada = errors.New("la rubrica ya esta asignada")
+
+// ErrPuntajeInvalido se devuelve cuando un puntaje no corresponde a las
+// rubricas asignadas al trabajo
+var ErrPuntajeInvalido = errors.New("el puntaje no corresponde a las rubricas del trabajo")
+
+func (r *Rubrica) CreateRubrica(db *pgxpool.Pool) error {
+	now := time.Now()
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	err = tx.QueryRow(
+		context.Background(),
+		`INSERT INTO rubricas(nombre, descripcion, creadoPor, activo, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $5, $6)
+		RETURNING id, createdAt, updatedAt`,
+		r.Nombre, r.Descripcion, r.CreadoPor, r.Activo, now, now,
+	).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err = r.createCriterios(tx); err != nil {
+		return err
+	}
+	return tx.Commit(context.Background())
+}
+
+func (r *Rubrica) createCriterios(tx pgx.Tx) error {
+	for i := range r.Criterios {
+		c := &r.Criterios[i]
+		c.RubricaId = r.ID
+		if c.Orden == 0 {
+			c.Orden = i + 1
+		}
+		err := tx.QueryRow(
+			context.Background(),
+			`INSERT INTO rubricaCriterios(rubricaId, nombre, descripcion, orden)
+			VALUES($1, $2, $3, $4)
+			RETURNING id`,
+			c.RubricaId, c.Nombre, c.Descripcion, c.Orden,
+		).Scan(&c.ID)
+		if err != nil {
+			return err
+		}
+
+		for j := range c.Niveles {
+			n := &c.Niveles[j]
+			n.CriterioId = c.ID
+			err := tx.QueryRow(
+				context.Background(),
+				`INSERT INTO rubricaNiveles(criterioId, nombre, descripcion, puntos)
+				VALUES($1, $2, $3, $4)
+				RETURNING id`,
+				n.CriterioId, n.Nombre, n.Descripcion, n.Puntos,
+			).Scan(&n.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetRubrica obtiene la rubrica con sus criterios y niveles
+func (r *Rubrica) GetRubrica(db *pgxpool.Pool) error {
+	err := db.QueryRow(
+		context.Background(),
+		`SELECT nombre, descripcion, creadoPor, activo, createdAt, updatedAt
+		FROM rubricas
+		WHERE id=$1`,
+		r.ID).Scan(&r.Nombre, &r.Descripcion, &r.CreadoPor,
+		&r.Activo, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return r.getCriterios(db)
+}
+
+func (r *Rubrica) getCriterios(db *pgxpool.Pool) error {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT c.id, c.nombre, c.descripcion, c.orden,
+		n.id, n.nombre, n.descripcion, n.puntos
+		FROM rubricaCriterios c
+		LEFT JOIN rubricaNiveles n ON n.criterioId = c.id
+		WHERE c.rubricaId=$1
+		ORDER BY c.orden, c.id, n.puntos, n.id`,
+		r.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	r.Criterios = []Criterio{}
+	for rows.Next() {
+		var c Criterio
+		var nivelId *int
+		var nivelNombre, nivelDescripcion *string
+		var puntos *float32
+		err := rows.Scan(&c.ID, &c.Nombre, &c.Descripcion, &c.Orden,
+			&nivelId, &nivelNombre, &nivelDescripcion, &puntos)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Criterio, no satisfacen a 'Scan' %s",
+				err)
+			return err
+		}
+		last := len(r.Criterios) - 1
+		if last < 0 || r.Criterios[last].ID != c.ID {
+			c.RubricaId = r.ID
+			c.Niveles = []Nivel{}
+			r.Criterios = append(r.Criterios, c)
+			last++
+		}
+		if nivelId != nil {
+			r.Criterios[last].Niveles = append(r.Criterios[last].Niveles, Nivel{
+				ID: *nivelId, CriterioId: c.ID, Nombre: *nivelNombre,
+				Descripcion: *nivelDescripcion, Puntos: *puntos,
+			})
+		}
+	}
+	return rows.Err()
+}
+
+// GetRubricas lista las rubricas sin sus criterios
+func GetRubricas(db *pgxpool.Pool) ([]Rubrica, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, nombre, descripcion, creadoPor, activo, createdAt, updatedAt
+		FROM rubricas
+		ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rubricas := []Rubrica{}
+	for rows.Next() {
+		var r Rubrica
+		err := rows.Scan(&r.ID, &r.Nombre, &r.Descripcion, &r.CreadoPor,
+			&r.Activo, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Rubrica, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		rubricas = append(rubricas, r)
+	}
+	return rubricas, nil
+}
+
+// rubricaEnUso consulta si hay puntajes registrados con la rubrica $1
+const rubricaEnUso = `SELECT EXISTS(
+	SELECT 1 FROM rubricaPuntajes p
+	JOIN rubricaCriterios c ON c.id = p.criterioId
+	WHERE c.rubricaId=$1)`
+
+// EnUso indica si ya se evaluaron entregas con la rubrica
+func (r *Rubrica) EnUso(db *pgxpool.Pool) (bool, error) {
+	var enUso bool
+	err := db.QueryRow(context.Background(), rubricaEnUso, r.ID).Scan(&enUso)
+	return enUso, err
+}
+
+// UpdateRubrica actualiza la rubrica. Si se envian criterios reemplazan a
+// los anteriores, siempre que la rubrica no se haya usado.
+func (r *Rubrica) UpdateRubrica(db *pgxpool.Pool) error {
+	updTime := time.Now()
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	err = tx.QueryRow(
+		context.Background(),
+		`UPDATE rubricas SET nombre=$1, descripcion=$2, activo=$3, updatedAt=$4
+		WHERE id=$5
+		RETURNING creadoPor, createdAt, updatedAt`,
+		r.Nombre, r.Descripcion, r.Activo, updTime, r.ID,
+	).Scan(&r.CreadoPor, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if r.Criterios != nil {
+		// con la rubrica bloqueada hasta el commit, y GuardarPuntajes
+		// bloqueandola antes de insertar, ninguna evaluacion se cuela entre
+		// la verificacion y el reemplazo de los criterios
+		_, err = tx.Exec(
+			context.Background(),
+			`SELECT id FROM rubricas WHERE id=$1 FOR UPDATE`,
+			r.ID)
+		if err != nil {
+			return err
+		}
+		var enUso bool
+		err = tx.QueryRow(context.Background(), rubricaEnUso, r.ID).Scan(&enUso)
+		if err != nil {
+			return err
+		}
+		if enUso {
+			return ErrRubricaEnUso
+		}
+
+		_, err = tx.Exec(
+			context.Background(),
+			`DELETE FROM rubricaCriterios WHERE rubricaId=$1`,
+			r.ID)
+		if err != nil {
+			return err
+		}
+		if err = r.createCriterios(tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(context.Background())
+}
+
+func (r *Rubrica) DeleteRubrica(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`DELETE FROM rubricas WHERE id=$1`,
+		r.ID)
+	return err
+}
+
+// PuntosMaximos es la suma del nivel mas alto de cada criterio
+func (r *Rubrica) PuntosMaximos() float32 {
+	var total float32
+	for _, c := range r.Criterios {
+		var max float32
+		for _, n := range c.Niveles {
+			if n.Puntos > max {
+				max = n.Puntos
+			}
+		}
+		total += max
+	}
+	return total
+}
+
+func (ra *RubricaAsignada) CreateRubricaAsignada(db *pgxpool.Pool) error {
+	err := db.QueryRow(
+		context.Background(),
+		`INSERT INTO rubricaAsignaciones(rubricaId, trabajoId, preguntaTrabajoId, createdAt)
+		VALUES($1, NULLIF($2, 0), NULLIF($3, 0), $4)
+		ON CONFLICT DO NOTHING
+		RETURNING id, createdAt`,
+		ra.RubricaId, ra.TrabajoId, ra.PreguntaTrabajoId, time.Now(),
+	).Scan(&ra.ID, &ra.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return ErrRubricaYaAsignada
+	}
+	return err
+}
+
+func (ra *RubricaAsignada) DeleteRubricaAsignada(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`DELETE FROM rubricaAsignaciones WHERE id=$1`,
+		ra.ID)
+	return err
+}
+
+// GetRubricasTrabajo obtiene las rubricas asignadas al trabajo y a sus
+// preguntas, cada una con sus criterios y niveles
+func GetRubricasTrabajo(db *pgxpool.Pool, trabajoId int) ([]RubricaAsignada, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT ra.id, ra.rubricaId, COALESCE(ra.trabajoId, 0),
+		COALESCE(ra.preguntaTrabajoId, 0), ra.createdAt
+		FROM rubricaAsignaciones ra
+		LEFT JOIN preguntasTrabajo pt ON pt.id = ra.preguntaTrabajoId
+		WHERE ra.trabajoId=$1 OR pt.trabajoId=$1
+		ORDER BY ra.id`,
+		trabajoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	asignaciones := []RubricaAsignada{}
+	for rows.Next() {
+		var ra RubricaAsignada
+		err := rows.Scan(&ra.ID, &ra.RubricaId, &ra.TrabajoId,
+			&ra.PreguntaTrabajoId, &ra.CreatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para RubricaAsignada, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		asignaciones = append(asignaciones, ra)
+	}
+	rows.Close()
+
+	for i := range asignaciones {
+		asignaciones[i].Rubrica.ID = asignaciones[i].RubricaId
+		if err := asignaciones[i].Rubrica.GetRubrica(db); err != nil {
+			return nil, err
+		}
+	}
+	return asignaciones, nil
+}
+
+// GetPuntajes obtiene los puntajes con los que se evaluo la entrega
+func GetPuntajes(db *pgxpool.Pool, alumnoTrabajoId int) ([]Puntaje, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, alumnoTrabajoId, asignacionId, criterioId, nivelId,
+		puntos, comentario, evaluadoPor, updatedAt
+		FROM rubricaPuntajes
+		WHERE alumnoTrabajoId=$1
+		ORDER BY asignacionId, criterioId`,
+		alumnoTrabajoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	puntajes := []Puntaje{}
+	for rows.Next() {
+		var p Puntaje
+		err := rows.Scan(&p.ID, &p.AlumnoTrabajoId, &p.AsignacionId, &p.CriterioId,
+			&p.NivelId, &p.Puntos, &p.Comentario, &p.EvaluadoPor, &p.UpdatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para Puntaje, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		puntajes = append(puntajes, p)
+	}
+	return puntajes, nil
+}
+
+// CompletarPuntajes valida cada puntaje contra las rubricas asignadas y
+// completa los puntos segun el nivel elegido
+func CompletarPuntajes(asignaciones []RubricaAsignada, puntajes []Puntaje) error {
+	for i := range puntajes {
+		p := &puntajes[i]
+		encontrado := false
+		for _, ra := range asignaciones {
+			if ra.ID != p.AsignacionId {
+				continue
+			}
+			for _, c := range ra.Rubrica.Criterios {
+				if c.ID != p.CriterioId {
+					continue
+				}
+				for _, n := range c.Niveles {
+					if n.ID == p.NivelId {
+						p.Puntos = n.Puntos
+						encontrado = true
+					}
+				}
+			}
+		}
+		if !encontrado {
+			return ErrPuntajeInvalido
+		}
+	}
+	return nil
+}
+
+// CalcularCalificacionRubricas convierte los puntajes a la escala de 0 a
+// 'escala'. Los criterios sin puntaje cuentan como 0.
+func CalcularCalificacionRubricas(asignaciones []RubricaAsignada, puntajes []Puntaje, escala float32) float32 {
+	var maximo, obtenido float32
+	for _, ra := range asignaciones {
+		maximo += ra.Rubrica.PuntosMaximos()
+	}
+	for _, p := range puntajes {
+		obtenido += p.Puntos
+	}
+	if maximo == 0 {
+		return 0
+	}
+	nota := float64(obtenido / maximo * escala)
+	return float32(math.Round(nota*100) / 100)
+}
+
+// GuardarPuntajes reemplaza los puntajes de la entrega para los criterios
+// enviados, conservando los de otros criterios
+func GuardarPuntajes(db *pgxpool.Pool, alumnoTrabajoId, evaluadoPor int, puntajes []Puntaje) error {
+	now := time.Now()
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	// bloquea las rubricas de los criterios para que UpdateRubrica no los
+	// reemplace mientras se guardan los puntajes
+	criterios := make([]int, len(puntajes))
+	for i, p := range puntajes {
+		criterios[i] = p.CriterioId
+	}
+	_, err = tx.Exec(
+		context.Background(),
+		`SELECT r.id FROM rubricas r
+		JOIN rubricaCriterios c ON c.rubricaId = r.id
+		WHERE c.id = ANY($1)
+		FOR SHARE OF r`,
+		criterios)
+	if err != nil {
+		return err
+	}
+
+	for i := range puntajes {
+		p := &puntajes[i]
+		p.AlumnoTrabajoId = alumnoTrabajoId
+		p.EvaluadoPor = evaluadoPor
+		err := tx.QueryRow(
+			context.Background(),
+			`INSERT INTO rubricaPuntajes(alumnoTrabajoId, asignacionId, criterioId,
+			nivelId, puntos, comentario, evaluadoPor, updatedAt)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (alumnoTrabajoId, asignacionId, criterioId)
+			DO UPDATE SET nivelId=EXCLUDED.nivelId, puntos=EXCLUDED.puntos,
+			comentario=EXCLUDED.comentario, evaluadoPor=EXCLUDED.evaluadoPor,
+			updatedAt=EXCLUDED.updatedAt
+			RETURNING id, updatedAt`,
+			p.AlumnoTrabajoId, p.AsignacionId, p.CriterioId, p.NivelId,
+			p.Puntos, p.Comentario, p.EvaluadoPor, now,
+		).Scan(&p.ID, &p.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(context.Background())
+}
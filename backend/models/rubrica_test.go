@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestCreateRubrica(t *testing.T) {
+	utils.ClearTableRubrica(db)
+	utils.AddUsers(1, db)
+
+	r := Rubrica{
+		Nombre:    "ensayo",
+		CreadoPor: 1,
+		Activo:    true,
+		Criterios: []Criterio{
+			{Nombre: "redaccion", Niveles: []Nivel{{Nombre: "bajo", Puntos: 0}, {Nombre: "alto", Puntos: 4}}},
+			{Nombre: "fuentes", Niveles: []Nivel{{Nombre: "bajo", Puntos: 1}, {Nombre: "alto", Puntos: 6}}},
+		},
+	}
+	err := r.CreateRubrica(db)
+	if err != nil {
+		t.Errorf("No se creo la rubrica %s", err)
+	}
+
+	if r.ID != 1 {
+		t.Errorf("Se esperaba crear una rubrica con ID 1. Se obtuvo %d", r.ID)
+	}
+	if r.Criterios[1].ID != 2 || r.Criterios[1].Orden != 2 || r.Criterios[1].Niveles[1].ID != 4 {
+		t.Errorf("Se esperaba que los criterios y niveles tengan ID. Se obtuvo %v", r.Criterios)
+	}
+}
+
+func TestGetRubrica(t *testing.T) {
+	utils.ClearTableRubrica(db)
+	utils.AddRubricas(1, db)
+
+	r := Rubrica{ID: 1}
+	err := r.GetRubrica(db)
+	if err != nil {
+		t.Errorf("Se esperaba obtener la rubrica con ID 1. Se obtuvo %v", err)
+	}
+	if len(r.Criterios) != 2 || len(r.Criterios[0].Niveles) != 3 {
+		t.Errorf("Se esperaban 2 criterios de 3 niveles. Se obtuvo %v", r.Criterios)
+	}
+	if r.PuntosMaximos() != 10 {
+		t.Errorf("Se esperaban 10 puntos maximos. Se obtuvo %v", r.PuntosMaximos())
+	}
+}
+
+func TestNotGetRubrica(t *testing.T) {
+	utils.ClearTableRubrica(db)
+	r := Rubrica{ID: 1}
+	err := r.GetRubrica(db)
+	if err != pgx.ErrNoRows {
+		t.Errorf("Se esperaba error ErrNoRows, se obtuvo diferente error. ERROR %v", err)
+	}
+}
+
+func TestUpdateRubricaEnUso(t *testing.T) {
+	utils.ClearTableRubrica(db)
+	utils.ClearTableCurso(db)
+	utils.AddEntregas(1, db)
+	utils.AddRubricas(1, db)
+
+	ra := RubricaAsignada{RubricaId: 1, TrabajoId: 1}
+	if err := ra.CreateRubricaAsignada(db); err != nil {
+		t.Errorf("No se asigno la rubrica %s", err)
+	}
+	if err := ra.CreateRubricaAsignada(db); err != ErrRubricaYaAsignada {
+		t.Errorf("Se esperaba ErrRubricaYaAsignada. Se obtuvo %v", err)
+	}
+
+	puntajes := []Puntaje{{AsignacionId: 1, CriterioId: 1, NivelId: 3, Puntos: 5}}
+	if err := GuardarPuntajes(db, 1, 1, puntajes); err != nil {
+		t.Errorf("No se guardaron los puntajes %s", err)
+	}
+
+	r := Rubrica{ID: 1, Nombre: "renombrada", Activo: true}
+	if err := r.UpdateRubrica(db); err != nil {
+		t.Errorf("Se esperaba poder renombrar una rubrica en uso. ERROR %v", err)
+	}
+
+	r.Criterios = []Criterio{{Nombre: "nuevo", Niveles: []Nivel{{Puntos: 1}}}}
+	if err := r.UpdateRubrica(db); err != ErrRubricaEnUso {
+		t.Errorf("Se esperaba ErrRubricaEnUso. Se obtuvo %v", err)
+	}
+
+	var nombre string
+	db.QueryRow(context.Background(), "SELECT nombre FROM rubricas WHERE id=1").Scan(&nombre)
+	if nombre != "renombrada" {
+		t.Errorf("Se esperaba el nombre 'renombrada'. Se obtuvo '%s'", nombre)
+	}
+}
+
+func TestCalcularCalificacionRubricas(t *testing.T) {
+	rubrica := Rubrica{Criterios: []Criterio{
+		{ID: 1, Niveles: []Nivel{{ID: 1, Puntos: 0}, {ID: 2, Puntos: 4}}},
+		{ID: 2, Niveles: []Nivel{{ID: 3, Puntos: 0}, {ID: 4, Puntos: 6}}},
+	}}
+	asignaciones := []RubricaAsignada{
+		{ID: 1, Rubrica: rubrica},
+		{ID: 2, Rubrica: rubrica},
+	}
+
+	puntajes := []Puntaje{
+		{AsignacionId: 1, CriterioId: 1, NivelId: 2},
+		{AsignacionId: 1, CriterioId: 2, NivelId: 4},
+		{AsignacionId: 2, CriterioId: 2, NivelId: 4},
+	}
+	if err := CompletarPuntajes(asignaciones, puntajes); err != nil {
+		t.Errorf("No se esperaba error al completar los puntajes %v", err)
+	}
+
+	// 16 de 20 puntos, el criterio sin evaluar cuenta como 0
+	nota := CalcularCalificacionRubricas(asignaciones, puntajes, 20)
+	if nota != 16 {
+		t.Errorf("Se esperaba una calificacion de 16. Se obtuvo %v", nota)
+	}
+
+	invalido := []Puntaje{{AsignacionId: 1, CriterioId: 1, NivelId: 4}}
+	if err := CompletarPuntajes(asignaciones, invalido); err != ErrPuntajeInvalido {
+		t.Errorf("Se esperaba ErrPuntajeInvalido. Se obtuvo %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/blackadress/vaula/similitud"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// estados de un AnalisisSimilitud
+const (
+	AnalisisPendiente  = "pendiente"
+	AnalisisProcesando = "procesando"
+	AnalisisCompletado = "completado"
+	AnalisisFallido    = "fallido"
+)
+
+// AnalisisSimilitud compara en segundo plano las entregas de un trabajo.
+// Resultado es un ResultadoSimilitud cuando el estado es completado.
+type AnalisisSimilitud struct {
+	ID            int             `json:"id"`
+	TrabajoId     int             `json:"trabajoId"`
+	SolicitadoPor int             `json:"solicitadoPor"`
+	Estado        string          `json:"estado"`
+	Error         string          `json:"error"`
+	Resultado     json.RawMessage `json:"resultado"`
+
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+// fuentes de los documentos comparados
+const (
+	FuenteEntrega   = "entrega"
+	FuenteRespuesta = "respuesta"
+)
+
+// FuenteSimilitud identifica un documento comparado: la ultima entrega de
+// un alumno o su respuesta a una pregunta del trabajo
+type FuenteSimilitud struct {
+	Tipo              string `json:"tipo"`
+	ID                int    `json:"id"`
+	AlumnoId          int    `json:"alumnoId"`
+	PreguntaTrabajoId int    `json:"preguntaTrabajoId"`
+}
+
+type ParSimilitud struct {
+	A FuenteSimilitud `json:"a"`
+	B FuenteSimilitud `json:"b"`
+	similitud.Comparacion
+}
+
+// DocumentoOmitido es una entrega de la que no se pudo extraer texto
+type DocumentoOmitido struct {
+	FuenteSimilitud
+	Motivo string `json:"motivo"`
+}
+
+type ResultadoSimilitud struct {
+	Documentos int                `json:"documentos"`
+	Pares      []ParSimilitud     `json:"pares"`
+	Omitidos   []DocumentoOmitido `json:"omitidos"`
+}
+
+// CreateAnalisis registra un analisis pendiente. Si el trabajo ya tiene
+// uno pendiente o en proceso no crea nada y devuelve pgx.ErrNoRows.
+func (an *AnalisisSimilitud) CreateAnalisis(db *pgxpool.Pool) error {
+	an.Estado = AnalisisPendiente
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO analisisSimilitud(trabajoId, solicitadoPor, estado, createdAt)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (
+			SELECT 1 FROM analisisSimilitud
+			WHERE trabajoId=$1 AND estado IN ('pendiente', 'procesando'))
+		RETURNING id, createdAt`,
+		an.TrabajoId, an.SolicitadoPor, an.Estado, time.Now(),
+	).Scan(&an.ID, &an.CreatedAt)
+}
+
+func (an *AnalisisSimilitud) GetAnalisis(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT trabajoId, solicitadoPor, estado, error, resultado, createdAt,
+		finishedAt
+		FROM analisisSimilitud
+		WHERE id=$1`,
+		an.ID).Scan(&an.TrabajoId, &an.SolicitadoPor, &an.Estado, &an.Error,
+		&an.Resultado, &an.CreatedAt, &an.FinishedAt)
+}
+
+// GetUltimoAnalisis carga el analisis mas reciente del trabajo
+func (an *AnalisisSimilitud) GetUltimoAnalisis(db *pgxpool.Pool) error {
+	return db.QueryRow(
+		context.Background(),
+		`SELECT id, solicitadoPor, estado, error, resultado, createdAt,
+		finishedAt
+		FROM analisisSimilitud
+		WHERE trabajoId=$1
+		ORDER BY id DESC
+		LIMIT 1`,
+		an.TrabajoId).Scan(&an.ID, &an.SolicitadoPor, &an.Estado, &an.Error,
+		&an.Resultado, &an.CreatedAt, &an.FinishedAt)
+}
+
+func (an *AnalisisSimilitud) MarcarProcesando(db *pgxpool.Pool) error {
+	an.Estado = AnalisisProcesando
+	_, err := db.Exec(
+		context.Background(),
+		`UPDATE analisisSimilitud SET estado=$1 WHERE id=$2`,
+		an.Estado, an.ID)
+	return err
+}
+
+// Terminar guarda el resultado, o el error si el analisis fallo
+func (an *AnalisisSimilitud) Terminar(db *pgxpool.Pool, resultado ResultadoSimilitud, fallo error) error {
+	now := time.Now()
+	an.FinishedAt = &now
+	an.Estado = AnalisisCompletado
+	an.Resultado = nil
+	if fallo != nil {
+		an.Estado = AnalisisFallido
+		an.Error = fallo.Error()
+	} else {
+		var err error
+		if an.Resultado, err = json.Marshal(resultado); err != nil {
+			return err
+		}
+	}
+	_, err := db.Exec(
+		context.Background(),
+		`UPDATE analisisSimilitud SET estado=$1, error=$2, resultado=$3,
+		finishedAt=$4
+		WHERE id=$5`,
+		an.Estado, an.Error, an.Resultado, now, an.ID)
+	return err
+}
+
+// InterrumpirAnalisis marca como fallidos los analisis que quedaron en
+// curso, porque su goroutine no sobrevive a un reinicio del servidor
+func InterrumpirAnalisis(db *pgxpool.Pool) error {
+	_, err := db.Exec(
+		context.Background(),
+		`UPDATE analisisSimilitud SET estado=$1, error=$2, finishedAt=$3
+		WHERE estado IN ('pendiente', 'procesando')`,
+		AnalisisFallido, "interrumpido por un reinicio del servidor", time.Now())
+	return err
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/blackadress/vaula/utils"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestCreateAnalisisUnoEnCurso(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.ClearTableUsuario(db)
+	utils.AddUsers(1, db)
+	utils.AddTrabajos(1, db)
+
+	an := AnalisisSimilitud{TrabajoId: 1, SolicitadoPor: 1}
+	if err := an.CreateAnalisis(db); err != nil {
+		t.Fatalf("No se creo el analisis %s", err)
+	}
+	if an.Estado != AnalisisPendiente {
+		t.Errorf("Se esperaba el estado %s. Se obtuvo %s", AnalisisPendiente, an.Estado)
+	}
+
+	otro := AnalisisSimilitud{TrabajoId: 1, SolicitadoPor: 1}
+	if err := otro.CreateAnalisis(db); err != pgx.ErrNoRows {
+		t.Errorf("Se esperaba pgx.ErrNoRows con un analisis en curso. Se obtuvo %v", err)
+	}
+
+	if err := an.Terminar(db, ResultadoSimilitud{Documentos: 2}, nil); err != nil {
+		t.Fatalf("El metodo Terminar fallo %s", err)
+	}
+	if err := otro.CreateAnalisis(db); err != nil {
+		t.Errorf("Se esperaba crear otro analisis al terminar el anterior. Se obtuvo %v", err)
+	}
+}
+
+func TestTerminarAnalisis(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.ClearTableUsuario(db)
+	utils.AddUsers(1, db)
+	utils.AddTrabajos(2, db)
+
+	an := AnalisisSimilitud{TrabajoId: 1, SolicitadoPor: 1}
+	an.CreateAnalisis(db)
+	an.Terminar(db, ResultadoSimilitud{Documentos: 3}, nil)
+
+	ultimo := AnalisisSimilitud{TrabajoId: 1}
+	if err := ultimo.GetUltimoAnalisis(db); err != nil {
+		t.Fatalf("El metodo GetUltimoAnalisis fallo %s", err)
+	}
+	var resultado ResultadoSimilitud
+	json.Unmarshal(ultimo.Resultado, &resultado)
+	if ultimo.Estado != AnalisisCompletado || resultado.Documentos != 3 || ultimo.FinishedAt == nil {
+		t.Errorf("Se esperaba el analisis completado con 3 documentos. Se obtuvo %+v", ultimo)
+	}
+
+	fallido := AnalisisSimilitud{TrabajoId: 2, SolicitadoPor: 1}
+	fallido.CreateAnalisis(db)
+	fallido.Terminar(db, ResultadoSimilitud{}, errors.New("sin storage"))
+	fallido.GetAnalisis(db)
+	if fallido.Estado != AnalisisFallido || fallido.Error != "sin storage" {
+		t.Errorf("Se esperaba el analisis fallido con su error. Se obtuvo %+v", fallido)
+	}
+}
+
+func TestInterrumpirAnalisis(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.ClearTableUsuario(db)
+	utils.AddUsers(1, db)
+	utils.AddTrabajos(1, db)
+
+	an := AnalisisSimilitud{TrabajoId: 1, SolicitadoPor: 1}
+	an.CreateAnalisis(db)
+	an.MarcarProcesando(db)
+
+	if err := InterrumpirAnalisis(db); err != nil {
+		t.Fatalf("El metodo InterrumpirAnalisis fallo %s", err)
+	}
+	an.GetAnalisis(db)
+	if an.Estado != AnalisisFallido {
+		t.Errorf("Se esperaba el analisis interrumpido como fallido. Se obtuvo %s", an.Estado)
+	}
+}
@@ -0,0 +1,253 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// tipos de evento de supervision. El cliente envia todos menos
+// EventoCambioIp, que el servidor registra solo al ver una ip distinta
+// dentro del mismo intento; el cliente tambien puede enviarlo.
+const (
+	EventoPestanaOculta         = "pestanaOculta"
+	EventoVentanaDesenfocada    = "ventanaDesenfocada"
+	EventoPegar                 = "pegar"
+	EventoSalirPantallaCompleta = "salirPantallaCompleta"
+	EventoCambioIp              = "cambioIp"
+)
+
+// TiposEvento tiene los tipos que acepta la tabla eventosIntento
+var TiposEvento = map[string]bool{
+	EventoPestanaOculta:         true,
+	EventoVentanaDesenfocada:    true,
+	EventoPegar:                 true,
+	EventoSalirPantallaCompleta: true,
+	EventoCambioIp:              true,
+}
+
+type EventoIntento struct {
+	ID             int       `json:"id"`
+	AlumnoExamenId int       `json:"alumnoExamenId"`
+	Tipo           string    `json:"tipo"`
+	Detalle        string    `json:"detalle"`
+	Ip             string    `json:"ip"`
+	UserAgent      string    `json:"userAgent"`
+	OcurridoEn     time.Time `json:"ocurridoEn"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CreateEvento guarda el evento. Sin OcurridoEn se usa la hora del servidor.
+func (e *EventoIntento) CreateEvento(db *pgxpool.Pool) error {
+	now := time.Now()
+	if e.OcurridoEn.IsZero() {
+		e.OcurridoEn = now
+	}
+	return db.QueryRow(
+		context.Background(),
+		`INSERT INTO eventosIntento(alumnoExamenId, tipo, detalle, ip,
+		userAgent, ocurridoEn, createdAt)
+		VALUES($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, createdAt`,
+		e.AlumnoExamenId, e.Tipo, e.Detalle, e.Ip, e.UserAgent, e.OcurridoEn, now,
+	).Scan(&e.ID, &e.CreatedAt)
+}
+
+func GetEventosIntento(db *pgxpool.Pool, alumnoExamenId int) ([]EventoIntento, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT id, alumnoExamenId, tipo, detalle, ip, userAgent, ocurridoEn,
+		createdAt
+		FROM eventosIntento
+		WHERE alumnoExamenId=$1
+		ORDER BY createdAt, id`,
+		alumnoExamenId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	eventos := []EventoIntento{}
+	for rows.Next() {
+		var e EventoIntento
+		err := rows.Scan(&e.ID, &e.AlumnoExamenId, &e.Tipo, &e.Detalle, &e.Ip,
+			&e.UserAgent, &e.OcurridoEn, &e.CreatedAt)
+		if err != nil {
+			log.Printf("Las filas obtenidas de la BD para EventoIntento, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		eventos = append(eventos, e)
+	}
+	return eventos, nil
+}
+
+// UltimaIpIntento es la ip del ultimo evento o respuesta del intento, ""
+// si aun no hay ninguno
+func UltimaIpIntento(db *pgxpool.Pool, alumnoExamenId int) (string, error) {
+	var ip string
+	err := db.QueryRow(
+		context.Background(),
+		`SELECT ip FROM (
+			SELECT ip, createdAt AS fecha FROM eventosIntento
+			WHERE alumnoExamenId=$1 AND ip <> ''
+			UNION ALL
+			SELECT ip, updatedAt AS fecha FROM respuestas
+			WHERE alumnoExamenId=$1 AND ip <> ''
+		) registros
+		ORDER BY fecha DESC
+		LIMIT 1`,
+		alumnoExamenId).Scan(&ip)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	return ip, err
+}
+
+// Umbrales es la cantidad maxima de eventos de cada tipo que se tolera en
+// un intento. Un tipo sin umbral nunca marca el intento.
+type Umbrales map[string]int
+
+// UmbralesPorDefecto: un solo cambio de ip o salida de pantalla completa
+// ya es sospechoso, perder el foco unas veces no
+var UmbralesPorDefecto = Umbrales{
+	EventoPestanaOculta:         3,
+	EventoVentanaDesenfocada:    5,
+	EventoPegar:                 3,
+	EventoSalirPantallaCompleta: 0,
+	EventoCambioIp:              0,
+}
+
+// ParseUmbrales lee umbrales con el formato "pegar=2,cambioIp=0" sobre los
+// umbrales por defecto
+func ParseUmbrales(s string) (Umbrales, error) {
+	umbrales := Umbrales{}
+	for tipo, maximo := range UmbralesPorDefecto {
+		umbrales[tipo] = maximo
+	}
+	for _, par := range strings.Split(s, ",") {
+		if strings.TrimSpace(par) == "" {
+			continue
+		}
+		partes := strings.SplitN(par, "=", 2)
+		tipo := strings.TrimSpace(partes[0])
+		if len(partes) != 2 || !TiposEvento[tipo] {
+			return nil, fmt.Errorf("umbral invalido '%s'", par)
+		}
+		maximo, err := strconv.Atoi(strings.TrimSpace(partes[1]))
+		if err != nil || maximo < 0 {
+			return nil, fmt.Errorf("umbral invalido '%s'", par)
+		}
+		umbrales[tipo] = maximo
+	}
+	return umbrales, nil
+}
+
+// Evaluar devuelve un motivo por cada tipo cuyo conteo supera su umbral,
+// en orden alfabetico de tipo
+func (u Umbrales) Evaluar(conteos map[string]int) []string {
+	motivos := []string{}
+	for tipo, n := range conteos {
+		if maximo, ok := u[tipo]; ok && n > maximo {
+			motivos = append(motivos, fmt.Sprintf("%s: %d eventos (maximo %d)", tipo, n, maximo))
+		}
+	}
+	sort.Strings(motivos)
+	return motivos
+}
+
+// ResumenSupervision cuenta los eventos de un intento. El intento queda
+// marcado si algun conteo supera su umbral.
+type ResumenSupervision struct {
+	AlumnoExamenId int            `json:"alumnoExamenId"`
+	AlumnoId       int            `json:"alumnoId"`
+	Intento        int            `json:"intento"`
+	Conteos        map[string]int `json:"conteos"`
+	Marcado        bool           `json:"marcado"`
+	Motivos        []string       `json:"motivos"`
+}
+
+// GetSupervisionExamen resume todos los intentos del examen, tengan o no
+// eventos
+func GetSupervisionExamen(db *pgxpool.Pool, examenId int, umbrales Umbrales) ([]ResumenSupervision, error) {
+	rows, err := db.Query(
+		context.Background(),
+		`SELECT ae.id, ae.alumnoId, ae.intento, COALESCE(e.tipo, ''), COUNT(e.id)
+		FROM alumnoExamen ae
+		LEFT JOIN eventosIntento e ON e.alumnoExamenId = ae.id
+		WHERE ae.examenId=$1
+		GROUP BY ae.id, ae.alumnoId, ae.intento, e.tipo
+		ORDER BY ae.alumnoId, ae.intento`,
+		examenId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resumenes := []ResumenSupervision{}
+	for rows.Next() {
+		var id, alumnoId, intento, n int
+		var tipo string
+		if err := rows.Scan(&id, &alumnoId, &intento, &tipo, &n); err != nil {
+			log.Printf("Las filas obtenidas de la BD para ResumenSupervision, no satisfacen a 'Scan' %s",
+				err)
+			return nil, err
+		}
+		if len(resumenes) == 0 || resumenes[len(resumenes)-1].AlumnoExamenId != id {
+			resumenes = append(resumenes, ResumenSupervision{
+				AlumnoExamenId: id, AlumnoId: alumnoId, Intento: intento,
+				Conteos: map[string]int{},
+			})
+		}
+		if tipo != "" {
+			resumenes[len(resumenes)-1].Conteos[tipo] = n
+		}
+	}
+
+	for i := range resumenes {
+		resumenes[i].Motivos = umbrales.Evaluar(resumenes[i].Conteos)
+		resumenes[i].Marcado = len(resumenes[i].Motivos) > 0
+	}
+	return resumenes, nil
+}
+
+// EntradaLineaTiempo es un evento o el ultimo guardado de una respuesta,
+// con la hora del servidor
+type EntradaLineaTiempo struct {
+	Fecha      time.Time `json:"fecha"`
+	Tipo       string    `json:"tipo"`
+	Detalle    string    `json:"detalle"`
+	PreguntaId int       `json:"preguntaId"`
+	Ip         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+}
+
+// EntradaRespuesta es el tipo de las entradas que vienen de respuestas
+const EntradaRespuesta = "respuesta"
+
+// LineaTiempo ordena los eventos y respuestas del intento por fecha
+func LineaTiempo(eventos []EventoIntento, respuestas []Respuesta) []EntradaLineaTiempo {
+	linea := []EntradaLineaTiempo{}
+	for _, e := range eventos {
+		linea = append(linea, EntradaLineaTiempo{
+			Fecha: e.CreatedAt, Tipo: e.Tipo, Detalle: e.Detalle,
+			Ip: e.Ip, UserAgent: e.UserAgent,
+		})
+	}
+	for _, r := range respuestas {
+		linea = append(linea, EntradaLineaTiempo{
+			Fecha: r.UpdatedAt, Tipo: EntradaRespuesta, PreguntaId: r.PreguntaId,
+			Ip: r.Ip, UserAgent: r.UserAgent,
+		})
+	}
+	sort.SliceStable(linea, func(i, j int) bool { return linea[i].Fecha.Before(linea[j].Fecha) })
+	return linea
+}
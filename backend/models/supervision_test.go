@@ -0,0 +1,89 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/utils"
+)
+
+func TestParseUmbrales(t *testing.T) {
+	umbrales, err := ParseUmbrales(" pegar=2, cambioIp=3")
+	if err != nil {
+		t.Fatalf("No se leyeron los umbrales %s", err)
+	}
+	if umbrales[EventoPegar] != 2 || umbrales[EventoCambioIp] != 3 {
+		t.Errorf("Se esperaban los umbrales leidos. Se obtuvo %v", umbrales)
+	}
+	if umbrales[EventoPestanaOculta] != UmbralesPorDefecto[EventoPestanaOculta] {
+		t.Errorf("Se esperaba el umbral por defecto de pestanaOculta. Se obtuvo %v", umbrales)
+	}
+
+	for _, s := range []string{"copiar=1", "pegar", "pegar=-1", "pegar=x"} {
+		if _, err := ParseUmbrales(s); err == nil {
+			t.Errorf("Se esperaba un error con '%s'", s)
+		}
+	}
+}
+
+func TestEvaluarUmbrales(t *testing.T) {
+	umbrales := Umbrales{EventoPegar: 2, EventoCambioIp: 0}
+
+	motivos := umbrales.Evaluar(map[string]int{EventoPegar: 2, EventoCambioIp: 1})
+	if len(motivos) != 1 {
+		t.Errorf("Se esperaba solo el motivo del cambio de ip. Se obtuvo %v", motivos)
+	}
+	if motivos := umbrales.Evaluar(map[string]int{EventoPegar: 3}); len(motivos) != 1 {
+		t.Errorf("Se esperaba marcar 3 eventos pegar. Se obtuvo %v", motivos)
+	}
+}
+
+func TestLineaTiempo(t *testing.T) {
+	inicio := time.Now()
+	eventos := []EventoIntento{
+		{Tipo: EventoPegar, CreatedAt: inicio.Add(2 * time.Minute)},
+		{Tipo: EventoPestanaOculta, CreatedAt: inicio},
+	}
+	respuestas := []Respuesta{{PreguntaId: 4, UpdatedAt: inicio.Add(time.Minute)}}
+
+	var tipos []string
+	for _, e := range LineaTiempo(eventos, respuestas) {
+		tipos = append(tipos, e.Tipo)
+	}
+	esperado := []string{EventoPestanaOculta, EntradaRespuesta, EventoPegar}
+	if !reflect.DeepEqual(tipos, esperado) {
+		t.Errorf("Se esperaba el orden %v. Se obtuvo %v", esperado, tipos)
+	}
+}
+
+func TestGetSupervisionExamen(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddIntentos(2, db)
+
+	for _, tipo := range []string{EventoPegar, EventoPegar, EventoPestanaOculta} {
+		e := EventoIntento{AlumnoExamenId: 1, Tipo: tipo, Ip: "10.0.0.1"}
+		if err := e.CreateEvento(db); err != nil {
+			t.Fatalf("No se creo el evento %s", err)
+		}
+	}
+
+	ip, err := UltimaIpIntento(db, 1)
+	if err != nil || ip != "10.0.0.1" {
+		t.Errorf("Se esperaba la ip '10.0.0.1'. Se obtuvo '%s' %v", ip, err)
+	}
+
+	resumenes, err := GetSupervisionExamen(db, 1, Umbrales{EventoPegar: 1})
+	if err != nil {
+		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
+	}
+	if len(resumenes) != 2 {
+		t.Fatalf("Se esperaban los 2 intentos del examen. Se obtuvo %v", resumenes)
+	}
+	if !resumenes[0].Marcado || resumenes[0].Conteos[EventoPegar] != 2 {
+		t.Errorf("Se esperaba marcar el primer intento. Se obtuvo %+v", resumenes[0])
+	}
+	if resumenes[1].Marcado || len(resumenes[1].Conteos) != 0 {
+		t.Errorf("El segundo intento no tiene eventos. Se obtuvo %+v", resumenes[1])
+	}
+}
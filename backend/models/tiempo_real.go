@@ -0,0 +1,219 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// CanalEventos es el canal de LISTEN/NOTIFY por el que los eventos en
+// tiempo real llegan a todas las instancias del servidor
+const CanalEventos = "vaula_eventos"
+
+// tipos de evento en tiempo real
+const (
+	EventoAnuncio        = "anuncio"
+	EventoCalificacion   = "calificacion"
+	EventoTiempoExamen   = "examen.tiempo"
+	EventoEntregaForzada = "examen.entrega_forzada"
+)
+
+// motivos por los que termina un intento
+const (
+	MotivoTiempo   = "tiempo"
+	MotivoProfesor = "profesor"
+	MotivoAlumno   = "alumno"
+)
+
+// maxPayloadEvento es el limite de Postgres para el payload de NOTIFY
+const maxPayloadEvento = 8000
+
+var ErrEventoMuyGrande = errors.New("El evento excede el tamano maximo de NOTIFY")
+
+// EventoTiempoReal va dirigido a un canal: "usuario:<id>", "curso:<id>" o
+// "intento:<id>". Solo lo reciben los clientes suscritos a ese canal.
+type EventoTiempoReal struct {
+	Canal string          `json:"canal"`
+	Tipo  string          `json:"tipo"`
+	Datos json.RawMessage `json:"datos"`
+}
+
+// EntregaForzada son los datos del evento examen.entrega_forzada
+type EntregaForzada struct {
+	IntentoId int       `json:"intentoId"`
+	ExamenId  int       `json:"examenId"`
+	Motivo    string    `json:"motivo"`
+	Fecha     time.Time `json:"fecha"`
+}
+
+func CanalUsuario(usuarioId int) string {
+	return fmt.Sprintf("usuario:%d", usuarioId)
+}
+
+func CanalCurso(cursoId int) string {
+	return fmt.Sprintf("curso:%d", cursoId)
+}
+
+func CanalIntento(intentoId int) string {
+	return fmt.Sprintf("intento:%d", intentoId)
+}
+
+func payloadEvento(canal, tipo string, datos interface{}) (string, error) {
+	d, err := json.Marshal(datos)
+	if err != nil {
+		return "", err
+	}
+	p, err := json.Marshal(EventoTiempoReal{Canal: canal, Tipo: tipo, Datos: d})
+	if err != nil {
+		return "", err
+	}
+	if len(p) >= maxPayloadEvento {
+		return "", ErrEventoMuyGrande
+	}
+	return string(p), nil
+}
+
+// PublicarEvento envia el evento a todas las instancias, que lo reparten a
+// sus clientes suscritos a 'canal'
+func PublicarEvento(db *pgxpool.Pool, canal, tipo string, datos interface{}) error {
+	payload, err := payloadEvento(canal, tipo, datos)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(context.Background(), `SELECT pg_notify($1, $2)`, CanalEventos, payload)
+	return err
+}
+
+// publicarEventoTx publica dentro de la transaccion; Postgres solo entrega
+// el evento si la transaccion se confirma
+func publicarEventoTx(ctx context.Context, tx pgx.Tx, canal, tipo string, datos interface{}) error {
+	payload, err := payloadEvento(canal, tipo, datos)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `SELECT pg_notify($1, $2)`, CanalEventos, payload)
+	return err
+}
+
+// EscucharEventos reserva una conexion del pool para LISTEN y llama a
+// 'recibir' con cada evento hasta que se cancela el contexto o se pierde la
+// conexion
+func EscucharEventos(ctx context.Context, db *pgxpool.Pool, recibir func(EventoTiempoReal)) error {
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	// la conexion no vuelve al pool escuchando el canal
+	defer conn.Conn().Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+CanalEventos); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var e EventoTiempoReal
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			continue
+		}
+		recibir(e)
+	}
+}
+
+// CerrarIntentosVencidos termina los intentos activos cuyo tiempo ya paso
+// y devuelve cuantos cerro. Cada intento lo cierra una sola instancia, la
+// que hace el UPDATE, asi la entrega forzada se avisa una vez.
+func CerrarIntentosVencidos(db *pgxpool.Pool, ahora time.Time) (int, error) {
+	return cerrarIntentos(db, ahora, MotivoTiempo,
+		`ae.activo AND ae.fechaFinal <= $1`)
+}
+
+// ForzarEntrega termina el intento a pedido del profesor. Devuelve
+// pgx.ErrNoRows si el intento ya estaba terminado.
+func (ae *AlumnoExamen) ForzarEntrega(db *pgxpool.Pool) error {
+	cerrados, err := cerrarIntentos(db, time.Now(), MotivoProfesor,
+		`ae.activo AND ae.id = $2`, ae.ID)
+	if err != nil {
+		return err
+	}
+	if cerrados == 0 {
+		return pgx.ErrNoRows
+	}
+	return ae.GetAlumnoExamen(db)
+}
+
+// Entregar termina el intento a pedido del alumno. Devuelve
+// pgx.ErrNoRows si el intento ya estaba terminado.
+func (ae *AlumnoExamen) Entregar(db *pgxpool.Pool) error {
+	cerrados, err := cerrarIntentos(db, time.Now(), MotivoAlumno,
+		`ae.activo AND ae.id = $2`, ae.ID)
+	if err != nil {
+		return err
+	}
+	if cerrados == 0 {
+		return pgx.ErrNoRows
+	}
+	return ae.GetAlumnoExamen(db)
+}
+
+// calificacionIntento es la nota vigesimal del intento: lo obtenido en sus
+// respuestas sobre el puntaje de todas las preguntas del examen
+const calificacionIntento = `(SELECT COALESCE(ROUND(
+		(20 * SUM(COALESCE(r.puntaje, 0)) / NULLIF(SUM(p.puntaje), 0))::numeric, 2), 0)
+	FROM preguntas p
+	LEFT JOIN respuestas r ON r.preguntaId = p.id AND r.alumnoExamenId = ae.id
+	WHERE p.examenId = ae.examenId)`
+
+// cerrarIntentos termina los intentos que cumplen la condicion y los
+// califica en la misma transaccion
+func cerrarIntentos(db *pgxpool.Pool, ahora time.Time, motivo, condicion string, args ...interface{}) (int, error) {
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(
+		ctx,
+		`UPDATE alumnoExamen ae
+		SET activo=false, fechaFinal=LEAST(ae.fechaFinal, $1), updatedAt=$1,
+		calificacion=`+calificacionIntento+`
+		WHERE `+condicion+`
+		RETURNING ae.id, ae.examenId`,
+		append([]interface{}{ahora}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	cerrados := []EntregaForzada{}
+	for rows.Next() {
+		c := EntregaForzada{Motivo: motivo, Fecha: ahora}
+		if err := rows.Scan(&c.IntentoId, &c.ExamenId); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		cerrados = append(cerrados, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// el alumno que rinde y los profesores que supervisan estan suscritos
+	// al canal del intento
+	for _, c := range cerrados {
+		err := publicarEventoTx(ctx, tx, CanalIntento(c.IntentoId), EventoEntregaForzada, c)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(cerrados), tx.Commit(ctx)
+}
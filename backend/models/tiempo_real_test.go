@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/blackadress/vaula/utils"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestCerrarIntentosVencidos(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddIntentos(2, db)
+	ctx := context.Background()
+
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("No se pudo reservar una conexion %s", err)
+	}
+	defer conn.Release()
+	defer conn.Conn().Close(ctx)
+	conn.Exec(ctx, "LISTEN "+CanalEventos)
+
+	// los intentos de AddIntentos terminan en una hora
+	cerrados, err := CerrarIntentosVencidos(db, time.Now().Add(30*time.Minute))
+	if err != nil || cerrados != 0 {
+		t.Fatalf("No se esperaban intentos vencidos. Se obtuvo %d, %v", cerrados, err)
+	}
+	limite := time.Now().Add(2 * time.Hour)
+	cerrados, err = CerrarIntentosVencidos(db, limite)
+	if err != nil || cerrados != 2 {
+		t.Fatalf("Se esperaban 2 intentos cerrados. Se obtuvo %d, %v", cerrados, err)
+	}
+	if cerrados, _ := CerrarIntentosVencidos(db, limite); cerrados != 0 {
+		t.Errorf("Los intentos solo se cierran una vez. Se obtuvo %d", cerrados)
+	}
+
+	intento := AlumnoExamen{ID: 1}
+	intento.GetAlumnoExamen(db)
+	if intento.Activo {
+		t.Errorf("Se esperaba el intento inactivo")
+	}
+
+	espera, cancelar := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelar()
+	n, err := conn.Conn().WaitForNotification(espera)
+	if err != nil {
+		t.Fatalf("No llego el evento %s", err)
+	}
+	var e EventoTiempoReal
+	json.Unmarshal([]byte(n.Payload), &e)
+	var entrega EntregaForzada
+	json.Unmarshal(e.Datos, &entrega)
+	if e.Tipo != EventoEntregaForzada || e.Canal != CanalIntento(entrega.IntentoId) ||
+		entrega.Motivo != MotivoTiempo {
+		t.Errorf("Se esperaba la entrega forzada por tiempo. Se obtuvo %s", n.Payload)
+	}
+}
+
+func TestForzarEntrega(t *testing.T) {
+	utils.ClearTableCurso(db)
+	utils.AddIntentos(1, db)
+
+	intento := AlumnoExamen{ID: 1}
+	if err := intento.ForzarEntrega(db); err != nil {
+		t.Fatalf("El metodo ForzarEntrega fallo %s", err)
+	}
+	if intento.Activo || intento.FechaFinal.After(time.Now()) {
+		t.Errorf("Se esperaba el intento terminado. Se obtuvo %v", intento)
+	}
+	if err := intento.ForzarEntrega(db); err != pgx.ErrNoRows {
+		t.Errorf("Se esperaba pgx.ErrNoRows en un intento terminado. Se obtuvo %v", err)
+	}
+}
+
+func TestEventoMuyGrande(t *testing.T) {
+	datos := map[string]string{"texto": string(make([]byte, maxPayloadEvento))}
+	if _, err := payloadEvento(CanalCurso(1), EventoAnuncio, datos); err != ErrEventoMuyGrande {
+		t.Errorf("Se esperaba ErrEventoMuyGrande. Se obtuvo %v", err)
+	}
+}
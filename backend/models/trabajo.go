@@ -3,11 +3,24 @@ package models
 import (
 	"context"
 	"log"
+	"math"
 	"time"
 
+	"github.com/blackadress/vaula/consulta"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+// politicas de entrega tardia
+const (
+	// no se aceptan entregas despues de la fecha final
+	PoliticaCorte = "corte"
+	// se aceptan entregas hasta MinutosGracia despues, sin descuento
+	PoliticaGracia = "gracia"
+	// se aceptan siempre, descontando DescuentoDiario por dia de retraso
+	// hasta DescuentoMaximo
+	PoliticaDescuento = "descuento"
+)
+
 // faltan campos?
 type Trabajo struct {
 	ID          int       `json:"id"`
@@ -15,7 +28,12 @@ type Trabajo struct {
 	FechaInicio time.Time `json:"fechaInicio"`
 	FechaFinal  time.Time `json:"fechaFinal"`
 	CursoId     int       `json:"cursoId"`
-	Curso       Curso     `json:"curso"`
+	Curso       *Curso    `json:"curso,omitempty"`
+
+	PoliticaTardanza string  `json:"politicaTardanza"`
+	MinutosGracia    int     `json:"minutosGracia"`
+	DescuentoDiario  float32 `json:"descuentoDiario"` // porcentaje
+	DescuentoMaximo  float32 `json:"descuentoMaximo"` // porcentaje
 
 	Activo    bool      `json:"activo"`
 	CreatedAt time.Time `json:"createdAt"`
@@ -24,35 +42,62 @@ type Trabajo struct {
 
 func (t *Trabajo) CreateTrabajo(db *pgxpool.Pool) error {
 	now := time.Now()
+	if t.PoliticaTardanza == "" {
+		t.PoliticaTardanza = PoliticaCorte
+	}
 	return db.QueryRow(
 		context.Background(),
 		`INSERT INTO trabajos(descripcion, cursoId, activo,
-		fechaInicio, fechaFinal, createdAt, updatedAt)
-		VALUES($1, $2, $3, $4, $5, $6, $7)
+		fechaInicio, fechaFinal, politicaTardanza, minutosGracia,
+		descuentoDiario, descuentoMaximo, createdAt, updatedAt)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id`,
 		t.Descripcion, t.CursoId, t.Activo, t.FechaInicio,
-		t.FechaFinal, now, now).Scan(&t.ID)
+		t.FechaFinal, t.PoliticaTardanza, t.MinutosGracia,
+		t.DescuentoDiario, t.DescuentoMaximo, now, now).Scan(&t.ID)
 }
 
 func (t *Trabajo) GetTrabajo(db *pgxpool.Pool) error {
 	return db.QueryRow(
 		context.Background(),
 		`SELECT descripcion, cursoId, activo, fechaInicio,
-		fechaFinal, CreatedAt, UpdatedAt
+		fechaFinal, politicaTardanza, minutosGracia, descuentoDiario,
+		descuentoMaximo, CreatedAt, UpdatedAt
 		FROM trabajos
 		WHERE id=$1`,
 		t.ID).Scan(&t.Descripcion, &t.CursoId, &t.Activo,
-		&t.FechaInicio, &t.FechaFinal, &t.CreatedAt, &t.UpdatedAt)
+		&t.FechaInicio, &t.FechaFinal, &t.PoliticaTardanza, &t.MinutosGracia,
+		&t.DescuentoDiario, &t.DescuentoMaximo, &t.CreatedAt, &t.UpdatedAt)
 }
 
-func GetTrabajos(db *pgxpool.Pool) ([]Trabajo, error) {
+// ListadoTrabajos son los filtros y ordenes de GET /trabajos
+var ListadoTrabajos = consulta.Definicion{
+	Campos: map[string]consulta.Campo{
+		"descripcion": {Columna: "descripcion", Tipo: consulta.Texto, Filtro: true, Parcial: true, Orden: true},
+		"cursoId":     {Columna: "cursoId", Tipo: consulta.Entero, Filtro: true, Orden: true},
+		"activo":      {Columna: "activo", Tipo: consulta.Booleano, Filtro: true},
+		"fechaInicio": {Columna: "fechaInicio", Tipo: consulta.Fecha, Orden: true},
+		"fechaFinal":  {Columna: "fechaFinal", Tipo: consulta.Fecha, Orden: true},
+		"createdAt":   {Columna: "createdAt", Tipo: consulta.Fecha, Orden: true},
+	},
+}
+
+func GetTrabajos(db *pgxpool.Pool, c consulta.Consulta) ([]Trabajo, int, error) {
+	total, err := contar(db, "trabajos", c)
+	if err != nil {
+		return nil, 0, err
+	}
+	where, args := c.Filtro()
+
 	rows, err := db.Query(
 		context.Background(),
 		`SELECT id, descripcion, cursoId, activo,
-		fechaInicio, fechaFinal, CreatedAt, UpdatedAt
-		FROM trabajos`)
+		fechaInicio, fechaFinal, politicaTardanza, minutosGracia,
+		descuentoDiario, descuentoMaximo, CreatedAt, UpdatedAt
+		FROM trabajos`+where+c.OrdenYPagina(),
+		args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -62,28 +107,34 @@ func GetTrabajos(db *pgxpool.Pool) ([]Trabajo, error) {
 		err := rows.Scan(
 			&tra.ID, &tra.Descripcion, &tra.CursoId,
 			&tra.Activo, &tra.FechaInicio, &tra.FechaFinal,
+			&tra.PoliticaTardanza, &tra.MinutosGracia,
+			&tra.DescuentoDiario, &tra.DescuentoMaximo,
 			&tra.CreatedAt, &tra.UpdatedAt)
 		if err != nil {
 			log.Printf("Las filas obtenidas de la BD para Trabajo, no satisfacen a 'Scan' %s",
 				err)
-			return nil, err
+			return nil, 0, err
 		}
 		trabajos = append(trabajos, tra)
 	}
 
-	return trabajos, nil
-
+	return trabajos, total, rows.Err()
 }
 
 func (t *Trabajo) UpdateTrabajo(db *pgxpool.Pool) error {
 	updTime := time.Now()
+	if t.PoliticaTardanza == "" {
+		t.PoliticaTardanza = PoliticaCorte
+	}
 	_, err := db.Exec(
 		context.Background(),
 		`UPDATE trabajos SET descripcion=$1, cursoId=$2, activo=$3,
-		fechaInicio=$4, fechaFinal=$5, updatedAt=$6
-		WHERE id=$7`,
+		fechaInicio=$4, fechaFinal=$5, politicaTardanza=$6,
+		minutosGracia=$7, descuentoDiario=$8, descuentoMaximo=$9, updatedAt=$10
+		WHERE id=$11`,
 		t.Descripcion, t.CursoId, t.Activo, t.FechaInicio,
-		t.FechaFinal, updTime, t.ID)
+		t.FechaFinal, t.PoliticaTardanza, t.MinutosGracia,
+		t.DescuentoDiario, t.DescuentoMaximo, updTime, t.ID)
 
 	return err
 }
@@ -95,3 +146,60 @@ func (t *Trabajo) DeleteTrabajo(db *pgxpool.Pool) error {
 		t.ID)
 	return err
 }
+
+// FechaFinalPara devuelve la fecha limite de entrega para el alumno
+// al que pertenece la prorroga 'p'
+func (t *Trabajo) FechaFinalPara(p Prorroga) time.Time {
+	if p.Activo && p.FechaFinal != nil && p.FechaFinal.After(t.FechaFinal) {
+		return *p.FechaFinal
+	}
+	return t.FechaFinal
+}
+
+// Tardanza es el resultado de aplicar la politica del trabajo a una entrega
+type Tardanza struct {
+	Tardio       bool    `json:"tardio"`
+	DiasTarde    int     `json:"diasTarde"`
+	Penalizacion float32 `json:"penalizacion"` // porcentaje descontado
+}
+
+// AceptaEntrega indica si la politica admite una entrega en 'fecha' dado
+// el 'limite' del alumno (ver FechaFinalPara)
+func (t *Trabajo) AceptaEntrega(fecha, limite time.Time) bool {
+	switch t.PoliticaTardanza {
+	case PoliticaGracia:
+		return !fecha.After(limite.Add(time.Duration(t.MinutosGracia) * time.Minute))
+	case PoliticaDescuento:
+		return true
+	default:
+		return !fecha.After(limite)
+	}
+}
+
+// CalcularTardanza aplica la politica a una entrega hecha en 'fecha'.
+// Con la politica 'gracia' el retraso se cuenta desde el fin del periodo de
+// gracia. Cada dia o fraccion de retraso cuenta como un dia completo.
+func (t *Trabajo) CalcularTardanza(fecha, limite time.Time) Tardanza {
+	if t.PoliticaTardanza == PoliticaGracia {
+		limite = limite.Add(time.Duration(t.MinutosGracia) * time.Minute)
+	}
+	if !fecha.After(limite) {
+		return Tardanza{}
+	}
+	retraso := fecha.Sub(limite)
+	tardanza := Tardanza{
+		Tardio:    true,
+		DiasTarde: int(math.Ceil(retraso.Hours() / 24)),
+	}
+	if t.PoliticaTardanza == PoliticaDescuento {
+		penalizacion := float32(tardanza.DiasTarde) * t.DescuentoDiario
+		if t.DescuentoMaximo > 0 && penalizacion > t.DescuentoMaximo {
+			penalizacion = t.DescuentoMaximo
+		}
+		if penalizacion > 100 {
+			penalizacion = 100
+		}
+		tardanza.Penalizacion = penalizacion
+	}
+	return tardanza
+}
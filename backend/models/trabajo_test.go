@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/blackadress/vaula/consulta"
 	"github.com/blackadress/vaula/utils"
 	"github.com/jackc/pgx/v4"
 )
@@ -59,7 +60,7 @@ func TestNotGetTrabajo(t *testing.T) {
 func TestGetTrabajos(t *testing.T) {
 	utils.ClearTableTrabajo(db)
 	utils.AddTrabajos(2, db)
-	trabajos, err := GetTrabajos(db)
+	trabajos, _, err := GetTrabajos(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("algo salio mal con la comunicacion con la DB %s", err)
 	}
@@ -73,7 +74,7 @@ func TestGetTrabajos(t *testing.T) {
 func TestGetZeroTrabajos(t *testing.T) {
 	utils.ClearTableTrabajo(db)
 
-	trabajos, err := GetTrabajos(db)
+	trabajos, _, err := GetTrabajos(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("Algo salio mal con la comunicacion con la DB %s", err)
 	}
@@ -169,3 +170,48 @@ func TestDeleteTrabajo(t *testing.T) {
 		t.Errorf("Ocurrio un error en el metodo DeleteTrabajo")
 	}
 }
+
+func TestCalcularTardanza(t *testing.T) {
+	limite := time.Date(2022, time.June, 22, 18, 0, 0, 0, time.UTC)
+
+	corte := Trabajo{PoliticaTardanza: PoliticaCorte}
+	if corte.AceptaEntrega(limite.Add(time.Minute), limite) {
+		t.Errorf("Se esperaba que la politica 'corte' rechace entregas tardias")
+	}
+
+	gracia := Trabajo{PoliticaTardanza: PoliticaGracia, MinutosGracia: 30}
+	if !gracia.AceptaEntrega(limite.Add(20*time.Minute), limite) {
+		t.Errorf("Se esperaba que la politica 'gracia' acepte entregas dentro del periodo")
+	}
+	tardanza := gracia.CalcularTardanza(limite.Add(20*time.Minute), limite)
+	if tardanza.Tardio || tardanza.Penalizacion != 0 {
+		t.Errorf("Una entrega dentro del periodo de gracia no es tardia. Se obtuvo %v", tardanza)
+	}
+	tardanza = gracia.CalcularTardanza(limite.Add(30*time.Minute), limite)
+	if tardanza.Tardio {
+		t.Errorf("Una entrega al fin del periodo de gracia no es tardia. Se obtuvo %v", tardanza)
+	}
+	tardanza = gracia.CalcularTardanza(limite.Add(31*time.Minute), limite)
+	if !tardanza.Tardio || tardanza.DiasTarde != 1 || tardanza.Penalizacion != 0 {
+		t.Errorf("Se esperaba una entrega tardia sin penalizacion. Se obtuvo %v", tardanza)
+	}
+
+	descuento := Trabajo{
+		PoliticaTardanza: PoliticaDescuento,
+		DescuentoDiario:  10,
+		DescuentoMaximo:  25,
+	}
+	tardanza = descuento.CalcularTardanza(limite.Add(25*time.Hour), limite)
+	if tardanza.DiasTarde != 2 || tardanza.Penalizacion != 20 {
+		t.Errorf("Se esperaban 2 dias tarde y 20%% de descuento. Se obtuvo %v", tardanza)
+	}
+	tardanza = descuento.CalcularTardanza(limite.Add(24*5*time.Hour), limite)
+	if tardanza.Penalizacion != 25 {
+		t.Errorf("Se esperaba el descuento maximo de 25%%. Se obtuvo %v", tardanza)
+	}
+
+	tardanza = descuento.CalcularTardanza(limite, limite)
+	if tardanza.Tardio {
+		t.Errorf("Una entrega en la fecha limite no es tardia. Se obtuvo %v", tardanza)
+	}
+}
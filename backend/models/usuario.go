@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/blackadress/vaula/consulta"
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
@@ -13,7 +14,8 @@ import (
 type User struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
-	Password string `json:"password"`
+	// vacio cuando el usuario se carga como relacion de otro recurso
+	Password string `json:"password,omitempty"`
 	Email    string `json:"email"`
 
 	Activo    bool      `json:"activo"`
@@ -86,14 +88,30 @@ func (u *User) CreateUser(db *pgxpool.Pool) error {
 		u.Activo, now, now).Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt)
 }
 
-func GetUsers(db *pgxpool.Pool) ([]User, error) {
+// ListadoUsuarios son los filtros y ordenes de GET /users
+var ListadoUsuarios = consulta.Definicion{
+	Campos: map[string]consulta.Campo{
+		"username":  {Columna: "username", Tipo: consulta.Texto, Filtro: true, Parcial: true, Orden: true},
+		"email":     {Columna: "email", Tipo: consulta.Texto, Filtro: true, Parcial: true, Orden: true},
+		"activo":    {Columna: "activo", Tipo: consulta.Booleano, Filtro: true},
+		"createdAt": {Columna: "createdAt", Tipo: consulta.Fecha, Orden: true},
+	},
+}
+
+func GetUsers(db *pgxpool.Pool, c consulta.Consulta) ([]User, int, error) {
+	total, err := contar(db, "usuarios", c)
+	if err != nil {
+		return nil, 0, err
+	}
+	where, args := c.Filtro()
+
 	rows, err := db.Query(context.Background(),
 		`SELECT id, username, email, activo, createdAt, updatedAt
-		FROM usuarios`,
-	)
+		FROM usuarios`+where+c.OrdenYPagina(),
+		args...)
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	defer rows.Close()
@@ -107,12 +125,12 @@ func GetUsers(db *pgxpool.Pool) ([]User, error) {
 			&u.Activo, &u.CreatedAt, &u.UpdatedAt)
 		if err != nil {
 			log.Printf("The rows we got from the DB can't be 'Scan'(ed) %s", err)
-			return nil, err
+			return nil, 0, err
 		}
 		users = append(users, u)
 	}
 
-	return users, nil
+	return users, total, rows.Err()
 }
 
 type Claims struct {
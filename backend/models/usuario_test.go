@@ -3,6 +3,7 @@ package models
 import (
 	"testing"
 
+	"github.com/blackadress/vaula/consulta"
 	"github.com/blackadress/vaula/utils"
 	"github.com/jackc/pgx/v4"
 )
@@ -49,7 +50,7 @@ func TestNotGetUser(t *testing.T) {
 func TestGetUsers(t *testing.T) {
 	utils.ClearTableUsuario(db)
 	utils.AddUsers(2, db)
-	users, err := GetUsers(db)
+	users, _, err := GetUsers(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("Metodo user.GetUsers no funciona %s", err)
 	}
@@ -62,7 +63,7 @@ func TestGetUsers(t *testing.T) {
 func TestGetZeroUsers(t *testing.T) {
 	utils.ClearTableUsuario(db)
 
-	users, err := GetUsers(db)
+	users, _, err := GetUsers(db, consulta.Consulta{})
 	if err != nil {
 		t.Errorf("Medodo user.GetUsers no funciona %s", err)
 	}
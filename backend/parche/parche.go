@@ -0,0 +1,54 @@
+// Package parche aplica JSON Merge Patch (RFC 7396): un documento JSON que
+// describe los cambios sobre otro. Las claves del parche reemplazan a las
+// del original, los objetos se fusionan recursivamente y un null borra la
+// clave. Cualquier otro valor, incluidos los arrays, reemplaza completo al
+// original.
+package parche
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// TipoContenido es el media type de los merge patch
+const TipoContenido = "application/merge-patch+json"
+
+var ErrNoEsObjeto = errors.New("El parche debe ser un objeto JSON")
+
+// Aplicar devuelve 'original' con 'parche' aplicado. Solo acepta parches
+// que sean objetos, porque un parche de otro tipo reemplazaria el recurso
+// entero.
+func Aplicar(original, parche []byte) ([]byte, error) {
+	var p interface{}
+	if err := json.Unmarshal(parche, &p); err != nil {
+		return nil, err
+	}
+	if _, ok := p.(map[string]interface{}); !ok {
+		return nil, ErrNoEsObjeto
+	}
+
+	var o interface{}
+	if err := json.Unmarshal(original, &o); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fusionar(o, p))
+}
+
+func fusionar(original, parche interface{}) interface{} {
+	p, ok := parche.(map[string]interface{})
+	if !ok {
+		return parche
+	}
+	o, ok := original.(map[string]interface{})
+	if !ok {
+		o = map[string]interface{}{}
+	}
+	for clave, valor := range p {
+		if valor == nil {
+			delete(o, clave)
+			continue
+		}
+		o[clave] = fusionar(o[clave], valor)
+	}
+	return o
+}
@@ -0,0 +1,51 @@
+package parche
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// casos del apendice A de la RFC 7396 con parches que son objetos
+func TestAplicar(t *testing.T) {
+	casos := []struct {
+		original, parche, esperado string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range casos {
+		obtenido, err := Aplicar([]byte(c.original), []byte(c.parche))
+		if err != nil {
+			t.Errorf("Error inesperado con %s: %s", c.parche, err)
+			continue
+		}
+		var o, e interface{}
+		json.Unmarshal(obtenido, &o)
+		json.Unmarshal([]byte(c.esperado), &e)
+		if !reflect.DeepEqual(o, e) {
+			t.Errorf("%s + %s: se esperaba %s. Se obtuvo %s",
+				c.original, c.parche, c.esperado, obtenido)
+		}
+	}
+}
+
+func TestAplicarSoloObjetos(t *testing.T) {
+	for _, p := range []string{`["c"]`, `null`, `"x"`} {
+		if _, err := Aplicar([]byte(`{"a":"b"}`), []byte(p)); err != ErrNoEsObjeto {
+			t.Errorf("Se esperaba ErrNoEsObjeto con %s. Se obtuvo %v", p, err)
+		}
+	}
+	if _, err := Aplicar([]byte(`{}`), []byte(`{`)); err == nil {
+		t.Errorf("Se esperaba un error con JSON invalido")
+	}
+}
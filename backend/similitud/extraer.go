@@ -0,0 +1,287 @@
+package similitud
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNoSoportado se devuelve para los archivos de los que no se puede
+// extraer texto, como las imagenes
+var ErrNoSoportado = errors.New("similitud: no se puede extraer texto de este tipo de archivo")
+
+// maxDescomprimido limita lo que se lee de un archivo comprimido
+const maxDescomprimido = 50 << 20
+
+// ExtraerTexto obtiene el texto de una entrega segun su tipo: texto plano
+// o Markdown, docx, odt, PDF o un .gz con texto. El nombre del archivo
+// distingue Markdown de texto plano.
+func ExtraerTexto(contenido []byte, mimeType, nombre string) (string, error) {
+	base, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		base = mimeType
+	}
+
+	switch base {
+	case "text/plain":
+		texto := aUTF8(contenido)
+		switch strings.ToLower(path.Ext(nombre)) {
+		case ".md", ".markdown":
+			texto = QuitarMarkdown(texto)
+		}
+		return texto, nil
+	case "application/x-gzip", "application/gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(contenido))
+		if err != nil {
+			return "", err
+		}
+		texto, err := ioutil.ReadAll(io.LimitReader(gz, maxDescomprimido))
+		if err != nil {
+			return "", err
+		}
+		nombre = strings.TrimSuffix(nombre, path.Ext(nombre))
+		return ExtraerTexto(texto, "text/plain", nombre)
+	case "application/zip":
+		return textoOffice(contenido)
+	case "application/pdf":
+		return textoPDF(contenido), nil
+	}
+	return "", ErrNoSoportado
+}
+
+// aUTF8 deja el texto en UTF-8; lo que no es UTF-8 valido se asume
+// Latin-1, comun en archivos guardados en Windows
+func aUTF8(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+var (
+	mdImagen     = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdEnlace     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdEtiqueta   = regexp.MustCompile(`<[^>]+>`)
+	mdMarcas     = regexp.MustCompile("(\\*\\*|__|~~|`+|\\*)")
+	mdInicioLine = regexp.MustCompile(`(?m)^[ \t]*(#{1,6}[ \t]+|>[ \t]?|[-*+][ \t]+|\d+[.)][ \t]+)`)
+)
+
+// QuitarMarkdown deja solo el texto: sin encabezados, listas, enfasis ni
+// direcciones de enlaces e imagenes, que no son parte de lo escrito
+func QuitarMarkdown(s string) string {
+	s = mdImagen.ReplaceAllString(s, "$1")
+	s = mdEnlace.ReplaceAllString(s, "$1")
+	s = mdEtiqueta.ReplaceAllString(s, "")
+	s = mdInicioLine.ReplaceAllString(s, "")
+	return mdMarcas.ReplaceAllString(s, "")
+}
+
+// textoOffice lee el texto de un docx (word/document.xml) o un odt
+// (content.xml). Otros zip no son documentos.
+func textoOffice(contenido []byte) (string, error) {
+	z, err := zip.NewReader(bytes.NewReader(contenido), int64(len(contenido)))
+	if err != nil {
+		return "", err
+	}
+	for _, f := range z.File {
+		switch f.Name {
+		case "word/document.xml":
+			return textoXML(f, "t", map[string]bool{"p": true, "tab": true, "br": true})
+		case "content.xml":
+			return textoXML(f, "", map[string]bool{"p": true, "h": true, "tab": true, "s": true, "line-break": true})
+		}
+	}
+	return "", ErrNoSoportado
+}
+
+// textoXML junta el texto de los elementos 'soloEn' (todos si es "") y
+// separa con un salto de linea o espacio al cerrar los elementos de 'cortes'
+func textoXML(f *zip.File, soloEn string, cortes map[string]bool) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var b strings.Builder
+	dentro := soloEn == ""
+	decoder := xml.NewDecoder(io.LimitReader(rc, maxDescomprimido))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return b.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == soloEn {
+				dentro = true
+			}
+		case xml.EndElement:
+			if soloEn != "" && t.Name.Local == soloEn {
+				dentro = false
+			}
+			if cortes[t.Name.Local] {
+				if t.Name.Local == "p" || t.Name.Local == "h" {
+					b.WriteString("\n")
+				} else {
+					b.WriteString(" ")
+				}
+			}
+		case xml.CharData:
+			if dentro {
+				b.Write(t)
+			}
+		}
+	}
+}
+
+var (
+	inicioStream = regexp.MustCompile(`stream\r?\n`)
+	filtroPDF    = regexp.MustCompile(`/Filter\s*\[?\s*/(\w+)`)
+)
+
+// textoPDF extrae lo que puede de los operadores de texto (Tj, TJ, ' y ")
+// de cada stream, descomprimiendo los FlateDecode. Es aproximado: los
+// PDF con fuentes CID o codificaciones propias dan texto ilegible, que
+// simplemente no coincidira con nada.
+func textoPDF(contenido []byte) string {
+	var b strings.Builder
+	for _, m := range inicioStream.FindAllIndex(contenido, -1) {
+		fin := bytes.Index(contenido[m[1]:], []byte("endstream"))
+		if fin < 0 {
+			continue
+		}
+		datos := contenido[m[1] : m[1]+fin]
+
+		// el diccionario del stream esta entre el 'obj' anterior y 'stream'
+		inicioDict := bytes.LastIndex(contenido[:m[0]], []byte("obj"))
+		if inicioDict < 0 {
+			inicioDict = 0
+		}
+		dict := contenido[inicioDict:m[0]]
+		if f := filtroPDF.FindSubmatch(dict); f != nil {
+			if string(f[1]) != "FlateDecode" {
+				continue // imagenes y otros filtros
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(datos))
+			if err != nil {
+				continue
+			}
+			// un stream cortado igual aporta lo que se alcanzo a leer
+			datos, _ = ioutil.ReadAll(io.LimitReader(zr, maxDescomprimido))
+		}
+		operadoresTexto(datos, &b)
+	}
+	return b.String()
+}
+
+// operadoresTexto recorre el contenido de una pagina y escribe las cadenas
+// que se muestran. Un desplazamiento grande dentro de TJ es un espacio.
+func operadoresTexto(datos []byte, b *strings.Builder) {
+	var pendientes []string
+	for i := 0; i < len(datos); i++ {
+		c := datos[i]
+		switch {
+		case c == '(':
+			s, fin := cadenaLiteral(datos, i)
+			pendientes = append(pendientes, s)
+			i = fin
+		case c == '/':
+			// nombres como /F1, para no confundirlos con operadores
+			for i+1 < len(datos) && strings.IndexByte(" \t\r\n/[]()<>", datos[i+1]) < 0 {
+				i++
+			}
+		case c == '[' || c == ']':
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			inicio := i
+			for i+1 < len(datos) && (datos[i+1] == '.' || (datos[i+1] >= '0' && datos[i+1] <= '9')) {
+				i++
+			}
+			if len(pendientes) > 0 && c == '-' && i-inicio >= 3 {
+				pendientes = append(pendientes, " ")
+			}
+		case c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '\'' || c == '"':
+			inicio := i
+			for i+1 < len(datos) && (datos[i+1] >= 'A' && datos[i+1] <= 'Z' ||
+				datos[i+1] >= 'a' && datos[i+1] <= 'z' || datos[i+1] == '*') {
+				i++
+			}
+			switch string(datos[inicio : i+1]) {
+			case "Tj", "TJ":
+				b.WriteString(strings.Join(pendientes, ""))
+			case "'", "\"":
+				b.WriteString("\n" + strings.Join(pendientes, ""))
+			case "Td", "TD":
+				b.WriteString(" ")
+			case "T*", "ET":
+				b.WriteString("\n")
+			}
+			pendientes = pendientes[:0]
+		}
+	}
+}
+
+// cadenaLiteral lee la cadena que empieza en datos[inicio] == '(' con sus
+// escapes y parentesis anidados. Devuelve la cadena y la posicion del ')'
+// final. Los bytes se toman como Latin-1, cercano a WinAnsi.
+func cadenaLiteral(datos []byte, inicio int) (string, int) {
+	var r []rune
+	nivel := 0
+	for i := inicio; i < len(datos); i++ {
+		c := datos[i]
+		switch {
+		case c == '\\' && i+1 < len(datos):
+			i++
+			switch e := datos[i]; e {
+			case 'n':
+				r = append(r, '\n')
+			case 'r', 't', 'b', 'f':
+				r = append(r, ' ')
+			case '\r', '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+					for n := 0; n < 3 && i < len(datos) && datos[i] >= '0' && datos[i] <= '7'; n++ {
+						v = v*8 + int(datos[i]-'0')
+						i++
+					}
+					i--
+					r = append(r, rune(v&0xff))
+				} else {
+					r = append(r, rune(e))
+				}
+			}
+		case c == '(':
+			nivel++
+			if nivel > 1 {
+				r = append(r, '(')
+			}
+		case c == ')':
+			nivel--
+			if nivel == 0 {
+				return string(r), i
+			}
+			r = append(r, ')')
+		default:
+			r = append(r, rune(c))
+		}
+	}
+	return string(r), len(datos)
+}
@@ -0,0 +1,259 @@
+// Package similitud detecta pasajes comunes entre textos con huellas de
+// winnowing (Schleimer, Wilkerson y Aiken, 2003): cada texto se reduce a
+// los hashes minimos de sus k-gramas de palabras, y dos textos se parecen
+// en la medida en que comparten huellas. No depende de la base de datos;
+// los handlers arman los documentos y guardan el resultado.
+package similitud
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// K es la cantidad de palabras de cada k-grama. Un pasaje comun de
+	// K+Ventana-1 palabras o mas siempre comparte al menos una huella.
+	K = 5
+	// Ventana es la cantidad de k-gramas consecutivos de los que se toma
+	// el hash minimo
+	Ventana = 4
+	// MaxPasajes limita los pasajes que se reportan por par, los mas largos
+	MaxPasajes = 10
+	// MaxLargoPasaje limita los bytes del texto de cada pasaje reportado
+	MaxLargoPasaje = 300
+)
+
+type palabra struct {
+	inicio, fin int // bytes en el texto original
+}
+
+// Documento es un texto ya reducido a sus huellas
+type Documento struct {
+	texto        string
+	palabras     []palabra
+	normalizadas []string
+	huellas      map[uint64][]int // hash del k-grama -> palabra donde empieza
+}
+
+// NuevoDocumento calcula las huellas del texto. Las palabras se comparan
+// sin mayusculas ni tildes, ignorando la puntuacion y los espacios.
+func NuevoDocumento(texto string) *Documento {
+	d := &Documento{texto: texto, huellas: map[uint64][]int{}}
+
+	inicio := -1
+	for i, r := range texto + " " {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if inicio < 0 {
+				inicio = i
+			}
+			continue
+		}
+		if inicio >= 0 {
+			d.palabras = append(d.palabras, palabra{inicio, i})
+			d.normalizadas = append(d.normalizadas, normalizar(texto[inicio:i]))
+			inicio = -1
+		}
+	}
+
+	hashes := make([]uint64, 0, len(d.normalizadas))
+	for i := 0; i+K <= len(d.normalizadas); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(d.normalizadas[i:i+K], " ")))
+		hashes = append(hashes, h.Sum64())
+	}
+
+	// en cada ventana se elige el hash minimo, el de mas a la derecha si
+	// hay empate, y se registra solo cuando cambia la eleccion
+	ventana := Ventana
+	if len(hashes) < ventana {
+		ventana = len(hashes)
+	}
+	elegido := -1
+	for fin := ventana; fin <= len(hashes) && ventana > 0; fin++ {
+		minimo := fin - ventana
+		for i := minimo; i < fin; i++ {
+			if hashes[i] <= hashes[minimo] {
+				minimo = i
+			}
+		}
+		if minimo != elegido {
+			elegido = minimo
+			d.huellas[hashes[minimo]] = append(d.huellas[hashes[minimo]], minimo)
+		}
+	}
+	return d
+}
+
+var sinTildes = strings.NewReplacer(
+	"á", "a", "à", "a", "ä", "a", "â", "a",
+	"é", "e", "è", "e", "ë", "e", "ê", "e",
+	"í", "i", "ì", "i", "ï", "i", "î", "i",
+	"ó", "o", "ò", "o", "ö", "o", "ô", "o",
+	"ú", "u", "ù", "u", "ü", "u", "û", "u",
+)
+
+func normalizar(s string) string {
+	return sinTildes.Replace(strings.ToLower(s))
+}
+
+// Huellas es la cantidad de huellas distintas del documento. Un texto de
+// menos de K palabras no tiene huellas y no se parece a ningun otro.
+func (d *Documento) Huellas() int {
+	return len(d.huellas)
+}
+
+// Pasaje es un fragmento que aparece en ambos textos
+type Pasaje struct {
+	TextoA   string `json:"textoA"`
+	TextoB   string `json:"textoB"`
+	Palabras int    `json:"palabras"` // largo aproximado, en palabras de A
+}
+
+// Comparacion de dos documentos. SimilitudA es el porcentaje de las huellas
+// de A que tambien estan en B, y SimilitudB al reves: si A copio todo B y
+// agrego otro tanto, SimilitudB es 100 y SimilitudA cerca de 50.
+type Comparacion struct {
+	SimilitudA float64  `json:"similitudA"`
+	SimilitudB float64  `json:"similitudB"`
+	Pasajes    []Pasaje `json:"pasajes"`
+}
+
+// Maxima es la mayor de las dos similitudes
+func (c Comparacion) Maxima() float64 {
+	if c.SimilitudA > c.SimilitudB {
+		return c.SimilitudA
+	}
+	return c.SimilitudB
+}
+
+type coincidencia struct {
+	a, b int // palabra donde empieza el k-grama en cada documento
+}
+
+// Comparar calcula la similitud de dos documentos y sus pasajes comunes
+func Comparar(a, b *Documento) Comparacion {
+	c := Comparacion{Pasajes: []Pasaje{}}
+	if a.Huellas() == 0 || b.Huellas() == 0 {
+		return c
+	}
+
+	comunes := 0
+	var coincidencias []coincidencia
+	for h, posicionesA := range a.huellas {
+		posicionesB, ok := b.huellas[h]
+		if !ok {
+			continue
+		}
+		comunes++
+		for _, pa := range posicionesA {
+			for _, pb := range posicionesB {
+				coincidencias = append(coincidencias, coincidencia{pa, pb})
+			}
+		}
+	}
+	c.SimilitudA = 100 * float64(comunes) / float64(a.Huellas())
+	c.SimilitudB = 100 * float64(comunes) / float64(b.Huellas())
+
+	// las coincidencias con el mismo desplazamiento entre A y B y cercanas
+	// entre si forman un solo pasaje
+	sort.Slice(coincidencias, func(i, j int) bool {
+		di, dj := coincidencias[i].b-coincidencias[i].a, coincidencias[j].b-coincidencias[j].a
+		if di != dj {
+			return di < dj
+		}
+		return coincidencias[i].a < coincidencias[j].a
+	})
+	type tramo struct{ inicio, fin, desplazamiento int }
+	var tramos []tramo
+	for _, co := range coincidencias {
+		d := co.b - co.a
+		if n := len(tramos); n > 0 && tramos[n-1].desplazamiento == d && co.a <= tramos[n-1].fin+Ventana {
+			if co.a+K > tramos[n-1].fin {
+				tramos[n-1].fin = co.a + K
+			}
+			continue
+		}
+		tramos = append(tramos, tramo{co.a, co.a + K, d})
+	}
+
+	// las huellas no marcan el inicio ni el fin exacto del pasaje, se
+	// extiende mientras las palabras sigan siendo iguales
+	extendidos := map[tramo]bool{}
+	unicos := tramos[:0]
+	for _, t := range tramos {
+		for t.inicio > 0 && t.inicio+t.desplazamiento > 0 &&
+			a.normalizadas[t.inicio-1] == b.normalizadas[t.inicio-1+t.desplazamiento] {
+			t.inicio--
+		}
+		for t.fin < len(a.normalizadas) && t.fin+t.desplazamiento < len(b.normalizadas) &&
+			a.normalizadas[t.fin] == b.normalizadas[t.fin+t.desplazamiento] {
+			t.fin++
+		}
+		if !extendidos[t] {
+			extendidos[t] = true
+			unicos = append(unicos, t)
+		}
+	}
+	tramos = unicos
+
+	sort.SliceStable(tramos, func(i, j int) bool {
+		return tramos[i].fin-tramos[i].inicio > tramos[j].fin-tramos[j].inicio
+	})
+	if len(tramos) > MaxPasajes {
+		tramos = tramos[:MaxPasajes]
+	}
+	sort.Slice(tramos, func(i, j int) bool { return tramos[i].inicio < tramos[j].inicio })
+
+	for _, t := range tramos {
+		c.Pasajes = append(c.Pasajes, Pasaje{
+			TextoA:   a.fragmento(t.inicio, t.fin),
+			TextoB:   b.fragmento(t.inicio+t.desplazamiento, t.fin+t.desplazamiento),
+			Palabras: t.fin - t.inicio,
+		})
+	}
+	return c
+}
+
+// fragmento devuelve el texto original de las palabras [inicio, fin),
+// recortado a MaxLargoPasaje bytes
+func (d *Documento) fragmento(inicio, fin int) string {
+	if fin > len(d.palabras) {
+		fin = len(d.palabras)
+	}
+	s := d.texto[d.palabras[inicio].inicio:d.palabras[fin-1].fin]
+	if len(s) <= MaxLargoPasaje {
+		return s
+	}
+	corte := MaxLargoPasaje
+	for corte > 0 && !utf8.RuneStart(s[corte]) {
+		corte--
+	}
+	return s[:corte] + "..."
+}
+
+// Par es la comparacion de los documentos con indices A y B
+type Par struct {
+	A int `json:"-"`
+	B int `json:"-"`
+	Comparacion
+}
+
+// CompararTodos compara cada par de documentos, O(n²) en la cantidad de
+// documentos, y devuelve los pares cuya similitud maxima llega a 'minimo'
+// ordenados de mayor a menor
+func CompararTodos(documentos []*Documento, minimo float64) []Par {
+	pares := []Par{}
+	for i := range documentos {
+		for j := i + 1; j < len(documentos); j++ {
+			c := Comparar(documentos[i], documentos[j])
+			if c.Maxima() > 0 && c.Maxima() >= minimo {
+				pares = append(pares, Par{A: i, B: j, Comparacion: c})
+			}
+		}
+	}
+	sort.SliceStable(pares, func(i, j int) bool { return pares[i].Maxima() > pares[j].Maxima() })
+	return pares
+}
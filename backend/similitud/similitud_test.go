@@ -0,0 +1,117 @@
+package similitud
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const original = "La fotosintesis es el proceso por el cual las plantas convierten " +
+	"la energia de la luz en energia quimica, liberando oxigeno a la atmosfera " +
+	"y produciendo glucosa a partir de dioxido de carbono y agua."
+
+func TestCompararCopia(t *testing.T) {
+	copia := "Introduccion propia del alumno sobre el tema. " +
+		"La FOTOSÍNTESIS es el proceso, por el cual las plantas convierten " +
+		"la energía de la luz en energía química, liberando oxígeno a la atmósfera " +
+		"y produciendo glucosa a partir de dioxido de carbono y agua."
+
+	c := Comparar(NuevoDocumento(original), NuevoDocumento(copia))
+
+	if c.SimilitudA != 100 {
+		t.Errorf("Se esperaba encontrar todo el original en la copia. Se obtuvo %v", c.SimilitudA)
+	}
+	if c.SimilitudB >= 100 || c.SimilitudB < 50 {
+		t.Errorf("Se esperaba una similitud parcial de la copia. Se obtuvo %v", c.SimilitudB)
+	}
+	if len(c.Pasajes) != 1 {
+		t.Fatalf("Se esperaba un solo pasaje. Se obtuvo %+v", c.Pasajes)
+	}
+	if !strings.HasPrefix(c.Pasajes[0].TextoB, "La FOTOSÍNTESIS es el proceso,") {
+		t.Errorf("El pasaje debe mostrar el texto original de B. Se obtuvo '%s'", c.Pasajes[0].TextoB)
+	}
+}
+
+func TestCompararDistintos(t *testing.T) {
+	otro := "El ciclo del agua describe el movimiento continuo del agua entre " +
+		"la superficie terrestre y la atmosfera mediante evaporacion y lluvia."
+
+	c := Comparar(NuevoDocumento(original), NuevoDocumento(otro))
+	if c.Maxima() != 0 || len(c.Pasajes) != 0 {
+		t.Errorf("No se esperaba similitud. Se obtuvo %+v", c)
+	}
+
+	corto := Comparar(NuevoDocumento("muy corto"), NuevoDocumento("muy corto"))
+	if corto.Maxima() != 0 {
+		t.Errorf("Un texto sin huellas no se parece a nada. Se obtuvo %v", corto.Maxima())
+	}
+}
+
+func TestCompararTodos(t *testing.T) {
+	documentos := []*Documento{
+		NuevoDocumento(original),
+		NuevoDocumento("Texto sin relacion alguna con los demas documentos del grupo de prueba."),
+		NuevoDocumento(original + " Conclusion agregada por el alumno."),
+	}
+
+	pares := CompararTodos(documentos, 10)
+	if len(pares) != 1 || pares[0].A != 0 || pares[0].B != 2 {
+		t.Errorf("Se esperaba solo el par 0-2. Se obtuvo %+v", pares)
+	}
+}
+
+func TestQuitarMarkdown(t *testing.T) {
+	md := "# Titulo\n\n- **Uno** y [enlace](http://x.com/a)\n> cita `codigo`\n![img](a.png)"
+	esperado := "Titulo\n\nUno y enlace\ncita codigo\nimg"
+	if s := QuitarMarkdown(md); s != esperado {
+		t.Errorf("Se esperaba %q. Se obtuvo %q", esperado, s)
+	}
+}
+
+func TestExtraerDocx(t *testing.T) {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	f, _ := z.Create("word/document.xml")
+	f.Write([]byte(`<w:document xmlns:w="w"><w:body>` +
+		`<w:p><w:r><w:t>Hola</w:t></w:r><w:r><w:t xml:space="preserve"> mundo</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:instrText>PAGE</w:instrText><w:t>Fin</w:t></w:r></w:p>` +
+		`</w:body></w:document>`))
+	z.Close()
+
+	texto, err := ExtraerTexto(buf.Bytes(), "application/zip", "trabajo.docx")
+	if err != nil {
+		t.Fatalf("No se extrajo el texto %s", err)
+	}
+	if texto != "Hola mundo\nFin\n" {
+		t.Errorf("Se esperaba el texto del documento. Se obtuvo %q", texto)
+	}
+}
+
+func TestExtraerPDF(t *testing.T) {
+	var contenido bytes.Buffer
+	zw := zlib.NewWriter(&contenido)
+	zw.Write([]byte("BT /F1 12 Tf 72 700 Td (Texto del \\(alumno\\)) Tj T* [(con)-300(espacio)] TJ ET"))
+	zw.Close()
+
+	var pdf bytes.Buffer
+	fmt.Fprintf(&pdf, "%%PDF-1.4\n4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", contenido.Len())
+	pdf.Write(contenido.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n%%EOF\n")
+
+	texto, err := ExtraerTexto(pdf.Bytes(), "application/pdf", "trabajo.pdf")
+	if err != nil {
+		t.Fatalf("No se extrajo el texto %s", err)
+	}
+	if strings.TrimSpace(texto) != "Texto del (alumno)\ncon espacio" {
+		t.Errorf("Se esperaba el texto de la pagina. Se obtuvo %q", texto)
+	}
+}
+
+func TestExtraerNoSoportado(t *testing.T) {
+	if _, err := ExtraerTexto([]byte{0x89, 'P', 'N', 'G'}, "image/png", "foto.png"); err != ErrNoSoportado {
+		t.Errorf("Se esperaba ErrNoSoportado. Se obtuvo %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+// Package storage guarda los archivos subidos por los usuarios (entregas,
+// adjuntos) detras de una interfaz, para poder cambiar entre el disco
+// local y un servicio compatible con S3 sin tocar los handlers.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNotFound se devuelve cuando la clave pedida no existe
+var ErrNotFound = errors.New("storage: archivo no encontrado")
+
+type Storage interface {
+	// Put guarda el contenido de 'r' bajo 'key'. Si la clave ya existe se
+	// sobreescribe.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get abre el archivo guardado bajo 'key'. Quien llama debe cerrarlo.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// FromEnv construye el Storage configurado por las variables de entorno.
+// STORAGE_DRIVER puede ser 'local' (por defecto) o 's3'.
+func FromEnv() (Storage, error) {
+	switch strings.ToLower(os.Getenv("STORAGE_DRIVER")) {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return NewLocal(dir)
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	default:
+		return nil, errors.New("storage: STORAGE_DRIVER desconocido")
+	}
+}
+
+// validKey rechaza claves vacias o que intenten salir del directorio base
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
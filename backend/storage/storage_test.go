@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 es un servidor compatible con S3 en memoria para las pruebas
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func roundTrip(t *testing.T, s Storage) {
+	ctx := context.Background()
+	content := []byte("contenido de la entrega")
+
+	err := s.Put(ctx, "trabajos/1/entrega.txt", bytes.NewReader(content),
+		int64(len(content)), "text/plain")
+	if err != nil {
+		t.Fatalf("Put fallo %s", err)
+	}
+
+	rc, err := s.Get(ctx, "trabajos/1/entrega.txt")
+	if err != nil {
+		t.Fatalf("Get fallo %s", err)
+	}
+	got, _ := ioutil.ReadAll(rc)
+	rc.Close()
+	if !bytes.Equal(got, content) {
+		t.Errorf("Se esperaba leer '%s'. Se obtuvo '%s'", content, got)
+	}
+
+	if err := s.Delete(ctx, "trabajos/1/entrega.txt"); err != nil {
+		t.Errorf("Delete fallo %s", err)
+	}
+	if _, err := s.Get(ctx, "trabajos/1/entrega.txt"); err != ErrNotFound {
+		t.Errorf("Se esperaba ErrNotFound luego de borrar. Se obtuvo %v", err)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vaula-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, s)
+}
+
+func TestS3(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: map[string][]byte{}})
+	defer server.Close()
+
+	s, err := NewS3(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "vaula",
+		AccessKey: "key",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, s)
+}
+
+func TestClaveInvalida(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "vaula-storage")
+	s, _ := NewLocal(dir)
+
+	for _, key := range []string{"", "/etc/passwd", "../fuera", "a/../../b"} {
+		err := s.Put(context.Background(), key, strings.NewReader("x"), 1, "")
+		if err == nil {
+			t.Errorf("Se esperaba rechazar la clave '%s'", key)
+		}
+	}
+}
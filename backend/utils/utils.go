@@ -34,6 +34,9 @@ func EnsureTableUsuarioExists(db *pgxpool.Pool) {
 func ClearTableUsuario(db *pgxpool.Pool) {
 	ClearTableAlumno(db)
 	ClearTableProfesor(db)
+	ClearTableRubrica(db)
+	ClearTableNotificacion(db)
+	ClearTableTokenCalendario(db)
 	_, err := db.Exec(context.Background(), "DELETE FROM usuarios")
 	if err != nil {
 		log.Printf("Error deleteando contenidos de la tabla usuarios %s", err)
@@ -87,6 +90,11 @@ func EnsureTableAlumnoExists(db *pgxpool.Pool) {
 }
 
 func ClearTableAlumno(db *pgxpool.Pool) {
+	ClearTableLibreta(db)
+	ClearTableAlumnoCurso(db)
+	ClearTableAlumnoTrabajo(db)
+	ClearTableAlumnoExamen(db)
+	ClearTableProrroga(db)
 	_, err := db.Exec(context.Background(), "DELETE FROM alumnos")
 	if err != nil {
 		log.Printf("Error deleteando contenidos de la tabla alumno %s", err)
@@ -143,7 +151,15 @@ func EnsureTableCursoExists(db *pgxpool.Pool) {
 }
 
 func ClearTableCurso(db *pgxpool.Pool) {
+	ClearTableRecordatorio(db)
+	ClearTableMensaje(db)
+	ClearTableForo(db)
+	ClearTableProfesorCurso(db)
+	ClearTableAsistencia(db)
+	ClearTableLibreta(db)
+	ClearTableAlumnoCurso(db)
 	ClearTablePregunta(db)
+	ClearTableBancoPreguntas(db)
 	ClearTableTrabajo(db)
 	ClearTablePreguntaTrabajo(db)
 	ClearTableExamen(db)
@@ -178,6 +194,7 @@ func AddCursos(count int, db *pgxpool.Pool) {
 }
 
 // EXAMEN
+// intentos 0 significa intentos ilimitados, igual que Examen.Intentos
 const tableExamenCreationQuery = `
 CREATE TABLE IF NOT EXISTS examenes
 	(
@@ -185,6 +202,8 @@ CREATE TABLE IF NOT EXISTS examenes
 		nombre VARCHAR(200) NOT NULL,
 		fechaInicio TIMESTAMPTZ NOT NULL,
 		fechaFinal TIMESTAMPTZ NOT NULL,
+		duracion INT NOT NULL DEFAULT 0,
+		intentos INT NOT NULL DEFAULT 0,
 		cursoId INT REFERENCES cursos(id),
 
 		activo BOOLEAN NOT NULL,
@@ -201,6 +220,8 @@ func EnsureTableExamenExists(db *pgxpool.Pool) {
 }
 
 func ClearTableExamen(db *pgxpool.Pool) {
+	ClearTableAlumnoExamen(db)
+	ClearTableProrroga(db)
 	_, err := db.Exec(context.Background(), "DELETE FROM examenes")
 	if err != nil {
 		log.Printf("Error deleteando contenidos de la tabla Examen %s", err)
@@ -241,12 +262,28 @@ func AddExamenes(count int, db *pgxpool.Pool) {
 }
 
 // PREGUNTA
+const tableBancoPreguntasCreationQuery = `
+CREATE TABLE IF NOT EXISTS bancosPreguntas
+	(
+		id SERIAL PRIMARY KEY,
+		nombre VARCHAR(200) NOT NULL,
+		cursoId INT REFERENCES cursos(id) ON DELETE CASCADE,
+
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL
+	)
+`
+
 const tablePreguntaCreationQuery = `
 CREATE TABLE IF NOT EXISTS preguntas
 	(
 		id SERIAL PRIMARY KEY,
 		enunciado TEXT NOT NULL,
-		examenId INT REFERENCES cursos(id),
+		tipo VARCHAR(20) NOT NULL DEFAULT 'multichoice'
+			CHECK (tipo IN ('multichoice', 'truefalse', 'shortanswer', 'numerical', 'essay')),
+		puntaje REAL NOT NULL DEFAULT 1,
+		examenId INT REFERENCES examenes(id) ON DELETE CASCADE,
+		bancoId INT REFERENCES bancosPreguntas(id) ON DELETE CASCADE,
 
 		activo BOOLEAN NOT NULL,
 		createdAt TIMESTAMPTZ NOT NULL,
@@ -255,7 +292,11 @@ CREATE TABLE IF NOT EXISTS preguntas
 `
 
 func EnsureTablePreguntaExists(db *pgxpool.Pool) {
-	_, err := db.Exec(context.Background(), tablePreguntaCreationQuery)
+	_, err := db.Exec(context.Background(), tableBancoPreguntasCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla bancosPreguntas: %s", err)
+	}
+	_, err = db.Exec(context.Background(), tablePreguntaCreationQuery)
 	if err != nil {
 		log.Printf("TEST: error creando tabla pregunta: %s", err)
 	}
@@ -273,6 +314,36 @@ func ClearTablePregunta(db *pgxpool.Pool) {
 
 }
 
+func ClearTableBancoPreguntas(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), "DELETE FROM bancosPreguntas")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla bancosPreguntas %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE bancosPreguntas_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de bancosPreguntas_id %s", err)
+	}
+}
+
+func AddBancosPreguntas(count int, db *pgxpool.Pool) {
+	AddCursos(1, db)
+	if count < 1 {
+		count = 1
+	}
+	now := time.Now()
+
+	for i := 0; i < count; i++ {
+		_, err := db.Exec(
+			context.Background(),
+			`INSERT INTO bancosPreguntas(nombre, cursoId, createdAt, updatedAt)
+			VALUES($1, 1, $2, $2)`,
+			"banco_test_"+strconv.Itoa(i), now)
+		if err != nil {
+			log.Printf("Error adding bancosPreguntas %s", err)
+		}
+	}
+}
+
 func AddPreguntas(count int, db *pgxpool.Pool) {
 	AddExamenes(count, db)
 	if count < 1 {
@@ -301,7 +372,7 @@ CREATE TABLE IF NOT EXISTS preguntasTrabajo
 	(
 		id SERIAL PRIMARY KEY,
 		enunciado TEXT NOT NULL,
-		trabajoId INT REFERENCES cursos(id),
+		trabajoId INT REFERENCES trabajos(id) ON DELETE CASCADE,
 
 		activo BOOLEAN NOT NULL,
 		createdAt TIMESTAMPTZ NOT NULL,
@@ -309,15 +380,42 @@ CREATE TABLE IF NOT EXISTS preguntasTrabajo
 	)
 `
 
+// la respuesta escrita de cada alumno a una pregunta de desarrollo
+const tableRespuestaTrabajoCreationQuery = `
+CREATE TABLE IF NOT EXISTS respuestasTrabajo
+	(
+		id SERIAL PRIMARY KEY,
+		preguntaTrabajoId INT NOT NULL REFERENCES preguntasTrabajo(id) ON DELETE CASCADE,
+		alumnoId INT NOT NULL REFERENCES alumnos(id) ON DELETE CASCADE,
+		texto TEXT NOT NULL,
+
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL,
+		UNIQUE (preguntaTrabajoId, alumnoId)
+	)
+`
+
 func EnsureTablePreguntaTrabajoExists(db *pgxpool.Pool) {
 	_, err := db.Exec(context.Background(), tablePreguntaTrabajoCreationQuery)
 	if err != nil {
 		log.Printf("TEST: error creando tabla preguntasTrabajo: %s", err)
 	}
+	_, err = db.Exec(context.Background(), tableRespuestaTrabajoCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla respuestasTrabajo: %s", err)
+	}
 }
 
 func ClearTablePreguntaTrabajo(db *pgxpool.Pool) {
-	_, err := db.Exec(context.Background(), "DELETE FROM preguntasTrabajo")
+	_, err := db.Exec(context.Background(), "DELETE FROM respuestasTrabajo")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla respuestasTrabajo %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE respuestasTrabajo_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de respuestasTrabajo_id %s", err)
+	}
+	_, err = db.Exec(context.Background(), "DELETE FROM preguntasTrabajo")
 	if err != nil {
 		log.Printf("Error deleteando contenidos de la tabla PreguntasTrabajo %s", err)
 	}
@@ -413,6 +511,10 @@ CREATE TABLE IF NOT EXISTS trabajos
 		fechaInicio TIMESTAMPTZ NOT NULL,
 		fechaFinal TIMESTAMPTZ NOT NULL,
 		cursoId INT REFERENCES cursos(id),
+		politicaTardanza VARCHAR(20) NOT NULL DEFAULT 'corte',
+		minutosGracia INT NOT NULL DEFAULT 0,
+		descuentoDiario REAL NOT NULL DEFAULT 0,
+		descuentoMaximo REAL NOT NULL DEFAULT 0,
 
 		activo BOOLEAN NOT NULL,
 		createdAt TIMESTAMPTZ NOT NULL,
@@ -420,15 +522,57 @@ CREATE TABLE IF NOT EXISTS trabajos
 	)
 `
 
+// analisis de similitud de las entregas de un trabajo, se ejecutan en
+// segundo plano. Solo puede haber uno en curso por trabajo.
+const tableAnalisisSimilitudCreationQuery = `
+CREATE TABLE IF NOT EXISTS analisisSimilitud
+	(
+		id SERIAL PRIMARY KEY,
+		trabajoId INT NOT NULL REFERENCES trabajos(id) ON DELETE CASCADE,
+		solicitadoPor INT NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+		estado VARCHAR(20) NOT NULL CHECK (estado IN ('pendiente', 'procesando',
+			'completado', 'fallido')),
+		error TEXT NOT NULL DEFAULT '',
+		resultado JSONB,
+
+		createdAt TIMESTAMPTZ NOT NULL,
+		finishedAt TIMESTAMPTZ
+	)
+`
+
+const indexAnalisisSimilitudEnCursoQuery = `
+CREATE UNIQUE INDEX IF NOT EXISTS analisisSimilitud_en_curso
+	ON analisisSimilitud(trabajoId)
+	WHERE estado IN ('pendiente', 'procesando')
+`
+
 func EnsureTableTrabajoExists(db *pgxpool.Pool) {
 	_, err := db.Exec(context.Background(), tableTrabajoCreationQuery)
 	if err != nil {
 		log.Printf("TEST: error creando tabla trabajos: %s", err)
 	}
+	_, err = db.Exec(context.Background(), tableAnalisisSimilitudCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla analisisSimilitud: %s", err)
+	}
+	_, err = db.Exec(context.Background(), indexAnalisisSimilitudEnCursoQuery)
+	if err != nil {
+		log.Printf("TEST: error creando indice de analisisSimilitud: %s", err)
+	}
 }
 
 func ClearTableTrabajo(db *pgxpool.Pool) {
-	_, err := db.Exec(context.Background(), "DELETE FROM trabajos")
+	ClearTableAlumnoTrabajo(db)
+	ClearTableProrroga(db)
+	_, err := db.Exec(context.Background(), "DELETE FROM analisisSimilitud")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla analisisSimilitud %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE analisisSimilitud_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de analisisSimilitud_id %s", err)
+	}
+	_, err = db.Exec(context.Background(), "DELETE FROM trabajos")
 	if err != nil {
 		log.Printf("Error deleteando contenidos de la tabla Trabajo %s", err)
 	}
@@ -472,8 +616,10 @@ const tableAlternativaCreationQuery = `
 CREATE TABLE IF NOT EXISTS alternativas
 	(
 		id SERIAL PRIMARY KEY,
-		valor VARCHAR(100) NOT NULL,
+		valor TEXT NOT NULL,
 		correcto BOOLEAN NOT NULL,
+		tolerancia REAL NOT NULL DEFAULT 0,
+		preguntaId INT REFERENCES preguntas(id) ON DELETE CASCADE,
 
 		activo BOOLEAN NOT NULL,
 		createdAt TIMESTAMPTZ NOT NULL,
@@ -520,3 +666,1143 @@ func AddAlternativas(count int, db *pgxpool.Pool) {
 		}
 	}
 }
+
+// ALUMNO EXAMEN
+const tableAlumnoExamenCreationQuery = `
+CREATE TABLE IF NOT EXISTS alumnoExamen
+	(
+		id SERIAL PRIMARY KEY,
+		calificacion REAL NOT NULL DEFAULT 0,
+		intento INT NOT NULL,
+		fechaInicio TIMESTAMPTZ NOT NULL,
+		fechaFinal TIMESTAMPTZ NOT NULL,
+		alumnoId INT REFERENCES alumnos(id) ON DELETE CASCADE,
+		examenId INT REFERENCES examenes(id) ON DELETE CASCADE,
+
+		activo BOOLEAN NOT NULL,
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+// una respuesta por pregunta en cada intento; alternativaId queda en NULL
+// en las preguntas que se responden con texto
+const tableRespuestaCreationQuery = `
+CREATE TABLE IF NOT EXISTS respuestas
+	(
+		id SERIAL PRIMARY KEY,
+		alumnoExamenId INT NOT NULL REFERENCES alumnoExamen(id) ON DELETE CASCADE,
+		preguntaId INT NOT NULL REFERENCES preguntas(id) ON DELETE CASCADE,
+		alternativaId INT REFERENCES alternativas(id) ON DELETE SET NULL,
+		texto TEXT NOT NULL DEFAULT '',
+		puntaje REAL NOT NULL DEFAULT 0,
+		ip VARCHAR(45) NOT NULL DEFAULT '',
+		userAgent TEXT NOT NULL DEFAULT '',
+
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL,
+		UNIQUE (alumnoExamenId, preguntaId)
+	)
+`
+
+// eventos de supervision enviados por el cliente durante el intento.
+// ocurridoEn es la hora que informa el cliente y createdAt la del servidor
+const tableEventoIntentoCreationQuery = `
+CREATE TABLE IF NOT EXISTS eventosIntento
+	(
+		id SERIAL PRIMARY KEY,
+		alumnoExamenId INT NOT NULL REFERENCES alumnoExamen(id) ON DELETE CASCADE,
+		tipo VARCHAR(30) NOT NULL CHECK (tipo IN ('pestanaOculta',
+			'ventanaDesenfocada', 'pegar', 'salirPantallaCompleta', 'cambioIp')),
+		detalle TEXT NOT NULL DEFAULT '',
+		ip VARCHAR(45) NOT NULL DEFAULT '',
+		userAgent TEXT NOT NULL DEFAULT '',
+		ocurridoEn TIMESTAMPTZ NOT NULL,
+
+		createdAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+func EnsureTableAlumnoExamenExists(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), tableAlumnoExamenCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla alumnoExamen: %s", err)
+	}
+	_, err = db.Exec(context.Background(), tableRespuestaCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla respuestas: %s", err)
+	}
+	_, err = db.Exec(context.Background(), tableEventoIntentoCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla eventosIntento: %s", err)
+	}
+}
+
+func ClearTableAlumnoExamen(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), "DELETE FROM eventosIntento")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla eventosIntento %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE eventosIntento_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de eventosIntento_id %s", err)
+	}
+	_, err = db.Exec(context.Background(), "DELETE FROM respuestas")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla respuestas %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE respuestas_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de respuestas_id %s", err)
+	}
+	_, err = db.Exec(context.Background(), "DELETE FROM alumnoExamen")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla alumnoExamen %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE alumnoExamen_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de alumnoExamen_id %s", err)
+	}
+}
+
+// AddIntentos agrega 'count' alumnos, el examen 1 activo y abierto, y un
+// intento en curso de cada alumno en el examen
+func AddIntentos(count int, db *pgxpool.Pool) {
+	AddAlumnos(count, db)
+	AddExamenes(1, db)
+	if count < 1 {
+		count = 1
+	}
+	now := time.Now()
+
+	_, err := db.Exec(
+		context.Background(),
+		`UPDATE examenes SET activo=true, fechaInicio=$1, fechaFinal=$2 WHERE id=1`,
+		now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		log.Printf("Error adding intentos %s", err)
+	}
+	for i := 0; i < count; i++ {
+		_, err := db.Exec(
+			context.Background(),
+			`INSERT INTO alumnoExamen(intento, fechaInicio, fechaFinal, alumnoId,
+				examenId, activo, createdAt, updatedAt)
+			VALUES(1, $1, $2, $3, 1, true, $1, $1)`,
+			now, now.Add(time.Hour), i+1)
+		if err != nil {
+			log.Printf("Error adding intentos %s", err)
+		}
+	}
+}
+
+// PRORROGAS
+const tableProrrogaCreationQuery = `
+CREATE TABLE IF NOT EXISTS prorrogas
+	(
+		id SERIAL PRIMARY KEY,
+		alumnoId INT NOT NULL REFERENCES alumnos(id) ON DELETE CASCADE,
+		examenId INT REFERENCES examenes(id) ON DELETE CASCADE,
+		trabajoId INT REFERENCES trabajos(id) ON DELETE CASCADE,
+		fechaFinal TIMESTAMPTZ,
+		minutosExtra INT NOT NULL DEFAULT 0,
+		intentosExtra INT NOT NULL DEFAULT 0,
+		motivo TEXT NOT NULL,
+		otorgadoPor INT NOT NULL REFERENCES usuarios(id),
+
+		activo BOOLEAN NOT NULL,
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL,
+
+		CHECK ((examenId IS NULL) <> (trabajoId IS NULL))
+	)
+`
+
+// el historial no referencia a prorrogas para sobrevivir a su eliminacion
+const tableProrrogaHistorialCreationQuery = `
+CREATE TABLE IF NOT EXISTS prorrogasHistorial
+	(
+		id SERIAL PRIMARY KEY,
+		prorrogaId INT NOT NULL,
+		usuarioId INT NOT NULL,
+		accion VARCHAR(20) NOT NULL,
+		detalle JSONB NOT NULL,
+		createdAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+func EnsureTableProrrogaExists(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), tableProrrogaCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla prorrogas: %s", err)
+	}
+	_, err = db.Exec(context.Background(), tableProrrogaHistorialCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla prorrogasHistorial: %s", err)
+	}
+}
+
+func ClearTableProrroga(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), "DELETE FROM prorrogas")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla prorrogas %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE prorrogas_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de prorroga_id %s", err)
+	}
+	_, err = db.Exec(context.Background(), "DELETE FROM prorrogasHistorial")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla prorrogasHistorial %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE prorrogasHistorial_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de prorrogasHistorial_id %s", err)
+	}
+}
+
+// AddProrrogas agrega 'count' alumnos con examenes, y a cada alumno
+// una prorroga sobre el examen con su mismo id, otorgada por el usuario 1
+func AddProrrogas(count int, db *pgxpool.Pool) {
+	AddAlumnos(count, db)
+	AddExamenes(count, db)
+	if count < 1 {
+		count = 1
+	}
+	now := time.Now()
+
+	for i := 0; i < count; i++ {
+		_, err := db.Exec(
+			context.Background(),
+			`INSERT INTO prorrogas(alumnoId, examenId, minutosExtra,
+				intentosExtra, motivo, otorgadoPor, activo, createdAt, updatedAt)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			i+1, i+1, 30, 1, "motivo_test_"+strconv.Itoa(i),
+			1, true, now, now)
+
+		if err != nil {
+			log.Printf("Error adding prorrogas %s", err)
+		}
+	}
+}
+
+// ALUMNO TRABAJO
+const tableAlumnoTrabajoCreationQuery = `
+CREATE TABLE IF NOT EXISTS alumnoTrabajo
+	(
+		id SERIAL PRIMARY KEY,
+		calificacion REAL NOT NULL DEFAULT 0,
+		uri TEXT NOT NULL,
+		fechaInicio TIMESTAMPTZ NOT NULL,
+		fechaFinal TIMESTAMPTZ NOT NULL,
+		alumnoId INT REFERENCES alumnos(id) ON DELETE CASCADE,
+		trabajoId INT REFERENCES trabajos(id) ON DELETE CASCADE,
+		calificacionBruta REAL NOT NULL DEFAULT 0,
+		tardio BOOLEAN NOT NULL DEFAULT false,
+		penalizacion REAL NOT NULL DEFAULT 0,
+		calificado BOOLEAN NOT NULL DEFAULT false,
+		fechaDevolucion TIMESTAMPTZ,
+		devolucionVista BOOLEAN NOT NULL DEFAULT false,
+
+		activo BOOLEAN NOT NULL,
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL,
+
+		UNIQUE (alumnoId, trabajoId)
+	)
+`
+
+const tableEntregaCreationQuery = `
+CREATE TABLE IF NOT EXISTS entregas
+	(
+		id SERIAL PRIMARY KEY,
+		alumnoTrabajoId INT NOT NULL REFERENCES alumnoTrabajo(id) ON DELETE CASCADE,
+		trabajoId INT NOT NULL REFERENCES trabajos(id) ON DELETE CASCADE,
+		alumnoId INT NOT NULL REFERENCES alumnos(id) ON DELETE CASCADE,
+		version INT NOT NULL,
+		nombreArchivo VARCHAR(255) NOT NULL,
+		mimeType VARCHAR(100) NOT NULL,
+		tamano BIGINT NOT NULL,
+		clave TEXT NOT NULL,
+		fechaEntrega TIMESTAMPTZ NOT NULL,
+		tardio BOOLEAN NOT NULL DEFAULT false,
+		createdAt TIMESTAMPTZ NOT NULL,
+
+		UNIQUE (alumnoTrabajoId, version)
+	)
+`
+
+// los comentarios forman hilos con respuestaA, y pueden referirse a una
+// pregunta del trabajo o a la entrega en general
+const tableComentarioCreationQuery = `
+CREATE TABLE IF NOT EXISTS comentarios
+	(
+		id SERIAL PRIMARY KEY,
+		alumnoTrabajoId INT NOT NULL REFERENCES alumnoTrabajo(id) ON DELETE CASCADE,
+		preguntaTrabajoId INT REFERENCES preguntasTrabajo(id) ON DELETE CASCADE,
+		respuestaA INT REFERENCES comentarios(id) ON DELETE CASCADE,
+		usuarioId INT NOT NULL REFERENCES usuarios(id),
+		texto TEXT NOT NULL,
+		nombreArchivo VARCHAR(255) NOT NULL DEFAULT '',
+		mimeType VARCHAR(100) NOT NULL DEFAULT '',
+		tamano BIGINT NOT NULL DEFAULT 0,
+		clave TEXT NOT NULL DEFAULT '',
+		createdAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+func EnsureTableAlumnoTrabajoExists(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), tableAlumnoTrabajoCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla alumnoTrabajo: %s", err)
+	}
+	_, err = db.Exec(context.Background(), tableEntregaCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla entregas: %s", err)
+	}
+	_, err = db.Exec(context.Background(), tableComentarioCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla comentarios: %s", err)
+	}
+}
+
+func ClearTableAlumnoTrabajo(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), "DELETE FROM comentarios")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla comentarios %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE comentarios_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de comentario_id %s", err)
+	}
+	_, err = db.Exec(context.Background(), "DELETE FROM entregas")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla entregas %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE entregas_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de entrega_id %s", err)
+	}
+	_, err = db.Exec(context.Background(), "DELETE FROM alumnoTrabajo")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla alumnoTrabajo %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE alumnoTrabajo_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de alumnoTrabajo_id %s", err)
+	}
+}
+
+// AddEntregas agrega 'count' alumnos y trabajos, y una entrega de cada
+// alumno al trabajo con su mismo id
+func AddEntregas(count int, db *pgxpool.Pool) {
+	AddAlumnos(count, db)
+	AddTrabajos(count, db)
+	if count < 1 {
+		count = 1
+	}
+	now := time.Now()
+
+	for i := 0; i < count; i++ {
+		_, err := db.Exec(
+			context.Background(),
+			`INSERT INTO alumnoTrabajo(uri, fechaInicio, fechaFinal,
+				alumnoId, trabajoId, activo, createdAt, updatedAt)
+			VALUES($1, $2, $2, $3, $3, true, $2, $2)`,
+			"/entregas/"+strconv.Itoa(i+1), now, i+1)
+		if err != nil {
+			log.Printf("Error adding alumnoTrabajo %s", err)
+		}
+
+		_, err = db.Exec(
+			context.Background(),
+			`INSERT INTO entregas(alumnoTrabajoId, trabajoId, alumnoId, version,
+				nombreArchivo, mimeType, tamano, clave, fechaEntrega, createdAt)
+			VALUES($1, $1, $1, 1, $2, 'text/plain; charset=utf-8', 4, $3, $4, $4)`,
+			i+1, "entrega_"+strconv.Itoa(i)+".txt",
+			"test/entrega_"+strconv.Itoa(i)+".txt", now)
+		if err != nil {
+			log.Printf("Error adding entregas %s", err)
+		}
+	}
+}
+
+// RUBRICAS
+const tableRubricaCreationQuery = `
+CREATE TABLE IF NOT EXISTS rubricas
+	(
+		id SERIAL PRIMARY KEY,
+		nombre VARCHAR(200) NOT NULL,
+		descripcion TEXT NOT NULL DEFAULT '',
+		creadoPor INT NOT NULL REFERENCES usuarios(id),
+
+		activo BOOLEAN NOT NULL,
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+const tableRubricaCriterioCreationQuery = `
+CREATE TABLE IF NOT EXISTS rubricaCriterios
+	(
+		id SERIAL PRIMARY KEY,
+		rubricaId INT NOT NULL REFERENCES rubricas(id) ON DELETE CASCADE,
+		nombre VARCHAR(200) NOT NULL,
+		descripcion TEXT NOT NULL DEFAULT '',
+		orden INT NOT NULL
+	)
+`
+
+const tableRubricaNivelCreationQuery = `
+CREATE TABLE IF NOT EXISTS rubricaNiveles
+	(
+		id SERIAL PRIMARY KEY,
+		criterioId INT NOT NULL REFERENCES rubricaCriterios(id) ON DELETE CASCADE,
+		nombre VARCHAR(200) NOT NULL,
+		descripcion TEXT NOT NULL DEFAULT '',
+		puntos REAL NOT NULL CHECK (puntos >= 0)
+	)
+`
+
+const tableRubricaAsignacionCreationQuery = `
+CREATE TABLE IF NOT EXISTS rubricaAsignaciones
+	(
+		id SERIAL PRIMARY KEY,
+		rubricaId INT NOT NULL REFERENCES rubricas(id) ON DELETE CASCADE,
+		trabajoId INT REFERENCES trabajos(id) ON DELETE CASCADE,
+		preguntaTrabajoId INT REFERENCES preguntasTrabajo(id) ON DELETE CASCADE,
+		createdAt TIMESTAMPTZ NOT NULL,
+
+		CHECK ((trabajoId IS NULL) <> (preguntaTrabajoId IS NULL)),
+		UNIQUE (rubricaId, trabajoId),
+		UNIQUE (rubricaId, preguntaTrabajoId)
+	)
+`
+
+// los puntajes no se borran en cascada con los criterios, una rubrica
+// usada para evaluar no se puede modificar
+const tableRubricaPuntajeCreationQuery = `
+CREATE TABLE IF NOT EXISTS rubricaPuntajes
+	(
+		id SERIAL PRIMARY KEY,
+		alumnoTrabajoId INT NOT NULL REFERENCES alumnoTrabajo(id) ON DELETE CASCADE,
+		asignacionId INT NOT NULL REFERENCES rubricaAsignaciones(id) ON DELETE CASCADE,
+		criterioId INT NOT NULL REFERENCES rubricaCriterios(id),
+		nivelId INT NOT NULL REFERENCES rubricaNiveles(id),
+		puntos REAL NOT NULL,
+		comentario TEXT NOT NULL DEFAULT '',
+		evaluadoPor INT NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL,
+
+		UNIQUE (alumnoTrabajoId, asignacionId, criterioId)
+	)
+`
+
+func EnsureTableRubricaExists(db *pgxpool.Pool) {
+	queries := []struct{ tabla, query string }{
+		{"rubricas", tableRubricaCreationQuery},
+		{"rubricaCriterios", tableRubricaCriterioCreationQuery},
+		{"rubricaNiveles", tableRubricaNivelCreationQuery},
+		{"rubricaAsignaciones", tableRubricaAsignacionCreationQuery},
+		{"rubricaPuntajes", tableRubricaPuntajeCreationQuery},
+	}
+	for _, q := range queries {
+		_, err := db.Exec(context.Background(), q.query)
+		if err != nil {
+			log.Printf("TEST: error creando tabla %s: %s", q.tabla, err)
+		}
+	}
+}
+
+func ClearTableRubrica(db *pgxpool.Pool) {
+	tablas := []string{"rubricaPuntajes", "rubricaAsignaciones",
+		"rubricaNiveles", "rubricaCriterios", "rubricas"}
+	for _, tabla := range tablas {
+		_, err := db.Exec(context.Background(), "DELETE FROM "+tabla)
+		if err != nil {
+			log.Printf("Error deleteando contenidos de la tabla %s %s", tabla, err)
+		}
+		_, err = db.Exec(context.Background(), "ALTER SEQUENCE "+tabla+"_id_seq RESTART WITH 1")
+		if err != nil {
+			log.Printf("Error reseteando secuencia de %s_id %s", tabla, err)
+		}
+	}
+}
+
+// AddRubricas agrega 'count' rubricas creadas por el usuario 1, cada una
+// con dos criterios de niveles 0, 2 y 5 puntos
+func AddRubricas(count int, db *pgxpool.Pool) {
+	if count < 1 {
+		count = 1
+	}
+	now := time.Now()
+
+	for i := 0; i < count; i++ {
+		var rubricaId int
+		err := db.QueryRow(
+			context.Background(),
+			`INSERT INTO rubricas(nombre, descripcion, creadoPor, activo, createdAt, updatedAt)
+			VALUES($1, $2, 1, true, $3, $3)
+			RETURNING id`,
+			"rubrica_test_"+strconv.Itoa(i), "desc_test_"+strconv.Itoa(i), now,
+		).Scan(&rubricaId)
+		if err != nil {
+			log.Printf("Error adding rubricas %s", err)
+			continue
+		}
+
+		for c := 0; c < 2; c++ {
+			var criterioId int
+			err := db.QueryRow(
+				context.Background(),
+				`INSERT INTO rubricaCriterios(rubricaId, nombre, orden)
+				VALUES($1, $2, $3)
+				RETURNING id`,
+				rubricaId, "criterio_test_"+strconv.Itoa(c), c+1,
+			).Scan(&criterioId)
+			if err != nil {
+				log.Printf("Error adding rubricaCriterios %s", err)
+				continue
+			}
+			for _, puntos := range []float32{0, 2, 5} {
+				_, err := db.Exec(
+					context.Background(),
+					`INSERT INTO rubricaNiveles(criterioId, nombre, puntos)
+					VALUES($1, $2, $3)`,
+					criterioId, "nivel_test", puntos)
+				if err != nil {
+					log.Printf("Error adding rubricaNiveles %s", err)
+				}
+			}
+		}
+	}
+}
+
+// ALUMNO CURSO
+const tableAlumnoCursoCreationQuery = `
+CREATE TABLE IF NOT EXISTS alumnoCurso
+	(
+		id SERIAL PRIMARY KEY,
+		calificacion REAL NOT NULL DEFAULT 0,
+		fechaInicio TIMESTAMPTZ NOT NULL,
+		fechaFinal TIMESTAMPTZ NOT NULL,
+		alumnoId INT NOT NULL REFERENCES alumnos(id) ON DELETE CASCADE,
+		cursoId INT NOT NULL REFERENCES cursos(id) ON DELETE CASCADE,
+
+		activo BOOLEAN NOT NULL,
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL,
+
+		UNIQUE (alumnoId, cursoId)
+	)
+`
+
+func EnsureTableAlumnoCursoExists(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), tableAlumnoCursoCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla alumnoCurso: %s", err)
+	}
+}
+
+func ClearTableAlumnoCurso(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), "DELETE FROM alumnoCurso")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla alumnoCurso %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE alumnoCurso_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de alumnoCurso_id %s", err)
+	}
+}
+
+// AddAlumnoCursos agrega 'count' alumnos matriculados en el curso 1
+func AddAlumnoCursos(count int, db *pgxpool.Pool) {
+	AddAlumnos(count, db)
+	AddCursos(1, db)
+	if count < 1 {
+		count = 1
+	}
+	now := time.Now()
+
+	for i := 0; i < count; i++ {
+		_, err := db.Exec(
+			context.Background(),
+			`INSERT INTO alumnoCurso(fechaInicio, fechaFinal, alumnoId, cursoId,
+				activo, createdAt, updatedAt)
+			VALUES($1, $1, $2, 1, true, $1, $1)`,
+			now, i+1)
+		if err != nil {
+			log.Printf("Error adding alumnoCurso %s", err)
+		}
+	}
+}
+
+// PROFESOR CURSO
+// profesores asignados al curso, los unicos que administran sus anuncios
+// y foros
+const tableProfesorCursoCreationQuery = `
+CREATE TABLE IF NOT EXISTS profesorCurso
+	(
+		id SERIAL PRIMARY KEY,
+		profesorId INT NOT NULL REFERENCES profesores(id) ON DELETE CASCADE,
+		cursoId INT NOT NULL REFERENCES cursos(id) ON DELETE CASCADE,
+		createdAt TIMESTAMPTZ NOT NULL,
+
+		UNIQUE (profesorId, cursoId)
+	)
+`
+
+func EnsureTableProfesorCursoExists(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), tableProfesorCursoCreationQuery)
+	if err != nil {
+		log.Printf("TEST: error creando tabla profesorCurso: %s", err)
+	}
+}
+
+func ClearTableProfesorCurso(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), "DELETE FROM profesorCurso")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla profesorCurso %s", err)
+	}
+	_, err = db.Exec(context.Background(), "ALTER SEQUENCE profesorCurso_id_seq RESTART WITH 1")
+	if err != nil {
+		log.Printf("Error reseteando secuencia de profesorCurso_id %s", err)
+	}
+}
+
+// LIBRETA DE NOTAS
+const tableCategoriaCalificacionCreationQuery = `
+CREATE TABLE IF NOT EXISTS categoriasCalificacion
+	(
+		id SERIAL PRIMARY KEY,
+		cursoId INT NOT NULL REFERENCES cursos(id) ON DELETE CASCADE,
+		nombre VARCHAR(200) NOT NULL,
+		tipo VARCHAR(20) NOT NULL CHECK (tipo IN ('examen', 'trabajo', 'manual',
+			'asistencia')),
+		peso REAL NOT NULL CHECK (peso > 0 AND peso <= 100),
+		descartarMenores INT NOT NULL DEFAULT 0 CHECK (descartarMenores >= 0),
+		orden INT NOT NULL DEFAULT 0,
+
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+// los examenes, trabajos y la asistencia del curso van cada uno a una sola
+// categoria
+const indexCategoriaCalificacionTipoQuery = `
+CREATE UNIQUE INDEX IF NOT EXISTS categoriasCalificacion_cursoId_tipo
+	ON categoriasCalificacion(cursoId, tipo) WHERE tipo <> 'manual'
+`
+
+const tableEvaluacionManualCreationQuery = `
+CREATE TABLE IF NOT EXISTS evaluacionesManuales
+	(
+		id SERIAL PRIMARY KEY,
+		categoriaId INT NOT NULL REFERENCES categoriasCalificacion(id) ON DELETE CASCADE,
+		nombre VARCHAR(200) NOT NULL,
+		createdAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+// referenciaId apunta a un examen, trabajo, evaluacion manual o categoria
+// segun el tipo, por eso no tiene FK. Las celdas de columnas que ya no
+// existen no se muestran.
+const tableCeldaManualCreationQuery = `
+CREATE TABLE IF NOT EXISTS celdasManuales
+	(
+		id SERIAL PRIMARY KEY,
+		cursoId INT NOT NULL REFERENCES cursos(id) ON DELETE CASCADE,
+		alumnoId INT NOT NULL REFERENCES alumnos(id) ON DELETE CASCADE,
+		tipo VARCHAR(20) NOT NULL,
+		referenciaId INT NOT NULL,
+		calificacion REAL NOT NULL CHECK (calificacion >= 0 AND calificacion <= 20),
+		motivo TEXT NOT NULL DEFAULT '',
+		usuarioId INT NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL,
+
+		UNIQUE (cursoId, alumnoId, tipo, referenciaId)
+	)
+`
+
+func EnsureTableLibretaExists(db *pgxpool.Pool) {
+	queries := []struct{ tabla, query string }{
+		{"categoriasCalificacion", tableCategoriaCalificacionCreationQuery},
+		{"categoriasCalificacion_cursoId_tipo", indexCategoriaCalificacionTipoQuery},
+		{"evaluacionesManuales", tableEvaluacionManualCreationQuery},
+		{"celdasManuales", tableCeldaManualCreationQuery},
+	}
+	for _, q := range queries {
+		_, err := db.Exec(context.Background(), q.query)
+		if err != nil {
+			log.Printf("TEST: error creando tabla %s: %s", q.tabla, err)
+		}
+	}
+}
+
+func ClearTableLibreta(db *pgxpool.Pool) {
+	tablas := []string{"celdasManuales", "evaluacionesManuales", "categoriasCalificacion"}
+	for _, tabla := range tablas {
+		_, err := db.Exec(context.Background(), "DELETE FROM "+tabla)
+		if err != nil {
+			log.Printf("Error deleteando contenidos de la tabla %s %s", tabla, err)
+		}
+		_, err = db.Exec(context.Background(), "ALTER SEQUENCE "+tabla+"_id_seq RESTART WITH 1")
+		if err != nil {
+			log.Printf("Error reseteando secuencia de %s_id %s", tabla, err)
+		}
+	}
+}
+
+// ASISTENCIA
+const tableSesionCreationQuery = `
+CREATE TABLE IF NOT EXISTS sesiones
+	(
+		id SERIAL PRIMARY KEY,
+		cursoId INT NOT NULL REFERENCES cursos(id) ON DELETE CASCADE,
+		fecha TIMESTAMPTZ NOT NULL,
+		tema TEXT NOT NULL DEFAULT '',
+
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+const tableAsistenciaCreationQuery = `
+CREATE TABLE IF NOT EXISTS asistencias
+	(
+		id SERIAL PRIMARY KEY,
+		sesionId INT NOT NULL REFERENCES sesiones(id) ON DELETE CASCADE,
+		alumnoId INT NOT NULL REFERENCES alumnos(id) ON DELETE CASCADE,
+		estado VARCHAR(20) NOT NULL CHECK (estado IN ('presente', 'tarde',
+			'ausente', 'justificado')),
+		observacion TEXT NOT NULL DEFAULT '',
+		usuarioId INT NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL,
+
+		UNIQUE (sesionId, alumnoId)
+	)
+`
+
+func EnsureTableAsistenciaExists(db *pgxpool.Pool) {
+	queries := []struct{ tabla, query string }{
+		{"sesiones", tableSesionCreationQuery},
+		{"asistencias", tableAsistenciaCreationQuery},
+	}
+	for _, q := range queries {
+		_, err := db.Exec(context.Background(), q.query)
+		if err != nil {
+			log.Printf("TEST: error creando tabla %s: %s", q.tabla, err)
+		}
+	}
+}
+
+func ClearTableAsistencia(db *pgxpool.Pool) {
+	tablas := []string{"asistencias", "sesiones"}
+	for _, tabla := range tablas {
+		_, err := db.Exec(context.Background(), "DELETE FROM "+tabla)
+		if err != nil {
+			log.Printf("Error deleteando contenidos de la tabla %s %s", tabla, err)
+		}
+		_, err = db.Exec(context.Background(), "ALTER SEQUENCE "+tabla+"_id_seq RESTART WITH 1")
+		if err != nil {
+			log.Printf("Error reseteando secuencia de %s_id %s", tabla, err)
+		}
+	}
+}
+
+// AddSesiones crea 'count' sesiones del curso 1, una por dia desde el
+// 20 de junio de 2022
+func AddSesiones(count int, db *pgxpool.Pool) {
+	AddCursos(1, db)
+	if count < 1 {
+		count = 1
+	}
+	now := time.Now()
+	loc, _ := time.LoadLocation("America/Lima")
+	fecha := time.Date(2022, time.June, 20, 8, 0, 0, 0, loc)
+
+	for i := 0; i < count; i++ {
+		_, err := db.Exec(
+			context.Background(),
+			`INSERT INTO sesiones(cursoId, fecha, tema, createdAt, updatedAt)
+			VALUES(1, $1, $2, $3, $3)`,
+			fecha.AddDate(0, 0, i), "tema_test_"+strconv.Itoa(i), now)
+		if err != nil {
+			log.Printf("Error adding sesiones %s", err)
+		}
+	}
+}
+
+// ANUNCIOS Y FOROS
+const tableAnuncioCreationQuery = `
+CREATE TABLE IF NOT EXISTS anuncios
+	(
+		id SERIAL PRIMARY KEY,
+		cursoId INT NOT NULL REFERENCES cursos(id) ON DELETE CASCADE,
+		autorId INT NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+		titulo VARCHAR(200) NOT NULL,
+		cuerpo TEXT NOT NULL,
+		fijado BOOLEAN NOT NULL DEFAULT false,
+
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+const tableForoCreationQuery = `
+CREATE TABLE IF NOT EXISTS foros
+	(
+		id SERIAL PRIMARY KEY,
+		cursoId INT NOT NULL REFERENCES cursos(id) ON DELETE CASCADE,
+		titulo VARCHAR(200) NOT NULL,
+		descripcion TEXT NOT NULL DEFAULT '',
+
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+// ultimaActividad es la fecha de la ultima publicacion del hilo, para
+// ordenar los hilos y saber si hay publicaciones sin leer
+const tableHiloCreationQuery = `
+CREATE TABLE IF NOT EXISTS hilos
+	(
+		id SERIAL PRIMARY KEY,
+		foroId INT NOT NULL REFERENCES foros(id) ON DELETE CASCADE,
+		autorId INT NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+		titulo VARCHAR(200) NOT NULL,
+		fijado BOOLEAN NOT NULL DEFAULT false,
+		bloqueado BOOLEAN NOT NULL DEFAULT false,
+		ultimaActividad TIMESTAMPTZ NOT NULL,
+
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+const tablePublicacionCreationQuery = `
+CREATE TABLE IF NOT EXISTS publicaciones
+	(
+		id SERIAL PRIMARY KEY,
+		hiloId INT NOT NULL REFERENCES hilos(id) ON DELETE CASCADE,
+		autorId INT NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+		cuerpo TEXT NOT NULL,
+		editado BOOLEAN NOT NULL DEFAULT false,
+
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+// versiones anteriores de anuncios y publicaciones. referenciaId apunta a
+// uno u otro segun el tipo, por eso no tiene FK.
+const tableEdicionCreationQuery = `
+CREATE TABLE IF NOT EXISTS ediciones
+	(
+		id SERIAL PRIMARY KEY,
+		tipo VARCHAR(20) NOT NULL CHECK (tipo IN ('anuncio', 'publicacion')),
+		referenciaId INT NOT NULL,
+		usuarioId INT NOT NULL,
+		tituloAnterior VARCHAR(200) NOT NULL DEFAULT '',
+		cuerpoAnterior TEXT NOT NULL,
+		createdAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+// ultima vez que el usuario leyo un anuncio o un hilo
+const tableLecturaCreationQuery = `
+CREATE TABLE IF NOT EXISTS lecturas
+	(
+		usuarioId INT NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+		tipo VARCHAR(20) NOT NULL CHECK (tipo IN ('anuncio', 'hilo')),
+		referenciaId INT NOT NULL,
+		leidoEn TIMESTAMPTZ NOT NULL,
+
+		PRIMARY KEY (usuarioId, tipo, referenciaId)
+	)
+`
+
+func EnsureTableForoExists(db *pgxpool.Pool) {
+	queries := []struct{ tabla, query string }{
+		{"anuncios", tableAnuncioCreationQuery},
+		{"foros", tableForoCreationQuery},
+		{"hilos", tableHiloCreationQuery},
+		{"publicaciones", tablePublicacionCreationQuery},
+		{"ediciones", tableEdicionCreationQuery},
+		{"lecturas", tableLecturaCreationQuery},
+	}
+	for _, q := range queries {
+		_, err := db.Exec(context.Background(), q.query)
+		if err != nil {
+			log.Printf("TEST: error creando tabla %s: %s", q.tabla, err)
+		}
+	}
+}
+
+func ClearTableForo(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), "DELETE FROM lecturas")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla lecturas %s", err)
+	}
+	tablas := []string{"ediciones", "publicaciones", "hilos", "foros", "anuncios"}
+	for _, tabla := range tablas {
+		_, err := db.Exec(context.Background(), "DELETE FROM "+tabla)
+		if err != nil {
+			log.Printf("Error deleteando contenidos de la tabla %s %s", tabla, err)
+		}
+		_, err = db.Exec(context.Background(), "ALTER SEQUENCE "+tabla+"_id_seq RESTART WITH 1")
+		if err != nil {
+			log.Printf("Error reseteando secuencia de %s_id %s", tabla, err)
+		}
+	}
+}
+
+// NOTIFICACIONES
+const tableNotificacionCreationQuery = `
+CREATE TABLE IF NOT EXISTS notificaciones
+	(
+		id SERIAL PRIMARY KEY,
+		usuarioId INT NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+		tipo VARCHAR(20) NOT NULL,
+		titulo TEXT NOT NULL,
+		cuerpo TEXT NOT NULL,
+		referenciaId INT NOT NULL DEFAULT 0,
+		leidaEn TIMESTAMPTZ,
+
+		createdAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+// sin fila para un tipo se usan los valores por defecto, ambos canales
+// activos
+const tablePreferenciaNotificacionCreationQuery = `
+CREATE TABLE IF NOT EXISTS preferenciasNotificacion
+	(
+		usuarioId INT NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+		tipo VARCHAR(20) NOT NULL,
+		app BOOLEAN NOT NULL,
+		email BOOLEAN NOT NULL,
+
+		PRIMARY KEY (usuarioId, tipo)
+	)
+`
+
+// cola de correos por enviar, la consume el despachador en segundo plano
+const tableEnvioCorreoCreationQuery = `
+CREATE TABLE IF NOT EXISTS enviosCorreo
+	(
+		id SERIAL PRIMARY KEY,
+		usuarioId INT NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+		destinatario TEXT NOT NULL,
+		asunto TEXT NOT NULL,
+		cuerpo TEXT NOT NULL,
+		estado VARCHAR(20) NOT NULL
+			CHECK (estado IN ('pendiente', 'enviando', 'enviado', 'fallido')),
+		intentos INT NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		proximoIntento TIMESTAMPTZ NOT NULL,
+		enviadoEn TIMESTAMPTZ,
+
+		createdAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+func EnsureTableNotificacionExists(db *pgxpool.Pool) {
+	queries := []struct{ tabla, query string }{
+		{"notificaciones", tableNotificacionCreationQuery},
+		{"preferenciasNotificacion", tablePreferenciaNotificacionCreationQuery},
+		{"enviosCorreo", tableEnvioCorreoCreationQuery},
+	}
+	for _, q := range queries {
+		_, err := db.Exec(context.Background(), q.query)
+		if err != nil {
+			log.Printf("TEST: error creando tabla %s: %s", q.tabla, err)
+		}
+	}
+}
+
+func ClearTableNotificacion(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), "DELETE FROM preferenciasNotificacion")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla preferenciasNotificacion %s", err)
+	}
+	tablas := []string{"notificaciones", "enviosCorreo"}
+	for _, tabla := range tablas {
+		_, err := db.Exec(context.Background(), "DELETE FROM "+tabla)
+		if err != nil {
+			log.Printf("Error deleteando contenidos de la tabla %s %s", tabla, err)
+		}
+		_, err = db.Exec(context.Background(), "ALTER SEQUENCE "+tabla+"_id_seq RESTART WITH 1")
+		if err != nil {
+			log.Printf("Error reseteando secuencia de %s_id %s", tabla, err)
+		}
+	}
+}
+
+// RECORDATORIOS
+// un recordatorio por alumno, anticipacion y fecha recordada: si el
+// profesor mueve la fecha se vuelve a recordar
+const tableRecordatorioCreationQuery = `
+CREATE TABLE IF NOT EXISTS recordatorios
+	(
+		tipo VARCHAR(20) NOT NULL CHECK (tipo IN ('examen', 'trabajo')),
+		referenciaId INT NOT NULL,
+		alumnoId INT NOT NULL REFERENCES alumnos(id) ON DELETE CASCADE,
+		anticipacion INT NOT NULL,
+		fecha TIMESTAMPTZ NOT NULL,
+
+		createdAt TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (tipo, referenciaId, alumnoId, anticipacion, fecha)
+	)
+`
+
+func EnsureTableRecordatorioExists(db *pgxpool.Pool) {
+	if _, err := db.Exec(context.Background(), tableRecordatorioCreationQuery); err != nil {
+		log.Printf("TEST: error creando tabla recordatorios: %s", err)
+	}
+}
+
+func ClearTableRecordatorio(db *pgxpool.Pool) {
+	if _, err := db.Exec(context.Background(), "DELETE FROM recordatorios"); err != nil {
+		log.Printf("Error deleteando contenidos de la tabla recordatorios %s", err)
+	}
+}
+
+// CALENDARIO
+// token secreto de la suscripcion al calendario, uno por usuario
+const tableTokenCalendarioCreationQuery = `
+CREATE TABLE IF NOT EXISTS tokensCalendario
+	(
+		usuarioId INT PRIMARY KEY REFERENCES usuarios(id) ON DELETE CASCADE,
+		token VARCHAR(64) NOT NULL UNIQUE,
+
+		createdAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+func EnsureTableTokenCalendarioExists(db *pgxpool.Pool) {
+	if _, err := db.Exec(context.Background(), tableTokenCalendarioCreationQuery); err != nil {
+		log.Printf("TEST: error creando tabla tokensCalendario: %s", err)
+	}
+}
+
+func ClearTableTokenCalendario(db *pgxpool.Pool) {
+	if _, err := db.Exec(context.Background(), "DELETE FROM tokensCalendario"); err != nil {
+		log.Printf("Error deleteando contenidos de la tabla tokensCalendario %s", err)
+	}
+}
+
+// MENSAJES
+// conversaciones privadas dentro de un curso entre dos o mas miembros
+const tableConversacionCreationQuery = `
+CREATE TABLE IF NOT EXISTS conversaciones
+	(
+		id SERIAL PRIMARY KEY,
+		cursoId INT NOT NULL REFERENCES cursos(id) ON DELETE CASCADE,
+		creadaPor INT NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+		asunto TEXT NOT NULL,
+
+		createdAt TIMESTAMPTZ NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+// leidoHasta es el id del ultimo mensaje que el participante leyo
+const tableParticipanteCreationQuery = `
+CREATE TABLE IF NOT EXISTS participantes
+	(
+		conversacionId INT NOT NULL REFERENCES conversaciones(id) ON DELETE CASCADE,
+		usuarioId INT NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+		leidoHasta INT NOT NULL DEFAULT 0,
+		leidoEn TIMESTAMPTZ,
+
+		PRIMARY KEY (conversacionId, usuarioId)
+	)
+`
+
+const tableMensajeCreationQuery = `
+CREATE TABLE IF NOT EXISTS mensajes
+	(
+		id SERIAL PRIMARY KEY,
+		conversacionId INT NOT NULL REFERENCES conversaciones(id) ON DELETE CASCADE,
+		usuarioId INT NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+		texto TEXT NOT NULL,
+		nombreArchivo VARCHAR(255) NOT NULL DEFAULT '',
+		mimeType VARCHAR(100) NOT NULL DEFAULT '',
+		tamano BIGINT NOT NULL DEFAULT 0,
+		clave TEXT NOT NULL DEFAULT '',
+
+		createdAt TIMESTAMPTZ NOT NULL
+	)
+`
+
+func EnsureTableMensajeExists(db *pgxpool.Pool) {
+	queries := []struct{ tabla, query string }{
+		{"conversaciones", tableConversacionCreationQuery},
+		{"participantes", tableParticipanteCreationQuery},
+		{"mensajes", tableMensajeCreationQuery},
+	}
+	for _, q := range queries {
+		_, err := db.Exec(context.Background(), q.query)
+		if err != nil {
+			log.Printf("TEST: error creando tabla %s: %s", q.tabla, err)
+		}
+	}
+}
+
+func ClearTableMensaje(db *pgxpool.Pool) {
+	_, err := db.Exec(context.Background(), "DELETE FROM participantes")
+	if err != nil {
+		log.Printf("Error deleteando contenidos de la tabla participantes %s", err)
+	}
+	tablas := []string{"mensajes", "conversaciones"}
+	for _, tabla := range tablas {
+		_, err := db.Exec(context.Background(), "DELETE FROM "+tabla)
+		if err != nil {
+			log.Printf("Error deleteando contenidos de la tabla %s %s", tabla, err)
+		}
+		_, err = db.Exec(context.Background(), "ALTER SEQUENCE "+tabla+"_id_seq RESTART WITH 1")
+		if err != nil {
+			log.Printf("Error reseteando secuencia de %s_id %s", tabla, err)
+		}
+	}
+}
+
+// BUSQUEDA
+// configuracion de texto completo en castellano que ademas ignora las
+// tildes, y los indices de cada campo que se busca. Las expresiones de los
+// indices deben coincidir con las de models.Buscar para que se usen.
+const busquedaConfiguracionQuery = `
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'es_unaccent') THEN
+		CREATE TEXT SEARCH CONFIGURATION es_unaccent (COPY = spanish);
+		ALTER TEXT SEARCH CONFIGURATION es_unaccent
+			ALTER MAPPING FOR hword, hword_part, word WITH unaccent, spanish_stem;
+	END IF;
+END
+$$
+`
+
+func EnsureBusquedaExists(db *pgxpool.Pool) {
+	queries := []struct{ nombre, query string }{
+		{"unaccent", `CREATE EXTENSION IF NOT EXISTS unaccent`},
+		{"es_unaccent", busquedaConfiguracionQuery},
+		{"cursos_busqueda_idx", `CREATE INDEX IF NOT EXISTS cursos_busqueda_idx ON cursos
+			USING GIN (to_tsvector('es_unaccent', nombre || ' ' || siglas))`},
+		{"preguntas_busqueda_idx", `CREATE INDEX IF NOT EXISTS preguntas_busqueda_idx ON preguntas
+			USING GIN (to_tsvector('es_unaccent', enunciado))`},
+		{"trabajos_busqueda_idx", `CREATE INDEX IF NOT EXISTS trabajos_busqueda_idx ON trabajos
+			USING GIN (to_tsvector('es_unaccent', descripcion))`},
+		{"alumnos_busqueda_idx", `CREATE INDEX IF NOT EXISTS alumnos_busqueda_idx ON alumnos
+			USING GIN (to_tsvector('es_unaccent', nombres || ' ' || apellidos || ' ' || codigo))`},
+		{"profesores_busqueda_idx", `CREATE INDEX IF NOT EXISTS profesores_busqueda_idx ON profesores
+			USING GIN (to_tsvector('es_unaccent', nombres || ' ' || apellidos))`},
+	}
+	for _, q := range queries {
+		_, err := db.Exec(context.Background(), q.query)
+		if err != nil {
+			log.Printf("TEST: error creando %s: %s", q.nombre, err)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Package websocket implementa el lado servidor del protocolo WebSocket
+// (RFC 6455) con lo necesario para enviar eventos a los navegadores:
+// handshake, mensajes de texto, ping/pong y cierre. No soporta extensiones
+// ni subprotocolos.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// guid fijo del RFC 6455 para calcular Sec-WebSocket-Accept
+const guid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opContinuacion = 0
+	OpTexto        = 1
+	OpBinario      = 2
+	opCierre       = 8
+	opPing         = 9
+	opPong         = 10
+)
+
+// codigos de cierre
+const (
+	CierreNormal    = 1000
+	CierreSaliendo  = 1001
+	CierreProtocolo = 1002
+	CierrePolitica  = 1008
+	CierreMuyGrande = 1009
+)
+
+// MaxMensaje limita el tamano de los mensajes que envia el cliente
+const MaxMensaje = 64 << 10
+
+// tiempoEscritura es lo maximo que puede tardar un frame en salir
+const tiempoEscritura = 10 * time.Second
+
+var (
+	ErrCerrada   = errors.New("websocket: conexion cerrada")
+	ErrProtocolo = errors.New("websocket: error de protocolo")
+	ErrMuyGrande = errors.New("websocket: mensaje demasiado grande")
+)
+
+// Conn es una conexion ya establecida. Leer debe llamarse desde una sola
+// goroutine; las escrituras se pueden hacer desde varias.
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	espera time.Duration
+
+	mu      sync.Mutex // serializa las escrituras
+	cerrada bool
+}
+
+// Upgrade completa el handshake. Si la request no es un handshake valido
+// responde el error HTTP y lo devuelve.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Metodo no permitido", http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("websocket: metodo %s", r.Method)
+	}
+	if !contieneToken(r.Header, "Connection", "upgrade") ||
+		!contieneToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "Se esperaba un handshake WebSocket", http.StatusBadRequest)
+		return nil, errors.New("websocket: faltan las cabeceras Upgrade")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Version de WebSocket no soportada", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: version no soportada")
+	}
+	clave := r.Header.Get("Sec-WebSocket-Key")
+	if clave == "" {
+		http.Error(w, "Falta Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: falta Sec-WebSocket-Key")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket no disponible", http.StatusInternalServerError)
+		return nil, errors.New("websocket: el ResponseWriter no soporta Hijack")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// el cliente no puede enviar frames antes de recibir la respuesta
+	if brw.Reader.Buffered() > 0 {
+		conn.Close()
+		return nil, ErrProtocolo
+	}
+
+	respuesta := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + claveAceptacion(clave) + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(tiempoEscritura))
+	if _, err := conn.Write([]byte(respuesta)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, br: brw.Reader}, nil
+}
+
+func claveAceptacion(clave string) string {
+	h := sha1.Sum([]byte(clave + guid))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// contieneToken revisa cabeceras de lista separada por comas, como
+// 'Connection: keep-alive, Upgrade'
+func contieneToken(h http.Header, nombre, token string) bool {
+	for _, v := range h.Values(nombre) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LimiteLectura hace que Leer falle si el cliente pasa mas de 'd' sin
+// enviar nada, ni siquiera un pong. Con 0 no hay limite.
+func (c *Conn) LimiteLectura(d time.Duration) {
+	c.espera = d
+}
+
+// Leer devuelve el siguiente mensaje de texto o binario. Responde los ping
+// por su cuenta y, si el cliente cierra, contesta el cierre y devuelve
+// ErrCerrada.
+func (c *Conn) Leer() (int, []byte, error) {
+	var mensaje []byte
+	op := 0
+	for {
+		fin, opcode, payload, err := c.leerFrame()
+		if err != nil {
+			switch err {
+			case ErrProtocolo:
+				c.Cerrar(CierreProtocolo, "")
+			case ErrMuyGrande:
+				c.Cerrar(CierreMuyGrande, "")
+			default:
+				c.conn.Close()
+			}
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.escribirFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opCierre:
+			codigo := CierreNormal
+			if len(payload) >= 2 {
+				codigo = int(binary.BigEndian.Uint16(payload))
+			}
+			c.Cerrar(codigo, "")
+			return 0, nil, ErrCerrada
+		case opContinuacion:
+			if op == 0 {
+				c.Cerrar(CierreProtocolo, "")
+				return 0, nil, ErrProtocolo
+			}
+		case OpTexto, OpBinario:
+			if op != 0 {
+				c.Cerrar(CierreProtocolo, "")
+				return 0, nil, ErrProtocolo
+			}
+			op = opcode
+		default:
+			c.Cerrar(CierreProtocolo, "")
+			return 0, nil, ErrProtocolo
+		}
+
+		if len(mensaje)+len(payload) > MaxMensaje {
+			c.Cerrar(CierreMuyGrande, "")
+			return 0, nil, ErrMuyGrande
+		}
+		mensaje = append(mensaje, payload...)
+		if fin {
+			return op, mensaje, nil
+		}
+	}
+}
+
+func (c *Conn) leerFrame() (bool, int, []byte, error) {
+	if c.espera > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.espera))
+	}
+
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := h[0]&0x80 != 0
+	opcode := int(h[0] & 0x0f)
+	// sin extensiones los bits RSV deben ser 0, y los frames del cliente
+	// siempre vienen enmascarados
+	if h[0]&0x70 != 0 || h[1]&0x80 == 0 {
+		return false, 0, nil, ErrProtocolo
+	}
+
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= opCierre && (n > 125 || !fin) {
+		return false, 0, nil, ErrProtocolo
+	}
+	if n > MaxMensaje {
+		return false, 0, nil, ErrMuyGrande
+	}
+
+	var mascara [4]byte
+	if _, err := io.ReadFull(c.br, mascara[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mascara[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// EscribirTexto envia 'mensaje' en un solo frame de texto
+func (c *Conn) EscribirTexto(mensaje []byte) error {
+	return c.escribirFrame(OpTexto, mensaje)
+}
+
+// Ping envia un ping; el cliente responde con un pong que renueva el
+// LimiteLectura
+func (c *Conn) Ping() error {
+	return c.escribirFrame(opPing, nil)
+}
+
+// Cerrar envia el frame de cierre y cierra la conexion. Se puede llamar
+// mas de una vez.
+func (c *Conn) Cerrar(codigo int, motivo string) error {
+	if len(motivo) > 123 {
+		motivo = motivo[:123]
+	}
+	payload := make([]byte, 2, 2+len(motivo))
+	binary.BigEndian.PutUint16(payload, uint16(codigo))
+	payload = append(payload, motivo...)
+
+	err := c.escribirFrame(opCierre, payload)
+	c.mu.Lock()
+	c.cerrada = true
+	c.mu.Unlock()
+	c.conn.Close()
+	if err == ErrCerrada {
+		return nil
+	}
+	return err
+}
+
+func (c *Conn) escribirFrame(opcode int, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cerrada {
+		return ErrCerrada
+	}
+
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, 0x80|byte(opcode))
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, 127)
+		frame = append(frame, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+	frame = append(frame, payload...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(tiempoEscritura))
+	_, err := c.conn.Write(frame)
+	return err
+}
@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// clientePrueba es un cliente minimo que enmascara sus frames como exige
+// el RFC
+type clientePrueba struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func conectar(t *testing.T, url string) *clientePrueba {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatalf("No se pudo conectar %s", err)
+	}
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: vaula\r\n"+
+		"Connection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("Respuesta del handshake invalida %s", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Se esperaba 101. Se obtuvo %d", resp.StatusCode)
+	}
+	if acc := resp.Header.Get("Sec-WebSocket-Accept"); acc != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept incorrecto %q", acc)
+	}
+	return &clientePrueba{conn: conn, br: br}
+}
+
+func (c *clientePrueba) enviar(opcode byte, payload []byte, enmascarar bool) {
+	frame := []byte{0x80 | opcode, byte(len(payload))}
+	if !enmascarar {
+		c.conn.Write(append(frame, payload...))
+		return
+	}
+	mascara := []byte{1, 2, 3, 4}
+	frame[1] |= 0x80
+	frame = append(frame, mascara...)
+	for i, b := range payload {
+		frame = append(frame, b^mascara[i%4])
+	}
+	c.conn.Write(frame)
+}
+
+func (c *clientePrueba) recibir(t *testing.T) (byte, []byte) {
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		t.Fatalf("No se pudo leer el frame %s", err)
+	}
+	n := int(h[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	io.ReadFull(c.br, payload)
+	return h[0] & 0x0f, payload
+}
+
+// servidorEco responde cada mensaje de texto con el mismo texto
+func servidorEco(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		for {
+			_, mensaje, err := conn.Leer()
+			if err != nil {
+				return
+			}
+			conn.EscribirTexto(mensaje)
+		}
+	}))
+}
+
+func TestEcoYCierre(t *testing.T) {
+	srv := servidorEco(t)
+	defer srv.Close()
+	c := conectar(t, srv.URL)
+	defer c.conn.Close()
+
+	c.enviar(OpTexto, []byte("hola"), true)
+	if op, payload := c.recibir(t); op != OpTexto || string(payload) != "hola" {
+		t.Errorf("Se esperaba el eco 'hola'. Se obtuvo %d %q", op, payload)
+	}
+
+	largo := []byte(strings.Repeat("x", 300))
+	frame := []byte{0x80 | OpTexto, 0x80 | 126, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(frame[2:], uint16(len(largo)))
+	c.conn.Write(append(frame, largo...)) // mascara 0, el payload no cambia
+	if _, payload := c.recibir(t); len(payload) != len(largo) {
+		t.Errorf("Se esperaban %d bytes. Se obtuvo %d", len(largo), len(payload))
+	}
+
+	c.enviar(opPing, []byte("p"), true)
+	if op, payload := c.recibir(t); op != opPong || string(payload) != "p" {
+		t.Errorf("Se esperaba un pong. Se obtuvo %d %q", op, payload)
+	}
+
+	c.enviar(opCierre, []byte{0x03, 0xe8}, true)
+	op, payload := c.recibir(t)
+	if op != opCierre || binary.BigEndian.Uint16(payload) != CierreNormal {
+		t.Errorf("Se esperaba el cierre 1000. Se obtuvo %d %v", op, payload)
+	}
+}
+
+func TestFrameSinMascara(t *testing.T) {
+	srv := servidorEco(t)
+	defer srv.Close()
+	c := conectar(t, srv.URL)
+	defer c.conn.Close()
+
+	c.enviar(OpTexto, []byte("hola"), false)
+	op, payload := c.recibir(t)
+	if op != opCierre || binary.BigEndian.Uint16(payload) != CierreProtocolo {
+		t.Errorf("Se esperaba el cierre 1002. Se obtuvo %d %v", op, payload)
+	}
+}
+
+func TestUpgradeSinCabeceras(t *testing.T) {
+	srv := servidorEco(t)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Error inesperado %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Se esperaba 400. Se obtuvo %d", resp.StatusCode)
+	}
+}